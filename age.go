@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"interfaces/m/v2/internal/flagtypes"
+)
+
+// ageBucketUnknown is the bucket of the interfaces without attach time.
+const ageBucketUnknown = "unknown"
+
+// ageBucket is the number of interfaces of a group attached for an age range.
+type ageBucket struct {
+	Label string
+	Count int
+}
+
+// parseAgeBuckets parses the value of -age-buckets.
+//
+// value: The comma separated upper bounds of the buckets, e.g. 7d,30d,90d,365d.
+// []time.Duration: The bounds, in increasing order.
+// error: If a bound does not parse, is not positive or the bounds are not increasing.
+func parseAgeBuckets(value string) ([]time.Duration, error) {
+	bounds := []time.Duration{}
+	for _, part := range strings.Split(value, ",") {
+		bound, err := flagtypes.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if bound <= 0 {
+			return nil, fmt.Errorf("%s is not a positive duration", part)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("the bounds must be increasing, %s is not above %s", part, flagtypes.FormatDuration(bounds[len(bounds)-1]))
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// getAgeHistogram counts the interfaces of a group per age bucket, the age being the time since attachment.
+//
+// result: The network interfaces of the group.
+// bounds: The upper bounds of the buckets, in increasing order.
+// now: The time the ages are measured at.
+// []ageBucket: A bucket below every bound, one above the last and the unknown bucket for the interfaces
+// without attach time.
+func getAgeHistogram(result groupResult, bounds []time.Duration, now time.Time) []ageBucket {
+	buckets := []ageBucket{}
+	for i, bound := range bounds {
+		label := "<" + flagtypes.FormatDuration(bound)
+		if i > 0 {
+			label = flagtypes.FormatDuration(bounds[i-1]) + "-" + flagtypes.FormatDuration(bound)
+		}
+		buckets = append(buckets, ageBucket{Label: label})
+	}
+	buckets = append(buckets, ageBucket{Label: ">" + flagtypes.FormatDuration(bounds[len(bounds)-1])}, ageBucket{Label: ageBucketUnknown})
+
+	for _, networkInterface := range result.NetworkInterfaces {
+		if networkInterface.Attachment == nil || networkInterface.Attachment.AttachTime == nil {
+			buckets[len(buckets)-1].Count++
+			continue
+		}
+		age := now.Sub(*networkInterface.Attachment.AttachTime)
+		buckets[sort.Search(len(bounds), func(i int) bool { return age < bounds[i] })].Count++
+	}
+	return buckets
+}
+
+// ageHistogramMap returns the counts of the buckets keyed by label, for JSON.
+//
+// buckets: The buckets, nil without -age-histogram.
+// map[string]int: The counts, nil without buckets.
+func ageHistogramMap(buckets []ageBucket) map[string]int {
+	if buckets == nil {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, bucket := range buckets {
+		counts[bucket.Label] = bucket.Count
+	}
+	return counts
+}
+
+// printAgeHistogram prints the age buckets of a group, with a bar per bucket on a terminal.
+//
+// w: The writer to print to.
+// buckets: The buckets, nil without -age-histogram.
+func printAgeHistogram(w io.Writer, buckets []ageBucket) {
+	if buckets == nil {
+		return
+	}
+	if ew, ok := w.(*errWriter); ok {
+		w = ew.w
+	}
+	if f, ok := w.(*os.File); ok && isTerminal(f) {
+		largest := 0
+		for _, bucket := range buckets {
+			largest = max(largest, bucket.Count)
+		}
+		fmt.Fprintf(w, "Age:\n")
+		for _, bucket := range buckets {
+			bar := ""
+			if largest > 0 {
+				bar = strings.Repeat("█", (bucket.Count*20+largest-1)/largest)
+			}
+			fmt.Fprintf(w, "  %-12s %-20s %d\n", bucket.Label, bar, bucket.Count)
+		}
+		return
+	}
+	parts := []string{}
+	for _, bucket := range buckets {
+		parts = append(parts, fmt.Sprintf("%s: %d", bucket.Label, bucket.Count))
+	}
+	fmt.Fprintf(w, "Age: %s\n", strings.Join(parts, ", "))
+}
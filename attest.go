@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// attestationVersion is the version of the attestation document.
+const attestationVersion = 1
+
+// attestation is the evidence that a sweep ran, written with -attest even when nothing was found.
+type attestation struct {
+	Version      int              `json:"version"`
+	ToolVersion  string           `json:"tool_version"`
+	Query        attestationQuery `json:"query"`
+	Account      string           `json:"account"`
+	Regions      []string         `json:"regions"`
+	StartedAt    time.Time        `json:"started_at"`
+	CompletedAt  time.Time        `json:"completed_at"`
+	Counts       attestationCount `json:"counts"`
+	ReportFormat string           `json:"report_format"`
+	// ReportSha256 is the SHA-256 of the report exactly as written to stdout.
+	ReportSha256 string `json:"report_sha256"`
+	// Hmac is the HMAC-SHA256 of the attestation with an empty Hmac, keyed with -attest-key-file.
+	Hmac string `json:"hmac_sha256,omitempty"`
+}
+
+// attestationQuery is what was looked up.
+type attestationQuery struct {
+	SecurityGroupNames []string `json:"security_group_names"`
+	MatchOn            string   `json:"match_on"`
+}
+
+// attestationCount is what the sweep found.
+type attestationCount struct {
+	Groups            int `json:"groups"`
+	NetworkInterfaces int `json:"network_interfaces"`
+	FailedRegions     int `json:"failed_regions"`
+}
+
+// newAttestation describes a run and the report it produced.
+//
+// query: What was looked up.
+// account: The account of the security groups.
+// regions: The regions scanned.
+// run: What the run found.
+// startedAt: When the run started.
+// report: The report exactly as written to stdout.
+// format: The output format of the report.
+// attestation: The attestation, without HMAC.
+func newAttestation(query attestationQuery, account string, regions []string, run scanResult, startedAt time.Time, report []byte, format string) attestation {
+	count := attestationCount{Groups: len(run.Results), FailedRegions: len(run.FailedRegions)}
+	for _, result := range run.Results {
+		count.NetworkInterfaces += len(result.NetworkInterfaces)
+	}
+	hash := sha256.Sum256(report)
+	return attestation{
+		Version:      attestationVersion,
+		ToolVersion:  programVersion(),
+		Query:        query,
+		Account:      account,
+		Regions:      regions,
+		StartedAt:    startedAt.UTC(),
+		CompletedAt:  time.Now().UTC(),
+		Counts:       count,
+		ReportFormat: format,
+		ReportSha256: hex.EncodeToString(hash[:]),
+	}
+}
+
+// sign computes the HMAC of the attestation.
+//
+// key: The HMAC key.
+// string: The hex encoded HMAC-SHA256 of the JSON form of the attestation with an empty Hmac.
+// error: If the attestation cannot be encoded.
+func (a attestation) sign(key []byte) (string, error) {
+	a.Hmac = ""
+	data, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// loadAttestKey reads the HMAC key of the attestations.
+//
+// path: The key file, empty when the attestations are not signed.
+// []byte: The key with the surrounding whitespace trimmed, nil without a file.
+// error: If the file cannot be read or is empty.
+func loadAttestKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return key, nil
+}
+
+// writeAttestation signs the attestation when a key is given and writes it.
+//
+// path: The attestation file.
+// a: The attestation.
+// key: The HMAC key. May be nil.
+// error: If the file cannot be written.
+func writeAttestation(path string, a attestation, key []byte) error {
+	if key != nil {
+		signature, err := a.sign(key)
+		if err != nil {
+			return err
+		}
+		a.Hmac = signature
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// runVerifyAttestation checks a stored report against its attestation.
+//
+// The report hash is always checked, the HMAC only when a key is given. Every check prints a PASS, FAIL, WARN or
+// SKIP line. The report hash alone does not prove anything was not modified, as whoever changes the report can
+// change the hash too: an unsigned attestation is flagged with WARN, and fails with requireSignature.
+//
+// w: The writer to print the checks to.
+// attestationPath: The attestation file.
+// reportPath: The stored report.
+// key: The HMAC key. May be nil.
+// requireSignature: Whether the HMAC must be present and checked, set with -require-signature.
+// int: The exit code, exitError when a check fails.
+func runVerifyAttestation(w io.Writer, attestationPath string, reportPath string, key []byte, requireSignature bool) int {
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		fmt.Fprintf(w, "FAIL  %-12s %v\n", "attestation", err)
+		return exitError
+	}
+	var a attestation
+	if err := json.Unmarshal(data, &a); err != nil {
+		fmt.Fprintf(w, "FAIL  %-12s %s is not an attestation: %v\n", "attestation", attestationPath, err)
+		return exitError
+	}
+
+	code := 0
+	report, err := os.ReadFile(reportPath)
+	if err != nil {
+		fmt.Fprintf(w, "FAIL  %-12s %v\n", "report", err)
+		code = exitError
+	} else if hash := sha256.Sum256(report); hex.EncodeToString(hash[:]) != a.ReportSha256 {
+		fmt.Fprintf(w, "FAIL  %-12s SHA-256 %s does not match the attested %s\n", "report", hex.EncodeToString(hash[:]), a.ReportSha256)
+		code = exitError
+	} else {
+		fmt.Fprintf(w, "PASS  %-12s SHA-256 %s, %d groups and %d network interfaces attested\n", "report", a.ReportSha256, a.Counts.Groups, a.Counts.NetworkInterfaces)
+	}
+
+	switch {
+	case a.Hmac == "" && (key != nil || requireSignature):
+		fmt.Fprintf(w, "FAIL  %-12s the attestation is not signed\n", "hmac")
+		code = exitError
+	case a.Hmac == "":
+		fmt.Fprintf(w, "WARN  %-12s the attestation is not signed, pass -require-signature to fail on it\n", "hmac")
+	case key == nil && requireSignature:
+		fmt.Fprintf(w, "FAIL  %-12s the attestation is signed, but -require-signature needs -attest-key-file to check it\n", "hmac")
+		code = exitError
+	case key == nil:
+		fmt.Fprintf(w, "SKIP  %-12s the attestation is signed, pass -attest-key-file to check it\n", "hmac")
+	default:
+		signature, err := a.sign(key)
+		if err != nil || !hmac.Equal([]byte(signature), []byte(a.Hmac)) {
+			fmt.Fprintf(w, "FAIL  %-12s the HMAC does not match, the attestation was modified or signed with another key\n", "hmac")
+			code = exitError
+		} else {
+			fmt.Fprintf(w, "PASS  %-12s HMAC-SHA256 matches\n", "hmac")
+		}
+	}
+	return code
+}
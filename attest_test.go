@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// attestTestFiles writes a report and its attestation, signed when a key is given.
+//
+// t: The test.
+// key: The HMAC key. May be nil.
+// string: The attestation file.
+// string: The report file.
+func attestTestFiles(t *testing.T, key []byte) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	report := []byte(`{"groups":[]}` + "\n")
+	reportPath, attestationPath := filepath.Join(dir, "report.json"), filepath.Join(dir, "attestation.json")
+	if err := os.WriteFile(reportPath, report, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	query := attestationQuery{SecurityGroupNames: []string{"web"}, MatchOn: "name"}
+	a := newAttestation(query, "123456789012", []string{"eu-west-1"}, testRun(false), time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), report, "json")
+	if err := writeAttestation(attestationPath, a, key); err != nil {
+		t.Fatal(err)
+	}
+	return attestationPath, reportPath
+}
+
+// tamper rewrites a JSON file.
+//
+// t: The test.
+// path: The file.
+// change: Changes the decoded document.
+func tamper(t *testing.T, path string, change func(document map[string]any)) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := map[string]any{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+	change(document)
+	if data, err = json.Marshal(document); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewAttestation(t *testing.T) {
+	attestationPath, _ := attestTestFiles(t, nil)
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a attestation
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	want := attestationCount{Groups: 3, NetworkInterfaces: 6}
+	if a.Counts != want || a.Hmac != "" || a.Version != attestationVersion || a.ReportFormat != "json" {
+		t.Errorf("attestation = %+v", a)
+	}
+	if a.ReportSha256 != "9f7ee9af3b6ad9e558e0a4ea51eba331d78e719b0246550529e656be79976fde" {
+		t.Errorf("report SHA-256 = %s", a.ReportSha256)
+	}
+}
+
+func TestVerifyAttestation(t *testing.T) {
+	key, otherKey := []byte("secret"), []byte("other")
+	for _, tt := range []struct {
+		name string
+		// signWith is the key the attestation is signed with, nil for an unsigned attestation.
+		signWith []byte
+		// change tampers with the files.
+		change           func(t *testing.T, attestationPath string, reportPath string)
+		key              []byte
+		requireSignature bool
+		wantCode         int
+		// want are the prefixes of the lines printed, with single spaces.
+		want []string
+	}{
+		{name: "signed", signWith: key, key: key, want: []string{"PASS report", "PASS hmac"}},
+		{name: "signed required", signWith: key, key: key, requireSignature: true, want: []string{"PASS report", "PASS hmac"}},
+		{name: "signed without key", signWith: key, want: []string{"PASS report", "SKIP hmac"}},
+		{name: "signed without key required", signWith: key, requireSignature: true, wantCode: exitError, want: []string{"PASS report", "FAIL hmac"}},
+		{name: "unsigned", want: []string{"PASS report", "WARN hmac"}},
+		{name: "unsigned required", requireSignature: true, wantCode: exitError, want: []string{"PASS report", "FAIL hmac"}},
+		{name: "unsigned with key", key: key, wantCode: exitError, want: []string{"PASS report", "FAIL hmac the attestation is not signed"}},
+		{name: "other key", signWith: key, key: otherKey, wantCode: exitError, want: []string{"PASS report", "FAIL hmac the HMAC does not match"}},
+		{
+			name: "report modified", signWith: key, key: key, wantCode: exitError,
+			change: func(t *testing.T, _ string, reportPath string) {
+				if err := os.WriteFile(reportPath, []byte(`{"groups":[{}]}`+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"FAIL report SHA-256", "PASS hmac"},
+		},
+		{
+			name: "counts modified", signWith: key, key: key, wantCode: exitError,
+			change: func(t *testing.T, attestationPath string, _ string) {
+				tamper(t, attestationPath, func(document map[string]any) {
+					document["counts"].(map[string]any)["network_interfaces"] = 0
+				})
+			},
+			want: []string{"PASS report", "FAIL hmac the HMAC does not match"},
+		},
+		{
+			name: "hash rewritten", signWith: key, key: key, wantCode: exitError,
+			change: func(t *testing.T, attestationPath string, reportPath string) {
+				if err := os.WriteFile(reportPath, []byte("{}\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				tamper(t, attestationPath, func(document map[string]any) {
+					document["report_sha256"] = "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356"
+				})
+			},
+			want: []string{"PASS report", "FAIL hmac the HMAC does not match"},
+		},
+		{
+			name: "hmac stripped", signWith: key, wantCode: exitError, requireSignature: true,
+			change: func(t *testing.T, attestationPath string, _ string) {
+				tamper(t, attestationPath, func(document map[string]any) { delete(document, "hmac_sha256") })
+			},
+			want: []string{"PASS report", "FAIL hmac the attestation is not signed"},
+		},
+		{
+			name: "hmac stripped with key", signWith: key, key: key, wantCode: exitError,
+			change: func(t *testing.T, attestationPath string, _ string) {
+				tamper(t, attestationPath, func(document map[string]any) { delete(document, "hmac_sha256") })
+			},
+			want: []string{"PASS report", "FAIL hmac the attestation is not signed"},
+		},
+		{
+			name: "not an attestation", wantCode: exitError,
+			change: func(t *testing.T, attestationPath string, _ string) {
+				if err := os.WriteFile(attestationPath, []byte("[]"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"FAIL attestation"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			attestationPath, reportPath := attestTestFiles(t, tt.signWith)
+			if tt.change != nil {
+				tt.change(t, attestationPath, reportPath)
+			}
+			var out strings.Builder
+			code := runVerifyAttestation(&out, attestationPath, reportPath, tt.key, tt.requireSignature)
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if code != tt.wantCode || len(lines) != len(tt.want) {
+				t.Fatalf("exit %d, want %d:\n%s", code, tt.wantCode, out.String())
+			}
+			for i, want := range tt.want {
+				// The checks are printed in aligned columns
+				if line := strings.Join(strings.Fields(lines[i]), " "); !strings.HasPrefix(line, want) {
+					t.Errorf("line %d = %q, want prefix %q", i+1, lines[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// zonalSingletonInterfaceTypes are the interface types that live in a single availability zone by design.
+// They are left out of the skew calculation.
+var zonalSingletonInterfaceTypes = map[types.NetworkInterfaceType]bool{
+	types.NetworkInterfaceTypeNatGateway: true,
+}
+
+// zoneBalanceReport is the JSON document describing the availability zone distribution of every security group.
+type zoneBalanceReport struct {
+	ZoneBalances  []zoneBalance   `json:"az_balance"`
+	FailedRegions []regionFailure `json:"failed_regions"`
+}
+
+// zoneBalance describes how the interfaces of a security group are spread across availability zones.
+type zoneBalance struct {
+	selector             enilookup.Selector
+	Region               string      `json:"region"`
+	SecurityGroupName    string      `json:"security_group_name,omitempty"`
+	SecurityGroupId      string      `json:"security_group_id,omitempty"`
+	Zones                []zoneCount `json:"zones"`
+	ExcludedInterfaces   int         `json:"excluded_interfaces"`
+	ExcludedShared       int         `json:"excluded_shared_interfaces"`
+	DominantZone         string      `json:"dominant_zone,omitempty"`
+	DominantSharePercent float64     `json:"dominant_share_percent"`
+	ThresholdPercent     float64     `json:"threshold_percent"`
+	Skewed               bool        `json:"skewed"`
+}
+
+// zoneCount holds the interface counts of a single availability zone.
+type zoneCount struct {
+	AvailabilityZone string  `json:"availability_zone"`
+	Interfaces       int     `json:"interfaces"`
+	InUse            int     `json:"in_use"`
+	SharePercent     float64 `json:"share_percent"`
+}
+
+// getZoneBalances computes the availability zone distribution of every result.
+//
+// results: The network interfaces found per security group.
+// thresholdPercent: The share of in-use interfaces in a single zone above which a group is skewed.
+// excludeShared: Whether shared service ENIs are left out of the skew calculation.
+// []zoneBalance: The distribution per security group, in the order of the results.
+func getZoneBalances(results []groupResult, thresholdPercent float64, excludeShared bool) []zoneBalance {
+	zoneBalances := []zoneBalance{}
+	for _, result := range results {
+		zoneBalances = append(zoneBalances, getZoneBalance(result, thresholdPercent, excludeShared))
+	}
+	return zoneBalances
+}
+
+// getZoneBalance computes the availability zone distribution of a single result.
+//
+// result: The network interfaces of a security group.
+// thresholdPercent: The share of in-use interfaces in a single zone above which the group is skewed.
+// excludeShared: Whether shared service ENIs are left out of the skew calculation.
+// zoneBalance: The distribution.
+func getZoneBalance(result groupResult, thresholdPercent float64, excludeShared bool) zoneBalance {
+	balance := zoneBalance{
+		selector:          result.Selector,
+		Region:            result.Region,
+		SecurityGroupName: result.Selector.GroupName,
+		SecurityGroupId:   result.Selector.GroupId,
+		Zones:             []zoneCount{},
+		ThresholdPercent:  thresholdPercent,
+	}
+
+	// Count the interfaces per zone
+	counts := map[string]*zoneCount{}
+	inUse := 0
+	for _, networkInterface := range result.NetworkInterfaces {
+		if zonalSingletonInterfaceTypes[networkInterface.InterfaceType] {
+			balance.ExcludedInterfaces++
+			continue
+		}
+		if excludeShared && enilookup.IsShared(networkInterface) {
+			balance.ExcludedShared++
+			continue
+		}
+		zone := aws.ToString(networkInterface.AvailabilityZone)
+		count, ok := counts[zone]
+		if !ok {
+			count = &zoneCount{AvailabilityZone: zone}
+			counts[zone] = count
+		}
+		count.Interfaces++
+		if networkInterface.Status == types.NetworkInterfaceStatusInUse {
+			count.InUse++
+			inUse++
+		}
+	}
+
+	// Compute the share of in-use interfaces of every zone
+	for _, count := range counts {
+		if inUse > 0 {
+			count.SharePercent = roundPercent(float64(count.InUse) * 100 / float64(inUse))
+		}
+		balance.Zones = append(balance.Zones, *count)
+	}
+	sort.Slice(balance.Zones, func(i, j int) bool {
+		return balance.Zones[i].AvailabilityZone < balance.Zones[j].AvailabilityZone
+	})
+
+	// Find the dominant zone
+	for _, zone := range balance.Zones {
+		if inUse > 0 && zone.SharePercent > balance.DominantSharePercent {
+			balance.DominantZone = zone.AvailabilityZone
+			balance.DominantSharePercent = zone.SharePercent
+		}
+	}
+	balance.Skewed = balance.DominantSharePercent > thresholdPercent
+
+	return balance
+}
+
+// printZoneBalances prints the availability zone distribution of every security group.
+//
+// w: The writer to print to.
+// zoneBalances: The distributions to print.
+// showRegion: Whether to print the region of every security group.
+func printZoneBalances(w io.Writer, zoneBalances []zoneBalance, showRegion bool) {
+	for _, balance := range zoneBalances {
+		printGroupHeader(w, balance.selector, balance.Region, showRegion)
+		for _, zone := range balance.Zones {
+			fmt.Fprintf(w, "  %s: %d interfaces, %d in use (%.1f%%)\n", zone.AvailabilityZone, zone.Interfaces, zone.InUse, zone.SharePercent)
+		}
+		if balance.ExcludedInterfaces > 0 {
+			fmt.Fprintf(w, "  Excluded from the skew calculation: %d NAT gateway interfaces\n", balance.ExcludedInterfaces)
+		}
+		if balance.ExcludedShared > 0 {
+			fmt.Fprintf(w, "  Excluded from the skew calculation: %d shared service ENIs\n", balance.ExcludedShared)
+		}
+		if balance.Skewed {
+			fmt.Fprintf(w, "  SKEWED: %s holds %.1f%% of the in-use interfaces (threshold %.1f%%)\n", balance.DominantZone, balance.DominantSharePercent, balance.ThresholdPercent)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "board", description: "a line per security group with its changes, redrawn in place with -watch on a terminal", contentType: "text/plain", extension: ".txt", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		printBoard(w, opts.Board.update(run.Results), opts.Board.inPlace, run.ScannedAt)
+		printRegionFailures(w, run.FailedRegions)
+		// The board has no room for the notes, they go to stderr
+		printRunNotes(os.Stderr, run, opts)
+		return nil
+	}})
+	registerRenderer(formatRenderer{name: "board-json", description: "the board rows as JSON", contentType: "application/json", extension: ".json", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if err := writeJSON(w, boardReport{Rows: opts.Board.update(run.Results), FailedRegions: run.FailedRegions}); err != nil {
+			return err
+		}
+		printRunNotes(os.Stderr, run, opts)
+		return nil
+	}})
+}
+
+// Board change indicators, comparing a group with the previous iteration.
+const (
+	boardNew       = "new"
+	boardUnchanged = "unchanged"
+	boardChanged   = "changed"
+	boardEmptied   = "emptied"
+	boardPublic    = "public+"
+)
+
+// ANSI colors of the board change indicators.
+const (
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+	colorReset  = "\033[0m"
+)
+
+// boardRow is the one-line summary of a security group.
+type boardRow struct {
+	Region    string `json:"region"`
+	Group     string `json:"group"`
+	InUse     int    `json:"in_use"`
+	Available int    `json:"available"`
+	Public    int    `json:"public"`
+	Change    string `json:"change"`
+}
+
+// boardReport is the JSON document of the board-json output.
+type boardReport struct {
+	Rows          []boardRow      `json:"rows"`
+	FailedRegions []regionFailure `json:"failed_regions"`
+}
+
+// boardState keeps the rows of the previous iteration so changes can be indicated under -watch.
+type boardState struct {
+	inPlace  bool
+	previous map[string]boardRow
+}
+
+// newBoardState creates the state of a board.
+//
+// inPlace: Whether the board is redrawn in place on a terminal.
+// *boardState: The board state.
+func newBoardState(inPlace bool) *boardState {
+	return &boardState{inPlace: inPlace}
+}
+
+// update builds the rows of the results and indicates the changes since the previous call.
+//
+// A group is emptied when its interfaces are all gone and public+ when its public interface count grew,
+// which take precedence over a plain change of counts.
+//
+// results: The network interfaces found per security group.
+// []boardRow: The rows, in the order of the results.
+func (b *boardState) update(results []groupResult) []boardRow {
+	rows := []boardRow{}
+	current := map[string]boardRow{}
+	for _, result := range results {
+		row := boardRow{Region: result.Region, Group: result.Selector.Key()}
+		for _, networkInterface := range result.NetworkInterfaces {
+			switch networkInterface.Status {
+			case types.NetworkInterfaceStatusInUse:
+				row.InUse++
+			case types.NetworkInterfaceStatusAvailable:
+				row.Available++
+			}
+			if isPublic(networkInterface) {
+				row.Public++
+			}
+		}
+
+		key := row.Region + "/" + row.Group
+		previous, seen := b.previous[key]
+		total := row.InUse + row.Available
+		switch {
+		case b.previous == nil || !seen:
+			row.Change = boardNew
+		case total == 0 && previous.InUse+previous.Available > 0:
+			row.Change = boardEmptied
+		case row.Public > previous.Public:
+			row.Change = boardPublic
+		case row.InUse != previous.InUse || row.Available != previous.Available || row.Public != previous.Public:
+			row.Change = boardChanged
+		default:
+			row.Change = boardUnchanged
+		}
+
+		current[key] = row
+		rows = append(rows, row)
+	}
+	b.previous = current
+	return rows
+}
+
+// printBoard prints one line per security group.
+//
+// w: The writer to print to.
+// rows: The rows to print.
+// inPlace: Whether to redraw the board in place and color the change indicators.
+// at: When the run started, printed in the header. Nil leaves the header without a timestamp.
+func printBoard(w io.Writer, rows []boardRow, inPlace bool, at *time.Time) {
+	if inPlace {
+		// Move to the top left corner and clear the screen
+		fmt.Fprint(w, "\033[H\033[2J")
+	}
+	timestamp := ""
+	if at != nil {
+		timestamp = formatTimestamp(*at)
+	}
+	fmt.Fprintf(w, "%-40s %-15s %6s %9s %6s  %s\n", "GROUP", "REGION", "IN-USE", "AVAILABLE", "PUBLIC", timestamp)
+	for _, row := range rows {
+		change := row.Change
+		if inPlace {
+			change = boardColor(row.Change) + change + colorReset
+		}
+		fmt.Fprintf(w, "%-40s %-15s %6d %9d %6d  %s\n", displayText(row.Group), row.Region, row.InUse, row.Available, row.Public, change)
+	}
+	if !inPlace {
+		fmt.Fprintln(w)
+	}
+}
+
+// boardColor returns the color of a change indicator.
+//
+// change: The change indicator.
+// string: The ANSI color.
+func boardColor(change string) string {
+	switch change {
+	case boardEmptied, boardPublic:
+		return colorRed
+	case boardChanged:
+		return colorYellow
+	default:
+		return colorGreen
+	}
+}
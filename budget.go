@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// defaultCallLatency is the latency of an API call assumed by the estimates before any call was timed.
+const defaultCallLatency = 250 * time.Millisecond
+
+// runBudget stops a run once it used up -budget-duration or -budget-api-calls.
+//
+// The budget is checked before the lookup of every security group, so a group already started completes and its
+// lookups are not cut in the middle. The groups completed so far are reported and the run is marked incomplete.
+type runBudget struct {
+	// duration is the maximum duration of the run, 0 for no limit
+	duration time.Duration
+	// apiCalls is the maximum number of API calls of the run, 0 for no limit
+	apiCalls int64
+	// started is when the run started
+	started time.Time
+	// calls is the number of API calls made, counted by the middleware
+	calls atomic.Int64
+	// latency is the total latency of the API calls made, in nanoseconds
+	latency atomic.Int64
+	// exhausted is why the run was stopped, empty while it is within budget
+	exhausted string
+}
+
+// newRunBudget returns the budget of a run.
+//
+// duration: The maximum duration of the run, 0 for no limit.
+// apiCalls: The maximum number of API calls of the run, 0 for no limit.
+// *runBudget: The budget, with its clock started.
+func newRunBudget(duration time.Duration, apiCalls int64) *runBudget {
+	return &runBudget{duration: duration, apiCalls: apiCalls, started: time.Now()}
+}
+
+// apiOptions returns the SDK middleware counting and timing the API calls against the budget.
+//
+// []func(*middleware.Stack) error: The API options to add to the AWS configuration.
+func (b *runBudget) apiOptions() []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("BudgetCallCounted", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				started := time.Now()
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				b.calls.Add(1)
+				b.latency.Add(int64(time.Since(started)))
+				return out, metadata, err
+			}), middleware.After)
+		},
+	}
+}
+
+// check reports whether the run used up its budget, remembering why.
+//
+// string: Why the run must stop, empty while it is within budget. Without a budget, always empty.
+func (b *runBudget) check() string {
+	if b == nil {
+		return ""
+	}
+	if b.exhausted == "" {
+		switch {
+		case b.apiCalls > 0 && b.calls.Load() >= b.apiCalls:
+			b.exhausted = fmt.Sprintf("-budget-api-calls %d exhausted", b.apiCalls)
+		case b.duration > 0 && time.Since(b.started) >= b.duration:
+			b.exhausted = fmt.Sprintf("-budget-duration %s exhausted", b.duration)
+		}
+	}
+	return b.exhausted
+}
+
+// callLatency returns the average latency of the API calls made so far.
+//
+// time.Duration: The average latency, defaultCallLatency before any call was made.
+func (b *runBudget) callLatency() time.Duration {
+	calls := b.calls.Load()
+	if calls == 0 {
+		return defaultCallLatency
+	}
+	return time.Duration(b.latency.Load() / calls)
+}
+
+// printEstimate prints the estimated number of API calls and duration of the lookups of a run to stderr.
+//
+// calls: The estimated number of API calls.
+func (b *runBudget) printEstimate(calls int) {
+	if b == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "estimated %s API calls, ~%s\n", formatCount(calls), formatEstimate(time.Duration(calls)*b.callLatency()))
+}
+
+// formatCount formats a count with a space between groups of three digits, e.g. 4 200.
+//
+// n: The count.
+// string: The formatted count.
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	groups := []string{}
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	return strings.Join(append([]string{digits}, groups...), " ")
+}
+
+// formatEstimate formats an estimated duration in whole minutes, or seconds below a minute.
+//
+// d: The duration.
+// string: The formatted duration, e.g. 18 minutes.
+func formatEstimate(d time.Duration) string {
+	n, unit := int(d.Round(time.Minute).Minutes()), "minute"
+	if d < time.Minute {
+		n, unit = int(d.Round(time.Second).Seconds()), "second"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetAPICalls(t *testing.T) {
+	budget := newRunBudget(0, 3)
+	cfg, calls := lookupEC2Config(t, 10*time.Millisecond, 0, budget.apiOptions())
+	progress, _ := newProgressReporter("none", "")
+
+	run, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(6), progress, scanOptions{Budget: budget})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(run.Results) != 3 || calls.Load() != 3 {
+		t.Errorf("%d groups looked up with %d calls, want 3 groups with the 3 calls of the budget", len(run.Results), calls.Load())
+	}
+	for i, result := range run.Results {
+		if len(result.NetworkInterfaces) != 1 {
+			t.Errorf("group %d of the partial results has %d interfaces", i, len(result.NetworkInterfaces))
+		}
+	}
+	if want := "-budget-api-calls 3 exhausted, 3 of 6 security groups looked up"; run.Incomplete != want {
+		t.Errorf("incomplete %q, want %q", run.Incomplete, want)
+	}
+}
+
+func TestBudgetDuration(t *testing.T) {
+	budget := newRunBudget(150*time.Millisecond, 0)
+	cfg, calls := lookupEC2Config(t, 50*time.Millisecond, 0, budget.apiOptions())
+	progress, _ := newProgressReporter("none", "")
+
+	run, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(20), progress, scanOptions{Budget: budget})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(run.Results) == 0 || len(run.Results) >= 20 || int(calls.Load()) != len(run.Results) {
+		t.Errorf("%d groups looked up with %d calls, want the groups started within the duration", len(run.Results), calls.Load())
+	}
+	if !strings.HasPrefix(run.Incomplete, "-budget-duration 150ms exhausted, ") {
+		t.Errorf("incomplete %q, want the duration exhausted", run.Incomplete)
+	}
+}
+
+func TestBudgetNotExhausted(t *testing.T) {
+	budget := newRunBudget(time.Minute, 10)
+	cfg, _ := lookupEC2Config(t, 0, 0, budget.apiOptions())
+	progress, _ := newProgressReporter("none", "")
+
+	run, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(4), progress, scanOptions{Budget: budget})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(run.Results) != 4 || run.Incomplete != "" {
+		t.Errorf("%d groups looked up, incomplete %q, want every group and a complete run", len(run.Results), run.Incomplete)
+	}
+	if budget.calls.Load() != 4 {
+		t.Errorf("the middleware counted %d calls, want 4", budget.calls.Load())
+	}
+	if budget.callLatency() <= 0 {
+		t.Errorf("call latency %v after timed calls", budget.callLatency())
+	}
+}
+
+func TestBudgetEstimate(t *testing.T) {
+	if got := newRunBudget(0, 1).callLatency(); got != defaultCallLatency {
+		t.Errorf("latency before any call %v, want %v", got, defaultCallLatency)
+	}
+	for n, want := range map[int]string{0: "0", 999: "999", 4200: "4 200", 1234567: "1 234 567"} {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+	for d, want := range map[time.Duration]string{1400 * time.Millisecond: "1 second", 80 * time.Second: "1 minute", 59 * time.Second: "59 seconds", 4200 * defaultCallLatency: "18 minutes"} {
+		if got := formatEstimate(d); got != want {
+			t.Errorf("formatEstimate(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheDirName is the name of the directory, under the user cache directory, holding the on-disk cache.
+const cacheDirName = "get-network-interfaces-by-security-group-names"
+
+// cacheEntry is the envelope written around every value stored in the on-disk cache.
+type cacheEntry struct {
+	StoredAt time.Time       `json:"stored_at"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// cachePath returns the path of the cache file with the given name.
+//
+// name: The name of the cache file.
+// string: The path of the cache file.
+// error: If the user cache directory cannot be determined.
+func cachePath(name string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cacheDirName, name), nil
+}
+
+// readCache reads the value stored under the given name into v.
+//
+// name: The name of the cache file.
+// ttl: The maximum age of the stored value. A ttl of zero disables the cache.
+// v: The value to decode into.
+// time.Time: When the value was stored.
+// bool: Whether a fresh value was found.
+func readCache(name string, ttl time.Duration, v any) (time.Time, bool) {
+	if ttl <= 0 {
+		return time.Time{}, false
+	}
+	path, err := cachePath(name)
+	if err != nil {
+		return time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return time.Time{}, false
+	}
+	if time.Since(entry.StoredAt) > ttl {
+		return time.Time{}, false
+	}
+	if err := json.Unmarshal(entry.Value, v); err != nil {
+		return time.Time{}, false
+	}
+	return entry.StoredAt, true
+}
+
+// writeCache stores the value under the given name.
+//
+// Failing to write the cache is not fatal, the error is returned so the caller can decide whether to report it.
+//
+// name: The name of the cache file.
+// v: The value to store.
+// error: If the value cannot be encoded or written.
+func writeCache(name string, v any) error {
+	path, err := cachePath(name)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cacheEntry{StoredAt: time.Now().UTC(), Value: value})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"interfaces/m/v2/internal/flagtypes"
+)
+
+// capabilitiesVersion is the version of the -capabilities document, incremented when a field changes meaning.
+const capabilitiesVersion = 1
+
+// modes are the subcommands, selected by the first argument.
+var modes = []struct {
+	Name        string
+	Description string
+}{
+	{"wait", "Wait until the security groups have no network interfaces, or at most -wait-until count<=N"},
+	{"selftest", "Check that the credentials allow every API call the lookups make"},
+	{"verify-attestation", "Check a report against the attestation written with -attest"},
+}
+
+// isMode reports whether an argument selects a subcommand.
+//
+// arg: The first command line argument.
+// bool: Whether it is the name of a subcommand.
+func isMode(arg string) bool {
+	for _, mode := range modes {
+		if mode.Name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilities is the -capabilities document, describing what this build supports for wrapper tooling.
+type capabilities struct {
+	CapabilitiesVersion int                `json:"capabilities_version"`
+	Version             string             `json:"version"`
+	Subcommands         []capabilityMode   `json:"subcommands"`
+	Flags               []capabilityFlag   `json:"flags"`
+	OutputFormats       []capabilityFormat `json:"output_formats"`
+	Filters             capabilityFilters  `json:"filters"`
+	Schemas             []capabilitySchema `json:"schemas"`
+}
+
+// capabilityMode is a subcommand.
+type capabilityMode struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// capabilityFlag is a flag, its type being one of bool, int, float, string, duration, count or list.
+type capabilityFlag struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Default    string `json:"default"`
+	Usage      string `json:"usage"`
+	Deprecated bool   `json:"deprecated"`
+	ReplacedBy string `json:"replaced_by,omitempty"`
+}
+
+// capabilityFormat is an output format of -output.
+type capabilityFormat struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ContentType string `json:"content_type"`
+	Extension   string `json:"extension"`
+}
+
+// capabilityFilters are the filters of the network interfaces.
+type capabilityFilters struct {
+	// ClientSide are the flags filtering the interfaces once they are looked up.
+	ClientSide []string `json:"client_side"`
+	// WhereVariables are the variables of a -where expression, with their types.
+	WhereVariables map[string]string `json:"where_variables"`
+	// ValidatedDescribeFilters are the --filters names whose values are checked as identifiers, any other
+	// DescribeNetworkInterfaces filter being passed as is.
+	ValidatedDescribeFilters []string `json:"validated_describe_filters"`
+	// SelectorDescribeFilters are the --filters names selecting security groups like -security-group-names.
+	SelectorDescribeFilters []string `json:"selector_describe_filters"`
+}
+
+// capabilitySchema is a versioned output or document format.
+type capabilitySchema struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// File is the JSON schema of the format in the repository, when there is one.
+	File string `json:"file,omitempty"`
+}
+
+// getCapabilities describes the subcommands, flags, output formats, filters and schemas of this build.
+//
+// Everything is read from the structures that define them, the flag set, the renderer registry and the filter
+// pipeline, so the document follows them.
+//
+// fs: The flag set, with every flag and its deprecated aliases defined.
+// capabilities: The document.
+func getCapabilities(fs *flag.FlagSet) capabilities {
+	c := capabilities{
+		CapabilitiesVersion: capabilitiesVersion,
+		Version:             programVersion(),
+		Subcommands:         []capabilityMode{},
+		Flags:               []capabilityFlag{},
+		OutputFormats:       []capabilityFormat{},
+		Schemas: []capabilitySchema{
+			{Name: "config-items", Version: configItemVersion, File: "schemas/config-items.schema.json"},
+			{Name: "graph-json", File: "schemas/graph-json.schema.json"},
+			{Name: "attestation", Version: strconv.Itoa(attestationVersion)},
+			{Name: "capabilities", Version: strconv.Itoa(capabilitiesVersion)},
+		},
+	}
+	for _, mode := range modes {
+		c.Subcommands = append(c.Subcommands, capabilityMode{Name: mode.Name, Description: mode.Description})
+	}
+
+	// The flags, in name order
+	fs.VisitAll(func(f *flag.Flag) {
+		capability := capabilityFlag{Name: f.Name, Type: flagType(f.Value), Default: f.DefValue, Usage: f.Usage}
+		if alias, ok := f.Value.(*deprecatedFlag); ok {
+			capability.Deprecated = true
+			capability.ReplacedBy = alias.alias.Canonical
+			capability.Default = fs.Lookup(alias.alias.Canonical).DefValue
+		}
+		c.Flags = append(c.Flags, capability)
+	})
+
+	for _, name := range rendererNames() {
+		r := renderers[name]
+		c.OutputFormats = append(c.OutputFormats, capabilityFormat{Name: name, Description: r.Description(), ContentType: r.ContentType(), Extension: r.Extension()})
+	}
+
+	// Build the pipeline with every client-side filter selected to list them
+	pipeline := newFilterPipeline(scanOptions{Status: "-", InstanceId: "-", PublicOnly: true, HasPermissionsOnly: true, NewSince: time.Second, Where: &whereFilter{}}, time.Now())
+	c.Filters.ClientSide = []string{}
+	for _, predicate := range pipeline.predicates {
+		c.Filters.ClientSide = append(c.Filters.ClientSide, predicate.Name)
+	}
+	c.Filters.WhereVariables = map[string]string{}
+	env := reflect.TypeOf(whereEnv{})
+	for i := 0; i < env.NumField(); i++ {
+		c.Filters.WhereVariables[env.Field(i).Tag.Get("expr")] = env.Field(i).Type.String()
+	}
+	c.Filters.ValidatedDescribeFilters = []string{}
+	for name := range filterIdentifierClasses {
+		c.Filters.ValidatedDescribeFilters = append(c.Filters.ValidatedDescribeFilters, name)
+	}
+	slices.Sort(c.Filters.ValidatedDescribeFilters)
+	c.Filters.SelectorDescribeFilters = groupFilterNames
+	return c
+}
+
+// flagType returns the type of the value of a flag, as listed by -capabilities.
+//
+// value: The flag value.
+// string: The type.
+func flagType(value flag.Value) string {
+	if alias, ok := value.(*deprecatedFlag); ok {
+		return flagType(alias.target)
+	}
+	if boolFlag, ok := value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+		return "bool"
+	}
+	switch value.(type) {
+	case *flagtypes.Duration:
+		return "duration"
+	case *flagtypes.Count:
+		return "count"
+	case *SecurityGroupNames, *rollupTags, *cliFilters:
+		return "list"
+	}
+	if getter, ok := value.(flag.Getter); ok {
+		switch getter.Get().(type) {
+		case int, int64, uint, uint64:
+			return "int"
+		case float64:
+			return "float"
+		case time.Duration:
+			return "duration"
+		}
+	}
+	return "string"
+}
+
+// printCapabilities prints the -capabilities document as JSON.
+//
+// w: The writer to print to.
+// fs: The flag set.
+// error: If the document cannot be written.
+func printCapabilities(w io.Writer, fs *flag.FlagSet) error {
+	return writeJSON(w, getCapabilities(fs))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"interfaces/m/v2/internal/flagtypes"
+)
+
+// TestCapabilitiesListEveryFlag builds the tool and checks that -capabilities lists every flag of -h, and every
+// deprecated name, so the document cannot drift from the flag registration.
+func TestCapabilitiesListEveryFlag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the tool")
+	}
+	binary, _ := buildTool(t, "")
+	out, err := exec.Command(binary, "-capabilities").Output()
+	if err != nil {
+		t.Fatalf("-capabilities: %v", err)
+	}
+	var c capabilities
+	if err := json.Unmarshal(out, &c); err != nil {
+		t.Fatalf("-capabilities is not JSON: %v\n%s", err, out)
+	}
+	listed := map[string]capabilityFlag{}
+	for _, f := range c.Flags {
+		listed[f.Name] = f
+	}
+
+	// -h only shows the canonical names
+	usage, _ := exec.Command(binary, "-h").CombinedOutput()
+	registered := []string{}
+	for _, match := range regexp.MustCompile(`(?m)^  -([a-z0-9-]+)`).FindAllStringSubmatch(string(usage), -1) {
+		registered = append(registered, match[1])
+	}
+	if len(registered) < 50 {
+		t.Fatalf("only %d flags in -h, the check below would prove little:\n%s", len(registered), usage)
+	}
+	for _, name := range registered {
+		if f, ok := listed[name]; !ok {
+			t.Errorf("-%s is missing from -capabilities", name)
+		} else if f.Deprecated {
+			t.Errorf("-%s is listed as deprecated", name)
+		}
+	}
+	for _, alias := range flagAliases {
+		if f := listed[alias.Deprecated]; !f.Deprecated || f.ReplacedBy != alias.Canonical {
+			t.Errorf("deprecated -%s listed as %+v, want replaced by -%s", alias.Deprecated, f, alias.Canonical)
+		}
+	}
+	if len(c.Flags) != len(registered)+len(flagAliases) {
+		t.Errorf("%d flags in -capabilities, want the %d of -h and %d deprecated names", len(c.Flags), len(registered), len(flagAliases))
+	}
+
+	formats := []string{}
+	for _, format := range c.OutputFormats {
+		formats = append(formats, format.Name)
+	}
+	if !slices.Equal(formats, rendererNames()) {
+		t.Errorf("output formats %v, want the renderers %v", formats, rendererNames())
+	}
+	modeNames := []string{}
+	for _, mode := range c.Subcommands {
+		modeNames = append(modeNames, mode.Name)
+	}
+	if !slices.Equal(modeNames, []string{"wait", "selftest", "verify-attestation"}) {
+		t.Errorf("subcommands %v", modeNames)
+	}
+}
+
+func TestCapabilitiesFlagTypes(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("quiet", false, "")
+	fs.Int("workers", 4, "")
+	fs.Float64("min-confidence", 0.5, "")
+	fs.String("output", "text", "")
+	fs.Duration("gap", time.Second, "")
+	flagtypes.NewDuration(fs, "timeout", time.Minute, "")
+	flagtypes.NewCount(fs, "max-results", 0, "")
+	fs.Var(&SecurityGroupNames{}, "security-group-names", "")
+	registerFlagAliases(fs, []flagAlias{{Deprecated: "silent", Canonical: "quiet"}, {Deprecated: "limit", Canonical: "max-results"}})
+
+	want := map[string]capabilityFlag{
+		"gap":                  {Type: "duration", Default: "1s"},
+		"limit":                {Type: "count", Default: "0", Deprecated: true, ReplacedBy: "max-results"},
+		"max-results":          {Type: "count", Default: "0"},
+		"min-confidence":       {Type: "float", Default: "0.5"},
+		"output":               {Type: "string", Default: "text"},
+		"quiet":                {Type: "bool", Default: "false"},
+		"security-group-names": {Type: "list", Default: ""},
+		"silent":               {Type: "bool", Default: "false", Deprecated: true, ReplacedBy: "quiet"},
+		"timeout":              {Type: "duration", Default: "1m0s"},
+		"workers":              {Type: "int", Default: "4"},
+	}
+	c := getCapabilities(fs)
+	if len(c.Flags) != len(want) {
+		t.Errorf("%d flags, want %d", len(c.Flags), len(want))
+	}
+	for _, f := range c.Flags {
+		w := want[f.Name]
+		if f.Type != w.Type || f.Default != w.Default || f.Deprecated != w.Deprecated || f.ReplacedBy != w.ReplacedBy {
+			t.Errorf("-%s: %+v, want %+v", f.Name, f, w)
+		}
+	}
+}
+
+// TestOutputHelp builds the tool and checks that -output help lists every renderer and exits 0 without AWS.
+func TestOutputHelp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the tool")
+	}
+	binary, _ := buildTool(t, "")
+	cmd := exec.Command(binary, "-output", "help")
+	cmd.Env = append(os.Environ(), "AWS_REGION=", "AWS_PROFILE=interfaces-output-help-missing")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("-output help: %v\n%s", err, out)
+	}
+	var want strings.Builder
+	if err := printOutputFormats(&want); err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != want.String() || !strings.Contains(string(out), "\njson: the text report as a JSON document\n") {
+		t.Errorf("-output help printed:\n%s\nwant:\n%s", out, want.String())
+	}
+}
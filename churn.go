@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// churnSnapshotTTL is the maximum age of the snapshots read by -churn, they do not expire with -cache-ttl.
+const churnSnapshotTTL = time.Duration(math.MaxInt64)
+
+// groupChurn is how much the network interfaces of a security group changed since the previous snapshot.
+type groupChurn struct {
+	NoPreviousSnapshot bool       `json:"no_previous_snapshot"`
+	PreviousAt         *time.Time `json:"previous_at,omitempty"`
+	Added              int        `json:"added"`
+	Removed            int        `json:"removed"`
+	Percent            float64    `json:"percent"`
+}
+
+// getChurn compares every result with the previous snapshot of the same query and stores the new snapshot.
+//
+// The snapshots are kept in the on-disk cache per account, region and security group. The churn percentage
+// is the number of added and removed interfaces over the number of interfaces in either snapshot. The groups whose
+// interfaces were not listed, denied or failed, keep their previous snapshot and get no churn.
+//
+// results: The network interfaces found per security group, updated with their churn.
+// accountId: The account the security groups belong to.
+func getChurn(results []groupResult, accountId string) {
+	for i, result := range results {
+		if groupStatus(result) != groupStatusOk {
+			// The interfaces were not listed, the snapshot is kept for the next run
+			continue
+		}
+		current := map[string]bool{}
+		for _, networkInterface := range result.NetworkInterfaces {
+			current[aws.ToString(networkInterface.NetworkInterfaceId)] = true
+		}
+
+		name := churnSnapshotName(accountId, result.Region, result.Selector.Key())
+		var previous []string
+		storedAt, ok := readCache(name, churnSnapshotTTL, &previous)
+		if !ok {
+			results[i].Churn = &groupChurn{NoPreviousSnapshot: true}
+		} else {
+			churn := &groupChurn{PreviousAt: &storedAt}
+			seen := map[string]bool{}
+			for _, networkInterfaceId := range previous {
+				seen[networkInterfaceId] = true
+				if !current[networkInterfaceId] {
+					churn.Removed++
+				}
+			}
+			for networkInterfaceId := range current {
+				if !seen[networkInterfaceId] {
+					churn.Added++
+				}
+			}
+			if union := len(previous) + churn.Added; union > 0 {
+				churn.Percent = roundPercent(float64(churn.Added+churn.Removed) * 100 / float64(union))
+			}
+			results[i].Churn = churn
+		}
+
+		// Store the new snapshot
+		snapshot := []string{}
+		for networkInterfaceId := range current {
+			snapshot = append(snapshot, networkInterfaceId)
+		}
+		if err := writeCache(name, snapshot); err != nil {
+			warnOnce("churn-snapshot", "could not store the churn snapshot: %v", err)
+		}
+	}
+}
+
+// churnSnapshotName returns the name of the cache file holding the snapshot of a query.
+//
+// accountId: The account the security group belongs to.
+// region: The region of the security group.
+// group: The security group, as given by the user.
+// string: The name of the cache file.
+func churnSnapshotName(accountId string, region string, group string) string {
+	sum := sha256.Sum256([]byte(group))
+	return fmt.Sprintf("churn-%s-%s-%s.json", accountId, region, hex.EncodeToString(sum[:8]))
+}
+
+// printChurn prints the churn of a security group.
+//
+// w: The writer to print to.
+// churn: The churn, nil when -churn is not set.
+func printChurn(w io.Writer, churn *groupChurn) {
+	switch {
+	case churn == nil:
+	case churn.NoPreviousSnapshot:
+		fmt.Fprintf(w, "Churn: no previous snapshot\n")
+	case churn.PreviousAt == nil:
+		fmt.Fprintf(w, "Churn: +%d -%d (%.1f%%)\n", churn.Added, churn.Removed, churn.Percent)
+	default:
+		fmt.Fprintf(w, "Churn: +%d -%d (%.1f%%) since %s\n", churn.Added, churn.Removed, churn.Percent, formatTimestamp(*churn.PreviousAt))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// loadConfig loads the default AWS configuration.
+//
+// ctx: The context used while loading the configuration.
+// mode: The endpoint variant every client built from the configuration calls.
+// aws.Config: The loaded configuration.
+// error: If the configuration cannot be loaded.
+func loadConfig(ctx context.Context, mode endpointMode) (aws.Config, error) {
+	// Create a config
+	return config.LoadDefaultConfig(ctx, mode.loadOptions()...)
+}
+
+// newEC2Client creates an EC2 client for the given region.
+//
+// cfg: The AWS configuration.
+// region: The region of the client. An empty region keeps the region of the configuration.
+// *ec2.Client: The EC2 client.
+func newEC2Client(cfg aws.Config, region string) *ec2.Client {
+	return ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if region != "" {
+			o.Region = region
+		}
+	})
+}
+
+// getAccountId retrieves the ID of the account the credentials belong to.
+//
+// ctx: The context used for the API call.
+// cfg: The AWS configuration.
+// string: The account ID.
+// error: If the caller identity cannot be retrieved.
+func getAccountId(ctx context.Context, cfg aws.Config) (string, error) {
+	getCallerIdentityOutput, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(getCallerIdentityOutput.Account), nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// cliFilterKeys are the members of a filter, in the order the AWS CLI lists them in its errors.
+var cliFilterKeys = []string{"Name", "Values"}
+
+// cliShorthandNextKey matches the start of the next key=value pair of a shorthand filter.
+var cliShorthandNextKey = regexp.MustCompile(`^\s*[A-Za-z0-9_]+\s*=`)
+
+// cliFilters collects the values of the repeatable --filters flag, parsed once the flags are parsed.
+type cliFilters struct {
+	Values []string
+}
+
+// Set appends a --filters occurrence.
+//
+// value: The filters, in the AWS CLI shorthand or JSON syntax.
+// error: Always nil, the syntax is checked by parseCLIFilters.
+func (f *cliFilters) Set(value string) error {
+	f.Values = append(f.Values, value)
+	return nil
+}
+
+// String returns the --filters occurrences separated by spaces.
+func (f *cliFilters) String() string {
+	return strings.Join(f.Values, " ")
+}
+
+// joinCLIFilterArgs joins the arguments following --filters that are not flags to its value, the way the AWS CLI
+// reads `--filters Name=a,Values=x Name=b,Values=y`, so the flag package does not stop parsing at them.
+//
+// args: The command line arguments, without the program name.
+// []string: The arguments with every --filters value in a single argument.
+func joinCLIFilterArgs(args []string) []string {
+	joined := []string{}
+	for i := 0; i < len(args); i++ {
+		joined = append(joined, args[i])
+		if args[i] != "--filters" && args[i] != "-filters" {
+			continue
+		}
+		values := []string{}
+		for i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			values = append(values, args[i])
+		}
+		if len(values) > 0 {
+			joined = append(joined, strings.Join(values, " "))
+		}
+	}
+	return joined
+}
+
+// parseCLIFilters translates --filters occurrences written for `aws ec2 describe-network-interfaces` into
+// DescribeNetworkInterfaces filters.
+//
+// Every occurrence holds a JSON array or object, or one or more shorthand filters separated by spaces, such as
+// `Name=group-name,Values=a,b`. Errors use the wording of the AWS CLI.
+//
+// values: The --filters occurrences.
+// []types.Filter: The filters, in order.
+// error: If an occurrence does not parse or a filter has an unknown or missing member.
+func parseCLIFilters(values []string) ([]types.Filter, error) {
+	filters := []types.Filter{}
+	for _, value := range values {
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+			parsed, err := parseCLIFiltersJSON(trimmed, len(filters))
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, parsed...)
+			continue
+		}
+		for _, shorthand := range splitCLIShorthand(trimmed) {
+			filter, err := parseCLIShorthand(shorthand, len(filters))
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+	return filters, nil
+}
+
+// parseCLIFiltersJSON parses the JSON form of --filters, an array of filters or a single filter.
+//
+// value: The JSON document.
+// index: The index of the first filter among all the filters, used in the errors.
+// []types.Filter: The filters.
+// error: If the document is not valid JSON or a filter has an unknown or missing member.
+func parseCLIFiltersJSON(value string, index int) ([]types.Filter, error) {
+	if strings.HasPrefix(value, "{") {
+		value = "[" + value + "]"
+	}
+	var members []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &members); err != nil {
+		return nil, fmt.Errorf("Error parsing parameter '--filters': Invalid JSON: %v\nJSON received: %s", err, value)
+	}
+	filters := []types.Filter{}
+	for i, member := range members {
+		filter := types.Filter{}
+		keys := []string{}
+		for key := range member {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			switch key {
+			case "Name":
+				var name string
+				if err := json.Unmarshal(member[key], &name); err != nil {
+					return nil, cliInvalidType(index+i, "Name", member[key], "str")
+				}
+				filter.Name = aws.String(name)
+			case "Values":
+				var values []string
+				if err := json.Unmarshal(member[key], &values); err != nil {
+					return nil, cliInvalidType(index+i, "Values", member[key], "list")
+				}
+				filter.Values = values
+			default:
+				return nil, cliUnknownParameter(index+i, key)
+			}
+		}
+		if filter.Name == nil {
+			return nil, cliMissingName(index + i)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// splitCLIShorthand splits a --filters value into its shorthand filters, separated by spaces outside quotes and
+// brackets.
+//
+// value: The --filters value.
+// []string: The shorthand filters.
+func splitCLIShorthand(value string) []string {
+	parts := []string{}
+	var current strings.Builder
+	quote := rune(0)
+	bracketed := false
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[' || r == ']':
+			bracketed = r == '['
+		case (r == ' ' || r == '\t') && !bracketed:
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// parseCLIShorthand parses a single shorthand filter, such as `Name=group-name,Values=a,b` or
+// `Name=status,Values=[available,in-use]`.
+//
+// value: The shorthand filter.
+// index: The index of the filter among all the filters, used in the errors.
+// types.Filter: The filter.
+// error: If the syntax is invalid, in the wording of the AWS CLI, or a member is unknown or missing.
+func parseCLIShorthand(value string, index int) (types.Filter, error) {
+	filter := types.Filter{}
+	pos := 0
+	for pos < len(value) {
+		// key=
+		equals := strings.IndexByte(value[pos:], '=')
+		if equals < 0 {
+			return types.Filter{}, cliShorthandError(value, len(value), "=", "EOF")
+		}
+		key := strings.TrimSpace(value[pos : pos+equals])
+		if key == "" {
+			return types.Filter{}, cliShorthandError(value, pos, "key", "=")
+		}
+		if !slices.Contains(cliFilterKeys, key) {
+			return types.Filter{}, cliUnknownParameter(index, key)
+		}
+		pos += equals + 1
+
+		// The values, up to the next key=
+		values := []string{}
+		if pos < len(value) && value[pos] == '[' {
+			end := strings.IndexByte(value[pos:], ']')
+			if end < 0 {
+				return types.Filter{}, cliShorthandError(value, len(value), "]", "EOF")
+			}
+			for _, item := range strings.Split(value[pos+1:pos+end], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, unquoteCLIValue(item))
+				}
+			}
+			pos += end + 1
+			if pos < len(value) {
+				if value[pos] != ',' {
+					return types.Filter{}, cliShorthandError(value, pos, ",", string(value[pos]))
+				}
+				pos++
+			}
+		} else {
+			for {
+				item, next, err := readCLIValue(value, pos)
+				if err != nil {
+					return types.Filter{}, err
+				}
+				values = append(values, item)
+				pos = next
+				if pos >= len(value) {
+					break
+				}
+				// Skip the comma, a value follows unless the next key does
+				pos++
+				if cliShorthandNextKey.MatchString(value[pos:]) {
+					break
+				}
+			}
+		}
+
+		switch key {
+		case "Name":
+			if len(values) != 1 {
+				// Printed as the Python list the AWS CLI received
+				quoted := []string{}
+				for _, value := range values {
+					quoted = append(quoted, "'"+value+"'")
+				}
+				return types.Filter{}, cliInvalidType(index, "Name", []byte("["+strings.Join(quoted, ", ")+"]"), "str")
+			}
+			filter.Name = aws.String(values[0])
+		case "Values":
+			filter.Values = append(filter.Values, values...)
+		}
+	}
+	if filter.Name == nil {
+		return types.Filter{}, cliMissingName(index)
+	}
+	return filter, nil
+}
+
+// readCLIValue reads a shorthand value, quoted or up to the next comma.
+//
+// value: The shorthand filter.
+// pos: The position of the value.
+// string: The value, unquoted.
+// int: The position after the value.
+// error: If a quote is not closed.
+func readCLIValue(value string, pos int) (string, int, error) {
+	if pos < len(value) && (value[pos] == '\'' || value[pos] == '"') {
+		end := strings.IndexByte(value[pos+1:], value[pos])
+		if end < 0 {
+			return "", 0, cliShorthandError(value, len(value), string(value[pos]), "EOF")
+		}
+		return value[pos+1 : pos+1+end], pos + end + 2, nil
+	}
+	end := strings.IndexByte(value[pos:], ',')
+	if end < 0 {
+		return strings.TrimSpace(value[pos:]), len(value), nil
+	}
+	return strings.TrimSpace(value[pos : pos+end]), pos + end, nil
+}
+
+// unquoteCLIValue removes the quotes around a value of a bracketed list.
+//
+// value: The value.
+// string: The unquoted value.
+func unquoteCLIValue(value string) string {
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// cliShorthandError returns a shorthand syntax error worded like the AWS CLI, pointing at the position.
+//
+// value: The shorthand filter.
+// pos: The position of the error.
+// expected: What was expected.
+// received: What was found.
+// error: The error.
+func cliShorthandError(value string, pos int, expected string, received string) error {
+	return fmt.Errorf("Error parsing parameter '--filters': Expected: '%s', received: '%s' for input:\n%s\n%s^", expected, received, value, strings.Repeat(" ", pos))
+}
+
+// cliUnknownParameter returns the AWS CLI validation error of an unknown filter member.
+//
+// index: The index of the filter.
+// key: The unknown member.
+// error: The error.
+func cliUnknownParameter(index int, key string) error {
+	return fmt.Errorf("Parameter validation failed:\nUnknown parameter in Filters[%d]: \"%s\", must be one of: %s", index, key, strings.Join(cliFilterKeys, ", "))
+}
+
+// cliMissingName returns the AWS CLI validation error of a filter without Name.
+//
+// index: The index of the filter.
+// error: The error.
+func cliMissingName(index int) error {
+	return fmt.Errorf("Parameter validation failed:\nMissing required parameter in Filters[%d]: \"Name\"", index)
+}
+
+// cliInvalidType returns the AWS CLI validation error of a member of the wrong type.
+//
+// index: The index of the filter.
+// key: The member.
+// value: The value received, as JSON.
+// valid: The valid type.
+// error: The error.
+func cliInvalidType(index int, key string, value []byte, valid string) error {
+	return fmt.Errorf("Parameter validation failed:\nInvalid type for parameter Filters[%d].%s, value: %s, valid types: <class '%s'>", index, key, bytes.TrimSpace(value), valid)
+}
+
+// groupFilterNames are the names of the --filters selecting security groups.
+var groupFilterNames = []string{"group-name", "group-id"}
+
+// splitGroupFilters takes the first group-name or group-id filter out of the --filters, as it selects the security
+// groups like -security-group-names does.
+//
+// The AWS CLI ANDs every filter, `Name=group-name,Values=web Name=group-id,Values=sg-1` only matching the interfaces
+// carrying both a group named web and sg-1. Only the first group filter is turned into selectors, the later ones
+// are sent with every DescribeNetworkInterfaces call, so the interfaces of the selected groups must match them too.
+//
+// filters: The --filters.
+// []string: The values of the first group-name or group-id filter.
+// []types.Filter: The other filters.
+func splitGroupFilters(filters []types.Filter) ([]string, []types.Filter) {
+	names := []string{}
+	others := []types.Filter{}
+	split := false
+	for _, filter := range filters {
+		if !split && slices.Contains(groupFilterNames, aws.ToString(filter.Name)) {
+			names = append(names, filter.Values...)
+			split = true
+		} else {
+			others = append(others, filter)
+		}
+	}
+	return names, others
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// formatFilters returns filters as `name=value,value` separated by spaces, for comparisons.
+//
+// filters: The filters.
+// string: The filters.
+func formatFilters(filters []types.Filter) string {
+	formatted := []string{}
+	for _, filter := range filters {
+		formatted = append(formatted, aws.ToString(filter.Name)+"="+strings.Join(filter.Values, ","))
+	}
+	return strings.Join(formatted, " ")
+}
+
+func TestParseCLIFilters(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"shorthand", []string{"Name=group-name,Values=a,b"}, "group-name=a,b"},
+		{"shorthand list", []string{"Name=status,Values=[available, in-use]"}, "status=available,in-use"},
+		{"shorthand quoted", []string{`Name=description,Values="a b",'c,d'`}, "description=a b,c,d"},
+		{"shorthand keys in any order", []string{"Values=x,Name=vpc-id"}, "vpc-id=x"},
+		{"shorthand no values", []string{"Name=status"}, "status="},
+		{"several shorthand", []string{"Name=status,Values=available Name=vpc-id,Values=vpc-1"}, "status=available vpc-id=vpc-1"},
+		{"json array", []string{`[{"Name": "status", "Values": ["available"]}, {"Name": "vpc-id", "Values": ["vpc-1", "vpc-2"]}]`}, "status=available vpc-id=vpc-1,vpc-2"},
+		{"json object", []string{` {"Name": "status", "Values": ["in-use"]}`}, "status=in-use"},
+		{"occurrences", []string{"Name=group-name,Values=web", `[{"Name": "status", "Values": ["available"]}]`, "Name=vpc-id,Values=vpc-1"}, "group-name=web status=available vpc-id=vpc-1"},
+		{"none", nil, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := parseCLIFilters(tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatFilters(filters); got != tt.want {
+				t.Errorf("filters %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCLIFiltersErrors(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  string
+	}{
+		{"Name", "Error parsing parameter '--filters': Expected: '=', received: 'EOF' for input:\nName\n    ^"},
+		{"=x", "Error parsing parameter '--filters': Expected: 'key', received: '=' for input:\n=x\n^"},
+		{"Name=status,Values=[a,b", "Error parsing parameter '--filters': Expected: ']', received: 'EOF' for input:\nName=status,Values=[a,b\n                       ^"},
+		{"Name=status,Values='a", "Error parsing parameter '--filters': Expected: ''', received: 'EOF' for input:\nName=status,Values='a\n                     ^"},
+		{"Name=a,Values=[x]y", "Error parsing parameter '--filters': Expected: ',', received: 'y' for input:\nName=a,Values=[x]y\n                 ^"},
+		{"Foo=x", "Parameter validation failed:\nUnknown parameter in Filters[0]: \"Foo\", must be one of: Name, Values"},
+		{"Name=a,Values=x Values=y", "Parameter validation failed:\nMissing required parameter in Filters[1]: \"Name\""},
+		{"Name=a,b,Values=x", "Parameter validation failed:\nInvalid type for parameter Filters[0].Name, value: ['a', 'b'], valid types: <class 'str'>"},
+		{`[{"Name": 1}]`, "Parameter validation failed:\nInvalid type for parameter Filters[0].Name, value: 1, valid types: <class 'str'>"},
+		{`[{"Name": "a", "Values": "x"}]`, "Parameter validation failed:\nInvalid type for parameter Filters[0].Values, value: \"x\", valid types: <class 'list'>"},
+		{`[{"Name": "a"}, {"Nam": "b"}]`, "Parameter validation failed:\nUnknown parameter in Filters[1]: \"Nam\", must be one of: Name, Values"},
+		{`{"Values": ["a"]}`, "Parameter validation failed:\nMissing required parameter in Filters[0]: \"Name\""},
+		{`[{`, "Error parsing parameter '--filters': Invalid JSON: unexpected end of JSON input\nJSON received: [{"},
+	} {
+		_, err := parseCLIFilters([]string{tt.value})
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error\n%v\nwant\n%s", tt.value, err, tt.want)
+		}
+	}
+
+	// The index counts the filters of the earlier occurrences
+	_, err := parseCLIFilters([]string{"Name=status,Values=available", "Foo=x"})
+	if want := `Unknown parameter in Filters[1]: "Foo"`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("second occurrence: error %v, want %q", err, want)
+	}
+}
+
+func TestJoinCLIFilterArgs(t *testing.T) {
+	args := []string{"-output", "json", "--filters", "Name=status,Values=available", "Name=vpc-id,Values=vpc-1", "-filters", "Name=a,Values=b", "-v"}
+	want := []string{"-output", "json", "--filters", "Name=status,Values=available Name=vpc-id,Values=vpc-1", "-filters", "Name=a,Values=b", "-v"}
+	if got := joinCLIFilterArgs(args); !slices.Equal(got, want) {
+		t.Errorf("joined %q, want %q", got, want)
+	}
+}
+
+func TestSplitGroupFilters(t *testing.T) {
+	filters, err := parseCLIFilters([]string{"Name=status,Values=in-use Name=group-name,Values=web,db Name=group-id,Values=sg-1 Name=group-name,Values=app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, others := splitGroupFilters(filters)
+	if !slices.Equal(names, []string{"web", "db"}) {
+		t.Errorf("selectors %q, want the first group filter", names)
+	}
+	if got, want := formatFilters(others), "status=in-use group-id=sg-1 group-name=app"; got != want {
+		t.Errorf("API filters %q, want %q", got, want)
+	}
+}
+
+// TestGroupFiltersAnded checks the group filters are ANDed like the AWS CLI does, against the fake.
+func TestGroupFiltersAnded(t *testing.T) {
+	web, db := testGroup("sg-0000000000000000a", "web"), testGroup("sg-0000000000000000b", "db")
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		types.SecurityGroup{GroupId: web.GroupId, GroupName: web.GroupName, VpcId: aws.String("vpc-1")},
+		types.SecurityGroup{GroupId: db.GroupId, GroupName: db.GroupName, VpcId: aws.String("vpc-1")},
+	)
+	fake.AddNetworkInterfaces(testInterface("eni-web", "", web), testInterface("eni-both", "", web, db), testInterface("eni-db", "", db))
+
+	filters, err := parseCLIFilters([]string{fmt.Sprintf("Name=group-name,Values=web Name=group-id,Values=%s", aws.ToString(db.GroupId))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, others := splitGroupFilters(filters)
+	ctx := enilookup.WithFilters(context.Background(), others)
+	networkInterfaces, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: names[0], GroupName: names[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networkInterfaces) != 1 || aws.ToString(networkInterfaces[0].NetworkInterfaceId) != "eni-both" {
+		t.Errorf("found %d interfaces, want eni-both only", len(networkInterfaces))
+	}
+}
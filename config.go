@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configDirName is the directory of the config file in the user config directory.
+const configDirName = "get-network-interfaces-by-security-group-names"
+
+// defaultConfigPath returns the path of the config file read when -config is not set.
+//
+// string: The path, empty when the user config directory is unknown.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, configDirName, "config.json")
+}
+
+// applyConfigFile sets the flags not given on the command line from a JSON config file, so wrapper scripts can
+// bake in settings such as the environment assertions.
+//
+// The file is a JSON object keyed by flag name, e.g. {"expect-account": "123456789012", "cache-ttl": "2h"}.
+// Strings, numbers and booleans are set as if given on the command line, and arrays set a repeatable flag once
+// per element.
+//
+// fs: The parsed flag set.
+// path: The config file.
+// required: Whether a missing file is an error, as it is when the path was given with -config.
+// error: If the file cannot be read or parsed, or names an unknown flag or an invalid value.
+func applyConfigFile(fs *flag.FlagSet, path string, required bool) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	settings := map[string]any{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// The command line wins over the file
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := []string{}
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		values := []any{settings[name]}
+		if list, ok := settings[name].([]any); ok {
+			values = list
+		}
+		for _, value := range values {
+			if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("%s: invalid value %v for %q: %w", path, value, name, err)
+			}
+		}
+	}
+	return nil
+}
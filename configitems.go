@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "config-items", description: "an AWS Config style configuration item per interface, as NDJSON", contentType: "application/x-ndjson", extension: ".ndjson", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if err := writeConfigItems(w, newConfigItems(run.Results, opts.AccountId, run.ScannedAt)); err != nil {
+			return err
+		}
+		// Configuration items have no room for the notes, they go to stderr
+		printRunNotes(os.Stderr, run, opts)
+		return nil
+	}})
+}
+
+// configItemVersion is the version of the AWS Config configuration item format the items follow.
+const configItemVersion = "1.3"
+
+// configItem is a network interface in the shape of an AWS Config configuration item.
+//
+// The configuration holds a subset of the AWS::EC2::NetworkInterface schema, described by
+// schemas/config-items.schema.json.
+type configItem struct {
+	Version                      string               `json:"version"`
+	AccountId                    string               `json:"accountId"`
+	ConfigurationItemCaptureTime *time.Time           `json:"configurationItemCaptureTime,omitempty"`
+	ConfigurationItemStatus      string               `json:"configurationItemStatus"`
+	ResourceType                 string               `json:"resourceType"`
+	ResourceId                   string               `json:"resourceId"`
+	AwsRegion                    string               `json:"awsRegion"`
+	AvailabilityZone             string               `json:"availabilityZone"`
+	Tags                         map[string]string    `json:"tags"`
+	Relationships                []configRelationship `json:"relationships"`
+	Configuration                configInterface      `json:"configuration"`
+}
+
+// configRelationship links a configuration item to another resource.
+type configRelationship struct {
+	ResourceType string `json:"resourceType"`
+	ResourceId   string `json:"resourceId"`
+	Name         string `json:"name"`
+}
+
+// configInterface is the configuration payload of a network interface item.
+type configInterface struct {
+	NetworkInterfaceId string             `json:"networkInterfaceId"`
+	Status             string             `json:"status"`
+	InterfaceType      string             `json:"interfaceType"`
+	Description        string             `json:"description"`
+	VpcId              string             `json:"vpcId"`
+	SubnetId           string             `json:"subnetId"`
+	MacAddress         string             `json:"macAddress"`
+	PrivateIpAddress   string             `json:"privateIpAddress"`
+	OwnerId            string             `json:"ownerId"`
+	RequesterManaged   bool               `json:"requesterManaged"`
+	Groups             []configGroup      `json:"groups"`
+	Attachment         *configAttachment  `json:"attachment,omitempty"`
+	Association        *configAssociation `json:"association,omitempty"`
+}
+
+// configGroup is a security group of a network interface item.
+type configGroup struct {
+	GroupId   string `json:"groupId"`
+	GroupName string `json:"groupName"`
+}
+
+// configAttachment is the attachment of a network interface item.
+type configAttachment struct {
+	AttachmentId string     `json:"attachmentId"`
+	InstanceId   string     `json:"instanceId,omitempty"`
+	DeviceIndex  int32      `json:"deviceIndex"`
+	Status       string     `json:"status"`
+	AttachTime   *time.Time `json:"attachTime,omitempty"`
+}
+
+// configAssociation is the public IP association of a network interface item.
+type configAssociation struct {
+	PublicIp      string `json:"publicIp"`
+	AllocationId  string `json:"allocationId,omitempty"`
+	AssociationId string `json:"associationId,omitempty"`
+	IpOwnerId     string `json:"ipOwnerId"`
+}
+
+// newConfigItems converts the network interfaces of the results to configuration items, one per interface.
+//
+// results: The network interfaces found per security group.
+// accountId: The account of the security groups, used when an interface has no owner.
+// capturedAt: When the run started, nil with -no-timestamps.
+// []configItem: The items, sorted by interface ID.
+func newConfigItems(results []groupResult, accountId string, capturedAt *time.Time) []configItem {
+	items := []configItem{}
+	seen := map[string]bool{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if seen[networkInterfaceId] {
+				continue
+			}
+			seen[networkInterfaceId] = true
+			items = append(items, newConfigItem(result.Region, networkInterface, accountId, capturedAt))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ResourceId < items[j].ResourceId })
+	return items
+}
+
+// newConfigItem converts a network interface to a configuration item.
+//
+// region: The region of the interface.
+// networkInterface: The network interface.
+// accountId: The account of the security groups, used when the interface has no owner.
+// capturedAt: When the run started, nil with -no-timestamps.
+// configItem: The item, related to its security groups, instance, subnet and VPC.
+func newConfigItem(region string, networkInterface types.NetworkInterface, accountId string, capturedAt *time.Time) configItem {
+	networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+	if owner := aws.ToString(networkInterface.OwnerId); owner != "" {
+		accountId = owner
+	}
+	item := configItem{
+		Version:                      configItemVersion,
+		AccountId:                    accountId,
+		ConfigurationItemCaptureTime: capturedAt,
+		ConfigurationItemStatus:      "OK",
+		ResourceType:                 "AWS::EC2::NetworkInterface",
+		ResourceId:                   networkInterfaceId,
+		AwsRegion:                    region,
+		AvailabilityZone:             aws.ToString(networkInterface.AvailabilityZone),
+		Tags:                         map[string]string{},
+		Relationships:                []configRelationship{},
+		Configuration: configInterface{
+			NetworkInterfaceId: networkInterfaceId,
+			Status:             string(networkInterface.Status),
+			InterfaceType:      string(networkInterface.InterfaceType),
+			Description:        aws.ToString(networkInterface.Description),
+			VpcId:              aws.ToString(networkInterface.VpcId),
+			SubnetId:           aws.ToString(networkInterface.SubnetId),
+			MacAddress:         aws.ToString(networkInterface.MacAddress),
+			PrivateIpAddress:   aws.ToString(networkInterface.PrivateIpAddress),
+			OwnerId:            aws.ToString(networkInterface.OwnerId),
+			RequesterManaged:   aws.ToBool(networkInterface.RequesterManaged),
+			Groups:             []configGroup{},
+		},
+	}
+	for _, tag := range networkInterface.TagSet {
+		item.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	// Relate the item to the resources already gathered
+	for _, group := range networkInterface.Groups {
+		item.Configuration.Groups = append(item.Configuration.Groups, configGroup{GroupId: aws.ToString(group.GroupId), GroupName: aws.ToString(group.GroupName)})
+		item.Relationships = append(item.Relationships, configRelationship{ResourceType: "AWS::EC2::SecurityGroup", ResourceId: aws.ToString(group.GroupId), Name: "Is associated with SecurityGroup"})
+	}
+	if attachment := networkInterface.Attachment; attachment != nil {
+		item.Configuration.Attachment = &configAttachment{
+			AttachmentId: aws.ToString(attachment.AttachmentId),
+			InstanceId:   aws.ToString(attachment.InstanceId),
+			DeviceIndex:  aws.ToInt32(attachment.DeviceIndex),
+			Status:       string(attachment.Status),
+			AttachTime:   attachment.AttachTime,
+		}
+		if attachment.InstanceId != nil {
+			item.Relationships = append(item.Relationships, configRelationship{ResourceType: "AWS::EC2::Instance", ResourceId: aws.ToString(attachment.InstanceId), Name: "Is attached to Instance"})
+		}
+	}
+	if association := networkInterface.Association; association != nil {
+		item.Configuration.Association = &configAssociation{
+			PublicIp:      aws.ToString(association.PublicIp),
+			AllocationId:  aws.ToString(association.AllocationId),
+			AssociationId: aws.ToString(association.AssociationId),
+			IpOwnerId:     aws.ToString(association.IpOwnerId),
+		}
+	}
+	if networkInterface.SubnetId != nil {
+		item.Relationships = append(item.Relationships, configRelationship{ResourceType: "AWS::EC2::Subnet", ResourceId: aws.ToString(networkInterface.SubnetId), Name: "Is contained in Subnet"})
+	}
+	if networkInterface.VpcId != nil {
+		item.Relationships = append(item.Relationships, configRelationship{ResourceType: "AWS::EC2::VPC", ResourceId: aws.ToString(networkInterface.VpcId), Name: "Is contained in Vpc"})
+	}
+	return item
+}
+
+// writeConfigItems writes the configuration items as NDJSON, one item per line.
+//
+// w: The writer to print to.
+// items: The items.
+// error: If an item cannot be encoded or written.
+func writeConfigItems(w io.Writer, items []configItem) error {
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
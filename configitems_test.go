@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compareShape checks a document only uses the members of a sample document, with the same JSON types.
+//
+// value: The document, as decoded into an any.
+// sample: The sample, as decoded into an any.
+// path: The path of the value in the document, for the problems.
+// []string: The problems, empty when the document has the shape of the sample.
+func compareShape(value any, sample any, path string) []string {
+	if sample == nil {
+		// The sample leaves the type open
+		return nil
+	}
+	if fmt.Sprintf("%T", value) != fmt.Sprintf("%T", sample) {
+		return []string{fmt.Sprintf("%s: %T, the sample has %T", path, value, sample)}
+	}
+	problems := []string{}
+	switch value := value.(type) {
+	case map[string]any:
+		sampleMembers := sample.(map[string]any)
+		keys := []string{}
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			member, ok := sampleMembers[key]
+			if !ok && path == "$.tags" {
+				// Tags are keyed by the tag keys
+				continue
+			}
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: not in the sample", path, key))
+				continue
+			}
+			problems = append(problems, compareShape(value[key], member, path+"."+key)...)
+		}
+	case []any:
+		if items := sample.([]any); len(items) > 0 {
+			for i, item := range value {
+				problems = append(problems, compareShape(item, items[0], fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+func TestConfigItemsMatchSample(t *testing.T) {
+	data, err := os.ReadFile("testdata/config-item-network-interface.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sample any
+	if err := json.Unmarshal(data, &sample); err != nil {
+		t.Fatal(err)
+	}
+
+	run := testRun(false)
+	capturedAt := time.Date(2025, 5, 6, 7, 8, 9, 0, time.UTC)
+	run.ScannedAt = &capturedAt
+	var b bytes.Buffer
+	if err := renderers["config-items"].Render(&b, run, renderOptions{Output: "config-items", AccountId: "123456789012"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("%d items, want one per interface:\n%s", len(lines), b.String())
+	}
+	for _, line := range lines {
+		var item any
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatal(err)
+		}
+		for _, problem := range compareShape(item, sample, "$") {
+			t.Error(problem)
+		}
+	}
+}
+
+func TestNewConfigItem(t *testing.T) {
+	run := testRun(false)
+	items := newConfigItems(run.Results, "210987654321", nil)
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ResourceId)
+	}
+	if got := strings.Join(ids, ","); got != "eni-0aaa,eni-0bbb,eni-0ccc,eni-0ddd,eni-0eee" {
+		t.Fatalf("items %s, want one per interface sorted by ID", got)
+	}
+
+	item := items[0]
+	if item.AccountId != "123456789012" || item.AwsRegion != "eu-west-1" || item.ConfigurationItemCaptureTime != nil || item.Tags["env"] != "prod" {
+		t.Errorf("item = %+v", item)
+	}
+	relationships := []string{}
+	for _, relationship := range item.Relationships {
+		relationships = append(relationships, relationship.ResourceType+" "+relationship.ResourceId)
+	}
+	want := "AWS::EC2::SecurityGroup sg-0a1b2c3d4e5f60718,AWS::EC2::SecurityGroup sg-0e1b2c3d4e5f60718,AWS::EC2::Instance i-0aaa,AWS::EC2::Subnet subnet-1,AWS::EC2::VPC vpc-1"
+	if got := strings.Join(relationships, ","); got != want {
+		t.Errorf("relationships\n%s\nwant\n%s", got, want)
+	}
+	if attachment := item.Configuration.Attachment; attachment == nil || attachment.InstanceId != "i-0aaa" || attachment.AttachTime == nil {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	if association := item.Configuration.Association; association == nil || association.PublicIp != "203.0.113.10" {
+		t.Errorf("association = %+v", association)
+	}
+
+	// An interface without owner belongs to the account of the groups, an unattached one has no instance
+	unattached := testInterface("eni-1", "")
+	unattached.OwnerId = nil
+	item = newConfigItem("eu-west-1", unattached, "210987654321", nil)
+	if item.AccountId != "210987654321" || item.Configuration.Attachment != nil || item.Configuration.Association != nil || len(item.Relationships) != 2 {
+		t.Errorf("unattached item = %+v", item)
+	}
+}
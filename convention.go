@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"gopkg.in/yaml.v2"
+)
+
+// The requirements a convention can breach.
+const (
+	requirementRequired  = "required"
+	requirementForbidden = "forbidden"
+)
+
+// convention is the -convention file: the security groups the network interfaces must and must not carry.
+//
+// The file is YAML, e.g.:
+//
+//	rules:
+//	  - name: prod
+//	    match:
+//	      subnets: [subnet-0123456789abcdef0]
+//	    require: ["prod-*"]
+//	    forbid: ["dev-*"]
+//
+// An interface is evaluated against a single rule, the most specific one matching it: a rule matching on subnets
+// wins over one matching on VPCs, which wins over one matching on tags, and a rule with an empty match is the
+// default. A rule matching on more of these wins at equal level, and equal rules go to the first in the file.
+type convention struct {
+	Rules []conventionRule `yaml:"rules"`
+}
+
+// conventionRule is a requirement on the security groups of the interfaces it matches.
+type conventionRule struct {
+	Name  string             `yaml:"name"`
+	Match conventionSelector `yaml:"match"`
+	// Require are the patterns each matched by at least one group of the interface.
+	Require []string `yaml:"require"`
+	// Forbid are the patterns no group of the interface may match.
+	Forbid []string `yaml:"forbid"`
+}
+
+// conventionSelector selects the interfaces a rule applies to. Every field given must match, an empty selector
+// matches every interface.
+type conventionSelector struct {
+	Subnets []string `yaml:"subnets"`
+	Vpcs    []string `yaml:"vpcs"`
+	// Tags are the tags of the interface, the values being patterns such as "prod*" or "*".
+	Tags map[string]string `yaml:"tags"`
+}
+
+// specificity ranks the selector for the precedence of the rules: subnets first, then VPCs, then tags.
+//
+// int: The rank, higher for a more specific selector.
+func (s conventionSelector) specificity() int {
+	rank := 0
+	if len(s.Subnets) > 0 {
+		rank += 4
+	}
+	if len(s.Vpcs) > 0 {
+		rank += 2
+	}
+	if len(s.Tags) > 0 {
+		rank++
+	}
+	return rank
+}
+
+// String describes what the selector matches on, as printed with the violations.
+func (s conventionSelector) String() string {
+	parts := []string{}
+	if len(s.Subnets) > 0 {
+		parts = append(parts, "subnet")
+	}
+	if len(s.Vpcs) > 0 {
+		parts = append(parts, "vpc")
+	}
+	if len(s.Tags) > 0 {
+		keys := []string{}
+		for key := range s.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts = append(parts, "tag "+strings.Join(keys, ","))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, "+")
+}
+
+// matches reports whether the selector selects a network interface.
+//
+// networkInterface: The interface.
+// bool: Whether every field of the selector matches it.
+func (s conventionSelector) matches(networkInterface types.NetworkInterface) bool {
+	if len(s.Subnets) > 0 && !containsFold(s.Subnets, aws.ToString(networkInterface.SubnetId)) {
+		return false
+	}
+	if len(s.Vpcs) > 0 && !containsFold(s.Vpcs, aws.ToString(networkInterface.VpcId)) {
+		return false
+	}
+	for key, pattern := range s.Tags {
+		value, ok := interfaceTag(networkInterface, key)
+		if !ok {
+			return false
+		}
+		if !globMatch(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch reports whether a value matches a pattern of the convention, in which * matches any run of
+// characters, / included, and every other character matches itself.
+//
+// pattern: The pattern, such as "prod-*" or "team/*".
+// value: The group name, group ID or tag value.
+// bool: Whether the whole value matches.
+func globMatch(pattern string, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+// containsFold reports whether a list holds a value, ignoring case.
+//
+// values: The list.
+// value: The value.
+// bool: Whether it is in the list.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceTag returns the value of a tag of a network interface.
+//
+// networkInterface: The interface.
+// key: The tag key.
+// string: The tag value.
+// bool: Whether the interface has the tag.
+func interfaceTag(networkInterface types.NetworkInterface, key string) (string, bool) {
+	for _, tag := range networkInterface.TagSet {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value), true
+		}
+	}
+	return "", false
+}
+
+// loadConvention reads and checks the -convention file.
+//
+// file: The file.
+// *convention: The convention.
+// error: If the file cannot be read or parsed, has an unknown field, or a rule is invalid.
+func loadConvention(file string) (*convention, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &convention{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(c.Rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", file)
+	}
+	names := map[string]bool{}
+	for i, rule := range c.Rules {
+		switch {
+		case rule.Name == "":
+			return nil, fmt.Errorf("%s: rule %d has no name", file, i+1)
+		case names[rule.Name]:
+			return nil, fmt.Errorf("%s: rule %q is defined twice", file, rule.Name)
+		case len(rule.Require) == 0 && len(rule.Forbid) == 0:
+			return nil, fmt.Errorf("%s: rule %q neither requires nor forbids a group", file, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return c, nil
+}
+
+// ruleFor returns the rule a network interface is evaluated against.
+//
+// networkInterface: The interface.
+// *conventionRule: The most specific rule matching the interface, the first in the file at equal specificity,
+// nil when no rule matches it.
+func (c *convention) ruleFor(networkInterface types.NetworkInterface) *conventionRule {
+	var selected *conventionRule
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if !rule.Match.matches(networkInterface) {
+			continue
+		}
+		if selected == nil || rule.Match.specificity() > selected.Match.specificity() {
+			selected = rule
+		}
+	}
+	return selected
+}
+
+// conventionViolation is a requirement of the convention an interface breaches.
+type conventionViolation struct {
+	Region             string `json:"region"`
+	NetworkInterfaceId string `json:"network_interface_id"`
+	SubnetId           string `json:"subnet_id"`
+	VpcId              string `json:"vpc_id"`
+	// Groups are the names of the security groups of the interface.
+	Groups []string `json:"groups"`
+	Rule   string   `json:"rule"`
+	// MatchedOn is what the rule matched the interface on: subnet, vpc, tag keys, or default.
+	MatchedOn string `json:"matched_on"`
+	// Requirement is requirementRequired or requirementForbidden.
+	Requirement string `json:"requirement"`
+	Pattern     string `json:"pattern"`
+	// Group is the group matching a forbidden pattern, empty for a missing required group.
+	Group string `json:"group,omitempty"`
+}
+
+// conventionReport is the JSON document of -convention.
+type conventionReport struct {
+	// Evaluated is the number of interfaces a rule matched, Unmatched the number no rule matched.
+	Evaluated     int                   `json:"evaluated"`
+	Unmatched     int                   `json:"unmatched"`
+	Violations    []conventionViolation `json:"violations"`
+	FailedRegions []regionFailure       `json:"failed_regions"`
+}
+
+// getViolations evaluates every network interface of the results against the convention.
+//
+// An interface found under several security groups is evaluated once.
+//
+// results: The network interfaces found per security group.
+// c: The convention.
+// conventionReport: The violations, ordered by region and interface ID, and the counts of interfaces.
+func getViolations(results []groupResult, c *convention) conventionReport {
+	r := conventionReport{Violations: []conventionViolation{}}
+	index := newGroupIndex(results)
+	seen := map[string]bool{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			key := result.Region + "/" + aws.ToString(networkInterface.NetworkInterfaceId)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			rule := c.ruleFor(networkInterface)
+			if rule == nil {
+				r.Unmatched++
+				continue
+			}
+			r.Evaluated++
+			r.Violations = append(r.Violations, evaluateRule(result.Region, networkInterface, rule, index)...)
+		}
+	}
+	sort.SliceStable(r.Violations, func(i, j int) bool {
+		if r.Violations[i].Region != r.Violations[j].Region {
+			return r.Violations[i].Region < r.Violations[j].Region
+		}
+		return r.Violations[i].NetworkInterfaceId < r.Violations[j].NetworkInterfaceId
+	})
+	return r
+}
+
+// evaluateRule checks the security groups of a network interface against a rule.
+//
+// A group is matched on its name and on its ID.
+//
+// region: The region of the interface.
+// networkInterface: The interface.
+// rule: The rule selected for the interface.
+// index: The security groups of the interfaces of the run.
+// []conventionViolation: A violation per required pattern no group matches and per group matching a forbidden
+// pattern.
+func evaluateRule(region string, networkInterface types.NetworkInterface, rule *conventionRule, index *groupIndex) []conventionViolation {
+	groupIds := index.groupsOf(aws.ToString(networkInterface.NetworkInterfaceId))
+	groups := []string{}
+	for _, groupId := range groupIds {
+		groups = append(groups, index.nameOf(groupId))
+	}
+	violation := func(requirement string, pattern string, group string) conventionViolation {
+		return conventionViolation{
+			Region:             region,
+			NetworkInterfaceId: aws.ToString(networkInterface.NetworkInterfaceId),
+			SubnetId:           aws.ToString(networkInterface.SubnetId),
+			VpcId:              aws.ToString(networkInterface.VpcId),
+			Groups:             groups,
+			Rule:               rule.Name,
+			MatchedOn:          rule.Match.String(),
+			Requirement:        requirement,
+			Pattern:            pattern,
+			Group:              group,
+		}
+	}
+	matchGroup := func(pattern string, groupId string) bool {
+		return globMatch(pattern, index.nameOf(groupId)) || globMatch(pattern, groupId)
+	}
+
+	violations := []conventionViolation{}
+	for _, pattern := range rule.Require {
+		found := false
+		for _, groupId := range groupIds {
+			found = found || matchGroup(pattern, groupId)
+		}
+		if !found {
+			violations = append(violations, violation(requirementRequired, pattern, ""))
+		}
+	}
+	for _, pattern := range rule.Forbid {
+		for _, groupId := range groupIds {
+			if matchGroup(pattern, groupId) {
+				violations = append(violations, violation(requirementForbidden, pattern, index.nameOf(groupId)))
+			}
+		}
+	}
+	return violations
+}
+
+// printViolations prints the violations of the convention, one line each.
+//
+// w: The writer to print to.
+// r: The violations and the counts of interfaces.
+// showRegion: Whether to print the region of every interface, as when several regions are scanned.
+func printViolations(w io.Writer, r conventionReport, showRegion bool) {
+	for _, violation := range r.Violations {
+		networkInterface := violation.NetworkInterfaceId
+		if showRegion {
+			networkInterface += " (" + violation.Region + ")"
+		}
+		groups := []string{}
+		for _, group := range violation.Groups {
+			groups = append(groups, displayText(group))
+		}
+		switch violation.Requirement {
+		case requirementRequired:
+			fmt.Fprintf(w, "%s in %s: rule %s (%s) requires a group matching %q, has: %s\n",
+				networkInterface, violation.SubnetId, displayText(violation.Rule), violation.MatchedOn, violation.Pattern, strings.Join(groups, ", "))
+		default:
+			fmt.Fprintf(w, "%s in %s: rule %s (%s) forbids groups matching %q, has %s\n",
+				networkInterface, violation.SubnetId, displayText(violation.Rule), violation.MatchedOn, violation.Pattern, displayText(violation.Group))
+		}
+	}
+	fmt.Fprintf(w, "Convention: %d violations, %d interfaces evaluated, %d matched no rule\n", len(r.Violations), r.Evaluated, r.Unmatched)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// conventionInterface returns an interface fixture in a subnet and a VPC, with an env tag unless empty.
+//
+// subnetId: The subnet.
+// vpcId: The VPC.
+// env: The value of the env tag, empty for none.
+// types.NetworkInterface: The interface.
+func conventionInterface(subnetId string, vpcId string, env string) types.NetworkInterface {
+	networkInterface := testInterface("eni-1", "")
+	networkInterface.SubnetId, networkInterface.VpcId = aws.String(subnetId), aws.String(vpcId)
+	if env != "" {
+		networkInterface.TagSet = []types.Tag{{Key: aws.String("env"), Value: aws.String(env)}}
+	}
+	return networkInterface
+}
+
+// TestConventionRuleFor checks the precedence of the rules: subnets count 4, VPCs 2 and tags 1, added up, and the
+// first rule in the file wins at equal specificity.
+func TestConventionRuleFor(t *testing.T) {
+	forbid := []string{"dev-*"}
+	c := &convention{Rules: []conventionRule{
+		{Name: "default", Forbid: forbid},
+		{Name: "prod tag", Match: conventionSelector{Tags: map[string]string{"env": "prod*"}}, Forbid: forbid},
+		{Name: "vpc", Match: conventionSelector{Vpcs: []string{"vpc-1"}}, Forbid: forbid},
+		{Name: "vpc and prod tag", Match: conventionSelector{Vpcs: []string{"vpc-1"}, Tags: map[string]string{"env": "prod*"}}, Forbid: forbid},
+		{Name: "subnet", Match: conventionSelector{Subnets: []string{"Subnet-2"}}, Forbid: forbid},
+		{Name: "any tag", Match: conventionSelector{Tags: map[string]string{"env": "*"}}, Forbid: forbid},
+		{Name: "subnet and prod tag", Match: conventionSelector{Subnets: []string{"subnet-3"}, Tags: map[string]string{"env": "prod*"}}, Forbid: forbid},
+		{Name: "subnet and vpc", Match: conventionSelector{Subnets: []string{"subnet-3"}, Vpcs: []string{"vpc-1"}}, Forbid: forbid},
+	}}
+	for _, tt := range []struct {
+		name             string
+		networkInterface types.NetworkInterface
+		want             string
+	}{
+		{"nothing but the default", conventionInterface("subnet-9", "vpc-9", ""), "default"},
+		{"tags tie, first in the file", conventionInterface("subnet-9", "vpc-9", "production"), "prod tag"},
+		{"vpc over tag", conventionInterface("subnet-9", "vpc-1", "dev"), "vpc"},
+		{"vpc and tag over vpc", conventionInterface("subnet-9", "vpc-1", "prod"), "vpc and prod tag"},
+		{"subnet over vpc and tag, ignoring case", conventionInterface("subnet-2", "vpc-1", "prod"), "subnet"},
+		{"subnet and tag", conventionInterface("subnet-3", "vpc-9", "prod"), "subnet and prod tag"},
+		{"subnet and vpc over subnet and tag", conventionInterface("subnet-3", "vpc-1", "prod"), "subnet and vpc"},
+		{"tag value with a slash", conventionInterface("subnet-9", "vpc-9", "prod/eu"), "prod tag"},
+	} {
+		rule := c.ruleFor(tt.networkInterface)
+		if rule == nil || rule.Name != tt.want {
+			t.Errorf("%s: rule %+v, want %s", tt.name, rule, tt.want)
+		}
+	}
+
+	// Without a default rule, an interface matching nothing is not evaluated
+	c.Rules = c.Rules[1:]
+	if rule := c.ruleFor(conventionInterface("subnet-9", "vpc-9", "")); rule != nil {
+		t.Errorf("rule %+v, want none", rule)
+	}
+}
+
+// TestConventionViolations matches the patterns on the group names and IDs, and evaluates an interface found under
+// two of the security groups once.
+func TestConventionViolations(t *testing.T) {
+	web := testGroup("sg-0web", "web-prod/eu")
+	legacy := testGroup("sg-0legacy", "legacy")
+	quarantined := testGroup("sg-0bad1", "ok-name")
+	shared := testInterface("eni-1", "i-1", web, legacy)
+	alone := testInterface("eni-2", "", quarantined)
+	unmatched := testInterface("eni-3", "", web)
+	unmatched.SubnetId = aws.String("subnet-9")
+	results := []groupResult{
+		testResult("eu-west-1", "web-prod", shared, unmatched),
+		testResult("eu-west-1", "legacy", shared),
+		testResult("eu-west-1", "ok-name", alone),
+	}
+	c := &convention{Rules: []conventionRule{{
+		Name:    "subnet-1",
+		Match:   conventionSelector{Subnets: []string{"subnet-1"}},
+		Require: []string{"web-*", "sg-0*"},
+		Forbid:  []string{"legacy", "sg-0bad*"},
+	}}}
+
+	r := getViolations(results, c)
+	if r.Evaluated != 2 || r.Unmatched != 1 {
+		t.Errorf("%d evaluated and %d unmatched interfaces, want 2 and 1", r.Evaluated, r.Unmatched)
+	}
+	type found struct {
+		networkInterfaceId, requirement, pattern, group string
+	}
+	got := []found{}
+	for _, violation := range r.Violations {
+		got = append(got, found{violation.NetworkInterfaceId, violation.Requirement, violation.Pattern, violation.Group})
+	}
+	want := []found{
+		{"eni-1", requirementForbidden, "legacy", "legacy"},
+		{"eni-2", requirementRequired, "web-*", ""},
+		{"eni-2", requirementForbidden, "sg-0bad*", "ok-name"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations %+v, want %+v", got, want)
+	}
+	if len(r.Violations) > 0 {
+		if violation := r.Violations[0]; !reflect.DeepEqual(violation.Groups, []string{"web-prod/eu", "legacy"}) || violation.MatchedOn != "subnet" || violation.Rule != "subnet-1" {
+			t.Errorf("violation %+v, want the groups web-prod/eu and legacy, matched on subnet by subnet-1", violation)
+		}
+	}
+}
+
+func TestLoadConvention(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"valid", "rules:\n  - name: prod\n    match:\n      subnets: [subnet-1]\n      tags: {env: \"prod*\"}\n    require: [\"prod-*\"]\n  - name: default\n    forbid: [\"dev-*\"]\n", ""},
+		{"unknown field", "rules:\n  - name: prod\n    requires: [\"prod-*\"]\n", "field requires not found"},
+		{"no rules", "rules: []\n", "no rules"},
+		{"rule without a name", "rules:\n  - forbid: [\"dev-*\"]\n", "rule 1 has no name"},
+		{"rule defined twice", "rules:\n  - name: prod\n    forbid: [\"dev-*\"]\n  - name: prod\n    require: [\"prod-*\"]\n", `rule "prod" is defined twice`},
+		{"rule without a requirement", "rules:\n  - name: prod\n    match:\n      vpcs: [vpc-1]\n", `rule "prod" neither requires nor forbids a group`},
+	} {
+		file := filepath.Join(t.TempDir(), "convention.yaml")
+		if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := loadConvention(file)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want == "" && len(c.Rules) != 2:
+			t.Errorf("%s: rules %+v", tt.name, c.Rules)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), file+": ")):
+			t.Errorf("%s: %v, want %s: %s", tt.name, err, file, tt.want)
+		}
+	}
+
+	if _, err := loadConvention(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"prod-*", "prod-app/web", true},
+		{"team/*", "team/payments", true},
+		{"*/payments", "org/team/payments", true},
+		{"*", "", true},
+		{"*", "a/b", true},
+		{"prod", "prod", true},
+		{"prod", "prod-app", false},
+		{"prod-*", "dev-prod-app", false},
+		{"*-prod", "web-prod-eu", false},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "a/b/x/c", true},
+		{"a*b*c", "acb", false},
+		{"ab*ba", "aba", false},
+		{"web-[12]", "web-[12]", true},
+		{"web-[12]", "web-1", false},
+		{"web-?", "web-1", false},
+	} {
+		if got := globMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
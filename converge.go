@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/smithy-go"
+)
+
+// maxActionAttempts is how many times a mutating action reads the current state and applies the change to an item
+// whose state changed under it, before reporting it conflicted.
+const maxActionAttempts = 3
+
+// Outcomes of a mutating action on an item, in the order they are summarized.
+const (
+	outcomeChanged    = "changed"
+	outcomeConverged  = "already-converged"
+	outcomeSkipped    = "skipped"
+	outcomeConflicted = "conflicted"
+	outcomeFailed     = "failed"
+)
+
+// actionOutcomes is the outcome of a mutating action on every item, so a rerun after an interruption reports the
+// items it left done as already converged instead of failing on them.
+type actionOutcomes struct {
+	// counts holds the number of items per outcome
+	counts map[string]int
+}
+
+// add reports the outcome of the action on an item.
+//
+// w: The writer to report to.
+// item: The item, e.g. an allocation ID.
+// outcome: The outcome.
+// detail: What happened to the item.
+func (o *actionOutcomes) add(w io.Writer, item string, outcome string, detail string) {
+	if o.counts == nil {
+		o.counts = map[string]int{}
+	}
+	o.counts[outcome]++
+	fmt.Fprintf(w, "%-17s %s: %s\n", outcome, item, detail)
+}
+
+// print prints the number of items per outcome.
+//
+// w: The writer to print to.
+// action: The name of the action.
+func (o *actionOutcomes) print(w io.Writer, action string) {
+	fmt.Fprintf(w, "%s: %d changed, %d already converged, %d skipped, %d conflicted, %d failed\n", action,
+		o.counts[outcomeChanged], o.counts[outcomeConverged], o.counts[outcomeSkipped], o.counts[outcomeConflicted], o.counts[outcomeFailed])
+}
+
+// err returns an error when an item is left conflicted or failed.
+//
+// action: The name of the action.
+// error: The number of items left in another state than the desired one, nil when there are none.
+func (o *actionOutcomes) err(action string) error {
+	if n := o.counts[outcomeConflicted] + o.counts[outcomeFailed]; n > 0 {
+		return fmt.Errorf("%s: %d items conflicted or failed, rerun to retry them", action, n)
+	}
+	return nil
+}
+
+// isConflict reports whether a mutating call failed because the item changed since its state was read, so the
+// action must read it again.
+//
+// err: The error of the call.
+// bool: Whether the error is a concurrent modification.
+func isConflict(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "InvalidAssociationID.NotFound", "InvalidAllocationID.NotFound", "InvalidIPAddress.InUse":
+		return true
+	}
+	return false
+}
+
+// isNotFound reports whether a call failed because the Elastic IP allocation does not exist.
+//
+// err: The error of the call.
+// bool: Whether the allocation is gone.
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidAllocationID.NotFound"
+}
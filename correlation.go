@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// correlationIdEnv is the environment variable the correlation ID is read from when -correlation-id is not set.
+const correlationIdEnv = "ENILOOKUP_CORRELATION_ID"
+
+// correlationIdPattern is what a correlation ID may hold, so it can be sent as-is in the SDK user agent.
+var correlationIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// resolveCorrelationId returns the correlation ID of the run: the flag, else the environment variable, else a
+// generated UUIDv4.
+//
+// flagValue: The value of -correlation-id.
+// string: The correlation ID.
+// bool: Whether the ID was generated.
+// error: If the ID given by the flag or the environment variable holds other characters than letters, digits
+// and ._:- or is longer than 128 characters.
+func resolveCorrelationId(flagValue string) (string, bool, error) {
+	id := flagValue
+	if id == "" {
+		id = os.Getenv(correlationIdEnv)
+	}
+	if id == "" {
+		generated, err := newUUIDv4()
+		return generated, true, err
+	}
+	if !correlationIdPattern.MatchString(id) {
+		return "", false, fmt.Errorf("%q: only letters, digits and ._:- are allowed, up to 128 characters", id)
+	}
+	return id, false, nil
+}
+
+// reportedCorrelationId returns the correlation ID stamped on the output.
+//
+// A generated ID differs on every run, so -no-timestamps leaves it out like the timestamps. An ID given by the
+// flag or the environment variable is always stamped.
+//
+// correlationId: The correlation ID of the run.
+// generated: Whether the ID was generated.
+// noTimestamps: Whether -no-timestamps is set.
+// string: The ID, empty when it is left out.
+func reportedCorrelationId(correlationId string, generated bool, noTimestamps bool) string {
+	if generated && noTimestamps {
+		return ""
+	}
+	return correlationId
+}
+
+// newUUIDv4 generates a random UUID.
+//
+// string: The UUID in its canonical form.
+// error: If the random source fails.
+func newUUIDv4() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// Set the version 4 and the RFC 4122 variant
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// correlationAPIOptions returns the SDK middleware adding the correlation ID to the user agent of every call, where
+// CloudTrail records it.
+//
+// id: The correlation ID.
+// []func(*middleware.Stack) error: The API options to add to the AWS configuration.
+func correlationAPIOptions(id string) []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		awsmiddleware.AddUserAgentKeyValue("correlation-id", id),
+	}
+}
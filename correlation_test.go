@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestReportedCorrelationId checks that -no-timestamps only leaves out a generated correlation ID.
+func TestReportedCorrelationId(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		flagValue    string
+		env          string
+		noTimestamps bool
+		want         string
+	}{
+		{"flag", "job-1", "", false, "job-1"},
+		{"flag with -no-timestamps", "job-1", "", true, "job-1"},
+		{"environment with -no-timestamps", "", "job-2", true, "job-2"},
+		{"generated with -no-timestamps", "", "", true, ""},
+	} {
+		t.Setenv(correlationIdEnv, tt.env)
+		id, generated, err := resolveCorrelationId(tt.flagValue)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := reportedCorrelationId(id, generated, tt.noTimestamps); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// A generated ID is stamped without -no-timestamps
+	t.Setenv(correlationIdEnv, "")
+	id, generated, err := resolveCorrelationId("")
+	if err != nil || !generated || reportedCorrelationId(id, generated, false) != id {
+		t.Errorf("generated %q, %v, %v, want it stamped", id, generated, err)
+	}
+
+	// An explicit ID ends up in the JSON metadata of a run without timestamps
+	run := testRun(false)
+	stripTimestamps(&run)
+	opts := renderOptions{Output: "json", CorrelationId: reportedCorrelationId("job-1", false, true)}
+	var b bytes.Buffer
+	if err := renderers["json"].Render(&b, run, opts); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || decoded.Metadata.CorrelationId != "job-1" {
+		t.Errorf("metadata %+v, %v, want the correlation ID job-1", decoded.Metadata, err)
+	}
+}
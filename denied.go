@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Statuses of the lookup of a security group.
+const (
+	groupStatusOk     = "ok"
+	groupStatusDenied = "denied"
+	groupStatusError  = "error"
+)
+
+// encodedAuthorizationMessage matches the encoded authorization message EC2 appends to UnauthorizedOperation errors.
+var encodedAuthorizationMessage = regexp.MustCompile(`Encoded authorization failure message: (\S+)`)
+
+// groupDenial is why the network interfaces of a security group could not be listed, although the group was found.
+//
+// Resource-level IAM conditions can deny DescribeNetworkInterfaces for some VPCs only, so the group is reported
+// as denied rather than as having no interfaces.
+type groupDenial struct {
+	Error string `json:"error"`
+	// EncodedMessage is the encoded authorization message of the error, if any.
+	EncodedMessage string `json:"encoded_message,omitempty"`
+	// DecodedMessage is the message decoded with sts:DecodeAuthorizationMessage, showing the
+	// condition that failed, when that call is permitted.
+	DecodedMessage string `json:"decoded_message,omitempty"`
+}
+
+// newGroupDenial describes a denied lookup, decoding its authorization message when permitted.
+//
+// ctx: The context used for the API call.
+// stsClient: The STS client decoding the message. May be nil, leaving the message encoded.
+// err: The access denied error of the lookup.
+// *groupDenial: The denial.
+func newGroupDenial(ctx context.Context, stsClient *sts.Client, err error) *groupDenial {
+	denial := &groupDenial{Error: err.Error()}
+	match := encodedAuthorizationMessage.FindStringSubmatch(denial.Error)
+	if match == nil {
+		return denial
+	}
+	denial.EncodedMessage = match[1]
+	if stsClient == nil {
+		return denial
+	}
+	decodeAuthorizationMessageOutput, decodeErr := stsClient.DecodeAuthorizationMessage(ctx, &sts.DecodeAuthorizationMessageInput{
+		EncodedMessage: aws.String(denial.EncodedMessage),
+	})
+	if decodeErr == nil {
+		denial.DecodedMessage = aws.ToString(decodeAuthorizationMessageOutput.DecodedMessage)
+	}
+	return denial
+}
+
+// groupStatus returns the status of the lookup of a security group.
+//
+// result: The result of the group.
+// string: groupStatusDenied when listing its interfaces was denied, groupStatusError when it failed for another
+// reason, groupStatusOk otherwise.
+func groupStatus(result groupResult) string {
+	switch {
+	case result.Denial != nil:
+		return groupStatusDenied
+	case result.LookupError != "":
+		return groupStatusError
+	}
+	return groupStatusOk
+}
+
+// countFailed counts the security groups whose lookup failed for another reason than a denial.
+//
+// results: The results of the groups.
+// int: The number of failed groups.
+func countFailed(results []groupResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.LookupError != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// countDenied counts the security groups whose interfaces could not be listed.
+//
+// results: The results of the groups.
+// int: The number of denied groups.
+func countDenied(results []groupResult) int {
+	denied := 0
+	for _, result := range results {
+		if result.Denial != nil {
+			denied++
+		}
+	}
+	return denied
+}
+
+// printDenial prints why the network interfaces of a security group could not be listed.
+//
+// w: The writer to print to.
+// denial: The denial.
+func printDenial(w io.Writer, denial *groupDenial) {
+	fmt.Fprintf(w, "Access denied, the network interfaces could not be listed: %s\n", denial.Error)
+	if denial.DecodedMessage != "" {
+		fmt.Fprintf(w, "  Decoded authorization message: %s\n", denial.DecodedMessage)
+	} else if denial.EncodedMessage != "" {
+		fmt.Fprintf(w, "  Decode the authorization message with: aws sts decode-authorization-message --encoded-message %s\n", denial.EncodedMessage)
+	}
+	fmt.Fprintln(w)
+}
+
+// printLookupError prints why the lookup of the network interfaces of a security group failed.
+//
+// w: The writer to print to.
+// message: The error of the lookup.
+func printLookupError(w io.Writer, message string) {
+	fmt.Fprintf(w, "Lookup failed, the network interfaces could not be listed: %s\n\n", message)
+}
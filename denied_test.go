@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// statusRun returns a run with a group of every status: ok, denied and error.
+//
+// scanResult: The run.
+func statusRun() scanResult {
+	group := testGroup("sg-1", "web")
+	denied := testResult("eu-west-1", "db")
+	denied.Denial = newGroupDenial(context.Background(), nil, errors.New("UnauthorizedOperation: You are not authorized to perform this operation. Encoded authorization failure message: abc123"))
+	failed := testResult("eu-west-1", "cache")
+	failed.LookupError = "InvalidParameterValue: injected failure"
+	return scanResult{
+		Results:       []groupResult{testResult("eu-west-1", "web", testInterface("eni-1", "", group)), denied, failed},
+		FailedRegions: []regionFailure{},
+		Warnings:      []runWarning{},
+	}
+}
+
+func TestGroupStatuses(t *testing.T) {
+	run := statusRun()
+	for i, want := range []string{groupStatusOk, groupStatusDenied, groupStatusError} {
+		if got := groupStatus(run.Results[i]); got != want {
+			t.Errorf("%s: status %s, want %s", run.Results[i].Selector.GroupName, got, want)
+		}
+	}
+	if countDenied(run.Results) != 1 || countFailed(run.Results) != 1 {
+		t.Errorf("%d denied and %d failed groups, want 1 each", countDenied(run.Results), countFailed(run.Results))
+	}
+
+	var decoded report
+	if err := json.Unmarshal(renderTestRun(t, "json", statusRun()), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, group := range decoded.Groups {
+		got = append(got, group.SecurityGroupName+" "+group.Status)
+	}
+	if strings.Join(got, ", ") != "web ok, db denied, cache error" {
+		t.Errorf("JSON statuses %v", got)
+	}
+	if len(decoded.Groups) == 3 {
+		if decoded.Groups[0].Denial != nil || decoded.Groups[0].Error != "" {
+			t.Errorf("ok group with denial %+v, error %q", decoded.Groups[0].Denial, decoded.Groups[0].Error)
+		}
+		if denial := decoded.Groups[1].Denial; denial == nil || denial.EncodedMessage != "abc123" || decoded.Groups[1].Error != "" {
+			t.Errorf("denied group with denial %+v, error %q", denial, decoded.Groups[1].Error)
+		}
+		if decoded.Groups[2].Denial != nil || decoded.Groups[2].Error != "InvalidParameterValue: injected failure" {
+			t.Errorf("failed group with denial %+v, error %q", decoded.Groups[2].Denial, decoded.Groups[2].Error)
+		}
+	}
+
+	text := string(renderTestRun(t, "text", statusRun()))
+	for _, line := range []string{
+		"NetworkInterface ID: eni-1",
+		"Access denied, the network interfaces could not be listed: UnauthorizedOperation",
+		"Decode the authorization message with: aws sts decode-authorization-message --encoded-message abc123",
+		"Lookup failed, the network interfaces could not be listed: InvalidParameterValue: injected failure",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("text output lacks %q:\n%s", line, text)
+		}
+	}
+	if strings.Count(text, "could not be listed") != 2 {
+		t.Errorf("text output reports the ok group as not listed:\n%s", text)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// sortResults puts everything the API returns in no particular order into a fixed order, so identical cloud state
+// renders to byte-identical output in every format.
+//
+// The order of the groups is set by sortSections. The network interfaces are sorted by ID, and their tags,
+// security groups, private IP addresses and permissions by key, group ID, address and permission ID.
+//
+// results: The network interfaces found per security group, sorted in place.
+func sortResults(results []groupResult) {
+	for _, result := range results {
+		sort.SliceStable(result.NetworkInterfaces, func(i, j int) bool {
+			return aws.ToString(result.NetworkInterfaces[i].NetworkInterfaceId) < aws.ToString(result.NetworkInterfaces[j].NetworkInterfaceId)
+		})
+		for _, networkInterface := range result.NetworkInterfaces {
+			tags := networkInterface.TagSet
+			sort.SliceStable(tags, func(i, j int) bool { return aws.ToString(tags[i].Key) < aws.ToString(tags[j].Key) })
+			groups := networkInterface.Groups
+			sort.SliceStable(groups, func(i, j int) bool { return aws.ToString(groups[i].GroupId) < aws.ToString(groups[j].GroupId) })
+			addresses := networkInterface.PrivateIpAddresses
+			sort.SliceStable(addresses, func(i, j int) bool {
+				return aws.ToString(addresses[i].PrivateIpAddress) < aws.ToString(addresses[j].PrivateIpAddress)
+			})
+		}
+		for _, permissions := range result.Permissions {
+			sort.SliceStable(permissions, func(i, j int) bool { return permissions[i].PermissionId < permissions[j].PermissionId })
+		}
+	}
+}
+
+// sortSections puts the security group sections in their deterministic order: by region, in the order the regions
+// were scanned, then by the ordinal of their selector, whatever order the lookups completed in.
+//
+// results: The network interfaces found per security group, sorted in place.
+// regions: The regions, in scan order.
+func sortSections(results []groupResult, regions []string) {
+	position := map[string]int{}
+	for i, region := range regions {
+		position[region] = i
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Region != results[j].Region {
+			return position[results[i].Region] < position[results[j].Region]
+		}
+		return results[i].Selector.Ordinal < results[j].Selector.Ordinal
+	})
+}
+
+// printResolvedOrder prints the order the sections of a region are rendered in, for -explain.
+//
+// w: The writer to print to.
+// region: The region.
+// selectors: The selectors resolved in the region.
+func printResolvedOrder(w io.Writer, region string, selectors []enilookup.Selector) {
+	fmt.Fprintf(w, "Resolved order (%s):\n", region)
+	for _, selector := range selectors {
+		if selector.MatchedOn != "" {
+			fmt.Fprintf(w, "  %d. %s, matched on %s\n", selector.Ordinal+1, selector.Key(), selector.MatchedOn)
+			continue
+		}
+		fmt.Fprintf(w, "  %d. %s\n", selector.Ordinal+1, selector.Key())
+	}
+}
+
+// roundPercent rounds a percentage to the single decimal every output prints, so the JSON outputs do not carry
+// floating point noise.
+//
+// percent: The percentage.
+// float64: The rounded percentage.
+func roundPercent(percent float64) float64 {
+	return math.Round(percent*10) / 10
+}
+
+// stripTimestamps removes every timestamp from a run, for -no-timestamps: when it ran, when the passes of -verify
+// ran, when the churn snapshots were taken and when the interfaces were first and last seen.
+//
+// run: What the run found, modified in place.
+func stripTimestamps(run *scanResult) {
+	run.ScannedAt = nil
+	run.Sightings = nil
+	if run.Verification != nil {
+		run.Verification.FirstPassAt = nil
+		run.Verification.SecondPassAt = nil
+	}
+	for _, result := range run.Results {
+		if result.Churn != nil {
+			result.Churn.PreviousAt = nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// renderTestRun renders a run the way scan leaves it, with its sections and interfaces sorted.
+//
+// t: The test.
+// name: The renderer.
+// run: The run, sorted in place.
+// []byte: The output.
+func renderTestRun(t *testing.T, name string, run scanResult) []byte {
+	t.Helper()
+	sortSections(run.Results, []string{"eu-west-1", "us-east-1"})
+	sortResults(run.Results)
+	opts := renderOptions{
+		Output:     name,
+		AccountId:  "123456789012",
+		ShowRegion: true,
+		Board:      newBoardState(false),
+	}
+	if name == "template" {
+		tmpl, err := parseTemplate("", "templates/managed-by.tmpl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Template, opts.TemplateScope = tmpl, templateScopeRun
+	}
+	if name == "csv" {
+		opts.RollupTags = []string{"env", "app"}
+	}
+	var b bytes.Buffer
+	if err := renderers[name].Render(&b, run, opts); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return b.Bytes()
+}
+
+func TestRenderersDeterministic(t *testing.T) {
+	for _, name := range rendererNames() {
+		t.Run(name, func(t *testing.T) {
+			first := renderTestRun(t, name, testRun(false))
+			if len(first) == 0 {
+				t.Fatal("no output")
+			}
+			if again := renderTestRun(t, name, testRun(false)); !bytes.Equal(again, first) {
+				t.Errorf("second render differs:\n%s\nfirst:\n%s", again, first)
+			}
+			if shuffled := renderTestRun(t, name, testRun(true)); !bytes.Equal(shuffled, first) {
+				t.Errorf("render of the shuffled run differs:\n%s\nin order:\n%s", shuffled, first)
+			}
+		})
+	}
+}
+
+// mixedSelectors resolves a mixed selector set against a fake: a group ID, a name and a name matching the Name
+// tag of two groups, in that order.
+//
+// t: The test.
+// []enilookup.Selector: The resolved selectors.
+func mixedSelectors(t *testing.T) []enilookup.Selector {
+	t.Helper()
+	named := func(groupId string, groupName string, nameTag string) types.SecurityGroup {
+		group := testGroup(groupId, groupName)
+		if nameTag != "" {
+			group.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(nameTag)}}
+		}
+		return group
+	}
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		named("sg-0000000000000000c", "shop-b", "shop"),
+		named("sg-0000000000000000a", "shop-a", "shop"),
+		named("sg-0000000000000000b", "db", ""),
+		named("sg-0000000000000000d", "cache", ""),
+	)
+	selectors, err := enilookup.ResolveWith(context.Background(), fake, []string{"sg-0000000000000000d", "db", "shop"}, enilookup.ResolveOptions{MatchOn: enilookup.MatchBoth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return selectors
+}
+
+func TestPrintResolvedOrder(t *testing.T) {
+	var b strings.Builder
+	printResolvedOrder(&b, "eu-west-1", mixedSelectors(t))
+	want := `Resolved order (eu-west-1):
+  1. sg-0000000000000000d
+  2. db (sg-0000000000000000b), matched on GroupName
+  3. shop (sg-0000000000000000a), matched on Name tag
+  4. shop (sg-0000000000000000c), matched on Name tag
+`
+	if b.String() != want {
+		t.Errorf("resolved order:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+// TestSectionOrderUnderConcurrency renders the same mixed selector set many times at once, with the sections
+// completing in a different order every time, and checks that every render is identical. Run it with -race.
+func TestSectionOrderUnderConcurrency(t *testing.T) {
+	selectors := mixedSelectors(t)
+	regions := []string{"eu-west-1", "us-east-1"}
+	const runs = 32
+	outputs := make([][]byte, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run := scanResult{FailedRegions: []regionFailure{}, Warnings: []runWarning{}}
+			for _, region := range regions {
+				for _, selector := range selectors {
+					networkInterface := testInterface("eni-"+selector.GroupId, "", testGroup(selector.GroupId, selector.Input))
+					run.Results = append(run.Results, groupResult{Region: region, Selector: selector, NetworkInterfaces: []types.NetworkInterface{networkInterface}, Found: 1})
+				}
+			}
+			// Completed in an order of their own
+			rand.New(rand.NewSource(int64(i))).Shuffle(len(run.Results), func(a, b int) {
+				run.Results[a], run.Results[b] = run.Results[b], run.Results[a]
+			})
+			sortSections(run.Results, regions)
+			sortResults(run.Results)
+			var b bytes.Buffer
+			if err := render(&b, run, renderOptions{Output: "json", ShowRegion: true}); err != nil {
+				t.Error(err)
+			}
+			outputs[i] = b.Bytes()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, output := range outputs[1:] {
+		if !bytes.Equal(output, outputs[0]) {
+			t.Fatalf("render %d differs:\n%s\nfirst:\n%s", i+1, output, outputs[0])
+		}
+	}
+	first := string(outputs[0])
+	position := func(s string) int { return strings.Index(first, s) }
+	if !(position(`"security_group_id": "sg-0000000000000000d"`) < position(`"security_group_id": "sg-0000000000000000b"`) &&
+		position(`"security_group_id": "sg-0000000000000000b"`) < position(`"security_group_id": "sg-0000000000000000a"`) &&
+		position(`"security_group_id": "sg-0000000000000000a"`) < position(`"security_group_id": "sg-0000000000000000c"`) &&
+		position(`"region": "eu-west-1"`) < position(`"region": "us-east-1"`)) {
+		t.Errorf("sections out of the resolved order:\n%s", first)
+	}
+}
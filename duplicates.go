@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+// collapseDuplicateSections leaves out the sections of the selectors selecting groups already reported by another
+// section of the same region, as when a wrapper passes both a group name and the ID of that group.
+//
+// A section is left out when its groups are all among the groups of another section, found through the groups of
+// the interfaces, and that section selects more groups or comes first in input order. Every section left out is
+// reported on stderr.
+//
+// results: The network interfaces found per security group, in input order.
+// []groupResult: The results without the duplicate sections.
+func collapseDuplicateSections(results []groupResult) []groupResult {
+	index := newGroupIndex(results)
+	kept := []groupResult{}
+	for i, result := range results {
+		if duplicateOf := coveringSection(results, index, i); duplicateOf != nil {
+			warn(runWarning{
+				Code:    warningDuplicateSection,
+				Subject: "section left out",
+				Region:  result.Region,
+				Group:   result.Selector.Key(),
+				Message: fmt.Sprintf("%s (%s) selects security groups already reported under %s, its section was left out",
+					result.Selector.Key(), result.Region, duplicateOf.Selector.Key()),
+			})
+			continue
+		}
+		kept = append(kept, result)
+	}
+	return kept
+}
+
+// coveringSection returns the section whose groups include every group of a section, if any.
+//
+// results: The network interfaces found per security group, in input order.
+// index: The groups of the interfaces of the results.
+// i: The position of the section in the results.
+// *groupResult: The covering section, nil when the section selects groups of its own.
+func coveringSection(results []groupResult, index *groupIndex, i int) *groupResult {
+	result := results[i]
+	if groupStatus(result) != groupStatusOk {
+		return nil
+	}
+	groupIds, _ := index.selected(result.Selector)
+	if len(groupIds) == 0 {
+		return nil
+	}
+	for j, other := range results {
+		if j == i || other.Region != result.Region || groupStatus(other) != groupStatusOk {
+			continue
+		}
+		otherGroupIds, otherSet := index.selected(other.Selector)
+		if len(otherGroupIds) < len(groupIds) || (len(otherGroupIds) == len(groupIds) && j > i) {
+			continue
+		}
+		covered := true
+		for _, groupId := range groupIds {
+			if !otherSet[groupId] {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return &results[j]
+		}
+	}
+	return nil
+}
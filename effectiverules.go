@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Source types of an effective rule. Rules of different source types never cover each other.
+const (
+	sourceIpv4          = "ipv4"
+	sourceIpv6          = "ipv6"
+	sourcePrefixList    = "prefix-list"
+	sourceSecurityGroup = "security-group"
+)
+
+// protocolAll is the protocol of the rules allowing every protocol, -1 in the API.
+const protocolAll = "all"
+
+// protocolNames are the names of the protocol numbers the API may return instead of a name.
+var protocolNames = map[string]string{
+	"-1": protocolAll,
+	"1":  "icmp",
+	"6":  "tcp",
+	"17": "udp",
+	"58": "icmpv6",
+}
+
+// instanceRules is the consolidated ingress allow-list of an instance, the union of the ingress rules of every
+// security group on every network interface of the instance.
+type instanceRules struct {
+	SecurityGroupIds []string        `json:"security_group_ids"`
+	Rules            []effectiveRule `json:"rules"`
+}
+
+// effectiveRule is a rule of an allow-list, allowing one source on a protocol and a range.
+//
+// For tcp and udp, FromPort and ToPort are the port range, 0-65535 for every port. For icmp and icmpv6 they are
+// the type and the code, -1 for every type or code. For the other protocols, and for all, both are -1.
+type effectiveRule struct {
+	Protocol   string `json:"protocol"`
+	FromPort   int32  `json:"from_port"`
+	ToPort     int32  `json:"to_port"`
+	Source     string `json:"source"`
+	SourceType string `json:"source_type"`
+}
+
+// getEffectiveRules computes the consolidated ingress allow-list of every instance.
+//
+// The security groups are gathered across every network interface of the instance, including those that are not
+// in the selected groups, so the instances must have been resolved. Egress rules are ignored.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region of the instances.
+// instances: The resolved instances keyed by instance ID.
+// map[string]instanceRules: The allow-lists keyed by instance ID.
+// error: If the security groups cannot be described.
+func getEffectiveRules(ctx context.Context, ec2Client *ec2.Client, instances map[string]types.Instance) (map[string]instanceRules, error) {
+	// Gather the groups of every instance
+	groupIds := map[string][]string{}
+	uniqueGroupIds := []string{}
+	seen := map[string]bool{}
+	for instanceId, instance := range instances {
+		instanceGroupIds := instanceSecurityGroupIds(instance)
+		groupIds[instanceId] = instanceGroupIds
+		for _, groupId := range instanceGroupIds {
+			if !seen[groupId] {
+				seen[groupId] = true
+				uniqueGroupIds = append(uniqueGroupIds, groupId)
+			}
+		}
+	}
+	effective := map[string]instanceRules{}
+	if len(uniqueGroupIds) == 0 {
+		return effective, nil
+	}
+
+	// Describe the ingress rules of the groups
+	rules := map[string][]effectiveRule{}
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: uniqueGroupIds,
+	})
+	for paginator.HasMorePages() {
+		describeSecurityGroupsOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, securityGroup := range describeSecurityGroupsOutput.SecurityGroups {
+			rules[aws.ToString(securityGroup.GroupId)] = effectiveRulesOf(securityGroup.IpPermissions)
+		}
+	}
+
+	for instanceId, instanceGroupIds := range groupIds {
+		merged := []effectiveRule{}
+		for _, groupId := range instanceGroupIds {
+			merged = append(merged, rules[groupId]...)
+		}
+		effective[instanceId] = instanceRules{SecurityGroupIds: instanceGroupIds, Rules: mergeRules(merged)}
+	}
+	return effective, nil
+}
+
+// instanceSecurityGroupIds returns the IDs of the security groups on the network interfaces of an instance.
+//
+// instance: The resolved instance.
+// []string: The unique group IDs, sorted.
+func instanceSecurityGroupIds(instance types.Instance) []string {
+	seen := map[string]bool{}
+	groupIds := []string{}
+	add := func(groups []types.GroupIdentifier) {
+		for _, group := range groups {
+			groupId := aws.ToString(group.GroupId)
+			if groupId != "" && !seen[groupId] {
+				seen[groupId] = true
+				groupIds = append(groupIds, groupId)
+			}
+		}
+	}
+	add(instance.SecurityGroups)
+	for _, networkInterface := range instance.NetworkInterfaces {
+		add(networkInterface.Groups)
+	}
+	sort.Strings(groupIds)
+	return groupIds
+}
+
+// effectiveRulesOf converts ingress rules to effective rules, one per source.
+//
+// permissions: The ingress rules.
+// []effectiveRule: The effective rules.
+func effectiveRulesOf(permissions []types.IpPermission) []effectiveRule {
+	rules := []effectiveRule{}
+	for _, permission := range permissions {
+		protocol, fromPort, toPort := normalizePorts(permission)
+		add := func(source string, sourceType string) {
+			rules = append(rules, effectiveRule{Protocol: protocol, FromPort: fromPort, ToPort: toPort, Source: source, SourceType: sourceType})
+		}
+		for _, ipRange := range permission.IpRanges {
+			add(normalizeCidr(aws.ToString(ipRange.CidrIp)), sourceIpv4)
+		}
+		for _, ipv6Range := range permission.Ipv6Ranges {
+			add(normalizeCidr(aws.ToString(ipv6Range.CidrIpv6)), sourceIpv6)
+		}
+		for _, prefixList := range permission.PrefixListIds {
+			add(aws.ToString(prefixList.PrefixListId), sourcePrefixList)
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			add(aws.ToString(pair.GroupId), sourceSecurityGroup)
+		}
+	}
+	return rules
+}
+
+// normalizePorts returns the protocol and range of an ingress rule in the form of an effectiveRule.
+//
+// permission: The ingress rule.
+// string: The protocol, by name when it has one.
+// int32: The first port, or the icmp type.
+// int32: The last port, or the icmp code.
+func normalizePorts(permission types.IpPermission) (string, int32, int32) {
+	protocol := strings.ToLower(aws.ToString(permission.IpProtocol))
+	if name, ok := protocolNames[protocol]; ok {
+		protocol = name
+	}
+	from, to := aws.ToInt32(permission.FromPort), aws.ToInt32(permission.ToPort)
+	switch protocol {
+	case "tcp", "udp":
+		if permission.FromPort == nil || (from == -1 && to == -1) {
+			return protocol, 0, 65535
+		}
+		return protocol, from, to
+	case "icmp", "icmpv6":
+		if permission.FromPort == nil || from == -1 {
+			return protocol, -1, -1
+		}
+		if permission.ToPort == nil {
+			to = -1
+		}
+		return protocol, from, to
+	default:
+		return protocol, -1, -1
+	}
+}
+
+// normalizeCidr returns the canonical form of a CIDR, with the host bits cleared, so equal ranges compare equal.
+//
+// cidr: The CIDR.
+// string: The canonical CIDR, or the CIDR as given when it cannot be parsed.
+func normalizeCidr(cidr string) string {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return cidr
+	}
+	return prefix.Masked().String()
+}
+
+// mergeRules consolidates effective rules into an allow-list allowing exactly the same traffic.
+//
+// The duplicates are removed, the overlapping and adjacent tcp and udp port ranges of the same protocol and
+// source are merged, then every rule covered by another one, by its protocol, its range and its source, is
+// removed. An IPv4 source never covers an IPv6 one and the other way round.
+//
+// rules: The effective rules of every security group of an instance.
+// []effectiveRule: The consolidated rules, sorted by protocol, range and source.
+func mergeRules(rules []effectiveRule) []effectiveRule {
+	// Merge the port ranges of the same protocol and source
+	type rangeKey struct {
+		protocol   string
+		source     string
+		sourceType string
+	}
+	ranges := map[rangeKey][]effectiveRule{}
+	merged := []effectiveRule{}
+	seen := map[effectiveRule]bool{}
+	for _, rule := range rules {
+		if seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		if rule.Protocol == "tcp" || rule.Protocol == "udp" {
+			key := rangeKey{protocol: rule.Protocol, source: rule.Source, sourceType: rule.SourceType}
+			ranges[key] = append(ranges[key], rule)
+			continue
+		}
+		merged = append(merged, rule)
+	}
+	for _, keyed := range ranges {
+		sort.Slice(keyed, func(i, j int) bool {
+			return keyed[i].FromPort < keyed[j].FromPort
+		})
+		current := keyed[0]
+		for _, rule := range keyed[1:] {
+			// Overlapping or adjacent
+			if int64(rule.FromPort) <= int64(current.ToPort)+1 {
+				current.ToPort = max(current.ToPort, rule.ToPort)
+				continue
+			}
+			merged = append(merged, current)
+			current = rule
+		}
+		merged = append(merged, current)
+	}
+
+	// Remove the rules covered by another one
+	consolidated := []effectiveRule{}
+	for i, rule := range merged {
+		covered := false
+		for j, other := range merged {
+			if i != j && covers(other, rule) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			consolidated = append(consolidated, rule)
+		}
+	}
+
+	sort.Slice(consolidated, func(i, j int) bool {
+		a, b := consolidated[i], consolidated[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.FromPort != b.FromPort {
+			return a.FromPort < b.FromPort
+		}
+		if a.ToPort != b.ToPort {
+			return a.ToPort < b.ToPort
+		}
+		if a.SourceType != b.SourceType {
+			return a.SourceType < b.SourceType
+		}
+		return a.Source < b.Source
+	})
+	return consolidated
+}
+
+// covers reports whether a rule allows all the traffic another rule allows.
+//
+// Distinct rules never cover each other, so covered rules can be removed in any order.
+//
+// a: The covering rule.
+// b: The covered rule.
+// bool: Whether a covers b.
+func covers(a effectiveRule, b effectiveRule) bool {
+	if !sourceCovers(a, b) {
+		return false
+	}
+	if a.Protocol == protocolAll {
+		return true
+	}
+	if a.Protocol != b.Protocol {
+		return false
+	}
+	switch a.Protocol {
+	case "tcp", "udp":
+		return a.FromPort <= b.FromPort && b.ToPort <= a.ToPort
+	case "icmp", "icmpv6":
+		if a.FromPort == -1 {
+			return true
+		}
+		return a.FromPort == b.FromPort && (a.ToPort == -1 || a.ToPort == b.ToPort)
+	default:
+		return true
+	}
+}
+
+// sourceCovers reports whether the source of a rule includes the source of another rule.
+//
+// a: The covering rule.
+// b: The covered rule.
+// bool: Whether the CIDR of a contains the CIDR of b, or both have the same prefix list or security group.
+func sourceCovers(a effectiveRule, b effectiveRule) bool {
+	if a.SourceType != b.SourceType {
+		return false
+	}
+	if a.SourceType != sourceIpv4 && a.SourceType != sourceIpv6 {
+		return a.Source == b.Source
+	}
+	prefixA, errA := netip.ParsePrefix(a.Source)
+	prefixB, errB := netip.ParsePrefix(b.Source)
+	if errA != nil || errB != nil {
+		return a.Source == b.Source
+	}
+	return prefixA.Bits() <= prefixB.Bits() && prefixA.Contains(prefixB.Addr())
+}
+
+// describeRange returns the range of an effective rule in human readable form.
+//
+// rule: The effective rule.
+// string: The range.
+func describeRange(rule effectiveRule) string {
+	switch rule.Protocol {
+	case "tcp", "udp":
+		switch {
+		case rule.FromPort == 0 && rule.ToPort == 65535:
+			return "all"
+		case rule.FromPort == rule.ToPort:
+			return fmt.Sprintf("%d", rule.FromPort)
+		default:
+			return fmt.Sprintf("%d-%d", rule.FromPort, rule.ToPort)
+		}
+	case "icmp", "icmpv6":
+		switch {
+		case rule.FromPort == -1:
+			return "all"
+		case rule.ToPort == -1:
+			return fmt.Sprintf("type %d", rule.FromPort)
+		default:
+			return fmt.Sprintf("type %d code %d", rule.FromPort, rule.ToPort)
+		}
+	default:
+		return "all"
+	}
+}
+
+// printEffectiveRules prints the consolidated allow-list of an instance.
+//
+// w: The writer to print to.
+// rules: The allow-list.
+func printEffectiveRules(w io.Writer, rules instanceRules) {
+	fmt.Fprintf(w, "  Effective ingress rules (from %s):\n", strings.Join(rules.SecurityGroupIds, ", "))
+	if len(rules.Rules) == 0 {
+		fmt.Fprintln(w, "    no ingress rules")
+	}
+	for _, rule := range rules.Rules {
+		source := rule.Source
+		if source == "0.0.0.0/0" || source == "::/0" {
+			source = fmt.Sprintf("%s (%s)", rule.Source, openToInternet)
+		}
+		fmt.Fprintf(w, "    %s %s from %s\n", rule.Protocol, describeRange(rule), source)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// tcpRule returns a tcp rule from an IPv4 source.
+//
+// fromPort: The first port.
+// toPort: The last port.
+// cidr: The source.
+// effectiveRule: The rule.
+func tcpRule(fromPort int32, toPort int32, cidr string) effectiveRule {
+	return effectiveRule{Protocol: "tcp", FromPort: fromPort, ToPort: toPort, Source: cidr, SourceType: sourceIpv4}
+}
+
+func TestNormalizePorts(t *testing.T) {
+	for _, tc := range []struct {
+		permission types.IpPermission
+		want       string
+	}{
+		{types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(443), ToPort: aws.Int32(443)}, "tcp 443-443"},
+		{types.IpPermission{IpProtocol: aws.String("6"), FromPort: aws.Int32(80), ToPort: aws.Int32(90)}, "tcp 80-90"},
+		{types.IpPermission{IpProtocol: aws.String("UDP")}, "udp 0-65535"},
+		{types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(-1), ToPort: aws.Int32(-1)}, "tcp 0-65535"},
+		{types.IpPermission{IpProtocol: aws.String("-1"), FromPort: aws.Int32(0), ToPort: aws.Int32(0)}, "all -1--1"},
+		{types.IpPermission{IpProtocol: aws.String("icmp"), FromPort: aws.Int32(-1), ToPort: aws.Int32(4)}, "icmp -1--1"},
+		{types.IpPermission{IpProtocol: aws.String("1"), FromPort: aws.Int32(3)}, "icmp 3--1"},
+		{types.IpPermission{IpProtocol: aws.String("58"), FromPort: aws.Int32(128), ToPort: aws.Int32(0)}, "icmpv6 128-0"},
+		{types.IpPermission{IpProtocol: aws.String("50"), FromPort: aws.Int32(1), ToPort: aws.Int32(2)}, "50 -1--1"},
+	} {
+		protocol, fromPort, toPort := normalizePorts(tc.permission)
+		if got := fmt.Sprintf("%s %d-%d", protocol, fromPort, toPort); got != tc.want {
+			t.Errorf("%s %v-%v: %s, want %s", aws.ToString(tc.permission.IpProtocol), aws.ToInt32(tc.permission.FromPort), aws.ToInt32(tc.permission.ToPort), got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeCidr(t *testing.T) {
+	for cidr, want := range map[string]string{
+		"10.0.0.0/8":      "10.0.0.0/8",
+		"10.1.2.3/8":      "10.0.0.0/8",
+		"2001:db8::1/32":  "2001:db8::/32",
+		"192.168.0.10/32": "192.168.0.10/32",
+		"not-a-cidr":      "not-a-cidr",
+	} {
+		if got := normalizeCidr(cidr); got != want {
+			t.Errorf("normalizeCidr(%s) = %s, want %s", cidr, got, want)
+		}
+	}
+}
+
+func TestEffectiveRulesOf(t *testing.T) {
+	rules := effectiveRulesOf([]types.IpPermission{{
+		IpProtocol:       aws.String("tcp"),
+		FromPort:         aws.Int32(22),
+		ToPort:           aws.Int32(22),
+		IpRanges:         []types.IpRange{{CidrIp: aws.String("10.1.2.3/16")}},
+		Ipv6Ranges:       []types.Ipv6Range{{CidrIpv6: aws.String("2001:db8::/32")}},
+		PrefixListIds:    []types.PrefixListId{{PrefixListId: aws.String("pl-1")}},
+		UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-1")}},
+	}})
+	want := []effectiveRule{
+		tcpRule(22, 22, "10.1.0.0/16"),
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, Source: "2001:db8::/32", SourceType: sourceIpv6},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, Source: "pl-1", SourceType: sourcePrefixList},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, Source: "sg-1", SourceType: sourceSecurityGroup},
+	}
+	if !slices.Equal(rules, want) {
+		t.Errorf("rules\n%+v\nwant\n%+v", rules, want)
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	allFrom := func(source string, sourceType string) effectiveRule {
+		return effectiveRule{Protocol: protocolAll, FromPort: -1, ToPort: -1, Source: source, SourceType: sourceType}
+	}
+	icmp := func(icmpType int32, code int32) effectiveRule {
+		return effectiveRule{Protocol: "icmp", FromPort: icmpType, ToPort: code, Source: "10.0.0.0/8", SourceType: sourceIpv4}
+	}
+	udp := tcpRule(53, 53, "10.0.0.0/8")
+	udp.Protocol = "udp"
+	ipv6 := func(rule effectiveRule, cidr string) effectiveRule {
+		rule.Source, rule.SourceType = cidr, sourceIpv6
+		return rule
+	}
+
+	for _, tc := range []struct {
+		name  string
+		rules []effectiveRule
+		want  []effectiveRule
+	}{
+		{"duplicates", []effectiveRule{tcpRule(443, 443, "10.0.0.0/8"), tcpRule(443, 443, "10.0.0.0/8")}, []effectiveRule{tcpRule(443, 443, "10.0.0.0/8")}},
+		{"overlapping ranges", []effectiveRule{tcpRule(85, 100, "10.0.0.0/8"), tcpRule(80, 90, "10.0.0.0/8")}, []effectiveRule{tcpRule(80, 100, "10.0.0.0/8")}},
+		{"adjacent ranges", []effectiveRule{tcpRule(80, 89, "10.0.0.0/8"), tcpRule(90, 100, "10.0.0.0/8")}, []effectiveRule{tcpRule(80, 100, "10.0.0.0/8")}},
+		{"chained ranges", []effectiveRule{tcpRule(1, 10, "10.0.0.0/8"), tcpRule(21, 30, "10.0.0.0/8"), tcpRule(11, 20, "10.0.0.0/8")}, []effectiveRule{tcpRule(1, 30, "10.0.0.0/8")}},
+		{"range inside a range", []effectiveRule{tcpRule(1000, 2000, "10.0.0.0/8"), tcpRule(1200, 1300, "10.0.0.0/8")}, []effectiveRule{tcpRule(1000, 2000, "10.0.0.0/8")}},
+		{"disjoint ranges", []effectiveRule{tcpRule(91, 100, "10.0.0.0/8"), tcpRule(80, 89, "10.0.0.0/8")}, []effectiveRule{tcpRule(80, 89, "10.0.0.0/8"), tcpRule(91, 100, "10.0.0.0/8")}},
+		{"adjacent ranges of other sources", []effectiveRule{tcpRule(80, 89, "10.0.0.0/8"), tcpRule(90, 100, "192.168.0.0/16")}, []effectiveRule{tcpRule(80, 89, "10.0.0.0/8"), tcpRule(90, 100, "192.168.0.0/16")}},
+		{"tcp and udp", []effectiveRule{tcpRule(53, 53, "10.0.0.0/8"), udp}, []effectiveRule{tcpRule(53, 53, "10.0.0.0/8"), udp}},
+		{"contained source", []effectiveRule{tcpRule(443, 443, "10.1.0.0/16"), tcpRule(0, 65535, "10.0.0.0/8")}, []effectiveRule{tcpRule(0, 65535, "10.0.0.0/8")}},
+		{"wider source, narrower range", []effectiveRule{tcpRule(443, 443, "10.0.0.0/8"), tcpRule(0, 65535, "10.1.0.0/16")}, []effectiveRule{tcpRule(0, 65535, "10.1.0.0/16"), tcpRule(443, 443, "10.0.0.0/8")}},
+		{"protocol all covers every protocol", []effectiveRule{tcpRule(22, 22, "10.1.0.0/16"), udp, icmp(-1, -1), allFrom("10.0.0.0/8", sourceIpv4)}, []effectiveRule{allFrom("10.0.0.0/8", sourceIpv4)}},
+		{"protocol all of a narrower source", []effectiveRule{allFrom("10.1.0.0/16", sourceIpv4), tcpRule(22, 22, "10.0.0.0/8")}, []effectiveRule{allFrom("10.1.0.0/16", sourceIpv4), tcpRule(22, 22, "10.0.0.0/8")}},
+		{"IPv4 never covers IPv6", []effectiveRule{allFrom("0.0.0.0/0", sourceIpv4), ipv6(tcpRule(22, 22, ""), "::/0")}, []effectiveRule{allFrom("0.0.0.0/0", sourceIpv4), ipv6(tcpRule(22, 22, ""), "::/0")}},
+		{"IPv6 never covers IPv4", []effectiveRule{allFrom("::/0", sourceIpv6), tcpRule(22, 22, "0.0.0.0/0")}, []effectiveRule{allFrom("::/0", sourceIpv6), tcpRule(22, 22, "0.0.0.0/0")}},
+		{"IPv6 containment", []effectiveRule{ipv6(tcpRule(443, 443, ""), "2001:db8:1::/48"), ipv6(tcpRule(0, 65535, ""), "2001:db8::/32")}, []effectiveRule{ipv6(tcpRule(0, 65535, ""), "2001:db8::/32")}},
+		{"IPv6 adjacent ranges", []effectiveRule{ipv6(tcpRule(80, 89, ""), "::/0"), ipv6(tcpRule(90, 99, ""), "::/0")}, []effectiveRule{ipv6(tcpRule(80, 99, ""), "::/0")}},
+		{"icmp types", []effectiveRule{icmp(3, 4), icmp(3, -1), icmp(8, 0)}, []effectiveRule{icmp(3, -1), icmp(8, 0)}},
+		{"icmp every type", []effectiveRule{icmp(3, 4), icmp(-1, -1), icmp(8, 0)}, []effectiveRule{icmp(-1, -1)}},
+		{"security groups", []effectiveRule{allFrom("sg-2", sourceSecurityGroup), {Protocol: "tcp", FromPort: 22, ToPort: 22, Source: "sg-1", SourceType: sourceSecurityGroup}, allFrom("sg-1", sourceSecurityGroup)}, []effectiveRule{allFrom("sg-1", sourceSecurityGroup), allFrom("sg-2", sourceSecurityGroup)}},
+		{"prefix lists", []effectiveRule{{Protocol: "tcp", FromPort: 443, ToPort: 443, Source: "pl-1", SourceType: sourcePrefixList}, allFrom("pl-2", sourcePrefixList)}, []effectiveRule{allFrom("pl-2", sourcePrefixList), {Protocol: "tcp", FromPort: 443, ToPort: 443, Source: "pl-1", SourceType: sourcePrefixList}}},
+		{"no rules", []effectiveRule{}, []effectiveRule{}},
+	} {
+		got := mergeRules(tc.rules)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s:\n%+v\nwant\n%+v", tc.name, got, tc.want)
+		}
+		// The merge is stable under the order of the rules
+		reversed := slices.Clone(tc.rules)
+		slices.Reverse(reversed)
+		if again := mergeRules(reversed); !slices.Equal(again, got) {
+			t.Errorf("%s: reversed rules merged to\n%+v\nwant\n%+v", tc.name, again, got)
+		}
+	}
+}
+
+func TestDescribeRange(t *testing.T) {
+	icmp := effectiveRule{Protocol: "icmp", FromPort: 3, ToPort: -1}
+	for rule, want := range map[effectiveRule]string{
+		tcpRule(0, 65535, ""):   "all",
+		tcpRule(443, 443, ""):   "443",
+		tcpRule(8000, 8080, ""): "8000-8080",
+		icmp:                    "type 3",
+		{Protocol: "icmp", FromPort: 3, ToPort: 4}:        "type 3 code 4",
+		{Protocol: "icmpv6", FromPort: -1, ToPort: -1}:    "all",
+		{Protocol: protocolAll, FromPort: -1, ToPort: -1}: "all",
+	} {
+		if got := describeRange(rule); got != want {
+			t.Errorf("describeRange(%+v) = %q, want %q", rule, got, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// eipHourlyCost is the hourly price in USD of a public IPv4 address, charged for every Elastic IP.
+const eipHourlyCost = 0.005
+
+// hoursPerMonth is the average number of hours in a month, used for the cost estimates.
+const hoursPerMonth = 730
+
+// Reasons an Elastic IP is idle.
+const (
+	idleEipAvailableInterface = "interface available"
+	idleEipStoppedInstance    = "instance stopped"
+)
+
+// idleEip is an Elastic IP associated with a network interface that serves no traffic.
+type idleEip struct {
+	Region             string  `json:"region"`
+	AllocationId       string  `json:"allocation_id"`
+	AssociationId      string  `json:"association_id"`
+	PublicIp           string  `json:"public_ip"`
+	NetworkInterfaceId string  `json:"network_interface_id"`
+	InstanceId         string  `json:"instance_id,omitempty"`
+	Reason             string  `json:"reason"`
+	MonthlyCost        float64 `json:"estimated_monthly_cost_usd"`
+}
+
+// eipAPI is the part of the EC2 API the Elastic IP audit and release use, implemented by *ec2.Client.
+type eipAPI interface {
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error)
+	ReleaseAddress(ctx context.Context, params *ec2.ReleaseAddressInput, optFns ...func(*ec2.Options)) (*ec2.ReleaseAddressOutput, error)
+}
+
+// trulyIdle reports whether nothing can use the Elastic IP: its interface is not attached to any instance.
+func (e idleEip) trulyIdle() bool {
+	return e.Reason == idleEipAvailableInterface
+}
+
+// getIdleEips finds the Elastic IPs associated with the network interfaces that are available or, when
+// the instances are resolved, attached to a stopped instance.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region.
+// region: The region.
+// results: The network interfaces found per security group in the region.
+// instances: The resolved instances of the region keyed by instance ID. May be empty.
+// []idleEip: The idle Elastic IPs, sorted by allocation ID.
+// error: If DescribeAddresses fails.
+func getIdleEips(ctx context.Context, ec2Client eipAPI, region string, results []groupResult, instances map[string]types.Instance) ([]idleEip, error) {
+	// Keep the interfaces with an Elastic IP that serve no traffic
+	candidates := map[string]idleEip{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			association := networkInterface.Association
+			if association == nil || aws.ToString(association.AllocationId) == "" {
+				continue
+			}
+			eip := idleEip{
+				Region:             region,
+				AllocationId:       aws.ToString(association.AllocationId),
+				PublicIp:           aws.ToString(association.PublicIp),
+				NetworkInterfaceId: aws.ToString(networkInterface.NetworkInterfaceId),
+				MonthlyCost:        eipHourlyCost * hoursPerMonth,
+			}
+			switch {
+			case networkInterface.Status == types.NetworkInterfaceStatusAvailable:
+				eip.Reason = idleEipAvailableInterface
+			case networkInterface.Attachment != nil && isStopped(instances[aws.ToString(networkInterface.Attachment.InstanceId)]):
+				eip.Reason = idleEipStoppedInstance
+				eip.InstanceId = aws.ToString(networkInterface.Attachment.InstanceId)
+			default:
+				continue
+			}
+			candidates[eip.AllocationId] = eip
+		}
+	}
+	if len(candidates) == 0 {
+		return []idleEip{}, nil
+	}
+
+	// Cross-reference the allocations, which also gives the association IDs needed to release them
+	allocationIds := []string{}
+	for allocationId := range candidates {
+		allocationIds = append(allocationIds, allocationId)
+	}
+	describeAddressesOutput, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []types.Filter{{Name: aws.String("allocation-id"), Values: allocationIds}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eips := []idleEip{}
+	for _, address := range describeAddressesOutput.Addresses {
+		eip, ok := candidates[aws.ToString(address.AllocationId)]
+		if !ok || aws.ToString(address.NetworkInterfaceId) != eip.NetworkInterfaceId {
+			// Reassociated since the interfaces were listed
+			continue
+		}
+		eip.AssociationId = aws.ToString(address.AssociationId)
+		eip.PublicIp = aws.ToString(address.PublicIp)
+		eips = append(eips, eip)
+	}
+	sort.Slice(eips, func(i, j int) bool { return eips[i].AllocationId < eips[j].AllocationId })
+	return eips, nil
+}
+
+// isStopped reports whether the instance is stopped.
+//
+// instance: The instance, the zero value when it was not resolved.
+// bool: Whether the instance is stopped.
+func isStopped(instance types.Instance) bool {
+	return instance.State != nil && instance.State.Name == types.InstanceStateNameStopped
+}
+
+// releaseIdleEips disassociates and releases the Elastic IPs of the available network interfaces.
+//
+// The Elastic IPs of stopped instances are only reported: the instance may be started again. Every Elastic IP is
+// read again before it is changed, so a rerun after an interruption reports those already released as converged,
+// and one associated with another interface since the audit is left alone as conflicted.
+//
+// ctx: The context used for the API calls.
+// clientFor: Returns the EC2 client of a region.
+// eips: The idle Elastic IPs.
+// dryRun: Whether to only print what would be released.
+// w: The writer to report every Elastic IP to.
+// error: If an Elastic IP is left conflicted or failed. The others are still released.
+func releaseIdleEips(ctx context.Context, clientFor func(region string) eipAPI, eips []idleEip, dryRun bool, w io.Writer) error {
+	outcomes := &actionOutcomes{}
+	for _, eip := range eips {
+		if !eip.trulyIdle() {
+			outcomes.add(w, eip.AllocationId, outcomeSkipped, fmt.Sprintf("%s on %s in %s, %s", eip.PublicIp, eip.NetworkInterfaceId, eip.Region, eip.Reason))
+			continue
+		}
+		outcome, detail := releaseIdleEip(ctx, clientFor(eip.Region), eip, dryRun)
+		outcomes.add(w, eip.AllocationId, outcome, detail)
+	}
+	outcomes.print(w, "Release Elastic IPs")
+	return outcomes.err("release Elastic IPs")
+}
+
+// releaseIdleEip brings an Elastic IP to its desired state, released, reading its current state before every attempt.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region of the Elastic IP.
+// eip: The idle Elastic IP, as found by the audit.
+// dryRun: Whether to only report what would be done.
+// string: The outcome.
+// string: What happened to the Elastic IP.
+func releaseIdleEip(ctx context.Context, ec2Client eipAPI, eip idleEip, dryRun bool) (string, string) {
+	for attempt := 1; attempt <= maxActionAttempts; attempt++ {
+		// Read the current state of the address
+		output, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{eip.AllocationId}})
+		if isNotFound(err) || (err == nil && len(output.Addresses) == 0) {
+			return outcomeConverged, fmt.Sprintf("%s is already released", eip.PublicIp)
+		}
+		if err != nil {
+			return outcomeFailed, fmt.Sprintf("describe: %v", err)
+		}
+		address := output.Addresses[0]
+		associationId := aws.ToString(address.AssociationId)
+		if associationId != "" && aws.ToString(address.NetworkInterfaceId) != eip.NetworkInterfaceId {
+			return outcomeConflicted, fmt.Sprintf("%s is now associated with %s, not %s", eip.PublicIp, aws.ToString(address.NetworkInterfaceId), eip.NetworkInterfaceId)
+		}
+		if dryRun {
+			return outcomeChanged, fmt.Sprintf("would release %s from %s in %s", eip.PublicIp, eip.NetworkInterfaceId, eip.Region)
+		}
+
+		// Disassociate it, unless an interrupted run already did
+		if associationId != "" {
+			_, err := ec2Client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: aws.String(associationId)})
+			if isConflict(err) {
+				continue
+			}
+			if err != nil {
+				return outcomeFailed, fmt.Sprintf("disassociate: %v", err)
+			}
+		}
+		_, err = ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String(eip.AllocationId)})
+		if isConflict(err) {
+			continue
+		}
+		if err != nil {
+			return outcomeFailed, fmt.Sprintf("release: %v", err)
+		}
+		return outcomeChanged, fmt.Sprintf("released %s from %s in %s", eip.PublicIp, eip.NetworkInterfaceId, eip.Region)
+	}
+	return outcomeConflicted, fmt.Sprintf("%s kept changing over %d attempts", eip.PublicIp, maxActionAttempts)
+}
+
+// printIdleEips prints the idle Elastic IPs and their estimated cost.
+//
+// w: The writer to print to.
+// eips: The idle Elastic IPs, nil when -eip-audit is not set.
+func printIdleEips(w io.Writer, eips []idleEip) {
+	if eips == nil {
+		return
+	}
+	total := 0.0
+	for _, eip := range eips {
+		total += eip.MonthlyCost
+	}
+	fmt.Fprintf(w, "Idle Elastic IPs: %d, about $%.2f per month\n", len(eips), total)
+	for _, eip := range eips {
+		instance := ""
+		if eip.InstanceId != "" {
+			instance = " " + eip.InstanceId
+		}
+		fmt.Fprintf(w, "  %s %s on %s (%s, %s%s): about $%.2f per month\n", eip.AllocationId, eip.PublicIp, eip.NetworkInterfaceId, eip.Region, eip.Reason, instance, eip.MonthlyCost)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// withEip associates an Elastic IP with a test interface.
+//
+// networkInterface: The interface.
+// allocationId: The allocation ID of the Elastic IP.
+// publicIp: Its address.
+// types.NetworkInterface: The interface.
+func withEip(networkInterface types.NetworkInterface, allocationId string, publicIp string) types.NetworkInterface {
+	networkInterface.Association = &types.NetworkInterfaceAssociation{AllocationId: aws.String(allocationId), PublicIp: aws.String(publicIp)}
+	return networkInterface
+}
+
+// eipFake returns a fake holding the Elastic IP of an interface under its association.
+//
+// networkInterfaces: The interfaces, with their Elastic IPs set by withEip.
+// *enitest.Fake: The fake.
+func eipFake(networkInterfaces ...types.NetworkInterface) *enitest.Fake {
+	fake := enitest.New()
+	for _, networkInterface := range networkInterfaces {
+		association := networkInterface.Association
+		fake.AddAddresses(types.Address{
+			AllocationId:       association.AllocationId,
+			AssociationId:      aws.String("eipassoc-" + aws.ToString(networkInterface.NetworkInterfaceId)),
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			PublicIp:           association.PublicIp,
+		})
+	}
+	return fake
+}
+
+func TestReleaseEipsIsMutating(t *testing.T) {
+	if !slices.Contains(mutatingFlags, "release-eips") {
+		t.Error("-release-eips is not a mutating flag, -read-only would let it through")
+	}
+}
+
+func TestGetIdleEips(t *testing.T) {
+	web := testGroup("sg-1", "web")
+	available := withEip(testInterface("eni-available", "", web), "eipalloc-2", "203.0.113.2")
+	stopped := withEip(testInterface("eni-stopped", "i-stopped", web), "eipalloc-1", "203.0.113.1")
+	running := withEip(testInterface("eni-running", "i-running", web), "eipalloc-3", "203.0.113.3")
+	moved := withEip(testInterface("eni-moved", "", web), "eipalloc-4", "203.0.113.4")
+	private := testInterface("eni-private", "", web)
+
+	fake := eipFake(available, stopped, running)
+	// Reassociated since the interfaces were listed
+	fake.AddAddresses(types.Address{AllocationId: aws.String("eipalloc-4"), AssociationId: aws.String("eipassoc-other"), NetworkInterfaceId: aws.String("eni-other")})
+
+	stoppedInstance := testInstance("i-stopped", "")
+	stoppedInstance.State = &types.InstanceState{Name: types.InstanceStateNameStopped}
+	instances := map[string]types.Instance{"i-stopped": stoppedInstance, "i-running": testInstance("i-running", "")}
+	results := []groupResult{testResult("eu-west-1", "web", available, stopped, running, moved, private)}
+
+	eips, err := getIdleEips(context.Background(), fake, "eu-west-1", results, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []idleEip{
+		{Region: "eu-west-1", AllocationId: "eipalloc-1", AssociationId: "eipassoc-eni-stopped", PublicIp: "203.0.113.1", NetworkInterfaceId: "eni-stopped", InstanceId: "i-stopped", Reason: idleEipStoppedInstance, MonthlyCost: 3.65},
+		{Region: "eu-west-1", AllocationId: "eipalloc-2", AssociationId: "eipassoc-eni-available", PublicIp: "203.0.113.2", NetworkInterfaceId: "eni-available", Reason: idleEipAvailableInterface, MonthlyCost: 3.65},
+	}
+	if !slices.Equal(eips, want) {
+		t.Errorf("idle EIPs\n%+v\nwant\n%+v", eips, want)
+	}
+	if !eips[1].trulyIdle() || eips[0].trulyIdle() {
+		t.Error("only the EIP of the available interface is truly idle")
+	}
+
+	// Without an Elastic IP on the interfaces, nothing is called
+	fake = enitest.New()
+	eips, err = getIdleEips(context.Background(), fake, "eu-west-1", []groupResult{testResult("eu-west-1", "web", private)}, nil)
+	if err != nil || len(eips) != 0 || fake.Calls("DescribeAddresses") != 0 {
+		t.Errorf("no EIPs: %v, %v, %d calls", eips, err, fake.Calls("DescribeAddresses"))
+	}
+}
+
+// idleTestEip returns an idle Elastic IP, as found by the audit, of an interface whose address eipFake holds.
+//
+// networkInterface: The interface, with its Elastic IP set by withEip.
+// reason: Why the Elastic IP is idle.
+// idleEip: The idle Elastic IP.
+func idleTestEip(networkInterface types.NetworkInterface, reason string) idleEip {
+	networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+	return idleEip{
+		Region:             "eu-west-1",
+		AllocationId:       aws.ToString(networkInterface.Association.AllocationId),
+		AssociationId:      "eipassoc-" + networkInterfaceId,
+		PublicIp:           aws.ToString(networkInterface.Association.PublicIp),
+		NetworkInterfaceId: networkInterfaceId,
+		Reason:             reason,
+	}
+}
+
+// allocationIds returns the allocation IDs of the Elastic IPs left in a fake.
+//
+// fake: The fake.
+// []string: The allocation IDs, in the order they were added.
+func allocationIds(fake *enitest.Fake) []string {
+	ids := []string{}
+	for _, address := range fake.Addresses() {
+		ids = append(ids, aws.ToString(address.AllocationId))
+	}
+	return ids
+}
+
+func TestReleaseIdleEipsRerun(t *testing.T) {
+	web := testGroup("sg-1", "web")
+	first := withEip(testInterface("eni-1", "", web), "eipalloc-1", "203.0.113.1")
+	second := withEip(testInterface("eni-2", "", web), "eipalloc-2", "203.0.113.2")
+	third := withEip(testInterface("eni-3", "", web), "eipalloc-3", "203.0.113.3")
+	stopped := withEip(testInterface("eni-4", "i-stopped", web), "eipalloc-4", "203.0.113.4")
+	fake := eipFake(first, second, third, stopped)
+	eips := []idleEip{
+		idleTestEip(first, idleEipAvailableInterface),
+		idleTestEip(second, idleEipAvailableInterface),
+		idleTestEip(third, idleEipAvailableInterface),
+		idleTestEip(stopped, idleEipStoppedInstance),
+	}
+	clientFor := func(region string) eipAPI { return fake }
+
+	// Interrupted between the disassociation and the release of the second address
+	ctx, cancel := context.WithCancel(context.Background())
+	fake.OnCall("ReleaseAddress", func(call int) {
+		if call == 2 {
+			cancel()
+		}
+	})
+	var out strings.Builder
+	if err := releaseIdleEips(ctx, clientFor, eips, false, &out); err == nil {
+		t.Errorf("interrupted run succeeded:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Release Elastic IPs: 1 changed, 0 already converged, 1 skipped, 0 conflicted, 2 failed") {
+		t.Errorf("interrupted run:\n%s", out.String())
+	}
+	addresses := fake.Addresses()
+	if got := allocationIds(fake); !slices.Equal(got, []string{"eipalloc-2", "eipalloc-3", "eipalloc-4"}) || addresses[0].AssociationId != nil {
+		t.Fatalf("after the interruption: %v, eipalloc-2 associated with %v", got, aws.ToString(addresses[0].AssociationId))
+	}
+
+	// The rerun converges without failing on what the interrupted run did
+	fake.OnCall("ReleaseAddress", nil)
+	disassociations := fake.Calls("DisassociateAddress")
+	out.Reset()
+	if err := releaseIdleEips(context.Background(), clientFor, eips, false, &out); err != nil {
+		t.Errorf("rerun: %v\n%s", err, out.String())
+	}
+	for _, line := range []string{
+		"already-converged eipalloc-1: 203.0.113.1 is already released",
+		"changed           eipalloc-2: released 203.0.113.2 from eni-2 in eu-west-1",
+		"changed           eipalloc-3: released 203.0.113.3 from eni-3 in eu-west-1",
+		"skipped           eipalloc-4: 203.0.113.4 on eni-4 in eu-west-1, " + idleEipStoppedInstance,
+		"Release Elastic IPs: 2 changed, 1 already converged, 1 skipped, 0 conflicted, 0 failed",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("rerun lacks %q:\n%s", line, out.String())
+		}
+	}
+	if got := fake.Calls("DisassociateAddress") - disassociations; got != 1 {
+		t.Errorf("rerun disassociated %d addresses, want only eipalloc-3", got)
+	}
+	if got := allocationIds(fake); !slices.Equal(got, []string{"eipalloc-4"}) {
+		t.Errorf("after the rerun: %v", got)
+	}
+
+	// A further rerun changes nothing
+	releases := fake.Calls("ReleaseAddress")
+	out.Reset()
+	if err := releaseIdleEips(context.Background(), clientFor, eips, false, &out); err != nil || fake.Calls("ReleaseAddress") != releases {
+		t.Errorf("second rerun: %v, %d releases\n%s", err, fake.Calls("ReleaseAddress")-releases, out.String())
+	}
+	if !strings.Contains(out.String(), "Release Elastic IPs: 0 changed, 3 already converged, 1 skipped, 0 conflicted, 0 failed") {
+		t.Errorf("second rerun:\n%s", out.String())
+	}
+}
+
+func TestReleaseIdleEipsConflicts(t *testing.T) {
+	web := testGroup("sg-1", "web")
+	raced := withEip(testInterface("eni-1", "", web), "eipalloc-1", "203.0.113.1")
+	moved := withEip(testInterface("eni-2", "", web), "eipalloc-2", "203.0.113.2")
+	fake := eipFake(raced)
+	fake.AddAddresses(types.Address{AllocationId: aws.String("eipalloc-2"), AssociationId: aws.String("eipassoc-other"), NetworkInterfaceId: aws.String("eni-other"), PublicIp: aws.String("203.0.113.2")})
+
+	// Another run disassociates the first address between its read and its disassociation
+	disassociated := false
+	fake.OnCall("DisassociateAddress", func(call int) {
+		if !disassociated {
+			disassociated = true
+			if _, err := fake.DisassociateAddress(context.Background(), &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-eni-1")}); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	var out strings.Builder
+	err := releaseIdleEips(context.Background(), func(region string) eipAPI { return fake }, []idleEip{idleTestEip(raced, idleEipAvailableInterface), idleTestEip(moved, idleEipAvailableInterface)}, false, &out)
+	if err == nil {
+		t.Errorf("a conflicted address was not reported as an error:\n%s", out.String())
+	}
+	for _, line := range []string{
+		"changed           eipalloc-1: released 203.0.113.1 from eni-1 in eu-west-1",
+		"conflicted        eipalloc-2: 203.0.113.2 is now associated with eni-other, not eni-2",
+		"Release Elastic IPs: 1 changed, 0 already converged, 0 skipped, 1 conflicted, 0 failed",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+	if got := allocationIds(fake); !slices.Equal(got, []string{"eipalloc-2"}) {
+		t.Errorf("left %v, want the address associated elsewhere kept", got)
+	}
+}
+
+func TestReleaseIdleEipsDryRun(t *testing.T) {
+	idle := withEip(testInterface("eni-1", "", testGroup("sg-1", "web")), "eipalloc-1", "203.0.113.1")
+	fake := eipFake(idle)
+	var out strings.Builder
+	if err := releaseIdleEips(context.Background(), func(region string) eipAPI { return fake }, []idleEip{idleTestEip(idle, idleEipAvailableInterface)}, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "would release 203.0.113.1 from eni-1 in eu-west-1") || fake.Calls("DisassociateAddress")+fake.Calls("ReleaseAddress") != 0 {
+		t.Errorf("dry run changed the address:\n%s", out.String())
+	}
+}
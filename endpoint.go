@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// endpointMode selects the variant of the service endpoints every client of the run calls.
+type endpointMode struct {
+	// FIPS calls the FIPS 140-2 validated endpoints, set with -use-fips.
+	FIPS bool
+	// DualStack calls the endpoints reachable over IPv4 and IPv6, set with -use-dualstack.
+	DualStack bool
+}
+
+// loadOptions returns the options pinning the endpoint resolution of the loaded configuration to the mode, so it
+// applies to every client built from it.
+//
+// []func(*config.LoadOptions) error: The options to load the configuration with.
+func (m endpointMode) loadOptions() []func(*config.LoadOptions) error {
+	options := []func(*config.LoadOptions) error{}
+	if m.FIPS {
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if m.DualStack {
+		options = append(options, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	return options
+}
+
+// String returns the mode as recorded in the JSON metadata: standard, fips, dualstack or fips+dualstack.
+//
+// string: The mode.
+func (m endpointMode) String() string {
+	switch {
+	case m.FIPS && m.DualStack:
+		return "fips+dualstack"
+	case m.FIPS:
+		return "fips"
+	case m.DualStack:
+		return "dualstack"
+	default:
+		return "standard"
+	}
+}
+
+// isEndpointFailure reports whether the error comes from a region lacking an endpoint of the mode, which surfaces
+// as an endpoint the SDK cannot resolve or as a host that does not exist.
+//
+// err: The error to check.
+// mode: The endpoint mode of the run.
+// bool: Whether the region has no endpoint of the mode. Always false for the standard endpoints.
+func isEndpointFailure(err error, mode endpointMode) bool {
+	if !mode.FIPS && !mode.DualStack {
+		return false
+	}
+	var notFoundErr *aws.EndpointNotFoundError
+	return errors.As(err, &notFoundErr) || errors.Is(enilookup.ClassifyError(err), enilookup.ErrRegionInvalid)
+}
+
+// endpointFailure records a region lacking an endpoint of the mode.
+//
+// region: The region.
+// err: The error of the region.
+// mode: The endpoint mode of the run.
+// regionFailure: The failed region.
+func endpointFailure(region string, err error, mode endpointMode) regionFailure {
+	return regionFailure{Region: region, Error: fmt.Sprintf("no %s endpoint: %v", mode, err)}
+}
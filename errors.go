@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// Exit codes of the program.
+const (
+	exitError         = 1
+	exitUsage         = 2
+	exitGroupNotFound = 3
+	exitAccessDenied  = 4
+	exitThrottled     = 5
+	exitRegionInvalid = 6
+	exitUnstable      = 7
+	exitEnvMismatch   = 8
+	exitInternal      = 9
+	exitViolation     = 10
+)
+
+// exitCode returns the exit code matching the failure class of the error.
+//
+// err: The error.
+// int: The exit code.
+func exitCode(err error) int {
+	err = enilookup.ClassifyError(err)
+	switch {
+	case errors.Is(err, enilookup.ErrGroupNotFound):
+		return exitGroupNotFound
+	case errors.Is(err, enilookup.ErrAccessDenied):
+		return exitAccessDenied
+	case errors.Is(err, enilookup.ErrThrottled):
+		return exitThrottled
+	case errors.Is(err, enilookup.ErrRegionInvalid):
+		return exitRegionInvalid
+	default:
+		return exitError
+	}
+}
+
+// runExitCode returns the exit code of a completed run, in order of precedence: a failed region or an incomplete
+// run, denied security groups, unstable passes, then violations of the convention.
+//
+// run: What the run found.
+// strict: Whether any denied security group fails the run, as -strict does.
+// failOnUnstable: Whether differing passes of -verify fail the run, as -fail-on-unstable does.
+// failOnViolation: Whether a violation of the convention fails the run, as -fail-on-violation does.
+// c: The -convention, nil without it.
+// int: The exit code, 0 when nothing fails the run.
+func runExitCode(run scanResult, strict bool, failOnUnstable bool, failOnViolation bool, c *convention) int {
+	denied := countDenied(run.Results)
+	switch {
+	case len(run.FailedRegions) > 0 || run.Incomplete != "":
+		return exitError
+	case denied > 0 && (strict || denied == len(run.Results)):
+		return exitAccessDenied
+	case failOnUnstable && run.Verification != nil && !run.Verification.Stable:
+		return exitUnstable
+	case failOnViolation && c != nil && len(getViolations(run.Results, c).Violations) > 0:
+		return exitViolation
+	default:
+		return 0
+	}
+}
+
+// fatal prints the error and exits with the exit code matching its failure class.
+//
+// err: The error.
+func fatal(err error) {
+	flushWarnings(os.Stderr)
+	fmt.Fprintln(os.Stderr, stderrText(fmt.Sprintf("error: %v", enilookup.ClassifyError(err))))
+	activeTelemetry.send(exitCode(err))
+	os.Exit(exitCode(err))
+}
+
+// internalError prints a violated invariant of the output as a bug to report and exits with the internal error
+// exit code, instead of rendering wrong data.
+//
+// err: The violation.
+func internalError(err error) {
+	fmt.Fprintf(os.Stderr, "internal error: %s\nThis is a bug, please report it with the command line used. -no-invariant-checks renders the output anyway.\n", stderrText(err.Error()))
+	activeTelemetry.send(exitInternal)
+	os.Exit(exitInternal)
+}
+
+// usageError prints a message about invalid flags and exits with the usage exit code.
+//
+// format: The message format.
+// args: The message arguments.
+func usageError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	activeTelemetry.send(exitUsage)
+	os.Exit(exitUsage)
+}
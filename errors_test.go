@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+func TestExitCode(t *testing.T) {
+	apiError := func(code string) error {
+		return &smithy.OperationError{ServiceID: "EC2", OperationName: "DescribeNetworkInterfaces", Err: &smithy.GenericAPIError{Code: code}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"typed group not found", &enilookup.GroupNotFoundError{Group: "web"}, exitGroupNotFound},
+		{"raw group not found", apiError("InvalidGroup.NotFound"), exitGroupNotFound},
+		{"typed access denied", &enilookup.AccessDeniedError{Action: "ec2:DescribeNetworkInterfaces", Err: errors.New("denied")}, exitAccessDenied},
+		{"raw unauthorized", apiError("UnauthorizedOperation"), exitAccessDenied},
+		{"raw throttled", apiError("RequestLimitExceeded"), exitThrottled},
+		{"wrapped throttled", fmt.Errorf("region eu-west-1: %w", &enilookup.ThrottledError{Err: errors.New("slow down")}), exitThrottled},
+		{"invalid region", &enilookup.RegionInvalidError{Region: "xx-nowhere-1"}, exitRegionInvalid},
+		{"ambiguous prefix", &enilookup.AmbiguousGroupIdError{Prefix: "sg-0a1b2c3d"}, exitError},
+		{"other API error", apiError("InvalidParameterValue"), exitError},
+		{"other error", errors.New("boom"), exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestRunExitCode checks the exit codes of completed runs, -fail-on-violation included, and their precedence.
+func TestRunExitCode(t *testing.T) {
+	web := testGroup("sg-0web", "web")
+	dev := testGroup("sg-0dev", "dev-web")
+	clean := testRun(false)
+	violating := testRun(false)
+	violating.Results = append(violating.Results, testResult("eu-west-1", "dev-web", testInterface("eni-0fff", "", web, dev)))
+	denied := testRun(false)
+	denied.Results[2].Denial = newGroupDenial(context.Background(), nil, errors.New("UnauthorizedOperation"))
+	allDenied := testRun(false)
+	for i := range allDenied.Results {
+		allDenied.Results[i].Denial = newGroupDenial(context.Background(), nil, errors.New("UnauthorizedOperation"))
+	}
+	failedRegion := violating
+	failedRegion.FailedRegions = []regionFailure{{Region: "ap-south-1", Error: "timeout"}}
+	incomplete := testRun(false)
+	incomplete.Incomplete = "interrupted"
+	unstable := violating
+	unstable.Verification = &verification{Stable: false}
+	c := &convention{Rules: []conventionRule{{Name: "default", Forbid: []string{"dev-*"}}}}
+
+	for _, tt := range []struct {
+		name                                    string
+		run                                     scanResult
+		strict, failOnUnstable, failOnViolation bool
+		want                                    int
+	}{
+		{"clean", clean, true, true, true, 0},
+		{"violation", violating, false, false, true, exitViolation},
+		{"violation without -fail-on-violation", violating, false, false, false, 0},
+		{"failed region over violation", failedRegion, false, false, true, exitError},
+		{"incomplete", incomplete, false, false, false, exitError},
+		{"one group denied", denied, false, false, false, 0},
+		{"one group denied, strict", denied, true, false, false, exitAccessDenied},
+		{"every group denied", allDenied, false, false, false, exitAccessDenied},
+		{"unstable over violation", unstable, false, true, true, exitUnstable},
+		{"unstable without -fail-on-unstable", unstable, false, false, true, exitViolation},
+	} {
+		if got := runExitCode(tt.run, tt.strict, tt.failOnUnstable, tt.failOnViolation, c); got != tt.want {
+			t.Errorf("%s: exit code %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := runExitCode(violating, false, false, true, nil); got != 0 {
+		t.Errorf("without -convention: exit code %d, want 0", got)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// envAssertionError is returned when the environment differs from the one asserted with the -expect flags.
+type envAssertionError struct {
+	Reason string
+}
+
+// Error returns the reason of the mismatch.
+func (e *envAssertionError) Error() string {
+	return e.Reason
+}
+
+// envMismatch is a queried security group whose environment tag disagrees with -expect-env.
+type envMismatch struct {
+	Region  string
+	GroupId string
+	// Value is the value of the tag, empty when the group does not carry it.
+	Value   string
+	Missing bool
+}
+
+// checkExpectedAccount compares the account of the credentials with -expect-account and -expect-account-alias.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// expectedAccount: The expected account ID, empty to skip the check.
+// expectedAlias: The expected account alias, empty to skip the check.
+// error: An *envAssertionError describing the mismatch, or the error of an API call.
+func checkExpectedAccount(ctx context.Context, cfg aws.Config, expectedAccount string, expectedAlias string) error {
+	if expectedAccount != "" {
+		accountId, err := getAccountId(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if accountId != expectedAccount {
+			return &envAssertionError{Reason: fmt.Sprintf("the credentials belong to account %s, not to the expected account %s", accountId, expectedAccount)}
+		}
+	}
+	if expectedAlias != "" {
+		listAccountAliasesOutput, err := iam.NewFromConfig(cfg).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(listAccountAliasesOutput.AccountAliases, expectedAlias) {
+			aliases := strings.Join(listAccountAliasesOutput.AccountAliases, ", ")
+			if aliases == "" {
+				aliases = "none"
+			}
+			return &envAssertionError{Reason: fmt.Sprintf("the account aliases of the credentials are %s, not the expected alias %s", aliases, expectedAlias)}
+		}
+	}
+	return nil
+}
+
+// parseExpectEnv parses the value of -expect-env.
+//
+// value: The tag and expected value, as key=value.
+// string: The tag key.
+// string: The expected value.
+// error: If the value is not key=value.
+func parseExpectEnv(value string) (string, string, error) {
+	key, expected, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("expected key=value, e.g. environment=staging")
+	}
+	return key, expected, nil
+}
+
+// checkExpectedEnv finds the queried security groups whose tag disagrees with the expected value, or that do not
+// carry the tag.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region.
+// region: The region.
+// securityGroupNames: The security group names and IDs.
+// key: The tag key.
+// expected: The expected value.
+// []envMismatch: The groups that disagree, sorted by group ID.
+// error: If DescribeSecurityGroups fails.
+func checkExpectedEnv(ctx context.Context, ec2Client ec2.DescribeSecurityGroupsAPIClient, region string, securityGroupNames []string, key string, expected string) ([]envMismatch, error) {
+	names, ids := []string{}, []string{}
+	for _, name := range securityGroupNames {
+		if strings.HasPrefix(name, "sg-") {
+			ids = append(ids, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+
+	// Filters are ANDed, so names and IDs are queried on their own
+	securityGroups := []types.SecurityGroup{}
+	for _, filter := range []types.Filter{{Name: aws.String("group-name"), Values: names}, {Name: aws.String("group-id"), Values: ids}} {
+		if len(filter.Values) == 0 {
+			continue
+		}
+		paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter}})
+		for paginator.HasMorePages() {
+			describeSecurityGroupsOutput, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			securityGroups = append(securityGroups, describeSecurityGroupsOutput.SecurityGroups...)
+		}
+	}
+
+	mismatches := []envMismatch{}
+	for _, securityGroup := range securityGroups {
+		value, found := "", false
+		for _, tag := range securityGroup.Tags {
+			if aws.ToString(tag.Key) == key {
+				value, found = aws.ToString(tag.Value), true
+			}
+		}
+		if !found || value != expected {
+			mismatches = append(mismatches, envMismatch{Region: region, GroupId: aws.ToString(securityGroup.GroupId), Value: value, Missing: !found})
+		}
+	}
+	slices.SortFunc(mismatches, func(a, b envMismatch) int { return strings.Compare(a.GroupId, b.GroupId) })
+	return mismatches, nil
+}
+
+// abortOnAssertion prints a loud error and exits when the environment is not the expected one.
+//
+// reason: What differs.
+func abortOnAssertion(reason string) {
+	fmt.Fprintf(os.Stderr, "\n*** ENVIRONMENT ASSERTION FAILED: %s ***\n*** Nothing was done. ***\n\n", reason)
+	activeTelemetry.send(exitEnvMismatch)
+	os.Exit(exitEnvMismatch)
+}
+
+// printEnvMismatches prints a warning per group disagreeing with -expect-env.
+//
+// w: The writer to print to, stderr.
+// expectEnv: The value of -expect-env.
+// mismatches: The groups.
+// key: The tag key.
+func printEnvMismatches(w io.Writer, expectEnv string, mismatches []envMismatch, key string) {
+	for _, mismatch := range mismatches {
+		fmt.Fprintln(w, stderrText(fmt.Sprintf("warning: -expect-env %s: %s", expectEnv, describeEnvMismatch(mismatch, key))))
+	}
+}
+
+// describeEnvMismatch describes a group disagreeing with -expect-env.
+//
+// mismatch: The group.
+// key: The tag key.
+// string: The description.
+func describeEnvMismatch(mismatch envMismatch, key string) string {
+	if mismatch.Missing {
+		return fmt.Sprintf("%s (%s) has no %s tag", mismatch.GroupId, mismatch.Region, key)
+	}
+	return fmt.Sprintf("%s (%s) is tagged %s=%s", mismatch.GroupId, mismatch.Region, key, mismatch.Value)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/internal/flagtypes"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// identityConfig returns an AWS configuration whose STS and IAM calls are answered by a test server, with the
+// account and aliases of the credentials.
+//
+// t: The test.
+// account: The account ID of the credentials.
+// aliases: The account aliases.
+// aws.Config: The configuration.
+func identityConfig(t *testing.T, account string, aliases ...string) aws.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		switch r.Form.Get("Action") {
+		case "GetCallerIdentity":
+			fmt.Fprintf(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult><Arn>arn:aws:iam::%[1]s:user/test</Arn><UserId>AIDATEST</UserId><Account>%[1]s</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`, account)
+		case "ListAccountAliases":
+			members := ""
+			for _, alias := range aliases {
+				members += "<member>" + alias + "</member>"
+			}
+			fmt.Fprintf(w, `<ListAccountAliasesResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/"><ListAccountAliasesResult><IsTruncated>false</IsTruncated><AccountAliases>%s</AccountAliases></ListAccountAliasesResult></ListAccountAliasesResponse>`, members)
+		default:
+			t.Errorf("unexpected call %s", r.Form.Get("Action"))
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return aws.Config{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...any) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: server.URL}, nil
+		}),
+	}
+}
+
+func TestCheckExpectedAccount(t *testing.T) {
+	cfg := identityConfig(t, "123456789012", "acme-staging")
+	for _, tt := range []struct {
+		account string
+		alias   string
+		want    string
+	}{
+		{"", "", ""},
+		{"123456789012", "", ""},
+		{"", "acme-staging", ""},
+		{"123456789012", "acme-staging", ""},
+		{"210987654321", "", "the credentials belong to account 123456789012, not to the expected account 210987654321"},
+		{"123456789012", "acme-prod", "the account aliases of the credentials are acme-staging, not the expected alias acme-prod"},
+	} {
+		err := checkExpectedAccount(context.Background(), cfg, tt.account, tt.alias)
+		var assertionErr *envAssertionError
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%q %q: %v", tt.account, tt.alias, err)
+		case tt.want != "" && (!errors.As(err, &assertionErr) || assertionErr.Reason != tt.want):
+			t.Errorf("%q %q: error %v, want %q", tt.account, tt.alias, err, tt.want)
+		}
+	}
+
+	// An account without alias
+	err := checkExpectedAccount(context.Background(), identityConfig(t, "123456789012"), "", "acme-staging")
+	if want := "the account aliases of the credentials are none, not the expected alias acme-staging"; err == nil || err.Error() != want {
+		t.Errorf("no alias: error %v, want %q", err, want)
+	}
+}
+
+func TestParseExpectEnv(t *testing.T) {
+	if key, value, err := parseExpectEnv("environment=staging"); err != nil || key != "environment" || value != "staging" {
+		t.Errorf("got %q %q %v", key, value, err)
+	}
+	if key, value, err := parseExpectEnv("environment="); err != nil || key != "environment" || value != "" {
+		t.Errorf("empty value: got %q %q %v", key, value, err)
+	}
+	for _, value := range []string{"environment", "=staging", ""} {
+		if _, _, err := parseExpectEnv(value); err == nil {
+			t.Errorf("%q: no error", value)
+		}
+	}
+}
+
+func TestCheckExpectedEnv(t *testing.T) {
+	tagged := func(id string, name string, tags ...string) types.SecurityGroup {
+		group := types.SecurityGroup{GroupId: aws.String(id), GroupName: aws.String(name)}
+		for _, tag := range tags {
+			key, value, _ := strings.Cut(tag, "=")
+			group.Tags = append(group.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		return group
+	}
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		tagged("sg-0000000000000000a", "web", "environment=staging"),
+		tagged("sg-0000000000000000b", "db", "environment=prod"),
+		tagged("sg-0000000000000000c", "cache", "owner=team-a"),
+		tagged("sg-0000000000000000d", "queue", "environment=staging"),
+	)
+
+	for _, tt := range []struct {
+		name   string
+		groups []string
+		want   []string
+	}{
+		{"match", []string{"web", "sg-0000000000000000d"}, nil},
+		{"mismatch", []string{"web", "db"}, []string{"sg-0000000000000000b (eu-west-1) is tagged environment=prod"}},
+		{"missing tag", []string{"sg-0000000000000000c"}, []string{"sg-0000000000000000c (eu-west-1) has no environment tag"}},
+		{"names and IDs", []string{"cache", "sg-0000000000000000b", "queue"}, []string{
+			"sg-0000000000000000b (eu-west-1) is tagged environment=prod",
+			"sg-0000000000000000c (eu-west-1) has no environment tag",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mismatches, err := checkExpectedEnv(context.Background(), fake, "eu-west-1", tt.groups, "environment", "staging")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, mismatch := range mismatches {
+				got = append(got, describeEnvMismatch(mismatch, "environment"))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("mismatches\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *string, *string, *time.Duration, *cliFilters) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		account := fs.String("expect-account", "", "")
+		env := fs.String("expect-env", "", "")
+		ttl := flagtypes.NewDuration(fs, "cache-ttl", time.Hour, "")
+		filters := &cliFilters{}
+		fs.Var(filters, "filters", "")
+		return fs, account, env, ttl, filters
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"expect-account": "123456789012", "expect-env": "environment=staging", "cache-ttl": "2h", "filters": ["Name=status,Values=available", "Name=vpc-id,Values=vpc-1"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, account, env, ttl, filters := newFlags()
+	if err := fs.Parse([]string{"-expect-env", "environment=prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(fs, path, true); err != nil {
+		t.Fatal(err)
+	}
+	if *account != "123456789012" || *ttl != 2*time.Hour || len(filters.Values) != 2 {
+		t.Errorf("expect-account %q, cache-ttl %v, filters %q", *account, *ttl, filters.Values)
+	}
+	if *env != "environment=prod" {
+		t.Errorf("expect-env %q, the command line must win over the file", *env)
+	}
+
+	// A missing file is only an error when given with -config
+	fs, _, _, _, _ = newFlags()
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if err := applyConfigFile(fs, missing, false); err != nil {
+		t.Errorf("missing default file: %v", err)
+	}
+	if err := applyConfigFile(fs, missing, true); err == nil {
+		t.Error("missing -config file: no error")
+	}
+
+	for content, want := range map[string]string{
+		`{"expect-acount": "1"}`: `unknown flag "expect-acount"`,
+		`{"cache-ttl": "soon"}`:  `invalid value soon for "cache-ttl"`,
+		`["expect-account"]`:     "cannot unmarshal array",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fs, _, _, _, _ = newFlags()
+		if err := applyConfigFile(fs, path, true); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// openToInternet is the marker printed for sources that allow the whole internet.
+const openToInternet = "OPEN TO INTERNET"
+
+// exposure is an ingress rule of a security group that applies to at least one public network interface.
+type exposure struct {
+	Protocol       string `json:"protocol"`
+	PortRange      string `json:"port_range"`
+	Source         string `json:"source"`
+	OpenToInternet bool   `json:"open_to_internet"`
+}
+
+// isPublic reports whether the network interface has a public IPv4 address.
+//
+// networkInterface: The network interface to check.
+// bool: Whether the interface has a public IP.
+func isPublic(networkInterface types.NetworkInterface) bool {
+	return networkInterface.Association != nil && aws.ToString(networkInterface.Association.PublicIp) != ""
+}
+
+// getExposures sets the exposures of every result that has public network interfaces.
+//
+// The ingress rules of a security group apply to every interface in it, so every ingress rule of a group with
+// at least one public interface is an exposure. Egress rules are ignored.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region of the results.
+// results: The results, updated in place.
+// error: If the security groups cannot be described.
+func getExposures(ctx context.Context, ec2Client *ec2.Client, results []groupResult) error {
+	// Find the IDs of the groups with public interfaces
+	index := newGroupIndex(results)
+	groupIds := map[int][]string{}
+	uniqueGroupIds := []string{}
+	seen := map[string]bool{}
+	for i, result := range results {
+		if len(result.NetworkInterfaces) == 0 {
+			continue
+		}
+		selectedGroupIds, _ := index.selected(result.Selector)
+		for _, groupId := range selectedGroupIds {
+			groupIds[i] = append(groupIds[i], groupId)
+			if !seen[groupId] {
+				seen[groupId] = true
+				uniqueGroupIds = append(uniqueGroupIds, groupId)
+			}
+		}
+	}
+	if len(uniqueGroupIds) == 0 {
+		return nil
+	}
+
+	// Describe the ingress rules of the groups
+	rules := map[string][]types.IpPermission{}
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: uniqueGroupIds,
+	})
+	for paginator.HasMorePages() {
+		describeSecurityGroupsOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, securityGroup := range describeSecurityGroupsOutput.SecurityGroups {
+			rules[aws.ToString(securityGroup.GroupId)] = securityGroup.IpPermissions
+		}
+	}
+
+	for i := range results {
+		exposures := []exposure{}
+		for _, groupId := range groupIds[i] {
+			exposures = append(exposures, exposuresOf(rules[groupId])...)
+		}
+		results[i].Exposures = uniqueExposures(exposures)
+	}
+	return nil
+}
+
+// exposuresOf converts ingress rules to exposures, one per source.
+//
+// permissions: The ingress rules.
+// []exposure: The exposures.
+func exposuresOf(permissions []types.IpPermission) []exposure {
+	exposures := []exposure{}
+	for _, permission := range permissions {
+		protocol, portRange := describePorts(permission)
+		add := func(source string, open bool) {
+			exposures = append(exposures, exposure{Protocol: protocol, PortRange: portRange, Source: source, OpenToInternet: open})
+		}
+		for _, ipRange := range permission.IpRanges {
+			cidr := aws.ToString(ipRange.CidrIp)
+			add(cidr, cidr == "0.0.0.0/0")
+		}
+		for _, ipv6Range := range permission.Ipv6Ranges {
+			cidr := aws.ToString(ipv6Range.CidrIpv6)
+			add(cidr, cidr == "::/0")
+		}
+		for _, prefixList := range permission.PrefixListIds {
+			add(aws.ToString(prefixList.PrefixListId), false)
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			add(aws.ToString(pair.GroupId), false)
+		}
+	}
+	return exposures
+}
+
+// describePorts returns the protocol and port range of an ingress rule in human readable form.
+//
+// permission: The ingress rule.
+// string: The protocol.
+// string: The port range.
+func describePorts(permission types.IpPermission) (string, string) {
+	protocol := aws.ToString(permission.IpProtocol)
+	if protocol == "-1" {
+		return "all", "all"
+	}
+	from, to := aws.ToInt32(permission.FromPort), aws.ToInt32(permission.ToPort)
+	switch {
+	case permission.FromPort == nil || (from == -1 && to == -1):
+		return protocol, "all"
+	case from == to:
+		return protocol, fmt.Sprintf("%d", from)
+	default:
+		return protocol, fmt.Sprintf("%d-%d", from, to)
+	}
+}
+
+// uniqueExposures removes duplicate exposures and sorts them, internet exposures first.
+//
+// exposures: The exposures.
+// []exposure: The unique, sorted exposures.
+func uniqueExposures(exposures []exposure) []exposure {
+	seen := map[exposure]bool{}
+	unique := []exposure{}
+	for _, e := range exposures {
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		a, b := unique[i], unique[j]
+		if a.OpenToInternet != b.OpenToInternet {
+			return a.OpenToInternet
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.PortRange != b.PortRange {
+			return a.PortRange < b.PortRange
+		}
+		return a.Source < b.Source
+	})
+	return unique
+}
+
+// printExposures prints the exposures of a security group.
+//
+// w: The writer to print to.
+// exposures: The exposures to print.
+func printExposures(w io.Writer, exposures []exposure) {
+	fmt.Fprintln(w, "Exposed on:")
+	if len(exposures) == 0 {
+		fmt.Fprintln(w, "  no ingress rules")
+	}
+	for _, e := range exposures {
+		source := e.Source
+		if e.OpenToInternet {
+			source = fmt.Sprintf("%s (%s)", e.Source, openToInternet)
+		}
+		fmt.Fprintf(w, "  %s %s from %s\n", e.Protocol, e.PortRange, source)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// filterSubject is what a client-side filter decides on: a network interface and what is known about it.
+type filterSubject struct {
+	// Result is the security group the interface was found for.
+	Result groupResult
+	// NetworkInterface is the interface.
+	NetworkInterface types.NetworkInterface
+	// Sighting is when the interface was first and last seen, nil without -history-file.
+	Sighting *interfaceSighting
+}
+
+// interfacePredicate is a named client-side filter of the network interfaces.
+type interfacePredicate struct {
+	// Name is the flag the filter comes from.
+	Name string
+	// Keep reports whether the interface passes the filter.
+	Keep func(subject filterSubject) (bool, error)
+}
+
+// filterElimination records the filter that removed the last interfaces of a security group.
+type filterElimination struct {
+	Region string
+	Group  string
+	// Found is the number of interfaces returned by the API.
+	Found int
+	// Filter is the filter that removed the last interfaces.
+	Filter string
+	// Removed is the number of interfaces that filter removed.
+	Removed int
+}
+
+// filterPipeline applies the client-side filters in order and counts the interfaces each one removes.
+type filterPipeline struct {
+	predicates []interfacePredicate
+	// removed is the number of interfaces removed by every predicate, in the order of the predicates.
+	removed []int
+}
+
+// newFilterPipeline builds the client-side filters selected by the options, cheapest first.
+//
+// opts: The options of the scan.
+// now: The time -new-since is measured from.
+// *filterPipeline: The pipeline, with no predicates when no filter is selected.
+func newFilterPipeline(opts scanOptions, now time.Time) *filterPipeline {
+	p := &filterPipeline{}
+	add := func(name string, keep func(subject filterSubject) (bool, error)) {
+		p.predicates = append(p.predicates, interfacePredicate{Name: name, Keep: keep})
+		p.removed = append(p.removed, 0)
+	}
+
+	if opts.Status != "" {
+		add("-status", func(subject filterSubject) (bool, error) {
+			return string(subject.NetworkInterface.Status) == opts.Status, nil
+		})
+	}
+	if opts.InstanceId != "" {
+		add("-instance-id", func(subject filterSubject) (bool, error) {
+			attachment := subject.NetworkInterface.Attachment
+			return attachment != nil && aws.ToString(attachment.InstanceId) == opts.InstanceId, nil
+		})
+	}
+	if opts.PublicOnly {
+		add("-public-only", func(subject filterSubject) (bool, error) {
+			return isPublic(subject.NetworkInterface), nil
+		})
+	}
+	if opts.HasPermissionsOnly {
+		add("-has-permissions-only", func(subject filterSubject) (bool, error) {
+			return len(subject.Result.Permissions[aws.ToString(subject.NetworkInterface.NetworkInterfaceId)]) > 0, nil
+		})
+	}
+	if opts.NewSince > 0 {
+		since := now.Add(-opts.NewSince)
+		add("-new-since", func(subject filterSubject) (bool, error) {
+			return subject.Sighting != nil && !subject.Sighting.FirstSeen.Before(since), nil
+		})
+	}
+	if opts.Where != nil {
+		add("-where", func(subject filterSubject) (bool, error) {
+			return opts.Where.match(subject.NetworkInterface)
+		})
+	}
+	return p
+}
+
+// apply filters the network interfaces of every result, one predicate at a time.
+//
+// results: The network interfaces found per security group, filtered in place.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// []filterElimination: The groups whose interfaces were all removed, with the filter that removed the last ones.
+// error: If a predicate fails.
+func (p *filterPipeline) apply(results []groupResult, sightings map[string]interfaceSighting) ([]filterElimination, error) {
+	eliminations := []filterElimination{}
+	for i, result := range results {
+		found := len(result.NetworkInterfaces)
+		networkInterfaces := result.NetworkInterfaces
+		for j, predicate := range p.predicates {
+			if len(networkInterfaces) == 0 {
+				break
+			}
+			kept := []types.NetworkInterface{}
+			for _, networkInterface := range networkInterfaces {
+				subject := filterSubject{Result: result, NetworkInterface: networkInterface}
+				if sighting, ok := sightings[aws.ToString(networkInterface.NetworkInterfaceId)]; ok {
+					subject.Sighting = &sighting
+				}
+				keep, err := predicate.Keep(subject)
+				if err != nil {
+					return nil, err
+				}
+				if keep {
+					kept = append(kept, networkInterface)
+				}
+			}
+			removed := len(networkInterfaces) - len(kept)
+			p.removed[j] += removed
+			if len(kept) == 0 {
+				eliminations = append(eliminations, filterElimination{Region: result.Region, Group: result.Selector.Key(), Found: found, Filter: predicate.Name, Removed: removed})
+			}
+			networkInterfaces = kept
+		}
+		results[i].NetworkInterfaces = networkInterfaces
+	}
+	return eliminations, nil
+}
+
+// summary describes the number of interfaces removed by every filter.
+//
+// string: The summary, empty when no filter is selected.
+func (p *filterPipeline) summary() string {
+	parts := []string{}
+	for i, predicate := range p.predicates {
+		parts = append(parts, fmt.Sprintf("%s removed %d", predicate.Name, p.removed[i]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// conflictingFilters returns the combinations of client-side filters that cannot match any interface by construction.
+//
+// opts: The options of the scan.
+// []string: A description of every conflict.
+func conflictingFilters(opts scanOptions) []string {
+	conflicts := []string{}
+	if opts.InstanceId != "" && opts.Status == string(types.NetworkInterfaceStatusAvailable) {
+		conflicts = append(conflicts, "-status available cannot match -instance-id: available interfaces are not attached to an instance")
+	}
+	return conflicts
+}
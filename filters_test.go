@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestFilterPipeline(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	db := testGroup("sg-0e1b2c3d4e5f60718", "db")
+	where, err := compileWhere(`subnet_id != "subnet-9"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public := testInterface("eni-1", "i-1", web)
+	public.Association = &types.NetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.10")}
+	private := testInterface("eni-2", "i-1", web)
+	other := testInterface("eni-3", "i-2", web)
+	available := testInterface("eni-4", "", web)
+	dbInterface := testInterface("eni-5", "i-2", db)
+	results := []groupResult{
+		testResult("eu-west-1", "web", public, private, other, available),
+		testResult("eu-west-1", "db", dbInterface),
+	}
+	sightings := map[string]interfaceSighting{
+		"eni-1": {FirstSeen: now.Add(-time.Hour), LastSeen: now},
+		"eni-2": {FirstSeen: now.Add(-30 * 24 * time.Hour), LastSeen: now},
+		"eni-5": {FirstSeen: now.Add(-time.Hour), LastSeen: now},
+	}
+
+	p := newFilterPipeline(scanOptions{Status: "in-use", InstanceId: "i-1", PublicOnly: true, NewSince: 24 * time.Hour, Where: where}, now)
+	names := []string{}
+	for _, predicate := range p.predicates {
+		names = append(names, predicate.Name)
+	}
+	if strings.Join(names, ",") != "-status,-instance-id,-public-only,-new-since,-where" {
+		t.Errorf("predicates = %v, want the cheapest first", names)
+	}
+
+	eliminations, err := p.apply(results, sightings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[0].NetworkInterfaces) != 1 || aws.ToString(results[0].NetworkInterfaces[0].NetworkInterfaceId) != "eni-1" {
+		t.Errorf("web kept %d interfaces, want eni-1", len(results[0].NetworkInterfaces))
+	}
+	// -status removes eni-4, -instance-id eni-3 and eni-5, -public-only eni-2
+	if got := p.summary(); got != "-status removed 1, -instance-id removed 2, -public-only removed 1, -new-since removed 0, -where removed 0" {
+		t.Errorf("summary = %q", got)
+	}
+	if len(eliminations) != 1 || eliminations[0] != (filterElimination{Region: "eu-west-1", Group: "db", Found: 1, Filter: "-instance-id", Removed: 1}) {
+		t.Errorf("eliminations = %+v, want db emptied by -instance-id", eliminations)
+	}
+}
+
+func TestFilterPipelineNewSince(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	results := []groupResult{testResult("eu-west-1", "web", testInterface("eni-1", "", web), testInterface("eni-2", "", web), testInterface("eni-3", "", web))}
+	sightings := map[string]interfaceSighting{
+		"eni-1": {FirstSeen: now.Add(-7 * 24 * time.Hour)},
+		"eni-2": {FirstSeen: now.Add(-8 * 24 * time.Hour)},
+	}
+
+	p := newFilterPipeline(scanOptions{NewSince: 7 * 24 * time.Hour}, now)
+	if _, err := p.apply(results, sightings); err != nil {
+		t.Fatal(err)
+	}
+	// The window includes its start, an interface without a sighting is not new
+	if len(results[0].NetworkInterfaces) != 1 || aws.ToString(results[0].NetworkInterfaces[0].NetworkInterfaceId) != "eni-1" {
+		t.Errorf("kept %d interfaces, want eni-1", len(results[0].NetworkInterfaces))
+	}
+}
+
+func TestFilterPipelineEmpty(t *testing.T) {
+	p := newFilterPipeline(scanOptions{}, time.Now())
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	results := []groupResult{testResult("eu-west-1", "web", testInterface("eni-1", "", web)), testResult("eu-west-1", "db")}
+	eliminations, err := p.apply(results, nil)
+	if err != nil || len(eliminations) != 0 || len(results[0].NetworkInterfaces) != 1 || p.summary() != "" {
+		t.Errorf("no filter: eliminations %v, err %v, summary %q", eliminations, err, p.summary())
+	}
+}
+
+func TestConflictingFilters(t *testing.T) {
+	tests := []struct {
+		opts scanOptions
+		want int
+	}{
+		{scanOptions{Status: "available", InstanceId: "i-1"}, 1},
+		{scanOptions{Status: "in-use", InstanceId: "i-1"}, 0},
+		{scanOptions{Status: "available"}, 0},
+		{scanOptions{InstanceId: "i-1"}, 0},
+	}
+	for _, tt := range tests {
+		if got := conflictingFilters(tt.opts); len(got) != tt.want {
+			t.Errorf("conflictingFilters(%+v) = %q", tt.opts, got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go/middleware"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// testGroup returns a security group fixture.
+//
+// groupId: The group ID.
+// groupName: The group name.
+// types.SecurityGroup: The security group.
+func testGroup(groupId string, groupName string) types.SecurityGroup {
+	return types.SecurityGroup{GroupId: aws.String(groupId), GroupName: aws.String(groupName), VpcId: aws.String("vpc-1")}
+}
+
+// testInterface returns a network interface fixture carrying the given groups, in use when attached.
+//
+// networkInterfaceId: The interface ID.
+// instanceId: The instance the interface is attached to, empty for an unattached interface.
+// groups: The groups of the interface.
+// types.NetworkInterface: The network interface.
+func testInterface(networkInterfaceId string, instanceId string, groups ...types.SecurityGroup) types.NetworkInterface {
+	networkInterface := types.NetworkInterface{
+		NetworkInterfaceId: aws.String(networkInterfaceId),
+		Status:             types.NetworkInterfaceStatusAvailable,
+		SubnetId:           aws.String("subnet-1"),
+		VpcId:              aws.String("vpc-1"),
+		AvailabilityZone:   aws.String("eu-west-1a"),
+		PrivateIpAddress:   aws.String("10.0.0.1"),
+		InterfaceType:      types.NetworkInterfaceTypeInterface,
+	}
+	for _, group := range groups {
+		networkInterface.Groups = append(networkInterface.Groups, types.GroupIdentifier{GroupId: group.GroupId, GroupName: group.GroupName})
+	}
+	if instanceId != "" {
+		networkInterface.Status = types.NetworkInterfaceStatusInUse
+		networkInterface.Attachment = &types.NetworkInterfaceAttachment{InstanceId: aws.String(instanceId), DeviceIndex: aws.Int32(0)}
+	}
+	return networkInterface
+}
+
+// testInstance returns a running instance fixture.
+//
+// instanceId: The instance ID.
+// name: The Name tag, empty for none.
+// types.Instance: The instance.
+func testInstance(instanceId string, name string) types.Instance {
+	instance := types.Instance{
+		InstanceId:   aws.String(instanceId),
+		InstanceType: types.InstanceTypeT3Micro,
+		SubnetId:     aws.String("subnet-1"),
+		VpcId:        aws.String("vpc-1"),
+		State:        &types.InstanceState{Name: types.InstanceStateNameRunning},
+	}
+	if name != "" {
+		instance.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(name)}}
+	}
+	return instance
+}
+
+// testResult returns the result of a security group selected by name.
+//
+// region: The region.
+// groupName: The group name.
+// networkInterfaces: The interfaces found under the group.
+// groupResult: The result.
+func testResult(region string, groupName string, networkInterfaces ...types.NetworkInterface) groupResult {
+	return groupResult{
+		Region:            region,
+		Selector:          enilookup.Selector{Input: groupName, GroupName: groupName},
+		NetworkInterfaces: networkInterfaces,
+		Found:             len(networkInterfaces),
+	}
+}
+
+// testRun returns a run over two regions and three security groups, with instance, load balancer, Lambda and
+// unattached interfaces, tags, several private addresses, public IPs and permissions.
+//
+// With shuffled, everything the API returns in no particular order is reversed: the sections, the interfaces
+// and their tags, groups and addresses.
+//
+// shuffled: Whether to reverse the orders.
+// scanResult: The run.
+func testRun(shuffled bool) scanResult {
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	db := testGroup("sg-0e1b2c3d4e5f60718", "db")
+	attachTime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	instance := testInterface("eni-0aaa", "i-0aaa", web, db)
+	instance.Attachment.AttachTime = aws.Time(attachTime)
+	instance.TagSet = []types.Tag{{Key: aws.String("app"), Value: aws.String("shop")}, {Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("Name"), Value: aws.String("shop-0")}}
+	instance.PrivateIpAddresses = []types.NetworkInterfacePrivateIpAddress{
+		{PrivateIpAddress: aws.String("10.0.0.1"), Primary: aws.Bool(true)},
+		{PrivateIpAddress: aws.String("10.0.0.2"), Primary: aws.Bool(false)},
+	}
+	instance.Association = &types.NetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.10"), IpOwnerId: aws.String("amazon")}
+	instance.OwnerId = aws.String("123456789012")
+
+	loadBalancer := testInterface("eni-0bbb", "", web)
+	loadBalancer.Status = types.NetworkInterfaceStatusInUse
+	loadBalancer.InterfaceType = types.NetworkInterfaceType("load_balancer")
+	loadBalancer.RequesterManaged = aws.Bool(true)
+	loadBalancer.Description = aws.String("ELB app/shop-alb/0123456789abcdef")
+	loadBalancer.PrivateIpAddress = aws.String("172.16.0.5")
+	loadBalancer.OwnerId = aws.String("123456789012")
+
+	lambda := testInterface("eni-0ccc", "", db)
+	lambda.Status = types.NetworkInterfaceStatusInUse
+	lambda.InterfaceType = types.NetworkInterfaceTypeLambda
+	lambda.Description = aws.String("AWS Lambda VPC ENI-shop-worker-0123")
+	lambda.AvailabilityZone = aws.String("eu-west-1b")
+	lambda.SubnetId = aws.String("subnet-2")
+	lambda.OwnerId = aws.String("123456789012")
+
+	unattached := testInterface("eni-0ddd", "", db)
+	unattached.TagSet = []types.Tag{{Key: aws.String("owner"), Value: aws.String("team-a")}, {Key: aws.String("env"), Value: aws.String("dev")}}
+	unattached.OwnerId = aws.String("123456789012")
+
+	other := testInterface("eni-0eee", "i-0bbb", web)
+	other.AvailabilityZone = aws.String("us-east-1a")
+	other.OwnerId = aws.String("123456789012")
+
+	webResult := testResult("eu-west-1", "web", instance, loadBalancer)
+	webResult.Selector.Ordinal = 0
+	webResult.Permissions = map[string][]interfacePermission{"eni-0aaa": {
+		{PermissionId: "eni-perm-1", AccountId: "210987654321", Permission: "INSTANCE-ATTACH", State: "granted"},
+		{PermissionId: "eni-perm-2", Service: "elasticloadbalancing.amazonaws.com", Permission: "INSTANCE-ATTACH", State: "granted"},
+	}}
+	dbResult := testResult("eu-west-1", "db", instance, lambda, unattached)
+	dbResult.Selector.Ordinal = 1
+	otherResult := testResult("us-east-1", "web", other)
+	run := scanResult{
+		Results: []groupResult{webResult, dbResult, otherResult},
+		Instances: map[string]types.Instance{
+			"i-0aaa": testInstance("i-0aaa", "shop-0"),
+			"i-0bbb": testInstance("i-0bbb", ""),
+		},
+		FailedRegions: []regionFailure{},
+		Warnings:      []runWarning{},
+	}
+	if shuffled {
+		reverseTestRun(&run)
+	}
+	return run
+}
+
+// reverseTestRun reverses the orders of a run that the API does not guarantee, copying what it changes.
+//
+// run: The run, modified in place.
+func reverseTestRun(run *scanResult) {
+	slices.Reverse(run.Results)
+	for i := range run.Results {
+		networkInterfaces := slices.Clone(run.Results[i].NetworkInterfaces)
+		slices.Reverse(networkInterfaces)
+		for j := range networkInterfaces {
+			networkInterfaces[j].TagSet = slices.Clone(networkInterfaces[j].TagSet)
+			slices.Reverse(networkInterfaces[j].TagSet)
+			networkInterfaces[j].Groups = slices.Clone(networkInterfaces[j].Groups)
+			slices.Reverse(networkInterfaces[j].Groups)
+			networkInterfaces[j].PrivateIpAddresses = slices.Clone(networkInterfaces[j].PrivateIpAddresses)
+			slices.Reverse(networkInterfaces[j].PrivateIpAddresses)
+		}
+		run.Results[i].NetworkInterfaces = networkInterfaces
+		if run.Results[i].Permissions == nil {
+			continue
+		}
+		permissions := map[string][]interfacePermission{}
+		for id, list := range run.Results[i].Permissions {
+			list = slices.Clone(list)
+			slices.Reverse(list)
+			permissions[id] = list
+		}
+		run.Results[i].Permissions = permissions
+	}
+}
+
+// testGroupNames returns the names of n security groups.
+//
+// n: The number of groups.
+// []string: The names.
+func testGroupNames(n int) []string {
+	names := []string{}
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("group-%d", i))
+	}
+	return names
+}
+
+// lookupEC2Config returns an AWS configuration whose EC2 calls are answered by a test server after a delay, every
+// DescribeNetworkInterfaces call with an interface of the group it filters on, from the failFrom-th call on with
+// an InvalidParameterValue error.
+//
+// t: The test.
+// delay: How long the server takes to answer a call.
+// failFrom: The first call failing, 0 for none.
+// apiOptions: The SDK middleware of the configuration.
+// aws.Config: The configuration.
+// *atomic.Int32: The number of calls the server answered.
+func lookupEC2Config(t *testing.T, delay time.Duration, failFrom int32, apiOptions []func(*middleware.Stack) error) (aws.Config, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(delay)
+		call := calls.Add(1)
+		w.Header().Set("Content-Type", "text/xml")
+		if action := r.Form.Get("Action"); action != "DescribeNetworkInterfaces" {
+			t.Errorf("unexpected call %s", action)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if failFrom > 0 && call >= failFrom {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `<Response><Errors><Error><Code>InvalidParameterValue</Code><Message>injected failure</Message></Error></Errors><RequestID>test</RequestID></Response>`)
+			return
+		}
+		group := r.Form.Get("Filter.1.Value.1")
+		fmt.Fprintf(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><networkInterfaceSet><item><networkInterfaceId>eni-%[1]s</networkInterfaceId><status>available</status><groupSet><item><groupId>sg-%[1]s</groupId><groupName>%[1]s</groupName></item></groupSet></item></networkInterfaceSet></DescribeNetworkInterfacesResponse>`, group)
+	}))
+	t.Cleanup(server.Close)
+	return aws.Config{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+		APIOptions:       apiOptions,
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...any) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: server.URL}, nil
+		}),
+	}, calls
+}
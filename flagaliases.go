@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// flagAlias maps a deprecated flag name to its replacement.
+type flagAlias struct {
+	Deprecated string
+	Canonical  string
+}
+
+// flagAliases is the table of the deprecated flag names that keep working, so existing scripts are not broken
+// when a released flag is renamed. Add an entry here when renaming one, a flag that never shipped needs none.
+var flagAliases = []flagAlias{}
+
+// deprecatedFlag forwards the value of a deprecated flag to its canonical flag and records its use.
+type deprecatedFlag struct {
+	alias  flagAlias
+	target flag.Value
+	used   *[]flagAlias
+}
+
+// Set sets the canonical flag and records the use of the deprecated name.
+func (d *deprecatedFlag) Set(value string) error {
+	*d.used = append(*d.used, d.alias)
+	return d.target.Set(value)
+}
+
+// String returns the value of the canonical flag.
+func (d *deprecatedFlag) String() string {
+	if d.target == nil {
+		return ""
+	}
+	return d.target.String()
+}
+
+// IsBoolFlag reports whether the canonical flag is a boolean flag, so the deprecated name can be used without a value.
+func (d *deprecatedFlag) IsBoolFlag() bool {
+	boolFlag, ok := d.target.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
+
+// registerFlagAliases defines the deprecated names of the flag set and hides them from its usage.
+//
+// It must be called once every canonical flag is defined.
+//
+// fs: The flag set.
+// aliases: The deprecated names, flagAliases for the command line.
+// *[]flagAlias: The aliases used on the command line, filled while parsing.
+func registerFlagAliases(fs *flag.FlagSet, aliases []flagAlias) *[]flagAlias {
+	used := &[]flagAlias{}
+	deprecated := map[string]bool{}
+	for _, alias := range aliases {
+		canonical := fs.Lookup(alias.Canonical)
+		if canonical == nil {
+			panic(fmt.Sprintf("flag alias -%s refers to the undefined flag -%s", alias.Deprecated, alias.Canonical))
+		}
+		fs.Var(&deprecatedFlag{alias: alias, target: canonical.Value, used: used}, alias.Deprecated, "Deprecated, use -"+alias.Canonical)
+		deprecated[alias.Deprecated] = true
+	}
+
+	// Only show the canonical names in -h
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		display := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		display.SetOutput(fs.Output())
+		fs.VisitAll(func(f *flag.Flag) {
+			if deprecated[f.Name] {
+				return
+			}
+			display.Var(f.Value, f.Name, f.Usage)
+			display.Lookup(f.Name).DefValue = f.DefValue
+		})
+		display.PrintDefaults()
+	}
+	return used
+}
+
+// checkFlagAliases reports the deprecated flag names used on the command line.
+//
+// Every deprecated name is reported once, naming its replacement. With strict set, using one is an error.
+//
+// w: The writer the deprecation notices are printed to.
+// used: The aliases used on the command line.
+// strict: Whether deprecated names are rejected.
+// error: With strict, the first deprecated name used, nothing being printed.
+func checkFlagAliases(w io.Writer, used []flagAlias, strict bool) error {
+	seen := map[string]bool{}
+	names := []flagAlias{}
+	for _, alias := range used {
+		if !seen[alias.Deprecated] {
+			seen[alias.Deprecated] = true
+			names = append(names, alias)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Deprecated < names[j].Deprecated })
+
+	for _, alias := range names {
+		if strict {
+			return fmt.Errorf("-%s is deprecated, use -%s (rejected by -strict-flags)", alias.Deprecated, alias.Canonical)
+		}
+	}
+	for _, alias := range names {
+		fmt.Fprintf(w, "warning: -%s is deprecated, use -%s\n", alias.Deprecated, alias.Canonical)
+	}
+	return nil
+}
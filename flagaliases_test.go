@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newAliasedFlagSet returns a flag set with a string and a boolean flag, both renamed.
+//
+// *flag.FlagSet: The flag set.
+// *string: The value of -report-file.
+// *bool: The value of -quiet.
+// *[]flagAlias: The aliases used, filled while parsing.
+func newAliasedFlagSet() (*flag.FlagSet, *string, *bool, *[]flagAlias) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	reportFile := fs.String("report-file", "", "The report")
+	quiet := fs.Bool("quiet", false, "Print less")
+	used := registerFlagAliases(fs, []flagAlias{
+		{Deprecated: "report", Canonical: "report-file"},
+		{Deprecated: "silent", Canonical: "quiet"},
+	})
+	return fs, reportFile, quiet, used
+}
+
+func TestFlagAliasesPassThrough(t *testing.T) {
+	fs, reportFile, quiet, used := newAliasedFlagSet()
+	if err := fs.Parse([]string{"-report", "a.json", "-silent", "-report", "b.json"}); err != nil {
+		t.Fatal(err)
+	}
+	if *reportFile != "b.json" || !*quiet {
+		t.Errorf("-report-file = %q, -quiet = %v; want the values given to the deprecated names", *reportFile, *quiet)
+	}
+
+	var notices bytes.Buffer
+	if err := checkFlagAliases(&notices, *used, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "warning: -report is deprecated, use -report-file\nwarning: -silent is deprecated, use -quiet\n"
+	if notices.String() != want {
+		t.Errorf("notices =\n%s\nwant, once per name,\n%s", notices.String(), want)
+	}
+}
+
+func TestFlagAliasesCanonical(t *testing.T) {
+	fs, reportFile, _, used := newAliasedFlagSet()
+	if err := fs.Parse([]string{"-report-file", "a.json"}); err != nil {
+		t.Fatal(err)
+	}
+	var notices bytes.Buffer
+	if err := checkFlagAliases(&notices, *used, true); err != nil || notices.Len() > 0 || *reportFile != "a.json" {
+		t.Errorf("canonical names: err %v, notices %q", err, notices.String())
+	}
+}
+
+func TestFlagAliasesStrict(t *testing.T) {
+	fs, _, _, used := newAliasedFlagSet()
+	if err := fs.Parse([]string{"-silent"}); err != nil {
+		t.Fatal(err)
+	}
+	var notices bytes.Buffer
+	err := checkFlagAliases(&notices, *used, true)
+	if err == nil || err.Error() != "-silent is deprecated, use -quiet (rejected by -strict-flags)" || notices.Len() > 0 {
+		t.Errorf("err = %v, notices = %q", err, notices.String())
+	}
+}
+
+func TestFlagAliasesUsage(t *testing.T) {
+	fs, _, _, _ := newAliasedFlagSet()
+	var usage bytes.Buffer
+	fs.SetOutput(&usage)
+	fs.Usage()
+	if !strings.Contains(usage.String(), "-report-file") || !strings.Contains(usage.String(), "-quiet") {
+		t.Errorf("usage misses a canonical name:\n%s", usage.String())
+	}
+	if strings.Contains(usage.String(), "-report ") || strings.Contains(usage.String(), "-silent") || strings.Contains(usage.String(), "Deprecated") {
+		t.Errorf("usage lists a deprecated name:\n%s", usage.String())
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "dot", description: "the graph of security groups, interfaces, instances and managed resources, in Graphviz DOT", contentType: "text/vnd.graphviz", extension: ".dot", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		printDOT(w, newGraph(run.Results, run.Instances, run.Sightings, opts.AccountId))
+		// DOT has no room for the notes, they go to stderr
+		printRunNotes(os.Stderr, run, opts)
+		return nil
+	}})
+	registerRenderer(formatRenderer{name: "graph-json", description: "the same graph as JSON, see schemas/graph-json.schema.json", contentType: "application/json", extension: ".json", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if err := writeJSON(w, newGraph(run.Results, run.Instances, run.Sightings, opts.AccountId)); err != nil {
+			return err
+		}
+		printRunNotes(os.Stderr, run, opts)
+		return nil
+	}})
+}
+
+// Graph node types. Node IDs are prefixed with their type so they are unique across types.
+const (
+	nodeSecurityGroup    = "security-group"
+	nodeNetworkInterface = "network-interface"
+	nodeInstance         = "instance"
+)
+
+// Graph edge types.
+const (
+	edgeMemberOf   = "member_of"
+	edgeAttachedTo = "attached_to"
+	edgeManagedBy  = "managed_by"
+)
+
+// graph is the in-memory model shared by the dot and graph-json outputs.
+type graph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// graphNode is a security group, network interface, instance or managed resource.
+type graphNode struct {
+	Id    string            `json:"id"`
+	Type  string            `json:"type"`
+	Label string            `json:"label"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+// graphEdge links two nodes.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// newGraph builds the graph of the results.
+//
+// Network interfaces are linked to the security groups they were found for, to the instances they are attached to
+// and to the managed resources they belong to. Nodes and edges are sorted so the output is stable.
+//
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// accountId: The account the security groups belong to.
+// graph: The graph.
+func newGraph(results []groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting, accountId string) graph {
+	nodes := map[string]graphNode{}
+	edges := map[graphEdge]bool{}
+
+	addNode := func(nodeType string, key string, label string, attrs map[string]string) string {
+		id := nodeType + ":" + key
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = graphNode{Id: id, Type: nodeType, Label: label, Attrs: attrs}
+		}
+		return id
+	}
+
+	for _, result := range results {
+		groupKey := result.Selector.GroupId
+		if groupKey == "" {
+			groupKey = result.Selector.GroupName
+		}
+		groupId := addNode(nodeSecurityGroup, groupKey, result.Selector.Input, map[string]string{
+			"region":  result.Region,
+			"account": accountId,
+		})
+
+		for _, networkInterface := range result.NetworkInterfaces {
+			account := aws.ToString(networkInterface.OwnerId)
+			attrs := map[string]string{
+				"region":         result.Region,
+				"account":        account,
+				"status":         string(networkInterface.Status),
+				"interface_type": string(networkInterface.InterfaceType),
+			}
+			if sighting, ok := sightings[aws.ToString(networkInterface.NetworkInterfaceId)]; ok {
+				attrs["first_seen"] = sighting.FirstSeen.UTC().Format(time.RFC3339)
+				attrs["last_seen"] = sighting.LastSeen.UTC().Format(time.RFC3339)
+			}
+			networkInterfaceId := addNode(nodeNetworkInterface, aws.ToString(networkInterface.NetworkInterfaceId), aws.ToString(networkInterface.NetworkInterfaceId), attrs)
+			edges[graphEdge{From: networkInterfaceId, To: groupId, Type: edgeMemberOf}] = true
+
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+				instanceKey := aws.ToString(networkInterface.Attachment.InstanceId)
+				label := instanceKey
+				if name := tagValue(instances[instanceKey].Tags, "Name"); name != "" {
+					label = name
+				}
+				instanceId := addNode(nodeInstance, instanceKey, label, map[string]string{
+					"region":  result.Region,
+					"account": account,
+				})
+				edges[graphEdge{From: networkInterfaceId, To: instanceId, Type: edgeAttachedTo}] = true
+			}
+
+			classification := enilookup.Classify(networkInterface)
+			if classification.ManagedBy != enilookup.ManagedByEC2 && classification.ResourceId != "" {
+				resourceId := addNode(strings.ToLower(string(classification.ManagedBy)), classification.ResourceId, classification.ResourceId, map[string]string{
+					"region":  result.Region,
+					"account": account,
+				})
+				edges[graphEdge{From: networkInterfaceId, To: resourceId, Type: edgeManagedBy}] = true
+			}
+		}
+	}
+
+	g := graph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	for _, node := range nodes {
+		g.Nodes = append(g.Nodes, node)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Id < g.Nodes[j].Id })
+	for edge := range edges {
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		if g.Edges[i].To != g.Edges[j].To {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].Type < g.Edges[j].Type
+	})
+
+	return g
+}
+
+// printDOT prints the graph in the Graphviz DOT language.
+//
+// w: The writer to print to.
+// g: The graph to print.
+func printDOT(w io.Writer, g graph) {
+	shapes := map[string]string{
+		nodeSecurityGroup:    "box",
+		nodeNetworkInterface: "ellipse",
+		nodeInstance:         "component",
+	}
+
+	fmt.Fprintln(w, "digraph network_interfaces {")
+	for _, node := range g.Nodes {
+		shape, ok := shapes[node.Type]
+		if !ok {
+			shape = "hexagon"
+		}
+		fmt.Fprintf(w, "  %q [label=%q, shape=%s];\n", node.Id, node.Label, shape)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Type)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// testGraphRun returns a run with an instance interface, a load balancer interface and an unattached interface.
+//
+// scanResult: The run.
+func testGraphRun() scanResult {
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	db := testGroup("sg-0e1b2c3d4e5f60718", "db")
+	loadBalancer := testInterface("eni-2", "", web)
+	loadBalancer.Status = types.NetworkInterfaceStatusInUse
+	loadBalancer.InterfaceType = types.NetworkInterfaceType("load_balancer")
+	loadBalancer.RequesterManaged = aws.Bool(true)
+	loadBalancer.Description = aws.String("ELB app/web-alb/0123456789abcdef")
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	return scanResult{
+		Results: []groupResult{
+			testResult("eu-west-1", "web", testInterface("eni-1", "i-1", web), loadBalancer),
+			testResult("eu-west-1", "db", testInterface("eni-1", "i-1", web, db), testInterface("eni-3", "", db)),
+		},
+		Instances: map[string]types.Instance{"i-1": testInstance("i-1", "app-1")},
+		Sightings: map[string]interfaceSighting{"eni-1": {FirstSeen: seen, LastSeen: seen}},
+	}
+}
+
+func TestGraphJSONSchema(t *testing.T) {
+	schema, err := loadSchema("schemas/graph-json.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := render(&out, testGraphRun(), renderOptions{Output: "graph-json", AccountId: "123456789012"}); err != nil {
+		t.Fatal(err)
+	}
+	var document any
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if problems := schema.validate(document, "$"); len(problems) > 0 {
+		t.Errorf("graph-json does not match its schema:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	run := testGraphRun()
+	g := newGraph(run.Results, run.Instances, run.Sightings, "123456789012")
+
+	ids := []string{}
+	labels := map[string]string{}
+	for _, node := range g.Nodes {
+		ids = append(ids, node.Id)
+		labels[node.Id] = node.Label
+	}
+	want := "elb:web-alb,instance:i-1,network-interface:eni-1,network-interface:eni-2,network-interface:eni-3,security-group:db,security-group:web"
+	if strings.Join(ids, ",") != want {
+		t.Errorf("nodes = %s, want %s", strings.Join(ids, ","), want)
+	}
+	if labels["instance:i-1"] != "app-1" {
+		t.Errorf("instance label = %q, want its Name tag", labels["instance:i-1"])
+	}
+
+	edges := []string{}
+	for _, edge := range g.Edges {
+		edges = append(edges, edge.From+" "+edge.Type+" "+edge.To)
+	}
+	wantEdges := []string{
+		"network-interface:eni-1 attached_to instance:i-1",
+		"network-interface:eni-1 member_of security-group:db",
+		"network-interface:eni-1 member_of security-group:web",
+		"network-interface:eni-2 managed_by elb:web-alb",
+		"network-interface:eni-2 member_of security-group:web",
+		"network-interface:eni-3 member_of security-group:db",
+	}
+	if strings.Join(edges, "\n") != strings.Join(wantEdges, "\n") {
+		t.Errorf("edges =\n%s\nwant\n%s", strings.Join(edges, "\n"), strings.Join(wantEdges, "\n"))
+	}
+}
+
+func TestSchemaValidatorRejects(t *testing.T) {
+	schema, err := loadSchema("schemas/graph-json.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document any
+	invalid := `{"nodes": [{"id": "eni-1", "type": "network-interface", "label": "eni-1", "attrs": {"region": "eu-west-1"}}], "edges": [{"from": "a", "to": "b", "type": "owns"}], "extra": 1}`
+	if err := json.Unmarshal([]byte(invalid), &document); err != nil {
+		t.Fatal(err)
+	}
+	if problems := schema.validate(document, "$"); len(problems) != 4 {
+		t.Errorf("problems = %q, want the pattern, the account, the edge type and the extra property", problems)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// untaggedApp is the bucket used for interfaces that carry no application tag.
+const untaggedApp = "(untagged)"
+
+// appBucket holds the network interfaces that belong to a single application.
+type appBucket struct {
+	App                string
+	SecurityGroupNames []string
+	NetworkInterfaces  []types.NetworkInterface
+}
+
+// groupByApp buckets the network interfaces of the results by the value of the application tag.
+//
+// The tag is looked up first on the network interface and then on the attached instance, when the instance was resolved.
+// Interfaces matched by several security groups are only counted once per application.
+//
+// results: The network interfaces found per security group.
+// appTagKey: The tag key holding the application name.
+// instances: The resolved instances keyed by instance ID.
+// []appBucket: The buckets sorted by application name, with the untagged bucket last.
+func groupByApp(results []groupResult, appTagKey string, instances map[string]types.Instance) []appBucket {
+	buckets := map[string]*appBucket{}
+	seenInterfaces := map[string]map[string]bool{}
+	seenGroups := map[string]map[string]bool{}
+
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			app := appForNetworkInterface(networkInterface, appTagKey, instances)
+
+			bucket, ok := buckets[app]
+			if !ok {
+				bucket = &appBucket{App: app}
+				buckets[app] = bucket
+				seenInterfaces[app] = map[string]bool{}
+				seenGroups[app] = map[string]bool{}
+			}
+			if !seenGroups[app][result.Selector.Input] {
+				seenGroups[app][result.Selector.Input] = true
+				bucket.SecurityGroupNames = append(bucket.SecurityGroupNames, result.Selector.Input)
+			}
+			if !seenInterfaces[app][aws.ToString(networkInterface.NetworkInterfaceId)] {
+				seenInterfaces[app][aws.ToString(networkInterface.NetworkInterfaceId)] = true
+				bucket.NetworkInterfaces = append(bucket.NetworkInterfaces, networkInterface)
+			}
+		}
+	}
+
+	// Sort the buckets by application name, keeping the untagged bucket last
+	sorted := []appBucket{}
+	for _, bucket := range buckets {
+		sorted = append(sorted, *bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i].App == untaggedApp) != (sorted[j].App == untaggedApp) {
+			return sorted[j].App == untaggedApp
+		}
+		return sorted[i].App < sorted[j].App
+	})
+
+	return sorted
+}
+
+// appForNetworkInterface returns the application a network interface belongs to.
+//
+// networkInterface: The network interface.
+// appTagKey: The tag key holding the application name.
+// instances: The resolved instances keyed by instance ID.
+// string: The application name, or the untagged bucket name when no tag was found.
+func appForNetworkInterface(networkInterface types.NetworkInterface, appTagKey string, instances map[string]types.Instance) string {
+	if app := tagValue(networkInterface.TagSet, appTagKey); app != "" {
+		return app
+	}
+	if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+		if instance, ok := instances[aws.ToString(networkInterface.Attachment.InstanceId)]; ok {
+			if app := tagValue(instance.Tags, appTagKey); app != "" {
+				return app
+			}
+		}
+	}
+	return untaggedApp
+}
+
+// printAppReport prints the network interfaces grouped by application, followed by a summary.
+//
+// w: The writer to print to.
+// buckets: The application buckets to print.
+func printAppReport(w io.Writer, buckets []appBucket) {
+	for _, bucket := range buckets {
+		fmt.Fprintf(w, "Application: %s\n", displayText(bucket.App))
+		for _, networkInterface := range bucket.NetworkInterfaces {
+			fmt.Fprintf(w, "  NetworkInterface ID: %s\n", derefOr(networkInterface.NetworkInterfaceId, missingValue))
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+				fmt.Fprintf(w, "  InstanceId: %s\n", derefOr(networkInterface.Attachment.InstanceId, missingValue))
+			}
+			fmt.Fprintf(w, "  Status: %s\n", networkInterface.Status)
+			fmt.Fprintln(w)
+		}
+	}
+
+	// Print the summary
+	fmt.Fprintln(w, "Summary:")
+	for _, bucket := range buckets {
+		fmt.Fprintf(w, "  %s: %d network interfaces, %d security groups\n", displayText(bucket.App), len(bucket.NetworkInterfaces), len(bucket.SecurityGroupNames))
+	}
+}
+
+// appReport is the JSON document describing the network interfaces grouped by application.
+type appReport struct {
+	Applications  []appBucketReport `json:"applications"`
+	FailedRegions []regionFailure   `json:"failed_regions"`
+}
+
+// appBucketReport is the JSON form of an appBucket.
+type appBucketReport struct {
+	App                string                   `json:"app"`
+	SecurityGroupNames []string                 `json:"security_group_names"`
+	NetworkInterfaces  []networkInterfaceReport `json:"network_interfaces"`
+}
+
+// newAppReport builds the JSON document for the application buckets.
+//
+// buckets: The application buckets.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// failedRegions: The regions whose scan failed.
+// appReport: The JSON document.
+func newAppReport(buckets []appBucket, instances map[string]types.Instance, sightings map[string]interfaceSighting, failedRegions []regionFailure) appReport {
+	applications := []appBucketReport{}
+	for _, bucket := range buckets {
+		applications = append(applications, appBucketReport{
+			App:                bucket.App,
+			SecurityGroupNames: bucket.SecurityGroupNames,
+			NetworkInterfaces:  newNetworkInterfaceReports(bucket.NetworkInterfaces, instances, nil, sightings),
+		})
+	}
+	return appReport{Applications: applications, FailedRegions: failedRegions}
+}
+
+// unattachedInstance is the bucket used for interfaces that are not attached to an instance.
+const unattachedInstance = "(unattached)"
+
+// instanceBucket holds the network interfaces attached to a single instance.
+type instanceBucket struct {
+	InstanceId         string
+	SecurityGroupNames []string
+	NetworkInterfaces  []types.NetworkInterface
+}
+
+// groupByInstance buckets the network interfaces of the results by the instance they are attached to.
+//
+// Interfaces matched by several security groups are only counted once per instance.
+//
+// results: The network interfaces found per security group.
+// []instanceBucket: The buckets sorted by instance ID, with the unattached bucket last.
+func groupByInstance(results []groupResult) []instanceBucket {
+	buckets := map[string]*instanceBucket{}
+	seenInterfaces := map[string]map[string]bool{}
+	seenGroups := map[string]map[string]bool{}
+
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			instanceId := unattachedInstance
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+				instanceId = aws.ToString(networkInterface.Attachment.InstanceId)
+			}
+
+			bucket, ok := buckets[instanceId]
+			if !ok {
+				bucket = &instanceBucket{InstanceId: instanceId}
+				buckets[instanceId] = bucket
+				seenInterfaces[instanceId] = map[string]bool{}
+				seenGroups[instanceId] = map[string]bool{}
+			}
+			if !seenGroups[instanceId][result.Selector.Input] {
+				seenGroups[instanceId][result.Selector.Input] = true
+				bucket.SecurityGroupNames = append(bucket.SecurityGroupNames, result.Selector.Input)
+			}
+			if !seenInterfaces[instanceId][aws.ToString(networkInterface.NetworkInterfaceId)] {
+				seenInterfaces[instanceId][aws.ToString(networkInterface.NetworkInterfaceId)] = true
+				bucket.NetworkInterfaces = append(bucket.NetworkInterfaces, networkInterface)
+			}
+		}
+	}
+
+	// Sort the buckets by instance ID, keeping the unattached bucket last
+	sorted := []instanceBucket{}
+	for _, bucket := range buckets {
+		sorted = append(sorted, *bucket)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i].InstanceId == unattachedInstance) != (sorted[j].InstanceId == unattachedInstance) {
+			return sorted[j].InstanceId == unattachedInstance
+		}
+		return sorted[i].InstanceId < sorted[j].InstanceId
+	})
+
+	return sorted
+}
+
+// printInstanceReport prints the network interfaces grouped by instance, followed by a summary.
+//
+// w: The writer to print to.
+// buckets: The instance buckets to print.
+// instances: The resolved instances keyed by instance ID.
+// effectiveRules: The consolidated allow-lists keyed by instance ID, set with -effective-rules. May be nil.
+func printInstanceReport(w io.Writer, buckets []instanceBucket, instances map[string]types.Instance, effectiveRules map[string]instanceRules) {
+	for _, bucket := range buckets {
+		if name := tagValue(instances[bucket.InstanceId].Tags, "Name"); name != "" {
+			fmt.Fprintf(w, "Instance: %s (%s)\n", bucket.InstanceId, displayText(name))
+		} else {
+			fmt.Fprintf(w, "Instance: %s\n", bucket.InstanceId)
+		}
+		for _, networkInterface := range bucket.NetworkInterfaces {
+			fmt.Fprintf(w, "  NetworkInterface ID: %s\n", derefOr(networkInterface.NetworkInterfaceId, missingValue))
+			fmt.Fprintf(w, "  Status: %s\n", networkInterface.Status)
+			fmt.Fprintln(w)
+		}
+		if rules, ok := effectiveRules[bucket.InstanceId]; ok {
+			printEffectiveRules(w, rules)
+		}
+	}
+
+	// Print the summary
+	fmt.Fprintln(w, "Summary:")
+	for _, bucket := range buckets {
+		fmt.Fprintf(w, "  %s: %d network interfaces, %d security groups\n", bucket.InstanceId, len(bucket.NetworkInterfaces), len(bucket.SecurityGroupNames))
+	}
+}
+
+// instanceReport is the JSON document describing the network interfaces grouped by instance.
+type instanceReport struct {
+	Instances     []instanceBucketReport `json:"instances"`
+	FailedRegions []regionFailure        `json:"failed_regions"`
+}
+
+// instanceBucketReport is the JSON form of an instanceBucket.
+type instanceBucketReport struct {
+	InstanceId         string                   `json:"instance_id"`
+	InstanceName       string                   `json:"instance_name,omitempty"`
+	SecurityGroupNames []string                 `json:"security_group_names"`
+	NetworkInterfaces  []networkInterfaceReport `json:"network_interfaces"`
+	EffectiveRules     *instanceRules           `json:"effective_rules,omitempty"`
+}
+
+// newInstanceReport builds the JSON document for the instance buckets.
+//
+// buckets: The instance buckets.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// effectiveRules: The consolidated allow-lists keyed by instance ID, set with -effective-rules. May be nil.
+// failedRegions: The regions whose scan failed.
+// instanceReport: The JSON document.
+func newInstanceReport(buckets []instanceBucket, instances map[string]types.Instance, sightings map[string]interfaceSighting, effectiveRules map[string]instanceRules, failedRegions []regionFailure) instanceReport {
+	reports := []instanceBucketReport{}
+	for _, bucket := range buckets {
+		report := instanceBucketReport{
+			InstanceId:         bucket.InstanceId,
+			InstanceName:       tagValue(instances[bucket.InstanceId].Tags, "Name"),
+			SecurityGroupNames: bucket.SecurityGroupNames,
+			NetworkInterfaces:  newNetworkInterfaceReports(bucket.NetworkInterfaces, instances, nil, sightings),
+		}
+		if rules, ok := effectiveRules[bucket.InstanceId]; ok {
+			report.EffectiveRules = &rules
+		}
+		reports = append(reports, report)
+	}
+	return instanceReport{Instances: reports, FailedRegions: failedRegions}
+}
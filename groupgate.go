@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// groupGate asks for confirmation before a run looks up more security groups than -max-groups.
+type groupGate struct {
+	// max is the number of groups above which the run asks, 0 never asks
+	max int
+	// yes proceeds without asking
+	yes bool
+	// interactive asks on the terminal, instead of aborting
+	interactive bool
+	// passed is set once the run was allowed, so -watch and -verify passes do not ask again
+	passed bool
+}
+
+// groupGateError is returned when a run selecting more groups than -max-groups is not confirmed.
+type groupGateError struct {
+	groups int
+	max    int
+}
+
+// Error returns the number of groups and how to proceed.
+func (e *groupGateError) Error() string {
+	return fmt.Sprintf("%d security groups selected, more than -max-groups %d; pass -yes, raise -max-groups or set it to 0 to proceed", e.groups, e.max)
+}
+
+// check lets the run proceed, asks for confirmation or aborts it, depending on the number of selected groups.
+//
+// groups: The number of security groups selected across the regions, after resolution.
+// calls: The estimated number of API calls of the lookups.
+// error: A *groupGateError when the run must not proceed.
+func (g *groupGate) check(groups int, calls int) error {
+	if g == nil || g.max == 0 || g.passed || groups <= g.max {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%d security groups selected, more than -max-groups %d: an estimated %d API calls\n", groups, g.max, calls)
+	if !g.yes {
+		if !g.interactive {
+			return &groupGateError{groups: groups, max: g.max}
+		}
+		fmt.Fprintf(os.Stderr, "Proceed? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return &groupGateError{groups: groups, max: g.max}
+		}
+	}
+	g.passed = true
+	return nil
+}
+
+// estimateAPICalls estimates the number of API calls of the lookups of a run, at their least.
+//
+// Every group takes at least one DescribeNetworkInterfaces page. The enrichment lookups are batched per region,
+// or per group when the groups are streamed, and take at least one call per batch.
+//
+// groups: The number of security groups selected across the regions.
+// regions: The number of regions with selected groups.
+// opts: What to look up besides the network interfaces.
+// int: The estimated number of API calls.
+func estimateAPICalls(groups int, regions int, opts scanOptions) int {
+	batches := regions
+	if opts.OnGroup != nil {
+		batches = groups
+	}
+	perBatch := 0
+	for _, enabled := range []bool{opts.ShowPermissions, opts.ResolveInstances, opts.PublicOnly, opts.EipAudit} {
+		if enabled {
+			perBatch++
+		}
+	}
+	calls := groups + batches*perBatch
+	if opts.IpCapacity {
+		// DescribeInstanceTypes runs once per region on the resolved instances
+		calls += regions
+	}
+	return calls
+}
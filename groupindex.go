@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// groupIndex indexes the security groups of the network interfaces of a run, built once so features comparing
+// groups do not rescan the Groups of every interface, which branch and shared service ENIs make long.
+//
+// Code relating interfaces and groups should go through the index rather than loop over networkInterface.Groups,
+// TestGroupsGoThroughIndex lists the code exempt from it.
+type groupIndex struct {
+	// groupIds holds the group IDs of every interface, in the order of its Groups, keyed by interface ID
+	groupIds map[string][]string
+	// named holds the IDs of the groups using a name, in the order they were first seen, keyed by group name
+	named map[string][]string
+	// namedSet holds the same IDs as named, as sets
+	namedSet map[string]map[string]bool
+	// names holds the name of every group, keyed by group ID
+	names map[string]string
+}
+
+// newGroupIndex indexes the security groups of the network interfaces of the results.
+//
+// results: The network interfaces found per security group.
+// *groupIndex: The index.
+func newGroupIndex(results []groupResult) *groupIndex {
+	index := &groupIndex{
+		groupIds: map[string][]string{},
+		named:    map[string][]string{},
+		namedSet: map[string]map[string]bool{},
+		names:    map[string]string{},
+	}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if _, ok := index.groupIds[networkInterfaceId]; ok {
+				// An interface found for several groups
+				continue
+			}
+			groupIds := make([]string, 0, len(networkInterface.Groups))
+			for _, group := range networkInterface.Groups {
+				groupId, groupName := aws.ToString(group.GroupId), aws.ToString(group.GroupName)
+				groupIds = append(groupIds, groupId)
+				index.names[groupId] = groupName
+				if index.namedSet[groupName] == nil {
+					index.namedSet[groupName] = map[string]bool{}
+				}
+				if !index.namedSet[groupName][groupId] {
+					index.namedSet[groupName][groupId] = true
+					index.named[groupName] = append(index.named[groupName], groupId)
+				}
+			}
+			index.groupIds[networkInterfaceId] = groupIds
+		}
+	}
+	return index
+}
+
+// groupsOf returns the IDs of the security groups of a network interface.
+//
+// networkInterfaceId: The ID of the network interface.
+// []string: The group IDs, in the order of the Groups of the interface.
+func (index *groupIndex) groupsOf(networkInterfaceId string) []string {
+	return index.groupIds[networkInterfaceId]
+}
+
+// nameOf returns the name of a security group.
+//
+// groupId: The group ID.
+// string: The group name, empty for a group of no indexed interface.
+func (index *groupIndex) nameOf(groupId string) string {
+	return index.names[groupId]
+}
+
+// selected returns the IDs of the security groups matching a selector.
+//
+// Groups selected by name are identified through the groups of the indexed interfaces, since a name may be used
+// by groups in several VPCs.
+//
+// selector: The security group, selected by name or by ID.
+// []string: The group IDs, in the order they were first seen.
+// map[string]bool: The same IDs, as a set.
+func (index *groupIndex) selected(selector enilookup.Selector) ([]string, map[string]bool) {
+	if selector.GroupId != "" {
+		return []string{selector.GroupId}, map[string]bool{selector.GroupId: true}
+	}
+	return index.named[selector.GroupName], index.namedSet[selector.GroupName]
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// syntheticResults returns a section of interfaces carrying many groups, as branch and shared service ENIs do.
+//
+// interfaces: The number of interfaces.
+// groups: The number of groups of every interface, out of 4 times as many, named after their ID modulo 100.
+// []groupResult: The results, a single section.
+func syntheticResults(interfaces int, groups int) []groupResult {
+	networkInterfaces := make([]types.NetworkInterface, 0, interfaces)
+	for i := 0; i < interfaces; i++ {
+		identifiers := make([]types.SecurityGroup, 0, groups)
+		for j := 0; j < groups; j++ {
+			group := (i + j*7) % (groups * 4)
+			identifiers = append(identifiers, testGroup(fmt.Sprintf("sg-%017d", group), fmt.Sprintf("group-%d", group%100)))
+		}
+		networkInterfaces = append(networkInterfaces, testInterface(fmt.Sprintf("eni-%017d", i), "", identifiers...))
+	}
+	return []groupResult{testResult("eu-west-1", "group-0", networkInterfaces...)}
+}
+
+func TestGroupIndex(t *testing.T) {
+	web, db, otherWeb := testGroup("sg-1", "web"), testGroup("sg-2", "db"), testGroup("sg-3", "web")
+	results := []groupResult{
+		testResult("eu-west-1", "web", testInterface("eni-1", "", web, db), testInterface("eni-2", "", otherWeb)),
+		testResult("eu-west-1", "db", testInterface("eni-1", "", web, db)),
+	}
+	index := newGroupIndex(results)
+	if got := fmt.Sprint(index.groupsOf("eni-1")); got != "[sg-1 sg-2]" {
+		t.Errorf("groups of eni-1: %s", got)
+	}
+	if index.nameOf("sg-3") != "web" || index.nameOf("sg-9") != "" {
+		t.Errorf("names of sg-3 and sg-9: %q, %q", index.nameOf("sg-3"), index.nameOf("sg-9"))
+	}
+	if got := index.groupsOf("eni-9"); got != nil {
+		t.Errorf("groups of an unknown interface: %v", got)
+	}
+	ids, set := index.selected(enilookup.Selector{Input: "web", GroupName: "web"})
+	if fmt.Sprint(ids) != "[sg-1 sg-3]" || len(set) != 2 || !set["sg-3"] {
+		t.Errorf("selected by name: %v %v, want the groups of every VPC using it", ids, set)
+	}
+	ids, set = index.selected(enilookup.Selector{Input: "sg-2", GroupId: "sg-2"})
+	if fmt.Sprint(ids) != "[sg-2]" || !set["sg-2"] {
+		t.Errorf("selected by ID: %v %v", ids, set)
+	}
+}
+
+// groupsExempt are the files reading the Groups of a network interface directly, and why they do not go through
+// the groupIndex.
+var groupsExempt = map[string]string{
+	"groupindex.go":     "builds the index",
+	"determinism.go":    "sorts the groups of every interface in place",
+	"configitems.go":    "copies the groups of the interface into its configuration item, once",
+	"where.go":          "copies the group names of the interface into the -where variables, before the run is complete",
+	"effectiverules.go": "reads the interfaces of the resolved instances, not those of the run",
+	"teardown.go":       "counts the groups of the interfaces it describes itself, not those of the run",
+}
+
+// groupIdentifierType is the path and name of the element type of the Groups of a network interface.
+const groupIdentifierType = "github.com/aws/aws-sdk-go-v2/service/ec2/types.GroupIdentifier"
+
+// exportData returns a lookup of the export data of the dependencies of the package in the current directory, built
+// by go list, for the importer of the type checker.
+//
+// t: The test.
+// func(string) (io.ReadCloser, error): The lookup of the export data by import path.
+func exportData(t *testing.T) func(string) (io.ReadCloser, error) {
+	t.Helper()
+	out, err := exec.Command("go", "list", "-export", "-deps", "-f", "{{.ImportPath}}={{.Export}}", ".").Output()
+	if err != nil {
+		t.Fatalf("listing the export data: %v", err)
+	}
+	exports := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if importPath, export, ok := strings.Cut(line, "="); ok && export != "" {
+			exports[importPath] = export
+		}
+	}
+	return func(importPath string) (io.ReadCloser, error) {
+		export, ok := exports[importPath]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", importPath)
+		}
+		return os.Open(export)
+	}
+}
+
+// TestGroupsGoThroughIndex is the regression guard of the groupIndex: outside of the exempt files, the code must
+// not read the Groups of a network interface, whatever the variable holding it is named, but look the groups up in
+// the index. The package is type-checked and every Groups field of type []types.GroupIdentifier is reported.
+func TestGroupsGoThroughIndex(t *testing.T) {
+	// The files of the default build, without the tests
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	parsed := []*ast.File{}
+	for _, file := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, f)
+	}
+	info := &gotypes.Info{Selections: map[*ast.SelectorExpr]*gotypes.Selection{}}
+	config := gotypes.Config{Importer: importer.ForCompiler(fset, "gc", exportData(t))}
+	if _, err := config.Check("main", fset, parsed, info); err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for selector, selection := range info.Selections {
+		if selector.Sel.Name != "Groups" || selection.Kind() != gotypes.FieldVal {
+			continue
+		}
+		slice, ok := selection.Type().(*gotypes.Slice)
+		if !ok {
+			continue
+		}
+		named, ok := slice.Elem().(*gotypes.Named)
+		if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path()+"."+named.Obj().Name() != groupIdentifierType {
+			continue
+		}
+		checked++
+		position := fset.Position(selector.Pos())
+		if groupsExempt[filepath.Base(position.Filename)] == "" {
+			t.Errorf("%s reads the Groups of a network interface, use the groupIndex or list the file in groupsExempt", position)
+		}
+	}
+	if checked == 0 {
+		t.Error("no read of the Groups of a network interface was found, the guard checks nothing")
+	}
+}
+
+func BenchmarkNewGroupIndex(b *testing.B) {
+	results := syntheticResults(50000, 16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newGroupIndex(results)
+	}
+}
+
+func BenchmarkGroupIndexLookup(b *testing.B) {
+	results := syntheticResults(50000, 16)
+	index := newGroupIndex(results)
+	ids := []string{}
+	for _, networkInterface := range results[0].NetworkInterfaces {
+		ids = append(ids, aws.ToString(networkInterface.NetworkInterfaceId))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.groupsOf(ids[i%len(ids)])
+		index.selected(enilookup.Selector{GroupName: "group-42"})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// groupNamesSyntax describes how -security-group-names values are split, for the usage text.
+const groupNamesSyntax = `Comma separated and repeatable. A literal comma, double quote or backslash is escaped with a backslash, or the name is double-quoted as in "web, public"; spaces around unquoted names are trimmed`
+
+// splitGroupNames splits a -security-group-names value into names.
+//
+// Names are separated by commas. A backslash escapes the character following it, and a double-quoted part is kept
+// as is, commas and spaces included. The spaces around the unquoted parts of a name are trimmed.
+//
+// value: The flag value.
+// []string: The names, in order.
+// error: If a quote is not closed, the value ends with a backslash or a name is empty.
+func splitGroupNames(value string) ([]string, error) {
+	names := []string{}
+	var name strings.Builder
+	// The quoted and escaped parts of a name, from first to kept, are never trimmed; first is -1 when it has none
+	first, kept := -1, 0
+	keep := func(start int) {
+		if first < 0 {
+			first = start
+		}
+		kept = name.Len()
+	}
+	quoted := false
+	escaped := false
+	end := func() error {
+		s := name.String()
+		trimmed := strings.TrimSpace(s)
+		if first >= 0 {
+			trimmed = strings.TrimLeft(s[:first], " ") + s[first:kept] + strings.TrimRight(s[kept:], " ")
+		}
+		if trimmed == "" {
+			return fmt.Errorf("empty security group name in %q", value)
+		}
+		names = append(names, trimmed)
+		name.Reset()
+		first, kept = -1, 0
+		return nil
+	}
+	for _, r := range value {
+		switch {
+		case escaped:
+			start := name.Len()
+			name.WriteRune(r)
+			keep(start)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			keep(name.Len())
+		case quoted:
+			name.WriteRune(r)
+			kept = name.Len()
+		case r == ',':
+			if err := end(); err != nil {
+				return nil, err
+			}
+		default:
+			name.WriteRune(r)
+		}
+	}
+	switch {
+	case escaped:
+		return nil, fmt.Errorf("%q ends with a backslash", value)
+	case quoted:
+		return nil, fmt.Errorf("%q has an unclosed double quote", value)
+	}
+	if err := end(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// quoteGroupName escapes a name so splitGroupNames returns it unchanged.
+//
+// name: The security group name.
+// string: The escaped name.
+func quoteGroupName(name string) string {
+	var quoted strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == ',' || r == '"' || r == '\\':
+			quoted.WriteRune('\\')
+		case r == ' ' && (i == 0 || i == len(runes)-1):
+			// Leading and trailing spaces would be trimmed
+			quoted.WriteRune('\\')
+		}
+		quoted.WriteRune(r)
+	}
+	return quoted.String()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitGroupNames(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  []string
+	}{
+		{`web`, []string{"web"}},
+		{`web,db`, []string{"web", "db"}},
+		{` web , db `, []string{"web", "db"}},
+		{`web\,public`, []string{"web,public"}},
+		{`"web, public",db`, []string{"web, public", "db"}},
+		{` "  padded  " `, []string{"  padded  "}},
+		{`\ leading,trailing\ `, []string{" leading", "trailing "}},
+		{`a "b, c" d`, []string{`a b, c d`}},
+		{`say \"hi\"`, []string{`say "hi"`}},
+		{`back\\slash`, []string{`back\slash`}},
+		{`"\""`, []string{`"`}},
+		{`café,日本語, ünï ,😀`, []string{"café", "日本語", "ünï", "😀"}},
+		{`"données, clients"`, []string{"données, clients"}},
+	} {
+		got, err := splitGroupNames(tt.value)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("splitGroupNames(%s) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	for value, want := range map[string]string{
+		`web,,db`:  "empty security group name",
+		`web,`:     "empty security group name",
+		` , web`:   "empty security group name",
+		`web\`:     "ends with a backslash",
+		`"web,db`:  "unclosed double quote",
+		`web,"db`:  "unclosed double quote",
+		``:         "empty security group name",
+		`"",web`:   "empty security group name",
+		`  ,  ,  `: "empty security group name",
+	} {
+		if _, err := splitGroupNames(value); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("splitGroupNames(%s): error %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestSecurityGroupNamesRoundTrip(t *testing.T) {
+	names := []string{"web", "web,public", " leading", "trailing ", " both ", `"quoted"`, `back\slash`, "in ner", "日本語, 中文", "😀", `,\" `}
+	given := &SecurityGroupNames{Names: names}
+	parsed := &SecurityGroupNames{}
+	if err := parsed.Set(given.String()); err != nil {
+		t.Fatalf("Set(%s): %v", given.String(), err)
+	}
+	if !slices.Equal(parsed.Names, names) {
+		t.Errorf("round trip through %s:\n%q\nwant\n%q", given.String(), parsed.Names, names)
+	}
+
+	// Every name on its own, as quoteGroupName is used by the other outputs too
+	for _, name := range names {
+		if got, err := splitGroupNames(quoteGroupName(name)); err != nil || !slices.Equal(got, []string{name}) {
+			t.Errorf("%q quoted as %s splits to %q, %v", name, quoteGroupName(name), got, err)
+		}
+	}
+
+	// Set appends, so the flag is repeatable
+	repeated := &SecurityGroupNames{}
+	for _, value := range []string{"web", `db\,primary,cache`} {
+		if err := repeated.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(repeated.Names, []string{"web", "db,primary", "cache"}) {
+		t.Errorf("repeated flag: %q", repeated.Names)
+	}
+}
+
+func TestSecurityGroupNamesConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"security-group-names": ["web\\,public", "\"db, primary\",cache"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	names := &SecurityGroupNames{}
+	fs.Var(names, "security-group-names", "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(fs, path, true); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names.Names, []string{"web,public", "db, primary", "cache"}) {
+		t.Errorf("names from the config file: %q", names.Names)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// interfaceSighting is when a network interface was first and last seen across runs.
+type interfaceSighting struct {
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// interfaceHistory is the history of the network interfaces seen across runs, stored as a JSON file holding a
+// sighting per interface ID.
+//
+// A run loads the whole file, records the interfaces it finds in memory and rewrites the file once at its end.
+//
+// A nil *interfaceHistory is valid and records nothing.
+type interfaceHistory struct {
+	path       string
+	Interfaces map[string]interfaceSighting `json:"interfaces"`
+}
+
+// openHistory loads the history file, a missing file is an empty history.
+//
+// path: The path of the history file.
+// *interfaceHistory: The history.
+// error: If the file exists but cannot be read or decoded.
+func openHistory(path string) (*interfaceHistory, error) {
+	h := &interfaceHistory{path: path, Interfaces: map[string]interfaceSighting{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("reading history %s: %w", path, err)
+	}
+	if h.Interfaces == nil {
+		h.Interfaces = map[string]interfaceSighting{}
+	}
+	return h, nil
+}
+
+// observe records that the network interfaces of the results were seen at the given time, save writes them.
+//
+// Interfaces absent from the history are first seen now.
+//
+// results: The network interfaces found per security group.
+// now: The time of the run.
+// map[string]interfaceSighting: The sightings of the interfaces of the results keyed by interface ID, nil for a nil history.
+func (h *interfaceHistory) observe(results []groupResult, now time.Time) map[string]interfaceSighting {
+	if h == nil {
+		return nil
+	}
+	sightings := map[string]interfaceSighting{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			sighting, ok := h.Interfaces[networkInterfaceId]
+			if !ok {
+				sighting.FirstSeen = now
+			}
+			sighting.LastSeen = now
+			h.Interfaces[networkInterfaceId] = sighting
+			sightings[networkInterfaceId] = sighting
+		}
+	}
+	return sightings
+}
+
+// save writes the history file, replacing it atomically. A nil history writes nothing.
+//
+// error: If the file cannot be written.
+func (h *interfaceHistory) save() error {
+	if h == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// printSighting prints when a network interface was first and last seen.
+//
+// w: The writer to print to.
+// sighting: The sighting of the interface.
+// ok: Whether the sighting is known.
+func printSighting(w io.Writer, sighting interfaceSighting, ok bool) {
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "  First seen: %s\n", formatTimestamp(sighting.FirstSeen))
+	fmt.Fprintf(w, "  Last seen: %s\n", formatTimestamp(sighting.LastSeen))
+}
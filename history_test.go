@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestInterfaceHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.observe([]groupResult{testResult("eu-west-1", "web", testInterface("eni-1", "", web))}, first)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the history was written before it was saved: %v", err)
+	}
+	if err := h.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later run reads the saved history
+	h, err = openHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sightings := h.observe([]groupResult{testResult("eu-west-1", "web", testInterface("eni-1", "", web), testInterface("eni-2", "", web))}, second)
+	if err := h.save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sightings["eni-1"]; !got.FirstSeen.Equal(first) || !got.LastSeen.Equal(second) {
+		t.Errorf("eni-1 = %+v, want first seen in the first run and last seen in the second", got)
+	}
+	if got := sightings["eni-2"]; !got.FirstSeen.Equal(second) || !got.LastSeen.Equal(second) {
+		t.Errorf("eni-2 = %+v, want first seen in this run", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file was left behind: %v", err)
+	}
+
+	var none *interfaceHistory
+	if sightings := none.observe(nil, second); sightings != nil {
+		t.Errorf("nil history observed %v", sightings)
+	}
+	if err := none.save(); err != nil {
+		t.Errorf("nil history saved: %v", err)
+	}
+}
+
+// TestScanSavesHistory streams the groups of a run and checks that the history saved at its end holds the interfaces
+// of every group.
+func TestScanSavesHistory(t *testing.T) {
+	cfg, _ := lookupEC2Config(t, 0, 0, nil)
+	progress, _ := newProgressReporter("none", "")
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onGroup := func(groupResult, map[string]types.Instance, map[string]interfaceSighting) {}
+
+	if _, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(3), progress, scanOptions{History: h, OnGroup: onGroup}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := openHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, networkInterfaceId := range []string{"eni-group-0", "eni-group-1", "eni-group-2"} {
+		if _, ok := saved.Interfaces[networkInterfaceId]; !ok {
+			t.Errorf("the saved history lacks %s: %+v", networkInterfaceId, saved.Interfaces)
+		}
+	}
+}
+
+func TestOpenHistoryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openHistory(path); err == nil {
+		t.Error("a corrupt history was opened")
+	}
+}
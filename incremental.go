@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// The sources of the details of a security group with -incremental.
+const (
+	refreshSourceStore     = "store"
+	refreshSourceRefreshed = "refreshed"
+)
+
+// groupRefresh is how -incremental obtained the details of a security group.
+type groupRefresh struct {
+	// Source is refreshSourceStore when the details were served from the store, refreshSourceRefreshed when
+	// they were looked up.
+	Source string `json:"source"`
+	// Reason is why, e.g. "count unchanged at 3" or "count changed from 3 to 4".
+	Reason string `json:"reason"`
+	// StoredAt is when the served details were stored, nil when they were refreshed.
+	StoredAt *time.Time `json:"stored_at,omitempty"`
+	// instances are the stored instances of the interfaces of a group served from the store.
+	instances map[string]types.Instance
+}
+
+// incrementalEntry is what the store records for a security group: its details before the client-side filters.
+type incrementalEntry struct {
+	Count     int                       `json:"count"`
+	Result    groupResult               `json:"result"`
+	Instances map[string]types.Instance `json:"instances"`
+}
+
+// incrementalStore serves the details of the security groups whose interface count has not changed since the
+// previous run from the on-disk cache.
+//
+// A nil *incrementalStore is valid and serves nothing.
+type incrementalStore struct {
+	account string
+	// query identifies the options the stored details depend on, a run with other options does not use them.
+	query string
+	ttl   time.Duration
+	// force refreshes every group, skipping the count pass, and still stores the details.
+	force bool
+}
+
+// newIncrementalStore creates the store of the details of the security groups.
+//
+// account: The account the security groups belong to.
+// opts: The options of the run, the details stored under other options are not served.
+// filters: The filters sent with every DescribeNetworkInterfaces call.
+// ttl: The maximum age of the stored details, set with -cache-ttl.
+// force: Whether to refresh every group, set with -force-refresh.
+// *incrementalStore: The store.
+func newIncrementalStore(account string, opts scanOptions, filters []types.Filter, ttl time.Duration, force bool) *incrementalStore {
+	query, _ := json.Marshal(struct {
+		MatchOn          enilookup.MatchOn
+		ResolveInstances bool
+		ShowPermissions  bool
+		Filters          []types.Filter
+	}{opts.MatchOn, opts.ResolveInstances, opts.ShowPermissions, filters})
+	sum := sha256.Sum256(query)
+	return &incrementalStore{account: account, query: hex.EncodeToString(sum[:8]), ttl: ttl, force: force}
+}
+
+// count performs the count pass, counting the interfaces of every security group of a region.
+//
+// A denied count pass refreshes every group rather than failing the run.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API of the region.
+// selectors: The security groups resolved in the region.
+// map[string]int: The number of network interfaces keyed by selector key, nil when every group is refreshed.
+// error: If an API call fails for another reason.
+func (s *incrementalStore) count(ctx context.Context, api enilookup.API, selectors []enilookup.Selector) (map[string]int, error) {
+	if s == nil || s.force || len(selectors) == 0 {
+		return nil, nil
+	}
+	counts, err := enilookup.CountPerGroup(ctx, api, selectors)
+	if errors.Is(err, enilookup.ErrAccessDenied) {
+		warnOnce("incremental-denied", "cannot count the network interfaces of the security groups, every group is refreshed: %v", err)
+		return nil, nil
+	}
+	return counts, err
+}
+
+// lookup returns the stored details of a security group whose count is unchanged.
+//
+// region: The region of the security group.
+// selector: The security group.
+// counts: The counts of the count pass, nil when every group is refreshed.
+// groupResult: The stored details, marked as served from the store.
+// *groupRefresh: Why the group is refreshed when it is not served, nil for a nil store.
+// bool: Whether the details are served from the store.
+func (s *incrementalStore) lookup(region string, selector enilookup.Selector, counts map[string]int) (groupResult, *groupRefresh, bool) {
+	if s == nil {
+		return groupResult{}, nil, false
+	}
+	if s.force {
+		return groupResult{}, &groupRefresh{Source: refreshSourceRefreshed, Reason: "forced by -force-refresh"}, false
+	}
+	count, counted := counts[selector.Key()]
+	if !counted {
+		return groupResult{}, &groupRefresh{Source: refreshSourceRefreshed, Reason: "not counted"}, false
+	}
+	var entry incrementalEntry
+	storedAt, ok := readCache(s.entryName(region, selector.Key()), s.ttl, &entry)
+	switch {
+	case !ok:
+		return groupResult{}, &groupRefresh{Source: refreshSourceRefreshed, Reason: "no stored details"}, false
+	case entry.Count != count:
+		return groupResult{}, &groupRefresh{Source: refreshSourceRefreshed, Reason: fmt.Sprintf("count changed from %d to %d", entry.Count, count)}, false
+	}
+	result := entry.Result
+	// Ordered by the selector of this run, the inputs may have changed since the stored one
+	result.Selector = selector
+	result.Incremental = &groupRefresh{Source: refreshSourceStore, Reason: fmt.Sprintf("count unchanged at %d", count), StoredAt: &storedAt, instances: entry.Instances}
+	return result, nil, true
+}
+
+// store records the details of the refreshed security groups.
+//
+// Failing to write the store is not fatal, the groups are refreshed again by the next run.
+//
+// results: The refreshed groups, with the details looked up and before the client-side filters.
+// instances: The resolved instances keyed by instance ID.
+func (s *incrementalStore) store(results []groupResult, instances map[string]types.Instance) {
+	if s == nil {
+		return
+	}
+	for _, result := range results {
+		if groupStatus(result) != groupStatusOk {
+			continue
+		}
+		entry := incrementalEntry{Count: len(result.NetworkInterfaces), Result: result, Instances: map[string]types.Instance{}}
+		entry.Result.Incremental = nil
+		for _, networkInterface := range result.NetworkInterfaces {
+			if networkInterface.Attachment == nil || networkInterface.Attachment.InstanceId == nil {
+				continue
+			}
+			instanceId := aws.ToString(networkInterface.Attachment.InstanceId)
+			if instance, ok := instances[instanceId]; ok {
+				entry.Instances[instanceId] = instance
+			}
+		}
+		if err := writeCache(s.entryName(result.Region, result.Selector.Key()), entry); err != nil {
+			warnOnce("incremental-store", "could not store the details of the security groups: %v", err)
+		}
+	}
+}
+
+// entryName returns the name of the cache file holding the details of a security group.
+//
+// region: The region of the security group.
+// group: The security group, as given by the user.
+// string: The name of the cache file.
+func (s *incrementalStore) entryName(region string, group string) string {
+	sum := sha256.Sum256([]byte(group))
+	return fmt.Sprintf("incremental-%s-%s-%s-%s.json", s.account, region, s.query, hex.EncodeToString(sum[:8]))
+}
+
+// printRefresh prints how -incremental obtained the details of a security group.
+//
+// w: The writer to print to.
+// refresh: How the details were obtained, nil without -incremental.
+func printRefresh(w io.Writer, refresh *groupRefresh) {
+	switch {
+	case refresh == nil:
+	case refresh.StoredAt != nil:
+		fmt.Fprintf(w, "Incremental: served from the store of %s, %s\n", formatTimestamp(*refresh.StoredAt), refresh.Reason)
+	default:
+		fmt.Fprintf(w, "Incremental: %s, %s\n", refresh.Source, refresh.Reason)
+	}
+}
+
+// printRefreshStats prints how many security groups were served from the store and refreshed.
+//
+// w: The writer to print to.
+// results: The network interfaces found per security group.
+func printRefreshStats(w io.Writer, results []groupResult) {
+	served, refreshed := 0, 0
+	for _, result := range results {
+		switch {
+		case result.Incremental == nil:
+			return
+		case result.Incremental.Source == refreshSourceStore:
+			served++
+		default:
+			refreshed++
+		}
+	}
+	fmt.Fprintf(w, "Incremental: %d groups served from the store, %d refreshed\n", served, refreshed)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// describeInstancesChunkSize is the number of instance IDs sent in a single DescribeInstances call.
+const describeInstancesChunkSize = 100
+
+// getInstancesForNetworkInterfaces retrieves the EC2 instances attached to the network interfaces in the results.
+//
+// An instance terminated since its interface was listed is left out of the map rather than failing the run.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client.
+// results: The network interfaces found per security group.
+// map[string]types.Instance: The instances keyed by instance ID.
+// error: If an API call fails.
+func getInstancesForNetworkInterfaces(ctx context.Context, ec2Client ec2.DescribeInstancesAPIClient, results []groupResult) (map[string]types.Instance, error) {
+	// Collect the unique instance IDs
+	seen := map[string]bool{}
+	instanceIds := []string{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			if networkInterface.Attachment == nil || networkInterface.Attachment.InstanceId == nil {
+				continue
+			}
+			instanceId := aws.ToString(networkInterface.Attachment.InstanceId)
+			if !seen[instanceId] {
+				seen[instanceId] = true
+				instanceIds = append(instanceIds, instanceId)
+			}
+		}
+	}
+
+	// Describe the instances in chunks
+	instances := map[string]types.Instance{}
+	for start := 0; start < len(instanceIds); start += describeInstancesChunkSize {
+		end := min(start+describeInstancesChunkSize, len(instanceIds))
+		err := describeInstances(ctx, ec2Client, &ec2.DescribeInstancesInput{InstanceIds: instanceIds[start:end]}, instances)
+		if isInstanceNotFound(err) {
+			// A single missing ID fails the whole call, while the instance-id filter skips missing instances
+			err = describeInstances(ctx, ec2Client, &ec2.DescribeInstancesInput{
+				Filters: []types.Filter{{Name: aws.String("instance-id"), Values: instanceIds[start:end]}},
+			}, instances)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return instances, nil
+}
+
+// describeInstances adds the instances matching the input to the map, following the pages of the response.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client.
+// input: The DescribeInstances input.
+// instances: The instances keyed by instance ID.
+// error: If an API call fails.
+func describeInstances(ctx context.Context, ec2Client ec2.DescribeInstancesAPIClient, input *ec2.DescribeInstancesInput, instances map[string]types.Instance) error {
+	paginator := ec2.NewDescribeInstancesPaginator(ec2Client, input)
+	for paginator.HasMorePages() {
+		describeInstancesOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, reservation := range describeInstancesOutput.Reservations {
+			for _, instance := range reservation.Instances {
+				instances[aws.ToString(instance.InstanceId)] = instance
+			}
+		}
+	}
+	return nil
+}
+
+// isInstanceNotFound reports whether a call failed because a requested instance does not exist.
+//
+// err: The error of the call.
+// bool: Whether an instance is gone.
+func isInstanceNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidInstanceID.NotFound"
+}
+
+// tagValue returns the value of the tag with the given key, or an empty string when it is not present.
+//
+// tags: The tags to search.
+// key: The tag key.
+// string: The tag value.
+func tagValue(tags []types.Tag, key string) string {
+	for _, tag := range tags {
+		if tag.Key != nil && aws.ToString(tag.Key) == key && tag.Value != nil {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+func TestGetInstancesForNetworkInterfaces(t *testing.T) {
+	web := testGroup("sg-0123456789abcdef0", "web")
+	fake := enitest.New()
+	fake.AddInstances(testInstance("i-1", "one"), testInstance("i-2", "two"))
+	results := []groupResult{testResult("eu-west-1", "web",
+		testInterface("eni-1", "i-1", web),
+		testInterface("eni-2", "i-2", web),
+		testInterface("eni-3", "", web),
+		testInterface("eni-4", "i-1", web),
+	)}
+
+	instances, err := getInstancesForNetworkInterfaces(context.Background(), fake, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 || tagValue(instances["i-1"].Tags, "Name") != "one" || tagValue(instances["i-2"].Tags, "Name") != "two" {
+		t.Errorf("instances = %v, want i-1 and i-2", instances)
+	}
+	if calls := fake.Calls("DescribeInstances"); calls != 1 {
+		t.Errorf("DescribeInstances calls = %d, want 1", calls)
+	}
+}
+
+func TestGetInstancesForNetworkInterfacesTerminated(t *testing.T) {
+	web := testGroup("sg-0123456789abcdef0", "web")
+	fake := enitest.New()
+	fake.AddInstances(testInstance("i-1", "one"))
+	// i-2 was terminated between the interface listing and the instance lookup
+	results := []groupResult{testResult("eu-west-1", "web",
+		testInterface("eni-1", "i-1", web),
+		testInterface("eni-2", "i-2", web),
+	)}
+
+	instances, err := getInstancesForNetworkInterfaces(context.Background(), fake, results)
+	if err != nil {
+		t.Fatalf("a terminated instance failed the lookup: %v", err)
+	}
+	if _, ok := instances["i-1"]; !ok || len(instances) != 1 {
+		t.Errorf("instances = %v, want only i-1", instances)
+	}
+}
+
+func TestGetInstancesForNetworkInterfacesChunks(t *testing.T) {
+	web := testGroup("sg-0123456789abcdef0", "web")
+	fake := enitest.New()
+	result := testResult("eu-west-1", "web")
+	for i := 0; i < 2*describeInstancesChunkSize+1; i++ {
+		instanceId := fmt.Sprintf("i-%d", i)
+		// Every tenth instance is gone
+		if i%10 != 0 {
+			fake.AddInstances(testInstance(instanceId, ""))
+		}
+		result.NetworkInterfaces = append(result.NetworkInterfaces, testInterface(fmt.Sprintf("eni-%d", i), instanceId, web))
+	}
+
+	instances, err := getInstancesForNetworkInterfaces(context.Background(), fake, []groupResult{result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2*describeInstancesChunkSize + 1 - 21; len(instances) != want {
+		t.Errorf("got %d instances, want %d", len(instances), want)
+	}
+	// Three chunks, each retried with the instance-id filter
+	if calls := fake.Calls("DescribeInstances"); calls != 6 {
+		t.Errorf("DescribeInstances calls = %d, want 6", calls)
+	}
+}
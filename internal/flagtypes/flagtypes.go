@@ -0,0 +1,225 @@
+// Package flagtypes provides flag.Value implementations accepting human forms of durations and counts,
+// so every flag of the program parses them the same way and reports the same errors.
+//
+// The flag package names the offending flag when Set fails, the errors returned here describe the value.
+package flagtypes
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// durationPartPattern matches one number and unit of a duration, such as 1.5h or 90d.
+	durationPartPattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)(ns|us|µs|ms|s|m|h|d|w)`)
+
+	// durationUnits are the units accepted in durations.
+	durationUnits = map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"µs": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+
+	// countPattern matches a count, such as 10k or 250.
+	countPattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)([km]?)$`)
+
+	// countUnits are the multipliers of the units accepted in counts.
+	countUnits = map[string]float64{"": 1, "k": 1e3, "m": 1e6}
+)
+
+// Errors returned when a value cannot be parsed.
+var (
+	ErrNegative    = errors.New("must not be negative")
+	ErrMissingUnit = errors.New("missing unit")
+	ErrSyntax      = errors.New("invalid syntax")
+	ErrRange       = errors.New("value out of range")
+)
+
+// ParseDuration parses a duration such as "90d", "1.5h" or "1d12h".
+//
+// It accepts the units of time.ParseDuration, plus d for days and w for weeks. Every number needs a unit,
+// except a plain "0".
+//
+// value: The duration to parse.
+// time.Duration: The parsed duration.
+// error: If the value is negative, lacks a unit or cannot be parsed.
+func ParseDuration(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if strings.HasPrefix(s, "-") {
+		return 0, ErrNegative
+	}
+	s = strings.TrimPrefix(s, "+")
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, ErrSyntax
+	}
+
+	var total float64
+	for s != "" {
+		match := durationPartPattern.FindStringSubmatch(s)
+		if match == nil {
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return 0, ErrMissingUnit
+			}
+			return 0, ErrSyntax
+		}
+		number, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, ErrSyntax
+		}
+		total += number * float64(durationUnits[match[2]])
+		s = s[len(match[0]):]
+	}
+	if total > math.MaxInt64 {
+		return 0, ErrRange
+	}
+	return time.Duration(total), nil
+}
+
+// FormatDuration formats a duration, using days when it is a whole number of days.
+//
+// d: The duration to format.
+// string: The formatted duration.
+func FormatDuration(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// ParseCount parses a count such as "10k" or "250".
+//
+// value: The count to parse.
+// int64: The count.
+// error: If the value is negative or cannot be parsed.
+func ParseCount(value string) (int64, error) {
+	return parseScaled(value, countPattern, countUnits)
+}
+
+// parseScaled parses a number followed by an optional unit.
+//
+// value: The value to parse.
+// pattern: Matches the number and the unit.
+// units: The multipliers of the units.
+// int64: The scaled value, rounded to the nearest integer.
+// error: If the value is negative or cannot be parsed.
+func parseScaled(value string, pattern *regexp.Regexp, units map[string]float64) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	if strings.HasPrefix(s, "-") {
+		return 0, ErrNegative
+	}
+	match := pattern.FindStringSubmatch(strings.TrimPrefix(s, "+"))
+	if match == nil {
+		return 0, ErrSyntax
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, ErrSyntax
+	}
+	scaled := math.Round(number * units[match[2]])
+	if scaled > math.MaxInt64 {
+		return 0, ErrRange
+	}
+	return int64(scaled), nil
+}
+
+// Duration is a flag.Value holding a time.Duration parsed with ParseDuration.
+type Duration time.Duration
+
+// Set parses the value.
+func (d *Duration) Set(value string) error {
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// String formats the value with FormatDuration.
+func (d *Duration) String() string {
+	return FormatDuration(time.Duration(*d))
+}
+
+// Count is a flag.Value holding a number parsed with ParseCount.
+type Count int64
+
+// Set parses the value.
+func (c *Count) Set(value string) error {
+	parsed, err := ParseCount(value)
+	if err != nil {
+		return err
+	}
+	*c = Count(parsed)
+	return nil
+}
+
+// String formats the value.
+func (c *Count) String() string {
+	return strconv.FormatInt(int64(*c), 10)
+}
+
+// DurationVar defines a Duration flag on the flag set.
+//
+// fs: The flag set.
+// p: Where the value is stored.
+// name: The name of the flag.
+// value: The default value.
+// usage: The usage text of the flag.
+func DurationVar(fs *flag.FlagSet, p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+	fs.Var((*Duration)(p), name, usage)
+}
+
+// CountVar defines a Count flag on the flag set.
+//
+// fs: The flag set.
+// p: Where the value is stored.
+// name: The name of the flag.
+// value: The default value.
+// usage: The usage text of the flag.
+func CountVar(fs *flag.FlagSet, p *int64, name string, value int64, usage string) {
+	*p = value
+	fs.Var((*Count)(p), name, usage)
+}
+
+// NewDuration defines a Duration flag on the flag set and returns where its value is stored.
+//
+// fs: The flag set.
+// name: The name of the flag.
+// value: The default value.
+// usage: The usage text of the flag.
+// *time.Duration: Where the value is stored.
+func NewDuration(fs *flag.FlagSet, name string, value time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	DurationVar(fs, p, name, value, usage)
+	return p
+}
+
+// NewCount defines a Count flag on the flag set and returns where its value is stored.
+//
+// fs: The flag set.
+// name: The name of the flag.
+// value: The default value.
+// usage: The usage text of the flag.
+// *int64: Where the value is stored.
+func NewCount(fs *flag.FlagSet, name string, value int64, usage string) *int64 {
+	p := new(int64)
+	CountVar(fs, p, name, value, usage)
+	return p
+}
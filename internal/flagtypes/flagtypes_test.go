@@ -0,0 +1,122 @@
+package flagtypes
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   error
+	}{
+		{"0", 0, nil},
+		{"+0", 0, nil},
+		{"90d", 90 * 24 * time.Hour, nil},
+		{"1.5h", 90 * time.Minute, nil},
+		{"1d12h", 36 * time.Hour, nil},
+		{"2w", 14 * 24 * time.Hour, nil},
+		{" 15s ", 15 * time.Second, nil},
+		{"500ms", 500 * time.Millisecond, nil},
+		{".5m", 30 * time.Second, nil},
+		{"0s", 0, nil},
+		{"-1h", 0, ErrNegative},
+		{"-0", 0, ErrNegative},
+		{"10", 0, ErrMissingUnit},
+		{"1h30", 0, ErrMissingUnit},
+		{"", 0, ErrSyntax},
+		{"h", 0, ErrSyntax},
+		{"1y", 0, ErrSyntax},
+		{"1.2.3h", 0, ErrSyntax},
+		{"100000000w", 0, ErrRange},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                   "0s",
+		90 * 24 * time.Hour: "90d",
+		36 * time.Hour:      "36h0m0s",
+		90 * time.Minute:    "1h30m0s",
+	}
+	for d, want := range tests {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", d, got, want)
+		}
+		if parsed, err := ParseDuration(FormatDuration(d)); err != nil || parsed != d {
+			t.Errorf("FormatDuration(%v) does not parse back: %v, %v", d, parsed, err)
+		}
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   error
+	}{
+		{"0", 0, nil},
+		{"250", 250, nil},
+		{"10k", 10000, nil},
+		{"10K", 10000, nil},
+		{"1.5k", 1500, nil},
+		{"2m", 2000000, nil},
+		{"+7", 7, nil},
+		{" 3 ", 3, nil},
+		{"-1", 0, ErrNegative},
+		{"", 0, ErrSyntax},
+		{"k", 0, ErrSyntax},
+		{"10g", 0, ErrSyntax},
+		{"ten", 0, ErrSyntax},
+		{"1e3", 0, ErrSyntax},
+		{"99999999999999999999m", 0, ErrRange},
+	}
+	for _, tt := range tests {
+		got, err := ParseCount(tt.value)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ParseCount(%q) = %v, %v; want %v, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	timeout := NewDuration(fs, "timeout", time.Minute, "")
+	maxResults := NewCount(fs, "max-results", 5, "")
+	if *timeout != time.Minute || *maxResults != 5 {
+		t.Fatalf("defaults = %v, %d", *timeout, *maxResults)
+	}
+	if err := fs.Parse([]string{"-timeout", "1d", "-max-results", "2k"}); err != nil {
+		t.Fatal(err)
+	}
+	if *timeout != 24*time.Hour || *maxResults != 2000 {
+		t.Errorf("values = %v, %d", *timeout, *maxResults)
+	}
+	if got := fs.Lookup("timeout").Value.String(); got != "1d" {
+		t.Errorf("timeout String() = %q", got)
+	}
+
+	// The flag package names the flag in front of the error describing the value
+	for _, args := range [][]string{{"-timeout", "-5m"}, {"-max-results", "-1"}} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		NewDuration(fs, "timeout", 0, "")
+		NewCount(fs, "max-results", 0, "")
+		err := fs.Parse(args)
+		if err == nil || !strings.Contains(err.Error(), args[0]) || !strings.Contains(err.Error(), ErrNegative.Error()) {
+			t.Errorf("Parse(%q) err = %v", args, err)
+		}
+	}
+}
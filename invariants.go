@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// invariantError is returned when the output of a run violates an invariant, which is a bug of the program rather
+// than a problem of the cloud state.
+type invariantError struct {
+	Violations []string
+}
+
+// Error returns every violation.
+func (e *invariantError) Error() string {
+	return fmt.Sprintf("output invariant violated: %s", strings.Join(e.Violations, "; "))
+}
+
+// checkInvariants verifies the results of a run before they are rendered.
+//
+// No network interface may appear under several security group sections unless it carries as many of the
+// selected groups, and the totals of the report must equal a count of the distinct interfaces every section lists.
+//
+// run: What the run found.
+// excludeShared: Whether shared service ENIs are left out of the totals.
+// error: An *invariantError listing the violations, nil when there are none.
+func checkInvariants(run scanResult, excludeShared bool) error {
+	violations := []string{}
+
+	// Check the interfaces listed under several sections
+	index := newGroupIndex(run.Results)
+	sections := map[string][]int{}
+	order := []string{}
+	distinct := make([][]types.NetworkInterface, len(run.Results))
+	for i, result := range run.Results {
+		seen := map[string]bool{}
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if seen[networkInterfaceId] {
+				violations = append(violations, fmt.Sprintf("%s is listed twice under %s", networkInterfaceId, result.Selector.Key()))
+				continue
+			}
+			seen[networkInterfaceId] = true
+			distinct[i] = append(distinct[i], networkInterface)
+			if sections[networkInterfaceId] == nil {
+				order = append(order, networkInterfaceId)
+			}
+			sections[networkInterfaceId] = append(sections[networkInterfaceId], i)
+		}
+	}
+	for _, networkInterfaceId := range order {
+		if len(sections[networkInterfaceId]) < 2 {
+			continue
+		}
+		// Count the selected groups the interface carries
+		carried := map[string]bool{}
+		keys := []string{}
+		for _, i := range sections[networkInterfaceId] {
+			_, selected := index.selected(run.Results[i].Selector)
+			for _, groupId := range index.groupsOf(networkInterfaceId) {
+				if selected[groupId] {
+					carried[groupId] = true
+				}
+			}
+			keys = append(keys, run.Results[i].Selector.Key())
+		}
+		if len(carried) < len(keys) {
+			violations = append(violations, fmt.Sprintf("%s is listed under %s but carries %d of their groups", networkInterfaceId, strings.Join(keys, ", "), len(carried)))
+		}
+	}
+
+	// Check the totals of the report against the distinct interfaces of every section, counted apart from it
+	r := newReport(run.Results, run.Instances, run.Sightings, excludeShared, run.FailedRegions)
+	for i, group := range r.Groups {
+		key := run.Results[i].Selector.Key()
+		total, shared := countInterfaces(distinct[i], excludeShared)
+		if group.TotalInterfaces != total || group.SharedInterfaces != shared {
+			violations = append(violations, fmt.Sprintf("%s totals %d network interfaces, %d shared, but lists %d distinct ones, %d shared", key, group.TotalInterfaces, group.SharedInterfaces, total, shared))
+		}
+		if group.FoundInterfaces < len(distinct[i]) || group.Truncated != (group.FoundInterfaces > len(distinct[i])) {
+			violations = append(violations, fmt.Sprintf("%s found %d network interfaces but lists %d distinct ones, truncated: %t", key, group.FoundInterfaces, len(distinct[i]), group.Truncated))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &invariantError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// invariantHelperEnv makes the test binary run the invariant checks of main on a run and exit, see
+// TestInvariantViolationExitCode.
+const invariantHelperEnv = "INTERFACES_INVARIANT_HELPER"
+
+// inconsistentRun returns the test run with an extra section selecting web by ID, which the duplicate collapsing
+// leaves out, and the load balancer interface of web also listed under db, whose group it does not carry.
+//
+// scanResult: The run.
+func inconsistentRun() scanResult {
+	run := testRun(false)
+	byId := run.Results[0]
+	byId.Selector.Input, byId.Selector.GroupName, byId.Selector.GroupId = "sg-0a1b2c3d4e5f60718", "", "sg-0a1b2c3d4e5f60718"
+	run.Results = append(run.Results, byId)
+	run.Results[1].NetworkInterfaces = append(run.Results[1].NetworkInterfaces, run.Results[0].NetworkInterfaces[1])
+	run.Results[1].Found++
+	return run
+}
+
+func TestCheckInvariants(t *testing.T) {
+	for _, excludeShared := range []bool{false, true} {
+		if err := checkInvariants(testRun(false), excludeShared); err != nil {
+			t.Errorf("excludeShared %v: %v", excludeShared, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		corrupt func(run *scanResult)
+		want    []string
+	}{
+		{"listed twice", func(run *scanResult) {
+			run.Results[0].NetworkInterfaces = append(run.Results[0].NetworkInterfaces, run.Results[0].NetworkInterfaces[0])
+		}, []string{"eni-0aaa is listed twice under web", "web totals 3 network interfaces, 0 shared, but lists 2 distinct ones, 0 shared"}},
+		{"shared listed twice", func(run *scanResult) {
+			lambda := run.Results[1].NetworkInterfaces[1]
+			run.Results[1].NetworkInterfaces = append(run.Results[1].NetworkInterfaces, lambda)
+		}, []string{"db totals 4 network interfaces, 2 shared, but lists 3 distinct ones, 1 shared"}},
+		{"group not carried", func(run *scanResult) {
+			run.Results[1].NetworkInterfaces = append(run.Results[1].NetworkInterfaces, run.Results[0].NetworkInterfaces[1])
+			run.Results[1].Found++
+		}, []string{"eni-0bbb is listed under web, db but carries 1 of their groups"}},
+		{"truncated without a missing interface", func(run *scanResult) {
+			run.Results[2].Truncated = true
+		}, []string{"web found 1 network interfaces but lists 1 distinct ones, truncated: true"}},
+		{"missing interface not truncated", func(run *scanResult) {
+			run.Results[2].Found = 5
+		}, []string{"web found 5 network interfaces but lists 1 distinct ones, truncated: false"}},
+	} {
+		run := testRun(false)
+		tt.corrupt(&run)
+		err := checkInvariants(run, false)
+		var invariantErr *invariantError
+		if !errors.As(err, &invariantErr) {
+			t.Errorf("%s: %v, want an invariantError", tt.name, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: %v\nwant %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+// TestInvariantViolationExitCode runs the test binary through the duplicate collapsing and the invariant checks of
+// main, and checks that an inconsistent run exits with the internal error code while a consistent one renders.
+func TestInvariantViolationExitCode(t *testing.T) {
+	if mode := os.Getenv(invariantHelperEnv); mode != "" {
+		run := inconsistentRun()
+		if mode == "consistent" {
+			run.Results[1].NetworkInterfaces = run.Results[1].NetworkInterfaces[:3]
+			run.Results[1].Found--
+		}
+		run.Results = collapseDuplicateSections(run.Results)
+		if err := checkInvariants(run, false); err != nil {
+			internalError(err)
+		}
+		os.Exit(0)
+	}
+
+	for _, tt := range []struct {
+		mode string
+		code int
+		want string
+	}{
+		{"inconsistent", exitInternal, "internal error: output invariant violated: eni-0bbb is listed under web, db but carries 1 of their groups"},
+		{"consistent", 0, "sg-0a1b2c3d4e5f60718 (eu-west-1) selects security groups already reported under web"},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestInvariantViolationExitCode$")
+		cmd.Env = append(os.Environ(), invariantHelperEnv+"="+tt.mode)
+		out, err := cmd.CombinedOutput()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code || !strings.Contains(string(out), tt.want) {
+			t.Errorf("%s: exit code %d, want %d, output:\n%s\nwant %q", tt.mode, code, tt.code, out, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// describeInstanceTypesChunkSize is the number of instance types sent in a single DescribeInstanceTypes call.
+const describeInstanceTypesChunkSize = 100
+
+// instanceTypeLimits are the network interface limits of an instance type.
+type instanceTypeLimits struct {
+	MaxNetworkInterfaces int `json:"max_network_interfaces"`
+	Ipv4PerInterface     int `json:"ipv4_per_interface"`
+}
+
+// builtinInstanceTypeLimits are the documented limits of common instance types, other types are looked up with
+// DescribeInstanceTypes.
+var builtinInstanceTypeLimits = map[string]instanceTypeLimits{
+	"t3.micro":    {2, 2},
+	"t3.small":    {3, 4},
+	"t3.medium":   {3, 6},
+	"t3.large":    {3, 12},
+	"t3.xlarge":   {4, 15},
+	"t3.2xlarge":  {4, 15},
+	"m5.large":    {3, 10},
+	"m5.xlarge":   {4, 15},
+	"m5.2xlarge":  {4, 15},
+	"m5.4xlarge":  {8, 30},
+	"m5.8xlarge":  {8, 30},
+	"m5.12xlarge": {8, 30},
+	"m5.16xlarge": {15, 50},
+	"m5.24xlarge": {15, 50},
+	"c5.large":    {3, 10},
+	"c5.xlarge":   {4, 15},
+	"c5.2xlarge":  {4, 15},
+	"c5.4xlarge":  {8, 30},
+	"c5.9xlarge":  {8, 30},
+	"c5.12xlarge": {8, 30},
+	"c5.18xlarge": {15, 50},
+	"r5.large":    {3, 10},
+	"r5.xlarge":   {4, 15},
+	"r5.2xlarge":  {4, 15},
+	"r5.4xlarge":  {8, 30},
+	"m6i.large":   {3, 10},
+	"m6i.xlarge":  {4, 15},
+	"m6i.2xlarge": {4, 15},
+	"m6i.4xlarge": {8, 30},
+	"c6i.large":   {3, 10},
+	"c6i.xlarge":  {4, 15},
+	"c6i.2xlarge": {4, 15},
+	"c6i.4xlarge": {8, 30},
+	"r6i.large":   {3, 10},
+	"r6i.xlarge":  {4, 15},
+	"r6i.2xlarge": {4, 15},
+	"r6i.4xlarge": {8, 30},
+}
+
+// instanceCapacity is the secondary IP usage of an instance against the limits of its type.
+type instanceCapacity struct {
+	Region            string              `json:"region"`
+	InstanceId        string              `json:"instance_id"`
+	InstanceType      string              `json:"instance_type"`
+	Used              int                 `json:"used"`
+	Max               int                 `json:"max"`
+	Percent           float64             `json:"percent"`
+	NearCapacity      bool                `json:"near_capacity"`
+	NetworkInterfaces []interfaceCapacity `json:"network_interfaces"`
+}
+
+// interfaceCapacity is the IP usage of a network interface against the per-interface limit of its instance type.
+type interfaceCapacity struct {
+	NetworkInterfaceId string  `json:"network_interface_id"`
+	Used               int     `json:"used"`
+	Max                int     `json:"max"`
+	Percent            float64 `json:"percent"`
+	NearCapacity       bool    `json:"near_capacity"`
+}
+
+// loadInstanceTypeLimits returns the built-in limits, overridden by the entries of the data file.
+//
+// The data file is a JSON object keyed by instance type, e.g. {"m7g.large": {"max_network_interfaces": 3, "ipv4_per_interface": 10}}.
+//
+// path: The path of the data file, empty for the built-in limits only.
+// map[string]instanceTypeLimits: The limits keyed by instance type.
+// error: If the data file cannot be read or decoded.
+func loadInstanceTypeLimits(path string) (map[string]instanceTypeLimits, error) {
+	limits := map[string]instanceTypeLimits{}
+	for instanceType, limit := range builtinInstanceTypeLimits {
+		limits[instanceType] = limit
+	}
+	if path == "" {
+		return limits, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]instanceTypeLimits{}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("reading instance type limits %s: %w", path, err)
+	}
+	for instanceType, limit := range overrides {
+		limits[instanceType] = limit
+	}
+	return limits, nil
+}
+
+// getIpCapacity compares the IP usage of every instance, and of each of its network interfaces, against the limits
+// of its instance type.
+//
+// Every attached interface of the instance is counted, not only those found for the security groups. Instance types
+// missing from the limits are looked up with DescribeInstanceTypes and added to them.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client.
+// region: The region of the instances.
+// instances: The resolved instances keyed by instance ID.
+// limits: The limits keyed by instance type, updated with the types looked up.
+// warnAt: The usage, in percent, at or above which an instance or interface is near capacity.
+// []instanceCapacity: The usage per instance, sorted by instance ID.
+// error: If an API call fails.
+func getIpCapacity(ctx context.Context, ec2Client *ec2.Client, region string, instances map[string]types.Instance, limits map[string]instanceTypeLimits, warnAt float64) ([]instanceCapacity, error) {
+	// Look up the unknown instance types
+	unknown := []string{}
+	seen := map[string]bool{}
+	for _, instance := range instances {
+		instanceType := string(instance.InstanceType)
+		if _, ok := limits[instanceType]; !ok && !seen[instanceType] {
+			seen[instanceType] = true
+			unknown = append(unknown, instanceType)
+		}
+	}
+	for start := 0; start < len(unknown); start += describeInstanceTypesChunkSize {
+		end := min(start+describeInstanceTypesChunkSize, len(unknown))
+		instanceTypes := []types.InstanceType{}
+		for _, instanceType := range unknown[start:end] {
+			instanceTypes = append(instanceTypes, types.InstanceType(instanceType))
+		}
+		paginator := ec2.NewDescribeInstanceTypesPaginator(ec2Client, &ec2.DescribeInstanceTypesInput{InstanceTypes: instanceTypes})
+		for paginator.HasMorePages() {
+			describeInstanceTypesOutput, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range describeInstanceTypesOutput.InstanceTypes {
+				if info.NetworkInfo == nil {
+					continue
+				}
+				limits[string(info.InstanceType)] = instanceTypeLimits{
+					MaxNetworkInterfaces: int(aws.ToInt32(info.NetworkInfo.MaximumNetworkInterfaces)),
+					Ipv4PerInterface:     int(aws.ToInt32(info.NetworkInfo.Ipv4AddressesPerInterface)),
+				}
+			}
+		}
+	}
+
+	capacities := []instanceCapacity{}
+	for instanceId, instance := range instances {
+		limit, ok := limits[string(instance.InstanceType)]
+		if !ok {
+			continue
+		}
+		capacity := instanceCapacity{
+			Region:            region,
+			InstanceId:        instanceId,
+			InstanceType:      string(instance.InstanceType),
+			Max:               limit.MaxNetworkInterfaces * limit.Ipv4PerInterface,
+			NetworkInterfaces: []interfaceCapacity{},
+		}
+		for _, networkInterface := range instance.NetworkInterfaces {
+			used := len(networkInterface.PrivateIpAddresses)
+			capacity.Used += used
+			percent := usagePercent(used, limit.Ipv4PerInterface)
+			capacity.NetworkInterfaces = append(capacity.NetworkInterfaces, interfaceCapacity{
+				NetworkInterfaceId: aws.ToString(networkInterface.NetworkInterfaceId),
+				Used:               used,
+				Max:                limit.Ipv4PerInterface,
+				Percent:            percent,
+				NearCapacity:       percent >= warnAt,
+			})
+		}
+		sort.Slice(capacity.NetworkInterfaces, func(i, j int) bool {
+			return capacity.NetworkInterfaces[i].NetworkInterfaceId < capacity.NetworkInterfaces[j].NetworkInterfaceId
+		})
+		capacity.Percent = usagePercent(capacity.Used, capacity.Max)
+		capacity.NearCapacity = capacity.Percent >= warnAt
+		capacities = append(capacities, capacity)
+	}
+	sort.Slice(capacities, func(i, j int) bool { return capacities[i].InstanceId < capacities[j].InstanceId })
+	return capacities, nil
+}
+
+// usagePercent returns the usage in percent, rounded to one decimal.
+//
+// used: The number of IPs used.
+// max: The maximum number of IPs.
+// float64: The usage in percent, 0 when the maximum is unknown.
+func usagePercent(used int, max int) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return roundPercent(float64(used) * 100 / float64(max))
+}
+
+// printIpCapacity prints the IP usage of every instance and of its network interfaces.
+//
+// w: The writer to print to.
+// capacities: The usage per instance, nil when -ip-capacity is not set.
+func printIpCapacity(w io.Writer, capacities []instanceCapacity) {
+	if capacities == nil {
+		return
+	}
+	fmt.Fprintf(w, "IP capacity:\n")
+	for _, capacity := range capacities {
+		flag := ""
+		if capacity.NearCapacity {
+			flag = " NEAR CAPACITY"
+		}
+		fmt.Fprintf(w, "  %s (%s, %s): %d/%d IPs (%.1f%%)%s\n", capacity.InstanceId, capacity.InstanceType, capacity.Region, capacity.Used, capacity.Max, capacity.Percent, flag)
+		for _, networkInterface := range capacity.NetworkInterfaces {
+			flag := ""
+			if networkInterface.NearCapacity {
+				flag = " NEAR CAPACITY"
+			}
+			fmt.Fprintf(w, "    %s: %d/%d IPs (%.1f%%)%s\n", networkInterface.NetworkInterfaceId, networkInterface.Used, networkInterface.Max, networkInterface.Percent, flag)
+		}
+	}
+	fmt.Fprintln(w)
+}
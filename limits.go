@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// resultLimiter caps the network interfaces listed per security group and in total, for -max-per-group and
+// -max-results.
+type resultLimiter struct {
+	perGroup int
+	total    int
+	// remaining is what is left of the total cap, across the groups limited so far.
+	remaining int
+}
+
+// newResultLimiter creates a limiter for a run.
+//
+// perGroup: The maximum number of interfaces listed per group, 0 for no cap.
+// total: The maximum number of interfaces listed across the groups, 0 for no cap.
+// *resultLimiter: The limiter.
+func newResultLimiter(perGroup int, total int) *resultLimiter {
+	return &resultLimiter{perGroup: perGroup, total: total, remaining: total}
+}
+
+// limit caps the interfaces of a group with the per-group cap, then with what is left of the total cap, marking
+// the group as truncated and recording the number of interfaces it had.
+//
+// result: The group, modified in place.
+func (l *resultLimiter) limit(result *groupResult) {
+	found := len(result.NetworkInterfaces)
+	listed := found
+	if l.perGroup > 0 {
+		listed = min(listed, l.perGroup)
+	}
+	if l.total > 0 {
+		listed = min(listed, l.remaining)
+		l.remaining -= listed
+	}
+	if listed < found {
+		result.NetworkInterfaces = result.NetworkInterfaces[:listed]
+		result.Truncated = true
+	}
+	result.Found = found
+}
+
+// apply caps the interfaces of every group, in order.
+//
+// results: The network interfaces found per security group, modified in place.
+func (l *resultLimiter) apply(results []groupResult) {
+	for i := range results {
+		l.limit(&results[i])
+	}
+}
+
+// printTruncation prints how many of the interfaces of a truncated group are listed.
+//
+// w: The writer to print to.
+// result: The group.
+func printTruncation(w io.Writer, result groupResult) {
+	if result.Truncated {
+		fmt.Fprintf(w, "Truncated: %d of %d network interfaces listed\n", len(result.NetworkInterfaces), result.Found)
+	}
+}
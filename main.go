@@ -1,36 +1,83 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"interfaces/m/v2/internal/flagtypes"
+	"interfaces/m/v2/pkg/enilookup"
 )
 
 type SecurityGroupNames struct {
 	Names []string
 }
 
-// Set appends the given value to the slice of names in the SecurityGroupNames struct.
+// Set appends the names of the given value to the slice of names in the SecurityGroupNames struct.
+//
+// The value is split on commas, see splitGroupNames for the escaping of literal commas.
 //
-// value: The value to be appended to the slice.
-// error: If an error occurs while appending the value.
+// value: The value holding the names to be appended to the slice.
+// error: If the value cannot be split.
 func (s *SecurityGroupNames) Set(value string) error {
-	s.Names = append(s.Names, value)
+	names, err := splitGroupNames(value)
+	if err != nil {
+		return err
+	}
+	s.Names = append(s.Names, names...)
 	return nil
 }
 
 // String returns a string representation of the SecurityGroupNames struct.
 //
-// It joins the names of the security groups in the struct using a comma as the separator.
+// It joins the names of the security groups in the struct using a comma as the separator, escaped so that
+// setting the resulting string returns the same names.
 // The resulting string is returned.
 func (s *SecurityGroupNames) String() string {
-	return strings.Join(s.Names, ",")
+	quoted := []string{}
+	for _, name := range s.Names {
+		quoted = append(quoted, quoteGroupName(name))
+	}
+	return strings.Join(quoted, ",")
+}
+
+// groupResult holds the network interfaces found for a single requested security group.
+type groupResult struct {
+	Region            string
+	Selector          enilookup.Selector
+	NetworkInterfaces []types.NetworkInterface
+	// Exposures are the ingress rules that apply to the public interfaces, set with -public-only.
+	Exposures []exposure
+	// Permissions are the permissions granted on the interfaces keyed by interface ID, set with -show-permissions.
+	Permissions map[string][]interfacePermission
+	// Churn is how much the interfaces changed since the previous snapshot, set with -churn.
+	Churn *groupChurn
+	// Denial is why the interfaces could not be listed, nil when the lookup succeeded.
+	Denial *groupDenial
+	// LookupError is why the lookup of the interfaces failed for another reason than a denial, empty when it
+	// succeeded. The run stops at such a group.
+	LookupError string
+	// AgeHistogram counts the interfaces per attachment age bucket, set with -age-histogram.
+	AgeHistogram []ageBucket
+	// Truncated reports that NetworkInterfaces was capped by -max-per-group or -max-results.
+	Truncated bool
+	// Found is the number of interfaces found before the caps.
+	Found int
+	// Incremental is how the details were obtained, set with -incremental.
+	Incremental *groupRefresh
 }
 
 // main is the entry point of the program.
@@ -43,113 +90,726 @@ func (s *SecurityGroupNames) String() string {
 // No parameters.
 // No return values.
 func main() {
-	// Create a flag to specify the security group names
-
 	// Create a flag to specify the security group names
 	var securityGroupNames SecurityGroupNames
-	flag.Var(&securityGroupNames, "security-group-names", "The names of the security groups to include in the output")
-
-	// Parse the command line arguments
-	flag.Parse()
-
-	// For each security group name, get the network interfaces that are attached to it
-	for _, securityGroupName := range securityGroupNames.Names {
-		networkInterfaces := getNetworkInterfacesForSecurityGroup(securityGroupName)
-		// Print the security group name and the network interfaces that are attached to it
-		fmt.Printf("Security group name: %s\n", securityGroupName)
-		for _, networkInterface := range networkInterfaces {
-			fmt.Printf("Network interfaces:\n")
-			fmt.Printf("  NetworkInterface ID: %s\n", *networkInterface.NetworkInterfaceId)
-			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
-				fmt.Printf("  InstanceId: %s\n", *networkInterface.Attachment.InstanceId)
+	flag.Var(&securityGroupNames, "security-group-names", "The names or IDs of the security groups to include in the output. "+groupNamesSyntax)
+	matchOn := flag.String("match-on", "groupname", "What the security group names are matched against: groupname, nametag (the Name tag) or both")
+	groupBy := flag.String("group-by", "", "Group the output by the given key instead of by security group (supported: app, instance)")
+	effectiveRules := flag.Bool("effective-rules", false, "With -group-by instance, print the consolidated ingress allow-list of every instance, across the security groups of all its network interfaces")
+	appTagKey := flag.String("app-tag-key", "app", "The tag key used to determine the owning application when -group-by app is used")
+	var rollupTagKeys rollupTags
+	flag.Var(&rollupTagKeys, "rollup-tag", "Only print the number of interfaces, instances and public IPs per value of this tag `key`, falling back to the instance tag with -resolve-instances; repeat for a multi-key pivot")
+	resolveInstances := flag.Bool("resolve-instances", false, "Look up the EC2 instances attached to the network interfaces")
+	allRegions := flag.Bool("all-regions", false, "Query every enabled region the credentials are permitted to use")
+	includeOptIn := flag.Bool("include-opt-in", false, "With -all-regions, also scan opt-in regions that are not enabled for the account")
+	excludeRegions := flag.String("exclude-regions", "", "With -all-regions, a comma separated list of regions to leave out")
+	noValidate := flag.Bool("no-validate", false, "Skip the syntax checks of the region, -exclude-regions, -vpc-id, -instance-id, -explain-eni and the identifiers of --filters, for partitions with other identifiers")
+	searchOther := flag.Bool("search-other-regions", false, "When a requested security group does not exist, look for it in the other enabled regions")
+	regionTimeout := flagtypes.NewDuration(flag.CommandLine, "region-timeout", 5*time.Second, "The maximum `duration` of the per-region probes used by -all-regions and -search-other-regions")
+	cacheTTL := flagtypes.NewDuration(flag.CommandLine, "cache-ttl", time.Hour, "The maximum age, as a `duration`, of values read from the on-disk cache (0 disables the cache)")
+	output := flag.String("output", "text", outputUsage())
+	status := flag.String("status", "", "Only report network interfaces with this status: available, associated, attaching, in-use or detaching")
+	instanceId := flag.String("instance-id", "", "Only report network interfaces attached to this instance")
+	publicOnly := flag.Bool("public-only", false, "Only report network interfaces with a public IP, and the ingress rules exposing them")
+	azBalance := flag.Bool("az-balance", false, "Report the distribution of each security group's interfaces across availability zones")
+	conventionFile := flag.String("convention", "", "A YAML file of the security groups the interfaces must and must not carry per subnet, VPC or tag; report the violations instead of the interfaces")
+	explainEni := flag.String("explain-eni", "", "Explain in a sentence per security group what this network interface is and what freeing the group from it takes, instead of reporting the interfaces")
+	verboseNarrative := flag.Bool("verbose-narrative", false, "Add a sentence explaining every interface to the text report")
+	failOnViolation := flag.Bool("fail-on-violation", false, "With -convention, exit 10 when an interface violates the convention")
+	azSkewThreshold := flag.Float64("az-skew-threshold", 70, "With -az-balance, the share of in-use interfaces in a single availability zone, in percent, above which a group is flagged")
+	excludeShared := flag.Bool("exclude-shared", false, "Leave shared service ENIs, such as Lambda Hyperplane ENIs, out of the totals used for thresholds")
+	showPermissions := flag.Bool("show-permissions", false, "List the accounts and services granted permissions on every network interface")
+	hasPermissionsOnly := flag.Bool("has-permissions-only", false, "Only report network interfaces that carry permissions (implies -show-permissions)")
+	where := flag.String("where", "", "Only report network interfaces for which this boolean expression holds, e.g. 'status == \"available\" && !(\"owner\" in tags)'")
+	resumeFile := flag.String("resume-file", "", "Record every completed (account, region, group) unit in this file and skip the units it already records")
+	noResume := flag.Bool("no-resume", false, "With -resume-file, ignore and overwrite the existing state")
+	historyFile := flag.String("history-file", "", "Record when every network interface was first and last seen in this file and report it")
+	newSince := flagtypes.NewDuration(flag.CommandLine, "new-since", 0, "With -history-file, only report network interfaces first seen within this `duration`")
+	splitBy := flag.String("split-by", "", "Write one file per partition instead of stdout, partitioned by: group, vpc or owner")
+	outputDir := flag.String("output-dir", "", "With -split-by, the directory the partition files and their manifest are written to")
+	ownerTagKey := flag.String("owner-tag-key", "owner", "The tag key used to determine the owner when -split-by owner is used")
+	showEmpty := flag.Bool("show-empty", false, "With -split-by, also write the partitions without network interfaces")
+	churn := flag.Bool("churn", false, "Report how much every group's interfaces changed since the previous run of the same query")
+	incremental := flag.Bool("incremental", false, "Count the interfaces of every group in a single pass first, and serve the details of the groups whose count is unchanged from the on-disk cache, of at most -cache-ttl")
+	forceRefresh := flag.Bool("force-refresh", false, "With -incremental, skip the count pass and refresh every group, still storing the details")
+	eipAudit := flag.Bool("eip-audit", false, "Report the Elastic IPs associated with available interfaces, or with stopped instances with -resolve-instances, and their cost")
+	releaseEips := flag.Bool("release-eips", false, "Disassociate and release the Elastic IPs of the available interfaces found by -eip-audit, requires -yes or -dry-run")
+	dryRun := flag.Bool("dry-run", false, "With -release-eips, only print what would be released")
+	yes := flag.Bool("yes", false, "With -release-eips, release without asking; also proceeds past -max-groups without asking")
+	attest := flag.String("attest", "", "Write an attestation of the run, with the SHA-256 of the report, to this `path`; in verify-attestation mode, the attestation to check")
+	attestKeyFile := flag.String("attest-key-file", "", "The `path` of the key used to sign the attestation with HMAC-SHA256")
+	reportFile := flag.String("report", "", "In verify-attestation mode, the `path` of the stored report")
+	requireSignature := flag.Bool("require-signature", false, "In verify-attestation mode, fail when the attestation is not signed or its HMAC cannot be checked")
+	streamGroups := flag.Bool("stream-groups", false, "Print every security group as soon as its lookup completes, in order of completion; json switches to NDJSON")
+	teardownBlockers := flag.Bool("teardown-blockers", false, "List every network interface left in the VPC of -vpc-id, by security group, and exit 0 only when there is none")
+	vpcId := flag.String("vpc-id", "", "With -teardown-blockers, the VPC being torn down")
+	maxPerGroup := flagtypes.NewCount(flag.CommandLine, "max-per-group", 0, "List at most this many network interfaces per security group, 0 for no cap")
+	budgetDuration := flagtypes.NewDuration(flag.CommandLine, "budget-duration", 0, "Stop looking up security groups once the run took this `duration`, reporting the groups completed and marking the run incomplete, 0 for no limit")
+	budgetAPICalls := flagtypes.NewCount(flag.CommandLine, "budget-api-calls", 0, "Stop looking up security groups once the run made this many API calls, reporting the groups completed and marking the run incomplete, 0 for no limit")
+	maxGroups := flagtypes.NewCount(flag.CommandLine, "max-groups", 500, "Ask for confirmation, or -yes, before looking up more security groups than this across the regions, 0 never asks")
+	maxResults := flagtypes.NewCount(flag.CommandLine, "max-results", 0, "List at most this many network interfaces across the security groups, 0 for no cap")
+	var describeFilters cliFilters
+	flag.Var(&describeFilters, "filters", "AWS CLI style DescribeNetworkInterfaces filters, e.g. Name=status,Values=available or JSON; group-name and group-id values select security groups (repeatable)")
+	runbook := flag.Bool("runbook", false, "Print, per network interface, the next action freeing the security group, with the AWS CLI command when there is one")
+	showCapabilities := flag.Bool("capabilities", false, "Print the subcommands, flags, output formats, filters and schema versions of this build as JSON, for wrapper tooling, and exit")
+	configFile := flag.String("config", "", "A JSON config `file` of flag defaults, by flag name (default: config.json in the user config directory, when it exists)")
+	expectAccount := flag.String("expect-account", "", "Abort unless the credentials belong to this account ID")
+	expectAccountAlias := flag.String("expect-account-alias", "", "Abort unless the account has this alias")
+	expectEnv := flag.String("expect-env", "", "A key=value tag, e.g. environment=staging, every queried security group must carry; mutating flags are refused otherwise")
+	ageHistogram := flag.Bool("age-histogram", false, "Count every group's interfaces per attachment age bucket")
+	ageBucketsValue := flag.String("age-buckets", "7d,30d,90d,365d", "With -age-histogram, the comma separated upper bounds of the age buckets, as durations")
+	readOnly := flag.Bool("read-only", readOnlyBuild, "Reject every flag that modifies resources and block every API call that is not a read")
+	timezone := flag.String("timezone", "UTC", "The IANA time zone, e.g. America/New_York, human readable timestamps are rendered in. Structured output stays in UTC")
+	noSanitize := flag.Bool("no-sanitize", false, "Print the descriptions, tag values and names as returned by AWS even on a terminal, control characters included")
+	noDedupeWarnings := flag.Bool("no-dedupe-warnings", false, "Print every occurrence of a warning raised by the run, instead of the first one and the number of repeats")
+	noTimestamps := flag.Bool("no-timestamps", false, "Leave every timestamp out of the output, so identical cloud state renders to identical output")
+	showStats := flag.Bool("stats", false, "Print the statistics of every run to stderr: counts, pages fetched and duplicates collapsed")
+	telemetryEndpoint := flag.String("telemetry-endpoint", "", "POST an anonymous usage report (flag names, bucketed counts, duration, version, exit class) to this URL at the end of the run")
+	telemetryDryRun := flag.Bool("telemetry-dry-run", false, "Print the anonymous usage report to stderr instead of sending it")
+	verify := flag.Bool("verify", false, "Run the lookup twice and report whether the two passes found the same network interfaces")
+	verifyGap := flagtypes.NewDuration(flag.CommandLine, "verify-gap", 10*time.Second, "With -verify, the `duration` between the two passes")
+	failOnUnstable := flag.Bool("fail-on-unstable", false, "With -verify, exit 7 when the two passes differ")
+	strict := flag.Bool("strict", false, "Exit 4 when the network interfaces of any security group could not be listed because access was denied")
+	strictFlags := flag.Bool("strict-flags", false, "Reject deprecated flag names instead of printing a deprecation notice")
+	ipCapacity := flag.Bool("ip-capacity", false, "With -resolve-instances, compare every instance's IP usage, per interface, with the limits of its instance type")
+	ipCapacityFile := flag.String("ip-capacity-file", "", "With -ip-capacity, a JSON file overriding the built-in instance type limits")
+	warnAt := flag.Float64("warn-at", 90, "With -ip-capacity, the IP usage in percent at or above which an instance or interface is flagged")
+	watch := flagtypes.NewDuration(flag.CommandLine, "watch", 0, "Repeat the lookup every `duration` until interrupted (0 runs it once)")
+	templateText := flag.String("template", "", "The Go template the output is rendered with, selecting -output template")
+	templateFile := flag.String("template-file", "", "The `file` holding the Go template the output is rendered with, selecting -output template")
+	templateScope := flag.String("template-scope", templateScopeInterface, "What the template is executed against: interface (once per network interface) or run (once, against the groups, the interfaces, the summary and the metadata)")
+	explain := flag.Bool("explain", false, "Print the resolved order of the security groups, which the sections are rendered in, to stderr")
+	noInvariantChecks := flag.Bool("no-invariant-checks", false, "Render the output even when it violates an invariant, such as an interface listed under groups it does not carry, instead of exiting with an internal error")
+	useFIPS := flag.Bool("use-fips", false, "Call the FIPS endpoints of every service")
+	useDualStack := flag.Bool("use-dualstack", false, "Call the dual-stack endpoints of every service, reachable over IPv6")
+	correlationIdFlag := flag.String("correlation-id", "", "The correlation ID stamped on the JSON metadata, the progress events and the user agent of the API calls, defaults to $"+correlationIdEnv+" or a generated UUID")
+	progressFormat := flag.String("progress-format", "tty", "How progress is reported on stderr: tty (a spinner when stderr is a terminal), json (newline-delimited events) or none")
+	waitUntil := flag.String("wait-until", "empty", "In wait mode, the condition to wait for: empty or count<=N")
+	waitTimeout := flagtypes.NewDuration(flag.CommandLine, "wait-timeout", 10*time.Minute, "In wait mode, the maximum `duration` of the wait")
+	waitInterval := flagtypes.NewDuration(flag.CommandLine, "wait-interval", 15*time.Second, "In wait mode, the `duration` between two polls")
+
+	usedAliases := registerFlagAliases(flag.CommandLine, flagAliases)
+
+	// Parse the command line arguments, the first argument may select a mode
+	mode := ""
+	if len(os.Args) > 1 && isMode(os.Args[1]) {
+		mode = os.Args[1]
+		flag.CommandLine.Parse(joinCLIFilterArgs(os.Args[2:]))
+	} else {
+		flag.CommandLine.Parse(joinCLIFilterArgs(os.Args[1:]))
+	}
+
+	// Describe this build for wrapper tooling, whatever the config file holds
+	if *showCapabilities {
+		if err := printCapabilities(os.Stdout, flag.CommandLine); err != nil {
+			fatal(err)
+		}
+		os.Exit(0)
+	}
+	if *output == "help" {
+		if err := printOutputFormats(os.Stdout); err != nil {
+			fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	// Read the config file, the command line wins
+	configPath, configRequired := *configFile, *configFile != ""
+	if !configRequired {
+		configPath = defaultConfigPath()
+	}
+	if err := applyConfigFile(flag.CommandLine, configPath, configRequired); err != nil {
+		usageError("invalid config file: %v", err)
+	}
+
+	if err := checkFlagAliases(os.Stderr, *usedAliases, *strictFlags); err != nil {
+		usageError("%v", err)
+	}
+	activeTelemetry = newTelemetry(*telemetryEndpoint, *telemetryDryRun, flag.CommandLine)
+
+	if readOnlyBuild && !*readOnly {
+		usageError("-read-only cannot be turned off in a readonly build")
+	}
+	if err := checkReadOnly(flag.CommandLine, *readOnly); err != nil {
+		usageError("%v", err)
+	}
+	expectEnvKey, expectEnvValue := "", ""
+	if *expectEnv != "" {
+		var err error
+		expectEnvKey, expectEnvValue, err = parseExpectEnv(*expectEnv)
+		if err != nil {
+			usageError("invalid value %q for -expect-env: %v", *expectEnv, err)
+		}
+	}
+	if err := setDisplayLocation(*timezone); err != nil {
+		usageError("invalid value %q for -timezone: %v", *timezone, err)
+	}
+	sanitizeDisplay = !*noSanitize && isTerminal(os.Stdout)
+	sanitizeStderr = !*noSanitize && isTerminal(os.Stderr)
+	dedupeWarnings = !*noDedupeWarnings
+	var ageBuckets []time.Duration
+	if *ageHistogram {
+		var err error
+		ageBuckets, err = parseAgeBuckets(*ageBucketsValue)
+		if err != nil {
+			usageError("invalid value %q for -age-buckets: %v", *ageBucketsValue, err)
+		}
+	}
+	parsedFilters, err := parseCLIFilters(describeFilters.Values)
+	if err != nil {
+		usageError("\n%v", err)
+	}
+	normalizeFilterValues(parsedFilters)
+	filterGroups, extraFilters := splitGroupFilters(parsedFilters)
+	*explainEni = strings.TrimSpace(*explainEni)
+	if *explainEni != "" {
+		// Only the explained interface is looked up
+		extraFilters = append(extraFilters, types.Filter{Name: aws.String("network-interface-id"), Values: []string{*explainEni}})
+	}
+	for _, name := range filterGroups {
+		if !slices.Contains(securityGroupNames.Names, name) {
+			securityGroupNames.Names = append(securityGroupNames.Names, name)
+		}
+	}
+	attestKey, err := loadAttestKey(*attestKeyFile)
+	if err != nil {
+		usageError("invalid value %q for -attest-key-file: %v", *attestKeyFile, err)
+	}
+	if mode == "verify-attestation" {
+		if *attest == "" || *reportFile == "" {
+			usageError("verify-attestation requires -attest and -report")
+		}
+		code := runVerifyAttestation(os.Stdout, *attest, *reportFile, attestKey, *requireSignature)
+		activeTelemetry.send(code)
+		os.Exit(code)
+	}
+	if (*budgetDuration > 0 || *budgetAPICalls > 0) && *watch > 0 {
+		usageError("-budget-duration and -budget-api-calls cannot be combined with -watch")
+	}
+	if *teardownBlockers {
+		if *vpcId == "" {
+			usageError("-teardown-blockers requires -vpc-id")
+		}
+		if *allRegions || (*output != "text" && *output != "json") {
+			usageError("-teardown-blockers only supports the text and json outputs in a single region")
+		}
+	}
+	if *streamGroups {
+		if (*output != "text" && *output != "json" && *output != "markdown") || *groupBy != "" || *azBalance {
+			usageError("-stream-groups only supports the text, json and markdown outputs, without -group-by or -az-balance")
+		}
+		if *verify || *splitBy != "" || *attest != "" {
+			usageError("-stream-groups cannot be combined with -verify, -split-by or -attest")
+		}
+	}
+	if *attest != "" && (*watch > 0 || *splitBy != "") {
+		usageError("-attest cannot be combined with -watch or -split-by")
+	}
+	matchOnValue, err := enilookup.ParseMatchOn(*matchOn)
+	if err != nil {
+		usageError("invalid value %q for -match-on: %v", *matchOn, err)
+	}
+
+	if *groupBy != "" && *groupBy != "app" && *groupBy != "instance" {
+		usageError("invalid value %q for -group-by: supported values are: app, instance", *groupBy)
+	}
+	if *effectiveRules && (*groupBy != "instance" || !*resolveInstances) {
+		usageError("-effective-rules requires -group-by instance and -resolve-instances")
+	}
+
+	switch {
+	case len(rollupTagKeys.Keys) == 0:
+	case *output != "text" && *output != "json" && *output != "csv":
+		usageError("-rollup-tag only supports -output text, json or csv")
+	case *groupBy != "" || *azBalance || *streamGroups:
+		usageError("-rollup-tag cannot be combined with -group-by, -az-balance or -stream-groups")
+	}
+	var groupConvention *convention
+	if *conventionFile != "" {
+		if (*output != "text" && *output != "json") || *groupBy != "" || *azBalance || len(rollupTagKeys.Keys) > 0 || *streamGroups {
+			usageError("-convention only supports the text and json outputs, without -group-by, -az-balance, -rollup-tag or -stream-groups")
+		}
+		groupConvention, err = loadConvention(*conventionFile)
+		if err != nil {
+			usageError("invalid -convention: %v", err)
+		}
+	} else if *failOnViolation {
+		usageError("-fail-on-violation requires -convention")
+	}
+	if *explainEni != "" || *verboseNarrative {
+		switch {
+		case *explainEni != "" && *verboseNarrative:
+			usageError("-explain-eni cannot be combined with -verbose-narrative")
+		case *explainEni != "" && *output != "text" && *output != "json", *verboseNarrative && *output != "text":
+			usageError("-explain-eni only supports the text and json outputs, -verbose-narrative the text output")
+		case *groupBy != "" || *azBalance || len(rollupTagKeys.Keys) > 0 || *conventionFile != "":
+			usageError("-explain-eni and -verbose-narrative cannot be combined with -group-by, -az-balance, -rollup-tag or -convention")
+		case *explainEni != "" && *streamGroups:
+			usageError("-explain-eni cannot be combined with -stream-groups")
+		}
+	}
+	var outputTemplate *template.Template
+	if *templateText != "" || *templateFile != "" {
+		if *templateText != "" && *templateFile != "" {
+			usageError("-template cannot be combined with -template-file")
+		}
+		if *output == "text" {
+			*output = "template"
+		}
+		if *output != "template" {
+			usageError("-template and -template-file cannot be combined with -output %s", *output)
+		}
+		outputTemplate, err = parseTemplate(*templateText, *templateFile)
+		if err != nil {
+			usageError("invalid template: %v", err)
+		}
+	}
+	if *templateScope != templateScopeInterface && *templateScope != templateScopeRun {
+		usageError("invalid value %q for -template-scope: supported values are: interface, run", *templateScope)
+	}
+	switch {
+	case *output == "template" && outputTemplate == nil:
+		usageError("-output template requires -template or -template-file")
+	case renderers[*output] == nil:
+		usageError("invalid value %q for -output: supported values are: %s", *output, strings.Join(rendererNames(), ", "))
+	case *output == "csv" && len(rollupTagKeys.Keys) == 0:
+		usageError("-output csv requires -rollup-tag")
+	case *output != "text" && *output != "json" && (*groupBy != "" || *azBalance):
+		usageError("-output %s cannot be combined with -group-by or -az-balance", *output)
+	}
+	var split *splitter
+	if *splitBy != "" {
+		if *outputDir == "" {
+			usageError("-split-by requires -output-dir")
+		}
+		splitter, err := newSplitter(*outputDir, *splitBy, *ownerTagKey, *showEmpty)
+		if err != nil {
+			usageError("invalid value %q for -split-by: %v", *splitBy, err)
+		}
+		split = splitter
+	}
+	if *releaseEips && !*yes && !*dryRun {
+		usageError("-release-eips requires -yes or -dry-run")
+	}
+	var instanceTypeLimits map[string]instanceTypeLimits
+	if *ipCapacity {
+		if !*resolveInstances {
+			usageError("-ip-capacity requires -resolve-instances")
+		}
+		instanceTypeLimits, err = loadInstanceTypeLimits(*ipCapacityFile)
+		if err != nil {
+			usageError("invalid value %q for -ip-capacity-file: %v", *ipCapacityFile, err)
+		}
+	}
+	if *status != "" && !slices.Contains(types.NetworkInterfaceStatus("").Values(), types.NetworkInterfaceStatus(*status)) {
+		usageError("invalid value %q for -status: supported values are: available, associated, attaching, in-use, detaching", *status)
+	}
+	if *newSince > 0 && *historyFile == "" {
+		usageError("-new-since requires -history-file")
+	}
+	if *verify && *resumeFile != "" {
+		usageError("-verify cannot be combined with -resume-file")
+	}
+	if *watch > 0 && *resumeFile != "" {
+		usageError("-watch cannot be combined with -resume-file")
+	}
+	if *forceRefresh && !*incremental {
+		usageError("-force-refresh requires -incremental")
+	}
+	if *verify && *incremental {
+		usageError("-verify cannot be combined with -incremental")
+	}
+
+	var whereFilter *whereFilter
+	if *where != "" {
+		filter, err := compileWhere(*where)
+		if err != nil {
+			usageError("invalid -where expression: %v", err)
+		}
+		whereFilter = filter
+	}
+
+	correlationId, generated, err := resolveCorrelationId(*correlationIdFlag)
+	if err != nil {
+		usageError("invalid correlation ID %v", err)
+	}
+	if generated {
+		fmt.Fprintf(os.Stderr, "Correlation ID: %s\n", correlationId)
+	}
+
+	progress, err := newProgressReporter(*progressFormat, correlationId)
+	if err != nil {
+		usageError("invalid value %q for -progress-format: %v", *progressFormat, err)
+	}
+
+	// context
+	ctx := enilookup.WithNotify(context.TODO(), func(notice string) {
+		printNotice(os.Stderr, notice)
+	})
+	if len(extraFilters) > 0 {
+		ctx = enilookup.WithFilters(ctx, extraFilters)
+	}
+
+	// Create a config
+	endpoints := endpointMode{FIPS: *useFIPS, DualStack: *useDualStack}
+	cfg, err := loadConfig(ctx, endpoints)
+	if err != nil {
+		fatal(err)
+	}
+	cfg.Region = strings.TrimSpace(cfg.Region)
+	*vpcId = strings.TrimSpace(*vpcId)
+	*instanceId = strings.TrimSpace(*instanceId)
+	cfg.APIOptions = append(cfg.APIOptions, correlationAPIOptions(correlationId)...)
+	cfg.APIOptions = append(cfg.APIOptions, progress.apiOptions()...)
+	var usage *usageStats
+	if *showStats {
+		usage = &usageStats{}
+		cfg.APIOptions = append(cfg.APIOptions, usage.apiOptions()...)
+	}
+	var budget *runBudget
+	if *budgetDuration > 0 || *budgetAPICalls > 0 {
+		budget = newRunBudget(*budgetDuration, *budgetAPICalls)
+		cfg.APIOptions = append(cfg.APIOptions, budget.apiOptions()...)
+	}
+	if *readOnly {
+		cfg.APIOptions = append(cfg.APIOptions, readOnlyAPIOptions()...)
+	}
+
+	// Check the identifiers before any lookup
+	if !*noValidate {
+		problems := validateInputs(ctx, newEC2Client(cfg, ""), validationInputs{
+			Region:         cfg.Region,
+			AllRegions:     *allRegions,
+			ExcludeRegions: parseRegionList(*excludeRegions),
+			VpcId:          *vpcId,
+			InstanceId:     *instanceId,
+			ExplainEni:     *explainEni,
+			Filters:        parsedFilters,
+		}, *cacheTTL)
+		if len(problems) > 0 {
+			usageError("%s", formatInputProblems(problems))
+		}
+	}
+
+	// Make sure this is the expected account before doing anything
+	if err := checkExpectedAccount(ctx, cfg, *expectAccount, *expectAccountAlias); err != nil {
+		var assertionErr *envAssertionError
+		if errors.As(err, &assertionErr) {
+			abortOnAssertion(assertionErr.Reason)
+		}
+		fatal(err)
+	}
+
+	if mode == "selftest" {
+		code := runSelftest(ctx, cfg)
+		activeTelemetry.send(code)
+		os.Exit(code)
+	}
+
+	if mode == "wait" {
+		if *allRegions {
+			usageError("-all-regions is not supported in wait mode")
+		}
+		code := runWait(ctx, newEC2Client(cfg, ""), securityGroupNames.Names, *waitUntil, *waitTimeout, *waitInterval, *excludeShared)
+		activeTelemetry.send(code)
+		os.Exit(code)
+	}
+
+	if *teardownBlockers {
+		report, err := getTeardownBlockers(ctx, newEC2Client(cfg, ""), *vpcId)
+		if err != nil {
+			fatal(err)
+		}
+		if *output == "json" {
+			if err := writeJSON(os.Stdout, report); err != nil {
+				fatal(err)
+			}
+		} else {
+			printTeardownReport(os.Stdout, report)
+		}
+		code := 0
+		if report.RemainingInterfaces > 0 {
+			code = exitError
+		}
+		activeTelemetry.send(code)
+		os.Exit(code)
+	}
+
+	// Determine the regions to query
+	regions := []string{cfg.Region}
+	if *allRegions {
+		availableRegions, err := getRegions(ctx, newEC2Client(cfg, ""), *includeOptIn)
+		if err != nil {
+			fatal(err)
+		}
+		regions = selectRegions(availableRegions, parseRegionList(*excludeRegions))
+		regions = getPermittedRegions(ctx, cfg, regions, *regionTimeout, *cacheTTL)
+		fmt.Fprintf(os.Stderr, "Regions: %s\n", strings.Join(regions, ", "))
+	}
+
+	// Check the environment tag of the queried groups, refusing to mutate anything on a mismatch
+	if expectEnvKey != "" {
+		mismatches := []envMismatch{}
+		for _, region := range regions {
+			regionMismatches, err := checkExpectedEnv(ctx, newEC2Client(cfg, region), region, securityGroupNames.Names, expectEnvKey, expectEnvValue)
+			if err != nil {
+				fatal(err)
+			}
+			mismatches = append(mismatches, regionMismatches...)
+		}
+		printEnvMismatches(os.Stderr, *expectEnv, mismatches, expectEnvKey)
+		if set := setMutatingFlags(flag.CommandLine); len(mismatches) > 0 && len(set) > 0 {
+			abortOnAssertion(fmt.Sprintf("%d security groups are not tagged %s, refusing -%s", len(mismatches), *expectEnv, strings.Join(set, ", -")))
+		}
+	}
+
+	// Open the resume state
+	var resume *resumeState
+	if *resumeFile != "" {
+		accountId, err := getAccountId(ctx, cfg)
+		if err != nil {
+			fatal(err)
+		}
+		resume, err = openResumeState(*resumeFile, accountId, *noResume)
+		if err != nil {
+			fatal(err)
+		}
+		defer resume.close()
+	}
+
+	// Open the history
+	var history *interfaceHistory
+	if *historyFile != "" {
+		history, err = openHistory(*historyFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	// Identify the account of the churn snapshots
+	churnAccount := ""
+	if *churn {
+		churnAccount, err = getAccountId(ctx, cfg)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	// Identify the account of the attestation
+	attestAccount := ""
+	if *attest != "" {
+		attestAccount, err = getAccountId(ctx, cfg)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	opts := scanOptions{
+		MatchOn:            matchOnValue,
+		ResolveInstances:   *resolveInstances,
+		Status:             *status,
+		InstanceId:         *instanceId,
+		PublicOnly:         *publicOnly,
+		ShowPermissions:    *showPermissions || *hasPermissionsOnly,
+		HasPermissionsOnly: *hasPermissionsOnly,
+		Resume:             resume,
+		Budget:             budget,
+		Sts:                sts.NewFromConfig(cfg),
+		GroupGate:          &groupGate{max: int(*maxGroups), yes: *yes, interactive: isTerminal(os.Stdin) && isTerminal(os.Stderr)},
+		Where:              whereFilter,
+		History:            history,
+		NewSince:           *newSince,
+		Churn:              *churn,
+		Stats:              *showStats,
+		Usage:              usage,
+		IpCapacity:         *ipCapacity,
+		EffectiveRules:     *effectiveRules,
+		EndpointMode:       endpoints,
+		Explain:            *explain,
+		EipAudit:           *eipAudit || *releaseEips,
+		AgeBuckets:         ageBuckets,
+		MaxPerGroup:        int(*maxPerGroup),
+		MaxResults:         int(*maxResults),
+		InstanceTypeLimits: instanceTypeLimits,
+		WarnAt:             *warnAt,
+		ChurnAccount:       churnAccount,
+	}
+	if *incremental {
+		// The stored details are kept per account
+		accountId, err := getAccountId(ctx, cfg)
+		if err != nil {
+			fatal(err)
+		}
+		opts.Incremental = newIncrementalStore(accountId, opts, extraFilters, *cacheTTL, *forceRefresh)
+	}
+	for _, conflict := range conflictingFilters(opts) {
+		warnOnce(conflict, "%s, no network interface will be reported", conflict)
+	}
+	renderOpts := renderOptions{
+		Output:          *output,
+		GroupBy:         *groupBy,
+		AppTagKey:       *appTagKey,
+		AzBalance:       *azBalance,
+		AzSkewThreshold: *azSkewThreshold,
+		ExcludeShared:   *excludeShared,
+		PublicOnly:      *publicOnly,
+		ShowRegion:      *allRegions,
+		Board:           newBoardState(*watch > 0 && isTerminal(os.Stdout)),
+		Runbook:         *runbook,
+		RollupTags:      rollupTagKeys.Keys,
+		Endpoints:       endpoints.String(),
+		Template:        outputTemplate,
+		TemplateScope:   *templateScope,
+		Convention:      groupConvention,
+		ExplainEni:      *explainEni,
+		Narrative:       *verboseNarrative,
+	}
+	renderOpts.CorrelationId = reportedCorrelationId(correlationId, generated, *noTimestamps)
+	if *output == "dot" || *output == "graph-json" || *output == "config-items" {
+		renderOpts.AccountId, _ = getAccountId(ctx, cfg)
+	}
+	var streamer *groupStreamer
+	if *streamGroups {
+		var onViolation func(err error)
+		if !*noInvariantChecks {
+			onViolation = internalError
+		}
+		streamer = newGroupStreamer(os.Stdout, renderOpts, *noTimestamps, onViolation)
+		opts.OnGroup = streamer.group
+	}
+
+	searched := false
+	for {
+		// With -verify, the churn snapshot is only taken by the second pass, the one reported
+		firstOpts := opts
+		firstOpts.Churn = opts.Churn && !*verify
+		startedAt := time.Now().UTC()
+		run, err := scan(ctx, cfg, regions, securityGroupNames.Names, progress, firstOpts)
+		if err != nil {
+			// Split files and attestations are only written for complete runs
+			if split == nil && *attest == "" {
+				renderPartial(run, streamer, renderOpts, *noTimestamps)
+			}
+			fatal(err)
+		}
+		if *verify {
+			time.Sleep(*verifyGap)
+			secondStartedAt := time.Now().UTC()
+			second, err := scan(ctx, cfg, regions, securityGroupNames.Names, progress, opts)
+			if err != nil {
+				if split == nil && *attest == "" {
+					renderPartial(second, streamer, renderOpts, *noTimestamps)
+				}
+				fatal(err)
+			}
+			second.Verification = verifyRuns(run.Results, startedAt, second.Results, secondStartedAt)
+			run = second
+		}
+		if *noTimestamps {
+			stripTimestamps(&run)
+		}
+		if !*noInvariantChecks && streamer == nil {
+			if err := checkInvariants(run, *excludeShared); err != nil {
+				internalError(err)
+			}
+		}
+		if streamer != nil {
+			if err := streamer.finish(run); err != nil {
+				fatal(err)
+			}
+		} else if split != nil {
+			if err := split.write(run, renderOpts); err != nil {
+				fatal(err)
+			}
+		} else if *attest != "" {
+			// Keep a copy of the report to hash it
+			var report bytes.Buffer
+			if err := render(io.MultiWriter(os.Stdout, &report), run, renderOpts); err != nil {
+				fatal(err)
 			}
-			fmt.Printf("  Status: %s\n", networkInterface.Status)
-			fmt.Println()
+			query := attestationQuery{SecurityGroupNames: securityGroupNames.Names, MatchOn: *matchOn}
+			a := newAttestation(query, attestAccount, regions, run, startedAt, report.Bytes(), *output)
+			if err := writeAttestation(*attest, a, attestKey); err != nil {
+				fatal(err)
+			}
+		} else if err := render(os.Stdout, run, renderOpts); err != nil {
+			fatal(err)
+		}
+
+		// Release the Elastic IPs nothing can use, compiled out of the readonly build
+		if *releaseEips && !readOnlyBuild {
+			clientFor := func(region string) eipAPI { return newEC2Client(cfg, region) }
+			if err := releaseIdleEips(ctx, clientFor, run.IdleEips, *dryRun, os.Stderr); err != nil {
+				fatal(err)
+			}
+		}
+
+		// Look for the missing security groups in the other regions, once
+		interactive := *watch <= 0 && isTerminal(os.Stdin) && isTerminal(os.Stderr)
+		if !searched && (*searchOther || interactive) {
+			searched = true
+			missing, err := missingSelectors(ctx, cfg, run.Results)
+			if err != nil {
+				fatal(err)
+			}
+			if len(missing) > 0 && (*searchOther || confirmSearchOtherRegions(missing)) {
+				if err := searchOtherRegions(ctx, cfg, missing, regions, parseRegionList(*excludeRegions), *regionTimeout); err != nil {
+					fatal(err)
+				}
+			}
+		}
+
+		activeTelemetry.observe(len(regions), run)
+		if *watch <= 0 {
+			code := runExitCode(run, *strict, *failOnUnstable, *failOnViolation, groupConvention)
+			activeTelemetry.send(code)
+			if code != 0 {
+				os.Exit(code)
+			}
+			return
 		}
+		time.Sleep(*watch)
 	}
 }
 
 // getSecurityGroupNames retrieves the names of all security groups.
 //
-// It does so by creating a config using the LoadDefaultConfig function from the AWS SDK for Go.
-// If an error occurs during the creation of the config, it panics.
-//
-// It then creates a context using the TODO function from the context package.
+// It describes the security groups using the DescribeSecurityGroupsInput struct from the AWS SDK for Go,
+// following the pages of the response.
 //
-// Next, it creates an EC2 client using the NewFromConfig function from the AWS SDK for Go.
-//
-// After that, it describes the security groups using the DescribeSecurityGroupsInput struct from the AWS SDK for Go.
-//
-// If an error occurs during the execution of the DescribeSecurityGroups function, it panics.
+// If an error occurs during the execution of the DescribeSecurityGroups function, it is returned.
 //
 // Finally, it retrieves the security group names by iterating over the security groups in the DescribeSecurityGroupsOutput struct and appending their names to a slice.
 //
 // The function returns a slice of strings containing the security group names.
-func getSecurityGroupNames() []string {
-	// Create a config
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		panic(err)
-	}
-	// context
-	ctx := context.TODO()
-
-	// Create an EC2 client
-	ec2Client := ec2.NewFromConfig(cfg)
-
+func getSecurityGroupNames(ctx context.Context, ec2Client *ec2.Client) ([]string, error) {
 	// Describe the security groups
 	describeSecurityGroupsInput := &ec2.DescribeSecurityGroupsInput{}
 
-	describeSecurityGroupsOutput, err := ec2Client.DescribeSecurityGroups(ctx, describeSecurityGroupsInput)
-
-	if err != nil {
-		panic(err)
-	}
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, describeSecurityGroupsInput)
 
 	// Get the security group names
 	securityGroupNames := []string{}
-	for _, securityGroup := range describeSecurityGroupsOutput.SecurityGroups {
-		securityGroupNames = append(securityGroupNames, *securityGroup.GroupName)
+	for paginator.HasMorePages() {
+		describeSecurityGroupsOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, securityGroup := range describeSecurityGroupsOutput.SecurityGroups {
+			securityGroupNames = append(securityGroupNames, aws.ToString(securityGroup.GroupName))
+		}
 	}
 
-	return securityGroupNames
+	return securityGroupNames, nil
 }
 
 // getNetworkInterfacesForSecurityGroup retrieves the network interfaces for a given security group.
 //
-// securityGroupName: The name of the security group.
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client.
+// selector: The security group, selected by name or by ID.
 // []types.NetworkInterface: An array of network interfaces.
-func getNetworkInterfacesForSecurityGroup(securityGroupName string) []types.NetworkInterface {
-	// Create a config
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		panic(err)
-	}
-
-	// context
-	ctx := context.TODO()
-
-	// Create an EC2 client
-	ec2Client := ec2.NewFromConfig(cfg)
-
-	// Describe the network interfaces
-	describeNetworkInterfacesOutput, err := ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("group-name"),
-				Values: []string{securityGroupName},
-			},
-		},
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	// Get the network interfaces
-	networkInterfaces := []types.NetworkInterface{}
-	for _, networkInterface := range describeNetworkInterfacesOutput.NetworkInterfaces {
-		networkInterfaces = append(networkInterfaces, networkInterface)
-	}
-
-	return networkInterfaces
+// error: If the lookup fails.
+func getNetworkInterfacesForSecurityGroup(ctx context.Context, ec2Client *ec2.Client, selector enilookup.Selector) ([]types.NetworkInterface, error) {
+	return enilookup.Lookup(ctx, ec2Client, selector)
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "markdown", description: "a Markdown table per security group", contentType: "text/markdown", extension: ".md", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		printMarkdownReport(w, run.Results, run.Instances, run.Sightings, opts.ShowRegion, opts.PublicOnly)
+		printRegionFailures(w, run.FailedRegions)
+		printRunNotes(w, run, opts)
+		return nil
+	}})
+}
+
+// printMarkdownReport prints the security groups and the network interfaces that are attached to them as Markdown.
+//
+// w: The writer to print to.
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// showRegion: Whether to print the region of every security group.
+// publicOnly: Whether the exposures of the security groups are printed.
+func printMarkdownReport(w io.Writer, results []groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting, showRegion bool, publicOnly bool) {
+	for _, result := range results {
+		title := "Security group name: " + result.Selector.GroupName
+		if result.Selector.GroupId != "" {
+			title = "Security group ID: " + result.Selector.GroupId
+		}
+		if result.Selector.MatchedOn != "" {
+			title += ", matched on " + result.Selector.MatchedOn + " " + result.Selector.Input
+		}
+		if showRegion {
+			title += " (" + result.Region + ")"
+		}
+		fmt.Fprintf(w, "## %s\n\n", markdownEscape(title))
+		if result.Denial != nil {
+			fmt.Fprintf(w, "**Access denied**, the network interfaces could not be listed: %s\n\n", markdownEscape(result.Denial.Error))
+			continue
+		}
+		if result.LookupError != "" {
+			fmt.Fprintf(w, "**Lookup failed**, the network interfaces could not be listed: %s\n\n", markdownEscape(result.LookupError))
+			continue
+		}
+
+		if sightings != nil {
+			fmt.Fprintln(w, "| Network interface | Instance | Status | First seen | Last seen |")
+			fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+		} else {
+			fmt.Fprintln(w, "| Network interface | Instance | Status |")
+			fmt.Fprintln(w, "| --- | --- | --- |")
+		}
+		for _, networkInterface := range result.NetworkInterfaces {
+			instance := ""
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+				instance = aws.ToString(networkInterface.Attachment.InstanceId)
+				if name := tagValue(instances[instance].Tags, "Name"); name != "" {
+					instance += " (" + name + ")"
+				}
+			}
+			row := fmt.Sprintf("| %s | %s | %s |", markdownEscape(derefOr(networkInterface.NetworkInterfaceId, missingValue)), markdownEscape(instance), networkInterface.Status)
+			if sighting, ok := sightings[aws.ToString(networkInterface.NetworkInterfaceId)]; ok {
+				row += fmt.Sprintf(" %s | %s |", formatTimestamp(sighting.FirstSeen), formatTimestamp(sighting.LastSeen))
+			}
+			fmt.Fprintln(w, row)
+		}
+		fmt.Fprintln(w)
+		if result.Truncated {
+			printTruncation(w, result)
+			fmt.Fprintln(w)
+		}
+		if result.AgeHistogram != nil {
+			printAgeHistogram(w, result.AgeHistogram)
+			fmt.Fprintln(w)
+		}
+		if result.Churn != nil {
+			printChurn(w, result.Churn)
+			fmt.Fprintln(w)
+		}
+		if result.Incremental != nil {
+			printRefresh(w, result.Incremental)
+			fmt.Fprintln(w)
+		}
+
+		if publicOnly && len(result.NetworkInterfaces) > 0 {
+			fmt.Fprintln(w, "| Protocol | Ports | Source |")
+			fmt.Fprintln(w, "| --- | --- | --- |")
+			for _, e := range result.Exposures {
+				source := markdownEscape(e.Source)
+				if e.OpenToInternet {
+					source = fmt.Sprintf("%s **%s**", source, openToInternet)
+				}
+				fmt.Fprintf(w, "| %s | %s | %s |\n", e.Protocol, e.PortRange, source)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// markdownEscape escapes the characters that would break a Markdown table cell.
+//
+// value: The value to escape.
+// string: The escaped value.
+func markdownEscape(value string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(displayText(value))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "matrix-csv", description: "the attachment matrix, security groups by resource category, as CSV", contentType: "text/csv", extension: ".csv", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if err := writeMatrixCSV(w, getMatrix(run.Results)); err != nil {
+			return err
+		}
+		printRegionFailures(os.Stderr, run.FailedRegions)
+		return nil
+	}})
+	registerRenderer(formatRenderer{name: "matrix-json", description: "the attachment matrix as a JSON array of rows", contentType: "application/json", extension: ".json", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if err := writeJSON(w, getMatrix(run.Results)); err != nil {
+			return err
+		}
+		printRegionFailures(os.Stderr, run.FailedRegions)
+		return nil
+	}})
+}
+
+// matrixTotal is the security group of the totals row of the matrix.
+const matrixTotal = "total"
+
+// matrixColumns are the resource categories of the matrix, in column order. The categories of the attached
+// interfaces come from enilookup.Classify, as in the detailed reports, and the available interfaces have their own.
+var matrixColumns = []struct {
+	Label     string
+	ManagedBy enilookup.ManagedBy
+}{
+	{"EC2", enilookup.ManagedByEC2},
+	{"Lambda", enilookup.ManagedByLambda},
+	{"ELB/NLB", enilookup.ManagedByELB},
+	{"RDS", enilookup.ManagedByRDS},
+	{"NAT", enilookup.ManagedByNAT},
+	{"Endpoint", enilookup.ManagedByEndpoint},
+	{"EFS", enilookup.ManagedByEFS},
+	{"Other", enilookup.ManagedByOther},
+	{"Available", ""},
+}
+
+// matrixRow counts the network interfaces of a security group per resource category.
+type matrixRow struct {
+	Region        string `json:"region"`
+	SecurityGroup string `json:"security_group"`
+	EC2           int    `json:"ec2"`
+	Lambda        int    `json:"lambda"`
+	ELB           int    `json:"elb_nlb"`
+	RDS           int    `json:"rds"`
+	NAT           int    `json:"nat"`
+	Endpoint      int    `json:"endpoint"`
+	EFS           int    `json:"efs"`
+	Other         int    `json:"other"`
+	Available     int    `json:"available"`
+	Total         int    `json:"total"`
+}
+
+// cells returns the counts of the row in the order of matrixColumns, followed by the total.
+//
+// []*int: The counts.
+func (r *matrixRow) cells() []*int {
+	return []*int{&r.EC2, &r.Lambda, &r.ELB, &r.RDS, &r.NAT, &r.Endpoint, &r.EFS, &r.Other, &r.Available, &r.Total}
+}
+
+// getMatrix counts the network interfaces of every security group per resource category.
+//
+// An available interface counts as Available whatever manages it, an attached one under the category it is
+// classified in. An interface in several security groups counts in the row of each, so the totals row is the
+// sum of the rows.
+//
+// results: The network interfaces found per security group.
+// []matrixRow: A row per security group, in the order of the results, followed by the totals row.
+func getMatrix(results []groupResult) []matrixRow {
+	rows := []matrixRow{}
+	totals := matrixRow{SecurityGroup: matrixTotal}
+	for _, result := range results {
+		row := matrixRow{Region: result.Region, SecurityGroup: result.Selector.Key()}
+		cells := row.cells()
+		for _, networkInterface := range result.NetworkInterfaces {
+			column := len(matrixColumns) - 1
+			if networkInterface.Status != types.NetworkInterfaceStatusAvailable {
+				managedBy := enilookup.Classify(networkInterface).ManagedBy
+				for i, c := range matrixColumns {
+					if c.ManagedBy == managedBy {
+						column = i
+						break
+					}
+				}
+			}
+			*cells[column]++
+			row.Total++
+		}
+		totalCells := totals.cells()
+		for i, cell := range cells {
+			*totalCells[i] += *cell
+		}
+		rows = append(rows, row)
+	}
+	return append(rows, totals)
+}
+
+// matrixRecords returns the header and the rows of the matrix as table cells.
+//
+// rows: The matrix rows, the totals row last.
+// [][]string: The header followed by a record per row.
+func matrixRecords(rows []matrixRow) [][]string {
+	header := []string{"region", "security_group"}
+	for _, c := range matrixColumns {
+		header = append(header, c.Label)
+	}
+	records := [][]string{append(header, "Total")}
+	for _, row := range rows {
+		record := []string{row.Region, row.SecurityGroup}
+		for _, cell := range row.cells() {
+			record = append(record, strconv.Itoa(*cell))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// writeMatrixCSV writes the matrix as CSV.
+//
+// w: The writer to print to.
+// rows: The matrix rows, the totals row last.
+// error: If the CSV cannot be written.
+func writeMatrixCSV(w io.Writer, rows []matrixRow) error {
+	return csv.NewWriter(w).WriteAll(matrixRecords(rows))
+}
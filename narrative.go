@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// narrativeFuncs are the functions of the narrative templates.
+var narrativeFuncs = template.FuncMap{
+	"article": narrativeArticle,
+	"plural": func(count int, noun string) string {
+		if count == 1 {
+			return "1 " + noun
+		}
+		return fmt.Sprintf("%d %ss", count, noun)
+	},
+}
+
+// anAcronyms are the acronyms starting a kind of resource that are spelled out with a vowel sound, and so take an.
+// Other acronyms, such as NAT or VPC, take a.
+var anAcronyms = map[string]bool{
+	"ALB": true,
+	"EC2": true,
+	"ECS": true,
+	"EFS": true,
+	"EKS": true,
+	"ELB": true,
+	"EMR": true,
+	"ENI": true,
+	"FSx": true,
+	"NLB": true,
+	"RDS": true,
+}
+
+// narrativeArticle returns a kind of resource preceded by its indefinite article, read out for the acronyms.
+//
+// kind: The kind, such as "RDS database" or "load balancer".
+// string: The kind with a or an, empty for an empty kind.
+func narrativeArticle(kind string) string {
+	word, _, _ := strings.Cut(kind, " ")
+	switch {
+	case kind == "":
+		return ""
+	case anAcronyms[word]:
+		return "an " + kind
+	case word != strings.ToUpper(word) && strings.ContainsAny(strings.ToLower(kind[:1]), "aeiou"):
+		return "an " + kind
+	default:
+		return "a " + kind
+	}
+}
+
+// The templates of the narratives, executed with the interfaceFacts of an interface. The sentence with the owning
+// resource is only used when the classification is confident, the fallback states the facts read from the interface.
+var (
+	narrativeTemplate = template.Must(template.New("narrative").Funcs(narrativeFuncs).Parse(
+		`This ENI belongs to {{ if .Resource }}the {{ .Kind }} '{{ .Resource }}'{{ else }}{{ article .Kind }}{{ end }}` +
+			` in subnet {{ .Subnet }} ({{ .Zone }}); it is {{ .Status }} and carries {{ plural .Groups "security group" }}; {{ .Removal }}.`))
+	narrativeFallbackTemplate = template.Must(template.New("fallback").Funcs(narrativeFuncs).Parse(
+		`This ENI is in subnet {{ .Subnet }} ({{ .Zone }}); it is {{ .Status }} and carries {{ plural .Groups "security group" }};` +
+			` its description, type and attachment do not tell what it belongs to.`))
+)
+
+// narrativeRemovals are the templates of what freeing the security group from the interface takes, by kind of
+// runbook action.
+var narrativeRemovals = map[string]*template.Template{}
+
+func init() {
+	for kind, text := range map[string]string{
+		runbookDeleteInterface: `it is not attached, deleting the interface frees sg '{{ .Group }}'`,
+		runbookChangeGroups:    `removing sg '{{ .Group }}' from it only requires changing the groups of the interface, which keeps its other groups`,
+		runbookDetachInterface: `removing sg '{{ .Group }}' from it requires detaching it from the instance, as it is its only group`,
+		runbookTerminate:       `removing sg '{{ .Group }}' from it requires giving the primary interface of the instance another group, or terminating the instance`,
+		runbookLambda:          `removing sg '{{ .Group }}' from it requires updating the VPC configuration of the function`,
+		runbookEndpoint:        `removing sg '{{ .Group }}' from it requires updating the VPC endpoint`,
+		runbookLoadBalancer:    `removing sg '{{ .Group }}' from it requires updating the security groups of the load balancer`,
+		runbookNatGateway:      `freeing sg '{{ .Group }}' from it requires deleting the NAT gateway`,
+		runbookManual:          `removing sg '{{ .Group }}' from it requires changing the security groups of the {{ .Kind }}`,
+	} {
+		narrativeRemovals[kind] = template.Must(template.New(kind).Parse(text))
+	}
+}
+
+// managedByKinds name the resources owning the interfaces, as written in the narratives.
+var managedByKinds = map[enilookup.ManagedBy]string{
+	enilookup.ManagedByEC2:      "EC2 instance",
+	enilookup.ManagedByLambda:   "Lambda function",
+	enilookup.ManagedByELB:      "load balancer",
+	enilookup.ManagedByRDS:      "RDS database",
+	enilookup.ManagedByNAT:      "NAT gateway",
+	enilookup.ManagedByEndpoint: "VPC endpoint",
+	enilookup.ManagedByEFS:      "EFS file system",
+}
+
+// loadBalancerKinds name the load balancers by the prefix of the description of their interfaces.
+var loadBalancerKinds = []struct {
+	Prefix string
+	Kind   string
+}{
+	{"ELB app/", "Application Load Balancer"},
+	{"ELB net/", "Network Load Balancer"},
+	{"ELB gwy/", "Gateway Load Balancer"},
+	{"ELB ", "Classic Load Balancer"},
+}
+
+// interfaceFacts are the structured facts a narrative is assembled from.
+type interfaceFacts struct {
+	// Kind is the kind of the owning resource, such as "Application Load Balancer".
+	Kind string
+	// Resource is the name or ID of the owning resource, empty when it cannot be derived.
+	Resource string
+	Subnet   string
+	Zone     string
+	// Status is the status of the interface, in words.
+	Status string
+	// Groups is the number of security groups of the interface.
+	Groups int
+	// Group is the security group of the section, as given by the user.
+	Group string
+	// Removal is what freeing Group from the interface takes.
+	Removal string
+	// Confident is whether the classification found the owning resource.
+	Confident bool
+}
+
+// getInterfaceFacts collects the facts of the narrative of a network interface.
+//
+// result: The security group the interface was found under.
+// networkInterface: The interface.
+// instances: The resolved instances keyed by instance ID, naming the instances by their Name tag.
+// index: The security groups of the interfaces of the run.
+// interfaceFacts: The facts, the free-text fields escaped for display.
+func getInterfaceFacts(result groupResult, networkInterface types.NetworkInterface, instances map[string]types.Instance, index *groupIndex) interfaceFacts {
+	classification := enilookup.Classify(networkInterface)
+	facts := interfaceFacts{
+		Kind:      managedByKinds[classification.ManagedBy],
+		Resource:  classification.ResourceId,
+		Subnet:    derefOr(networkInterface.SubnetId, missingValue),
+		Zone:      derefOr(networkInterface.AvailabilityZone, missingValue),
+		Status:    string(networkInterface.Status),
+		Groups:    len(index.groupsOf(aws.ToString(networkInterface.NetworkInterfaceId))),
+		Group:     displayText(result.Selector.Key()),
+		Confident: classification.ManagedBy != enilookup.ManagedByOther,
+	}
+	switch networkInterface.Status {
+	case types.NetworkInterfaceStatusInUse:
+		facts.Status = "in use"
+	case types.NetworkInterfaceStatusAvailable:
+		facts.Status = "unattached"
+	}
+	if classification.ManagedBy == enilookup.ManagedByELB {
+		description := aws.ToString(networkInterface.Description)
+		for _, loadBalancer := range loadBalancerKinds {
+			if strings.HasPrefix(description, loadBalancer.Prefix) {
+				facts.Kind = loadBalancer.Kind
+				break
+			}
+		}
+	}
+	if instance, ok := instances[facts.Resource]; ok && classification.ManagedBy == enilookup.ManagedByEC2 {
+		if name := tagValue(instance.Tags, "Name"); name != "" {
+			facts.Resource = name
+		}
+	}
+	facts.Resource = displayText(facts.Resource)
+
+	// What freeing the group takes follows the runbook action
+	action := newRunbookAction(result, networkInterface, index)
+	var removal strings.Builder
+	if err := narrativeRemovals[action.Kind].Execute(&removal, facts); err == nil {
+		facts.Removal = removal.String()
+	}
+	return facts
+}
+
+// getNarrative returns the plain-English sentence describing a network interface.
+//
+// facts: The facts of the interface.
+// string: The sentence, the fallback stating the facts of the interface when the classification is not confident.
+func getNarrative(facts interfaceFacts) string {
+	tmpl := narrativeTemplate
+	if !facts.Confident {
+		tmpl = narrativeFallbackTemplate
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, facts); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// interfaceNarrative is the narrative of a network interface under a security group, for -explain-eni.
+type interfaceNarrative struct {
+	Region             string `json:"region"`
+	SecurityGroup      string `json:"security_group"`
+	NetworkInterfaceId string `json:"network_interface_id"`
+	Narrative          string `json:"narrative"`
+	// Confident is false when the narrative is the fallback, the owning resource being unknown.
+	Confident bool `json:"confident"`
+}
+
+// explainReport is the JSON document of -explain-eni.
+type explainReport struct {
+	NetworkInterfaceId string               `json:"network_interface_id"`
+	Narratives         []interfaceNarrative `json:"narratives"`
+	FailedRegions      []regionFailure      `json:"failed_regions"`
+}
+
+// getExplanation returns the narratives of a network interface, one per security group it was found under.
+//
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// networkInterfaceId: The interface to explain.
+// []interfaceNarrative: The narratives, in the order of the results, empty when the interface was not found.
+func getExplanation(results []groupResult, instances map[string]types.Instance, networkInterfaceId string) []interfaceNarrative {
+	narratives := []interfaceNarrative{}
+	index := newGroupIndex(results)
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			if aws.ToString(networkInterface.NetworkInterfaceId) != networkInterfaceId {
+				continue
+			}
+			facts := getInterfaceFacts(result, networkInterface, instances, index)
+			narratives = append(narratives, interfaceNarrative{
+				Region:             result.Region,
+				SecurityGroup:      result.Selector.Key(),
+				NetworkInterfaceId: networkInterfaceId,
+				Narrative:          getNarrative(facts),
+				Confident:          facts.Confident,
+			})
+		}
+	}
+	return narratives
+}
+
+// printExplanation prints the narratives of the network interface of -explain-eni.
+//
+// w: The writer to print to.
+// networkInterfaceId: The interface.
+// narratives: Its narratives.
+// showRegion: Whether to print the region of the security groups, as when several regions are scanned.
+func printExplanation(w io.Writer, networkInterfaceId string, narratives []interfaceNarrative, showRegion bool) {
+	if len(narratives) == 0 {
+		fmt.Fprintf(w, "%s is not a network interface of the security groups\n", networkInterfaceId)
+		return
+	}
+	for _, narrative := range narratives {
+		group := displayText(narrative.SecurityGroup)
+		if showRegion {
+			group += " (" + narrative.Region + ")"
+		}
+		fmt.Fprintf(w, "%s, under %s:\n  %s\n", networkInterfaceId, group, narrative.Narrative)
+	}
+}
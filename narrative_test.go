@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestNarratives(t *testing.T) {
+	run := testRun(false)
+	for _, tt := range []struct {
+		networkInterfaceId string
+		group              string
+		confident          bool
+		want               string
+	}{
+		{"eni-0aaa", "web", true, "This ENI belongs to the EC2 instance 'shop-0' in subnet subnet-1 (eu-west-1a); it is in use and carries 2 security groups; removing sg 'web' from it only requires changing the groups of the interface, which keeps its other groups."},
+		{"eni-0bbb", "web", true, "This ENI belongs to the Application Load Balancer 'shop-alb' in subnet subnet-1 (eu-west-1a); it is in use and carries 1 security group; removing sg 'web' from it requires updating the security groups of the load balancer."},
+		{"eni-0ccc", "db", true, "This ENI belongs to the Lambda function 'shop-worker-0123' in subnet subnet-2 (eu-west-1b); it is in use and carries 1 security group; removing sg 'db' from it requires updating the VPC configuration of the function."},
+		{"eni-0ddd", "db", false, "This ENI is in subnet subnet-1 (eu-west-1a); it is unattached and carries 1 security group; its description, type and attachment do not tell what it belongs to."},
+		{"eni-0eee", "web", true, "This ENI belongs to the EC2 instance 'i-0bbb' in subnet subnet-1 (us-east-1a); it is in use and carries 1 security group; removing sg 'web' from it requires giving the primary interface of the instance another group, or terminating the instance."},
+	} {
+		narratives := getExplanation(run.Results, run.Instances, tt.networkInterfaceId)
+		if len(narratives) == 0 {
+			t.Errorf("%s: no narrative", tt.networkInterfaceId)
+			continue
+		}
+		if n := narratives[0]; n.SecurityGroup != tt.group || n.Confident != tt.confident || n.Narrative != tt.want {
+			t.Errorf("%s: under %s, confident %v:\n%s\nwant under %s, confident %v:\n%s", tt.networkInterfaceId, n.SecurityGroup, n.Confident, n.Narrative, tt.group, tt.confident, tt.want)
+		}
+	}
+
+	// An interface found under two groups has a narrative per group
+	if narratives := getExplanation(run.Results, run.Instances, "eni-0aaa"); len(narratives) != 2 || !strings.Contains(narratives[1].Narrative, "removing sg 'db'") {
+		t.Errorf("eni-0aaa narratives %+v, want one under web and one under db", narratives)
+	}
+}
+
+// TestNarrativeFallback checks that an interface the classification cannot attribute gets the minimal sentence,
+// whatever its description suggests.
+func TestNarrativeFallback(t *testing.T) {
+	group := testGroup("sg-1", "web")
+	unknown := testInterface("eni-0fff", "", group)
+	unknown.Status = types.NetworkInterfaceStatusInUse
+	unknown.RequesterManaged = aws.Bool(true)
+	unknown.Description = aws.String("Looks like an RDS database")
+	result := testResult("eu-west-1", "web", unknown)
+
+	facts := getInterfaceFacts(result, unknown, nil, newGroupIndex([]groupResult{result}))
+	if facts.Confident {
+		t.Fatalf("facts %+v, want a low confidence classification", facts)
+	}
+	want := "This ENI is in subnet subnet-1 (eu-west-1a); it is in use and carries 1 security group; its description, type and attachment do not tell what it belongs to."
+	if got := getNarrative(facts); got != want {
+		t.Errorf("narrative:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A confident classification without a resource name uses the article of the kind
+	facts = interfaceFacts{Kind: "RDS database", Subnet: "subnet-1", Zone: "eu-west-1a", Status: "in use", Groups: 3, Removal: "removing sg 'web' from it requires changing the security groups of the RDS database", Confident: true}
+	want = "This ENI belongs to an RDS database in subnet subnet-1 (eu-west-1a); it is in use and carries 3 security groups; removing sg 'web' from it requires changing the security groups of the RDS database."
+	if got := getNarrative(facts); got != want {
+		t.Errorf("narrative:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNarrativeRemovals(t *testing.T) {
+	for _, kind := range runbookKinds {
+		if narrativeRemovals[kind] == nil {
+			t.Errorf("no narrative for the runbook action %s", kind)
+		}
+	}
+}
+
+func TestNarrativeArticle(t *testing.T) {
+	for kind, want := range map[string]string{
+		"EC2 instance":              "an EC2 instance",
+		"EFS file system":           "an EFS file system",
+		"RDS database":              "an RDS database",
+		"VPC endpoint":              "a VPC endpoint",
+		"load balancer":             "a load balancer",
+		"Lambda function":           "a Lambda function",
+		"NAT gateway":               "a NAT gateway",
+		"ALB":                       "an ALB",
+		"Application Load Balancer": "an Application Load Balancer",
+		"":                          "",
+	} {
+		if got := narrativeArticle(kind); got != want {
+			t.Errorf("narrativeArticle(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestPrintExplanation(t *testing.T) {
+	run := testRun(false)
+	var b bytes.Buffer
+	printExplanation(&b, "eni-0bbb", getExplanation(run.Results, run.Instances, "eni-0bbb"), true)
+	if want := "eni-0bbb, under web (eu-west-1):\n  This ENI belongs to the Application Load Balancer 'shop-alb'"; !strings.HasPrefix(b.String(), want) {
+		t.Errorf("output:\n%s\nwant it to start with:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	printExplanation(&b, "eni-0999", getExplanation(run.Results, run.Instances, "eni-0999"), false)
+	if want := "eni-0999 is not a network interface of the security groups\n"; b.String() != want {
+		t.Errorf("output %q, want %q", b.String(), want)
+	}
+}
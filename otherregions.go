@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// missingSelectors returns the requested security groups that do not exist in any scanned region.
+//
+// Only the groups without network interfaces are checked, with a DescribeSecurityGroups call per region.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// results: The network interfaces found per security group.
+// []enilookup.Selector: The missing security groups, in the order of the results.
+// error: If an API call fails.
+func missingSelectors(ctx context.Context, cfg aws.Config, results []groupResult) ([]enilookup.Selector, error) {
+	found := map[string]bool{}
+	candidates := []enilookup.Selector{}
+	for _, result := range results {
+		if len(result.NetworkInterfaces) > 0 || groupStatus(result) != groupStatusOk {
+			found[result.Selector.Input] = true
+			continue
+		}
+		if found[result.Selector.Input] {
+			continue
+		}
+		securityGroups, err := findSecurityGroups(ctx, newEC2Client(cfg, result.Region), result.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if len(securityGroups) > 0 {
+			found[result.Selector.Input] = true
+			continue
+		}
+		candidates = append(candidates, result.Selector)
+	}
+
+	missing := []enilookup.Selector{}
+	for _, selector := range candidates {
+		if !found[selector.Input] {
+			found[selector.Input] = true
+			missing = append(missing, selector)
+		}
+	}
+	return missing, nil
+}
+
+// searchOtherRegions reports, for every missing security group, the other enabled regions where it exists.
+//
+// The regions are probed concurrently with a DescribeSecurityGroups call each, without looking up
+// their network interfaces. Regions that fail or time out are skipped.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// missing: The security groups that do not exist in the scanned regions.
+// scanned: The regions that were scanned.
+// exclude: The regions to leave out, as given with -exclude-regions.
+// timeout: The maximum duration of the probe of a single region.
+// error: If the enabled regions cannot be listed.
+func searchOtherRegions(ctx context.Context, cfg aws.Config, missing []enilookup.Selector, scanned []string, exclude []string, timeout time.Duration) error {
+	availableRegions, err := getRegions(ctx, newEC2Client(cfg, ""), false)
+	if err != nil {
+		return err
+	}
+	regions := []string{}
+	for _, region := range selectRegions(availableRegions, exclude) {
+		if !slices.Contains(scanned, region) {
+			regions = append(regions, region)
+		}
+	}
+
+	for _, selector := range missing {
+		matches := make([][]string, len(regions))
+		var wg sync.WaitGroup
+		for i, region := range regions {
+			wg.Add(1)
+			go func(i int, region string) {
+				defer wg.Done()
+				probeCtx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				securityGroups, err := findSecurityGroups(probeCtx, newEC2Client(cfg, region), selector)
+				if err != nil {
+					return
+				}
+				for _, securityGroup := range securityGroups {
+					matches[i] = append(matches[i], fmt.Sprintf("%s (%s)", region, aws.ToString(securityGroup.GroupId)))
+				}
+			}(i, region)
+		}
+		wg.Wait()
+
+		found := []string{}
+		for _, regionMatches := range matches {
+			found = append(found, regionMatches...)
+		}
+		if len(found) == 0 {
+			fmt.Fprintf(os.Stderr, "notice: %s not found in %s, nor in any other enabled region\n", selector.Input, strings.Join(scanned, ", "))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "notice: %s not found in %s, but exists in %s\n", selector.Input, strings.Join(scanned, ", "), strings.Join(found, ", "))
+	}
+	return nil
+}
+
+// confirmSearchOtherRegions asks on the terminal whether the other regions should be searched.
+//
+// missing: The security groups that do not exist in the scanned regions.
+// bool: Whether the user accepted.
+func confirmSearchOtherRegions(missing []enilookup.Selector) bool {
+	inputs := []string{}
+	for _, selector := range missing {
+		inputs = append(inputs, selector.Input)
+	}
+	fmt.Fprintf(os.Stderr, "%s not found, search the other enabled regions? [y/N] ", strings.Join(inputs, ", "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// findSecurityGroups returns the security groups matching a selector in the client's region.
+//
+// ctx: The context used for the API call.
+// ec2Client: The EC2 client of the region.
+// selector: The security group, selected by name or by ID.
+// []types.SecurityGroup: The matching security groups.
+// error: If the API call fails.
+func findSecurityGroups(ctx context.Context, ec2Client *ec2.Client, selector enilookup.Selector) ([]types.SecurityGroup, error) {
+	output, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{selector.Filter()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.SecurityGroups, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// describePermissionsChunkSize is the number of interface IDs sent in a single DescribeNetworkInterfacePermissions filter.
+const describePermissionsChunkSize = 200
+
+// interfacePermission is a permission granted on a network interface to another account or service.
+type interfacePermission struct {
+	PermissionId string `json:"permission_id"`
+	AccountId    string `json:"aws_account_id,omitempty"`
+	Service      string `json:"aws_service,omitempty"`
+	Permission   string `json:"permission"`
+	State        string `json:"state"`
+}
+
+// grantee returns the account or service the permission is granted to.
+func (p interfacePermission) grantee() string {
+	if p.AccountId != "" {
+		return p.AccountId
+	}
+	return p.Service
+}
+
+// getPermissions sets the permissions granted on the network interfaces of every result.
+//
+// When the credentials may not call DescribeNetworkInterfacePermissions, a warning is recorded for every result
+// and the results are left without permissions.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region of the results.
+// results: The results, updated in place.
+// error: If an API call fails for another reason.
+func getPermissions(ctx context.Context, ec2Client *ec2.Client, results []groupResult) error {
+	// Collect the unique interface IDs
+	seen := map[string]bool{}
+	networkInterfaceIds := []string{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if !seen[networkInterfaceId] {
+				seen[networkInterfaceId] = true
+				networkInterfaceIds = append(networkInterfaceIds, networkInterfaceId)
+			}
+		}
+	}
+
+	// Describe the permissions in chunks
+	permissions := map[string][]interfacePermission{}
+	for start := 0; start < len(networkInterfaceIds); start += describePermissionsChunkSize {
+		end := min(start+describePermissionsChunkSize, len(networkInterfaceIds))
+		paginator := ec2.NewDescribeNetworkInterfacePermissionsPaginator(ec2Client, &ec2.DescribeNetworkInterfacePermissionsInput{
+			Filters: []types.Filter{{
+				Name:   aws.String("network-interface-permission.network-interface-id"),
+				Values: networkInterfaceIds[start:end],
+			}},
+		})
+		for paginator.HasMorePages() {
+			describePermissionsOutput, err := paginator.NextPage(ctx)
+			if errors.Is(enilookup.ClassifyError(err), enilookup.ErrAccessDenied) {
+				warnDenied("ec2:DescribeNetworkInterfacePermissions", results, "its interface permissions are left out", err)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for _, permission := range describePermissionsOutput.NetworkInterfacePermissions {
+				networkInterfaceId := aws.ToString(permission.NetworkInterfaceId)
+				permissions[networkInterfaceId] = append(permissions[networkInterfaceId], newInterfacePermission(permission))
+			}
+		}
+	}
+
+	for i, result := range results {
+		results[i].Permissions = map[string][]interfacePermission{}
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if granted, ok := permissions[networkInterfaceId]; ok {
+				results[i].Permissions[networkInterfaceId] = granted
+			}
+		}
+	}
+	return nil
+}
+
+// newInterfacePermission converts a network interface permission to its reported form.
+//
+// permission: The network interface permission.
+// interfacePermission: The reported form.
+func newInterfacePermission(permission types.NetworkInterfacePermission) interfacePermission {
+	state := ""
+	if permission.PermissionState != nil {
+		state = string(permission.PermissionState.State)
+	}
+	return interfacePermission{
+		PermissionId: aws.ToString(permission.NetworkInterfacePermissionId),
+		AccountId:    aws.ToString(permission.AwsAccountId),
+		Service:      aws.ToString(permission.AwsService),
+		Permission:   string(permission.Permission),
+		State:        state,
+	}
+}
+
+// printPermissions prints the permissions granted on a network interface.
+//
+// w: The writer to print to.
+// permissions: The permissions to print.
+func printPermissions(w io.Writer, permissions []interfacePermission) {
+	if len(permissions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  Permissions:\n")
+	for _, permission := range permissions {
+		fmt.Fprintf(w, "    %s: %s (%s)\n", permission.grantee(), permission.Permission, permission.State)
+	}
+}
@@ -0,0 +1,16 @@
+// Package enilookup finds the network interfaces that are attached to security groups.
+package enilookup
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// API is the subset of the EC2 client used by the package.
+//
+// *ec2.Client satisfies it, and tests can provide their own implementation.
+type API interface {
+	DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error)
+	DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error)
+}
@@ -0,0 +1,108 @@
+package enilookup
+
+import (
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ManagedBy is the kind of resource a network interface belongs to.
+type ManagedBy string
+
+// The resource kinds recognised by Classify.
+const (
+	ManagedByEC2      ManagedBy = "EC2"
+	ManagedByLambda   ManagedBy = "Lambda"
+	ManagedByELB      ManagedBy = "ELB"
+	ManagedByRDS      ManagedBy = "RDS"
+	ManagedByNAT      ManagedBy = "NAT"
+	ManagedByEndpoint ManagedBy = "Endpoint"
+	ManagedByEFS      ManagedBy = "EFS"
+	ManagedByOther    ManagedBy = "Other"
+)
+
+// Classification describes what a network interface is used by.
+type Classification struct {
+	// ManagedBy is the kind of resource the interface belongs to.
+	ManagedBy ManagedBy
+	// ResourceId identifies the owning resource when it can be derived, such as an instance ID,
+	// a Lambda function name or a load balancer name.
+	ResourceId string
+	// Shared reports a Hyperplane ENI shared by potentially many workloads, such as Lambda functions.
+	Shared bool
+}
+
+var (
+	// hyperplaneRequesterPattern matches the requester ID of the Lambda Hyperplane service.
+	hyperplaneRequesterPattern = regexp.MustCompile(`:awslambda_\d+`)
+
+	// descriptionPatterns map the descriptions set by AWS services to the owning resource kind.
+	// The first submatch, when present, is the owning resource identifier.
+	descriptionPatterns = []struct {
+		pattern   *regexp.Regexp
+		managedBy ManagedBy
+	}{
+		{regexp.MustCompile(`^AWS Lambda VPC ENI-(.+?)(-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})?$`), ManagedByLambda},
+		{regexp.MustCompile(`^ELB (?:app/|net/|gwy/)?([^/]+)`), ManagedByELB},
+		{regexp.MustCompile(`^RDSNetworkInterface`), ManagedByRDS},
+		{regexp.MustCompile(`^Interface for NAT Gateway (nat-[0-9a-f]+)`), ManagedByNAT},
+		{regexp.MustCompile(`^VPC Endpoint Interface (vpce-[0-9a-f]+)`), ManagedByEndpoint},
+		{regexp.MustCompile(`^EFS mount target for (fs-[0-9a-f]+)`), ManagedByEFS},
+	}
+
+	// interfaceTypes map the interface types set by AWS services to the owning resource kind.
+	interfaceTypes = map[types.NetworkInterfaceType]ManagedBy{
+		types.NetworkInterfaceTypeLambda:                      ManagedByLambda,
+		types.NetworkInterfaceTypeNatGateway:                  ManagedByNAT,
+		types.NetworkInterfaceTypeVpcEndpoint:                 ManagedByEndpoint,
+		types.NetworkInterfaceTypeGatewayLoadBalancerEndpoint: ManagedByEndpoint,
+		types.NetworkInterfaceTypeNetworkLoadBalancer:         ManagedByELB,
+		types.NetworkInterfaceTypeGatewayLoadBalancer:         ManagedByELB,
+		types.NetworkInterfaceTypeLoadBalancer:                ManagedByELB,
+	}
+)
+
+// Classify determines the resource a network interface belongs to.
+//
+// The description written by the owning service is checked first because it carries the resource identifier,
+// then the interface type, then the instance attachment.
+//
+// networkInterface: The network interface to classify.
+// Classification: The classification.
+func Classify(networkInterface types.NetworkInterface) Classification {
+	classification := Classification{ManagedBy: ManagedByOther}
+
+	description := aws.ToString(networkInterface.Description)
+	matched := false
+	for _, descriptionPattern := range descriptionPatterns {
+		if match := descriptionPattern.pattern.FindStringSubmatch(description); match != nil {
+			classification.ManagedBy = descriptionPattern.managedBy
+			if len(match) > 1 {
+				classification.ResourceId = match[1]
+			}
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		if managedBy, ok := interfaceTypes[networkInterface.InterfaceType]; ok {
+			classification.ManagedBy = managedBy
+		} else if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+			classification.ManagedBy = ManagedByEC2
+			classification.ResourceId = aws.ToString(networkInterface.Attachment.InstanceId)
+		}
+	}
+
+	classification.Shared = IsShared(networkInterface)
+	return classification
+}
+
+// IsShared reports whether the network interface is a Hyperplane ENI shared by potentially many workloads.
+//
+// networkInterface: The network interface to check.
+// bool: Whether the interface is shared.
+func IsShared(networkInterface types.NetworkInterface) bool {
+	return networkInterface.InterfaceType == types.NetworkInterfaceTypeLambda ||
+		hyperplaneRequesterPattern.MatchString(aws.ToString(networkInterface.RequesterId))
+}
@@ -0,0 +1,92 @@
+package enilookup_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// TestClassify classifies interfaces shaped as the AWS services create them.
+func TestClassify(t *testing.T) {
+	attached := &types.NetworkInterfaceAttachment{InstanceId: aws.String("i-0123456789abcdef0")}
+	for _, tt := range []struct {
+		name          string
+		description   string
+		interfaceType types.NetworkInterfaceType
+		attachment    *types.NetworkInterfaceAttachment
+		want          enilookup.Classification
+	}{
+		// The descriptions written by the owning services
+		{"Lambda with a version UUID", "AWS Lambda VPC ENI-payments-worker-1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", types.NetworkInterfaceTypeLambda, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByLambda, ResourceId: "payments-worker", Shared: true}},
+		{"Lambda without a UUID", "AWS Lambda VPC ENI-payments-worker", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByLambda, ResourceId: "payments-worker"}},
+		{"Application Load Balancer", "ELB app/payments-internal/50dc6c495c0c9188", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB, ResourceId: "payments-internal"}},
+		{"Network Load Balancer", "ELB net/payments-nlb/0123456789abcdef", types.NetworkInterfaceTypeNetworkLoadBalancer, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB, ResourceId: "payments-nlb"}},
+		{"Gateway Load Balancer", "ELB gwy/inspection/0123456789abcdef", types.NetworkInterfaceTypeGatewayLoadBalancer, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB, ResourceId: "inspection"}},
+		{"Classic Load Balancer", "ELB legacy-web", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB, ResourceId: "legacy-web"}},
+		{"RDS", "RDSNetworkInterface", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByRDS}},
+		{"NAT gateway", "Interface for NAT Gateway nat-0123456789abcdef0", types.NetworkInterfaceTypeNatGateway, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByNAT, ResourceId: "nat-0123456789abcdef0"}},
+		{"VPC endpoint", "VPC Endpoint Interface vpce-0123456789abcdef0", types.NetworkInterfaceTypeVpcEndpoint, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByEndpoint, ResourceId: "vpce-0123456789abcdef0"}},
+		{"EFS mount target", "EFS mount target for fs-0123abcd (fsmt-0123abcd)", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByEFS, ResourceId: "fs-0123abcd"}},
+		{"the description wins over the attachment", "RDSNetworkInterface", types.NetworkInterfaceTypeInterface, attached, enilookup.Classification{ManagedBy: enilookup.ManagedByRDS}},
+
+		// The interface types, without a description
+		{"lambda type", "", types.NetworkInterfaceTypeLambda, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByLambda, Shared: true}},
+		{"natGateway type", "", types.NetworkInterfaceTypeNatGateway, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByNAT}},
+		{"vpc_endpoint type", "", types.NetworkInterfaceTypeVpcEndpoint, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByEndpoint}},
+		{"gateway_load_balancer_endpoint type", "", types.NetworkInterfaceTypeGatewayLoadBalancerEndpoint, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByEndpoint}},
+		{"network_load_balancer type", "", types.NetworkInterfaceTypeNetworkLoadBalancer, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB}},
+		{"gateway_load_balancer type", "", types.NetworkInterfaceTypeGatewayLoadBalancer, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB}},
+		{"load_balancer type", "", types.NetworkInterfaceTypeLoadBalancer, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByELB}},
+		{"the type wins over the attachment", "", types.NetworkInterfaceTypeNatGateway, attached, enilookup.Classification{ManagedBy: enilookup.ManagedByNAT}},
+
+		// The instance attachment
+		{"instance", "", types.NetworkInterfaceTypeInterface, attached, enilookup.Classification{ManagedBy: enilookup.ManagedByEC2, ResourceId: "i-0123456789abcdef0"}},
+		{"instance with a free-text description", "Primary network interface", types.NetworkInterfaceTypeInterface, attached, enilookup.Classification{ManagedBy: enilookup.ManagedByEC2, ResourceId: "i-0123456789abcdef0"}},
+
+		// The low-confidence fallback
+		{"unattached", "", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByOther}},
+		{"unknown description", "Created by hand for the migration", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByOther}},
+		{"service name not at the start", "Copy of ELB app/payments/0123", types.NetworkInterfaceTypeInterface, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByOther}},
+		{"unknown type", "", types.NetworkInterfaceTypeTransitGateway, nil, enilookup.Classification{ManagedBy: enilookup.ManagedByOther}},
+		{"attachment without an instance", "", types.NetworkInterfaceTypeInterface, &types.NetworkInterfaceAttachment{InstanceOwnerId: aws.String("amazon-aws")}, enilookup.Classification{ManagedBy: enilookup.ManagedByOther}},
+	} {
+		networkInterface := types.NetworkInterface{InterfaceType: tt.interfaceType, Attachment: tt.attachment}
+		if tt.description != "" {
+			networkInterface.Description = aws.String(tt.description)
+		}
+		if got := enilookup.Classify(networkInterface); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsShared(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		interfaceType types.NetworkInterfaceType
+		requesterId   string
+		want          bool
+	}{
+		{"lambda type", types.NetworkInterfaceTypeLambda, "", true},
+		{"Hyperplane requester", types.NetworkInterfaceTypeInterface, "AROAEXAMPLEROLEID:awslambda_123456789", true},
+		{"Hyperplane requester of a lambda", types.NetworkInterfaceTypeLambda, "AROAEXAMPLEROLEID:awslambda_1", true},
+		{"load balancer requester", types.NetworkInterfaceTypeInterface, "amazon-elb", false},
+		{"requester without the number", types.NetworkInterfaceTypeInterface, "AROAEXAMPLEROLEID:awslambda_", false},
+		{"requester without the colon", types.NetworkInterfaceTypeInterface, "awslambda_123", false},
+		{"instance interface", types.NetworkInterfaceTypeInterface, "", false},
+	} {
+		networkInterface := types.NetworkInterface{InterfaceType: tt.interfaceType}
+		if tt.requesterId != "" {
+			networkInterface.RequesterId = aws.String(tt.requesterId)
+		}
+		if got := enilookup.IsShared(networkInterface); got != tt.want {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+		if got := enilookup.Classify(networkInterface).Shared; got != tt.want {
+			t.Errorf("%s: classified shared %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
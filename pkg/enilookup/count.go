@@ -0,0 +1,79 @@
+package enilookup
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// countChunkSize is the maximum number of values of a DescribeNetworkInterfaces filter.
+const countChunkSize = 200
+
+// CountPerGroup counts the network interfaces attached to every selected security group, with a paginated
+// DescribeNetworkInterfaces call per chunk of groups instead of a lookup per group.
+//
+// The groups selected by ID and by name are queried with the group-id and the group-name filter, and every
+// interface returned is counted for the selectors of the groups in its Groups field. An interface returned on
+// several pages, or by both filters, is counted once per selector. Unlike Stream, a denied group-name filter is
+// not retried with group IDs.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// selectors: The security groups.
+// map[string]int: The number of network interfaces keyed by the Key of the selectors, every selector included.
+// error: If an API call fails, a typed error matching ErrAccessDenied, ErrThrottled or ErrRegionInvalid when the
+// failure is of one of those classes.
+func CountPerGroup(ctx context.Context, api API, selectors []Selector) (map[string]int, error) {
+	counts := map[string]int{}
+	byGroupId := map[string][]string{}
+	byGroupName := map[string][]string{}
+	groupIds, groupNames := []string{}, []string{}
+	for _, selector := range selectors {
+		counts[selector.Key()] = 0
+		if selector.GroupId != "" {
+			if byGroupId[selector.GroupId] == nil {
+				groupIds = append(groupIds, selector.GroupId)
+			}
+			byGroupId[selector.GroupId] = append(byGroupId[selector.GroupId], selector.Key())
+			continue
+		}
+		if byGroupName[selector.GroupName] == nil {
+			groupNames = append(groupNames, selector.GroupName)
+		}
+		byGroupName[selector.GroupName] = append(byGroupName[selector.GroupName], selector.Key())
+	}
+
+	// Count every interface once per selector of its groups
+	seen := map[string]map[string]bool{}
+	count := func(networkInterface types.NetworkInterface) error {
+		networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+		for _, group := range networkInterface.Groups {
+			keys := append(append([]string{}, byGroupId[aws.ToString(group.GroupId)]...), byGroupName[aws.ToString(group.GroupName)]...)
+			for _, key := range keys {
+				if seen[key] == nil {
+					seen[key] = map[string]bool{}
+				}
+				if !seen[key][networkInterfaceId] {
+					seen[key][networkInterfaceId] = true
+					counts[key]++
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, query := range []struct {
+		name   string
+		values []string
+	}{{"group-id", groupIds}, {"group-name", groupNames}} {
+		for start := 0; start < len(query.values); start += countChunkSize {
+			end := min(start+countChunkSize, len(query.values))
+			filter := types.Filter{Name: aws.String(query.name), Values: query.values[start:end]}
+			if err := streamFilter(ctx, api, filter, count); err != nil {
+				return nil, classify(err, "")
+			}
+		}
+	}
+	return counts, nil
+}
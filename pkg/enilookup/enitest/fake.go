@@ -0,0 +1,734 @@
+// Package enitest provides an in-memory fake of the EC2 API used by enilookup.
+//
+// The fake stores security group and network interface fixtures and answers
+// DescribeSecurityGroups and DescribeNetworkInterfaces the way the real API does:
+//
+//   - The values of a filter are ORed and the filters are ANDed.
+//   - Filter values may use the * and ? wildcards.
+//   - An unsupported filter name fails with InvalidParameterValue.
+//   - Without MaxResults every match is returned in a single page, or in pages of the size set with
+//     SetPageSize as the real API does for large result sets. With MaxResults the matches are paginated
+//     and NextToken is set while more pages remain. MaxResults must be between 5 and 1000, and an
+//     unknown NextToken fails with InvalidNextToken.
+//   - GroupIds and NetworkInterfaceIds that do not exist fail with InvalidGroup.NotFound and
+//     InvalidNetworkInterfaceID.NotFound, GroupNames that do not exist with InvalidGroup.NotFound.
+//
+// It also answers DescribeInstances for the instances the interfaces are attached to, InstanceIds that do
+// not exist failing with InvalidInstanceID.NotFound, and DescribeRegions, which leaves the regions that are
+// not opted in out unless AllRegions is set. DescribeAddresses, DisassociateAddress and ReleaseAddress manage
+// the Elastic IP fixtures: an unknown allocation fails with InvalidAllocationID.NotFound, an unknown association
+// with InvalidAssociationID.NotFound, and releasing an associated address with InvalidIPAddress.InUse.
+//
+// Supported DescribeSecurityGroups filters: group-id, group-name, vpc-id, description, tag:<key>.
+// Supported DescribeNetworkInterfaces filters: group-id, group-name, status, subnet-id, vpc-id,
+// availability-zone, network-interface-id, interface-type, description, attachment.instance-id, tag:<key>.
+// Supported DescribeInstances filters: instance-id, instance-type, subnet-id, vpc-id, tag:<key>.
+// Supported DescribeAddresses filters: allocation-id, association-id, network-interface-id, public-ip.
+//
+// Errors are injected per operation with FailWith, or per filter with FailFilter, and OnCall runs a function
+// before the calls of an operation so tests can change the fixtures between two pages.
+//
+// Results are returned in the order the fixtures were added.
+package enitest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// Fake is an in-memory implementation of enilookup.API.
+//
+// It is safe for concurrent use.
+type Fake struct {
+	mu                sync.Mutex
+	securityGroups    []types.SecurityGroup
+	networkInterfaces []types.NetworkInterface
+	instances         []types.Instance
+	regions           []types.Region
+	addresses         []types.Address
+	errors            map[string]error
+	filterErrors      map[string]map[string]error
+	hooks             map[string]func(call int)
+	calls             map[string]int
+	pageSize          int
+}
+
+var _ enilookup.API = (*Fake)(nil)
+
+// New creates an empty fake.
+//
+// *Fake: The fake.
+func New() *Fake {
+	return &Fake{errors: map[string]error{}, filterErrors: map[string]map[string]error{}, hooks: map[string]func(int){}, calls: map[string]int{}}
+}
+
+// AddSecurityGroups adds security group fixtures.
+//
+// securityGroups: The security groups to add.
+func (f *Fake) AddSecurityGroups(securityGroups ...types.SecurityGroup) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.securityGroups = append(f.securityGroups, securityGroups...)
+}
+
+// AddNetworkInterfaces adds network interface fixtures.
+//
+// The interfaces belong to the security groups listed in their Groups field.
+//
+// networkInterfaces: The network interfaces to add.
+func (f *Fake) AddNetworkInterfaces(networkInterfaces ...types.NetworkInterface) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.networkInterfaces = append(f.networkInterfaces, networkInterfaces...)
+}
+
+// RemoveNetworkInterfaces removes network interface fixtures, as when they are deleted between two calls.
+//
+// networkInterfaceIds: The IDs of the interfaces to remove.
+func (f *Fake) RemoveNetworkInterfaces(networkInterfaceIds ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := []types.NetworkInterface{}
+	for _, networkInterface := range f.networkInterfaces {
+		if !slices.Contains(networkInterfaceIds, aws.ToString(networkInterface.NetworkInterfaceId)) {
+			kept = append(kept, networkInterface)
+		}
+	}
+	f.networkInterfaces = kept
+}
+
+// AddInstances adds EC2 instance fixtures.
+//
+// instances: The instances to add.
+func (f *Fake) AddInstances(instances ...types.Instance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.instances = append(f.instances, instances...)
+}
+
+// AddRegions adds region fixtures. A region without OptInStatus does not require opting in.
+//
+// regions: The regions to add.
+func (f *Fake) AddRegions(regions ...types.Region) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.regions = append(f.regions, regions...)
+}
+
+// AddAddresses adds Elastic IP fixtures.
+//
+// addresses: The addresses to add.
+func (f *Fake) AddAddresses(addresses ...types.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addresses = append(f.addresses, addresses...)
+}
+
+// Addresses returns the Elastic IP fixtures, as changed by DisassociateAddress and ReleaseAddress.
+//
+// []types.Address: The addresses, in the order they were added.
+func (f *Fake) Addresses() []types.Address {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.addresses)
+}
+
+// FailWith makes every later call of an operation fail with the given error, nil clears it.
+//
+// operation: The operation name, e.g. DescribeNetworkInterfaces.
+// err: The error to return.
+func (f *Fake) FailWith(operation string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[operation] = err
+}
+
+// FailFilter makes every later call of an operation using a filter fail with the given error, nil clears it,
+// as a permission boundary denying a condition does.
+//
+// operation: The operation name, e.g. DescribeNetworkInterfaces.
+// filter: The filter name, e.g. group-name.
+// err: The error to return.
+func (f *Fake) FailFilter(operation string, filter string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.filterErrors[operation] == nil {
+		f.filterErrors[operation] = map[string]error{}
+	}
+	f.filterErrors[operation][filter] = err
+}
+
+// OnCall calls fn before every later call of an operation, with the number of the call starting at 1, nil
+// clears it. fn may change the fixtures, to simulate changes between the pages of a lookup.
+//
+// operation: The operation name, e.g. DescribeNetworkInterfaces.
+// fn: The function to call.
+func (f *Fake) OnCall(operation string, fn func(call int)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks[operation] = fn
+}
+
+// SetPageSize sets the size of the pages returned to the calls without MaxResults, 0 returns a single page.
+//
+// pageSize: The page size.
+func (f *Fake) SetPageSize(pageSize int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pageSize = pageSize
+}
+
+// Calls returns the number of calls made to an operation, one per page.
+//
+// operation: The operation name, e.g. DescribeNetworkInterfaces.
+// int: The number of calls.
+func (f *Fake) Calls(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[operation]
+}
+
+// DescribeSecurityGroups returns the security groups matching the input.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DescribeSecurityGroupsOutput: A page of matching security groups.
+// error: If the input is invalid or an error was injected with FailWith.
+func (f *Fake) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.hook("DescribeSecurityGroups")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &ec2.DescribeSecurityGroupsInput{}
+	}
+
+	fields := func(securityGroup types.SecurityGroup) map[string][]string {
+		return withTags(map[string][]string{
+			"group-id":    {aws.ToString(securityGroup.GroupId)},
+			"group-name":  {aws.ToString(securityGroup.GroupName)},
+			"vpc-id":      {aws.ToString(securityGroup.VpcId)},
+			"description": {aws.ToString(securityGroup.Description)},
+		}, securityGroup.Tags)
+	}
+	if err := checkFilters(params.Filters, fields(types.SecurityGroup{})); err != nil {
+		return nil, err
+	}
+	if err := f.filterError("DescribeSecurityGroups", params.Filters); err != nil {
+		return nil, err
+	}
+
+	// The requested IDs and names must exist
+	for _, groupId := range params.GroupIds {
+		if !f.hasSecurityGroup(func(g types.SecurityGroup) bool { return aws.ToString(g.GroupId) == groupId }) {
+			return nil, apiError("InvalidGroup.NotFound", fmt.Sprintf("The security group '%s' does not exist", groupId))
+		}
+	}
+	for _, groupName := range params.GroupNames {
+		if !f.hasSecurityGroup(func(g types.SecurityGroup) bool { return aws.ToString(g.GroupName) == groupName }) {
+			return nil, apiError("InvalidGroup.NotFound", fmt.Sprintf("The security group '%s' does not exist in default VPC", groupName))
+		}
+	}
+
+	matches := []types.SecurityGroup{}
+	for _, securityGroup := range f.securityGroups {
+		if !contains(params.GroupIds, aws.ToString(securityGroup.GroupId)) || !contains(params.GroupNames, aws.ToString(securityGroup.GroupName)) {
+			continue
+		}
+		if matchFilters(params.Filters, fields(securityGroup)) {
+			matches = append(matches, securityGroup)
+		}
+	}
+
+	start, end, nextToken, err := f.paginate(len(matches), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: matches[start:end], NextToken: nextToken}, nil
+}
+
+// DescribeNetworkInterfaces returns the network interfaces matching the input.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DescribeNetworkInterfacesOutput: A page of matching network interfaces.
+// error: If the input is invalid or an error was injected with FailWith.
+func (f *Fake) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	f.hook("DescribeNetworkInterfaces")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DescribeNetworkInterfaces"); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &ec2.DescribeNetworkInterfacesInput{}
+	}
+
+	fields := func(networkInterface types.NetworkInterface) map[string][]string {
+		groupIds, groupNames := []string{}, []string{}
+		for _, group := range networkInterface.Groups {
+			groupIds = append(groupIds, aws.ToString(group.GroupId))
+			groupNames = append(groupNames, aws.ToString(group.GroupName))
+		}
+		instanceIds := []string{}
+		if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+			instanceIds = append(instanceIds, *networkInterface.Attachment.InstanceId)
+		}
+		return withTags(map[string][]string{
+			"group-id":               groupIds,
+			"group-name":             groupNames,
+			"status":                 {string(networkInterface.Status)},
+			"subnet-id":              {aws.ToString(networkInterface.SubnetId)},
+			"vpc-id":                 {aws.ToString(networkInterface.VpcId)},
+			"availability-zone":      {aws.ToString(networkInterface.AvailabilityZone)},
+			"network-interface-id":   {aws.ToString(networkInterface.NetworkInterfaceId)},
+			"interface-type":         {string(networkInterface.InterfaceType)},
+			"description":            {aws.ToString(networkInterface.Description)},
+			"attachment.instance-id": instanceIds,
+		}, networkInterface.TagSet)
+	}
+	if err := checkFilters(params.Filters, fields(types.NetworkInterface{})); err != nil {
+		return nil, err
+	}
+	if err := f.filterError("DescribeNetworkInterfaces", params.Filters); err != nil {
+		return nil, err
+	}
+	if params.MaxResults != nil && len(params.NetworkInterfaceIds) > 0 {
+		return nil, apiError("InvalidParameterCombination", "The parameter NetworkInterfaceIds cannot be used with the parameter MaxResults")
+	}
+
+	// The requested IDs must exist
+	for _, networkInterfaceId := range params.NetworkInterfaceIds {
+		found := false
+		for _, networkInterface := range f.networkInterfaces {
+			found = found || aws.ToString(networkInterface.NetworkInterfaceId) == networkInterfaceId
+		}
+		if !found {
+			return nil, apiError("InvalidNetworkInterfaceID.NotFound", fmt.Sprintf("The networkInterface ID '%s' does not exist", networkInterfaceId))
+		}
+	}
+
+	matches := []types.NetworkInterface{}
+	for _, networkInterface := range f.networkInterfaces {
+		if !contains(params.NetworkInterfaceIds, aws.ToString(networkInterface.NetworkInterfaceId)) {
+			continue
+		}
+		if matchFilters(params.Filters, fields(networkInterface)) {
+			matches = append(matches, networkInterface)
+		}
+	}
+
+	start, end, nextToken, err := f.paginate(len(matches), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: matches[start:end], NextToken: nextToken}, nil
+}
+
+// DescribeInstances returns the instances matching the input, one instance per reservation.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DescribeInstancesOutput: A page of matching instances.
+// error: If the input is invalid or an error was injected with FailWith.
+func (f *Fake) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.hook("DescribeInstances")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DescribeInstances"); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &ec2.DescribeInstancesInput{}
+	}
+
+	fields := func(instance types.Instance) map[string][]string {
+		return withTags(map[string][]string{
+			"instance-id":   {aws.ToString(instance.InstanceId)},
+			"instance-type": {string(instance.InstanceType)},
+			"subnet-id":     {aws.ToString(instance.SubnetId)},
+			"vpc-id":        {aws.ToString(instance.VpcId)},
+		}, instance.Tags)
+	}
+	if err := checkFilters(params.Filters, fields(types.Instance{})); err != nil {
+		return nil, err
+	}
+	if err := f.filterError("DescribeInstances", params.Filters); err != nil {
+		return nil, err
+	}
+	if params.MaxResults != nil && len(params.InstanceIds) > 0 {
+		return nil, apiError("InvalidParameterCombination", "The parameter instancesSet cannot be used with the parameter maxResults")
+	}
+
+	// The requested IDs must exist, the error lists every missing one
+	missing := []string{}
+	for _, instanceId := range params.InstanceIds {
+		found := false
+		for _, instance := range f.instances {
+			found = found || aws.ToString(instance.InstanceId) == instanceId
+		}
+		if !found {
+			missing = append(missing, instanceId)
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return nil, apiError("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", missing[0]))
+	default:
+		return nil, apiError("InvalidInstanceID.NotFound", fmt.Sprintf("The instance IDs '%s' do not exist", strings.Join(missing, ", ")))
+	}
+
+	matches := []types.Instance{}
+	for _, instance := range f.instances {
+		if !contains(params.InstanceIds, aws.ToString(instance.InstanceId)) {
+			continue
+		}
+		if matchFilters(params.Filters, fields(instance)) {
+			matches = append(matches, instance)
+		}
+	}
+
+	start, end, nextToken, err := f.paginate(len(matches), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	reservations := []types.Reservation{}
+	for _, instance := range matches[start:end] {
+		reservations = append(reservations, types.Reservation{Instances: []types.Instance{instance}})
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: reservations, NextToken: nextToken}, nil
+}
+
+// DescribeRegions returns the regions enabled for the account, or every region with AllRegions.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DescribeRegionsOutput: The regions.
+// error: If an error was injected with FailWith.
+func (f *Fake) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	f.hook("DescribeRegions")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DescribeRegions"); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &ec2.DescribeRegionsInput{}
+	}
+
+	regions := []types.Region{}
+	for _, region := range f.regions {
+		if aws.ToString(region.OptInStatus) == "not-opted-in" && !aws.ToBool(params.AllRegions) {
+			continue
+		}
+		if contains(params.RegionNames, aws.ToString(region.RegionName)) {
+			regions = append(regions, region)
+		}
+	}
+	return &ec2.DescribeRegionsOutput{Regions: regions}, nil
+}
+
+// DescribeAddresses returns the Elastic IPs matching the input, in a single page.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DescribeAddressesOutput: The matching addresses.
+// error: If the input is invalid or an error was injected with FailWith.
+func (f *Fake) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	f.hook("DescribeAddresses")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DescribeAddresses"); err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = &ec2.DescribeAddressesInput{}
+	}
+
+	fields := func(address types.Address) map[string][]string {
+		return map[string][]string{
+			"allocation-id":        {aws.ToString(address.AllocationId)},
+			"association-id":       {aws.ToString(address.AssociationId)},
+			"network-interface-id": {aws.ToString(address.NetworkInterfaceId)},
+			"public-ip":            {aws.ToString(address.PublicIp)},
+		}
+	}
+	if err := checkFilters(params.Filters, fields(types.Address{})); err != nil {
+		return nil, err
+	}
+	if err := f.filterError("DescribeAddresses", params.Filters); err != nil {
+		return nil, err
+	}
+
+	// The requested allocations must exist
+	for _, allocationId := range params.AllocationIds {
+		if f.address(allocationId) < 0 {
+			return nil, apiError("InvalidAllocationID.NotFound", fmt.Sprintf("The allocation ID '%s' does not exist", allocationId))
+		}
+	}
+
+	matches := []types.Address{}
+	for _, address := range f.addresses {
+		if contains(params.AllocationIds, aws.ToString(address.AllocationId)) && matchFilters(params.Filters, fields(address)) {
+			matches = append(matches, address)
+		}
+	}
+	return &ec2.DescribeAddressesOutput{Addresses: matches}, nil
+}
+
+// DisassociateAddress disassociates an Elastic IP from its network interface.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.DisassociateAddressOutput: The empty output.
+// error: InvalidAssociationID.NotFound if no address has the association, or an error injected with FailWith.
+func (f *Fake) DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput, optFns ...func(*ec2.Options)) (*ec2.DisassociateAddressOutput, error) {
+	f.hook("DisassociateAddress")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "DisassociateAddress"); err != nil {
+		return nil, err
+	}
+	associationId := aws.ToString(params.AssociationId)
+	for i, address := range f.addresses {
+		if associationId != "" && aws.ToString(address.AssociationId) == associationId {
+			f.addresses[i].AssociationId, f.addresses[i].NetworkInterfaceId, f.addresses[i].InstanceId = nil, nil, nil
+			f.addresses[i].PrivateIpAddress = nil
+			return &ec2.DisassociateAddressOutput{}, nil
+		}
+	}
+	return nil, apiError("InvalidAssociationID.NotFound", fmt.Sprintf("The association ID '%s' does not exist", associationId))
+}
+
+// ReleaseAddress releases an Elastic IP, removing its fixture.
+//
+// ctx: The context of the call.
+// params: The input of the call.
+// optFns: Ignored.
+// *ec2.ReleaseAddressOutput: The empty output.
+// error: InvalidAllocationID.NotFound if the allocation does not exist, InvalidIPAddress.InUse if it is still
+// associated, or an error injected with FailWith.
+func (f *Fake) ReleaseAddress(ctx context.Context, params *ec2.ReleaseAddressInput, optFns ...func(*ec2.Options)) (*ec2.ReleaseAddressOutput, error) {
+	f.hook("ReleaseAddress")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.begin(ctx, "ReleaseAddress"); err != nil {
+		return nil, err
+	}
+	allocationId := aws.ToString(params.AllocationId)
+	i := f.address(allocationId)
+	switch {
+	case i < 0:
+		return nil, apiError("InvalidAllocationID.NotFound", fmt.Sprintf("The allocation ID '%s' does not exist", allocationId))
+	case aws.ToString(f.addresses[i].AssociationId) != "":
+		return nil, apiError("InvalidIPAddress.InUse", fmt.Sprintf("Address '%s' is in use", aws.ToString(f.addresses[i].PublicIp)))
+	}
+	f.addresses = slices.Delete(f.addresses, i, i+1)
+	return &ec2.ReleaseAddressOutput{}, nil
+}
+
+// address returns the index of the Elastic IP fixture with an allocation ID.
+//
+// allocationId: The allocation ID.
+// int: The index, -1 when there is no such fixture.
+func (f *Fake) address(allocationId string) int {
+	return slices.IndexFunc(f.addresses, func(address types.Address) bool { return aws.ToString(address.AllocationId) == allocationId })
+}
+
+// hook calls the function set with OnCall for the operation, if any, before the call locks the fake.
+//
+// operation: The operation name.
+func (f *Fake) hook(operation string) {
+	f.mu.Lock()
+	fn, call := f.hooks[operation], f.calls[operation]+1
+	f.mu.Unlock()
+	if fn != nil {
+		fn(call)
+	}
+}
+
+// filterError returns the error set with FailFilter for a filter of the call, if any.
+//
+// operation: The operation name.
+// filters: The filters of the call.
+// error: The error the call fails with.
+func (f *Fake) filterError(operation string, filters []types.Filter) error {
+	for _, filter := range filters {
+		if err := f.filterErrors[operation][aws.ToString(filter.Name)]; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// begin counts a call and returns the context or injected error, if any.
+//
+// ctx: The context of the call.
+// operation: The operation name.
+// error: The error the call fails with.
+func (f *Fake) begin(ctx context.Context, operation string) error {
+	f.calls[operation]++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.errors[operation]
+}
+
+// hasSecurityGroup reports whether a security group fixture satisfies the predicate.
+//
+// match: The predicate.
+// bool: Whether a fixture satisfies it.
+func (f *Fake) hasSecurityGroup(match func(types.SecurityGroup) bool) bool {
+	for _, securityGroup := range f.securityGroups {
+		if match(securityGroup) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFilters rejects the filters the fake does not support.
+//
+// filters: The filters of the call.
+// supported: The fields of a resource, keyed by filter name.
+// error: InvalidParameterValue for an unsupported filter name.
+func checkFilters(filters []types.Filter, supported map[string][]string) error {
+	for _, filter := range filters {
+		if strings.HasPrefix(aws.ToString(filter.Name), "tag:") {
+			continue
+		}
+		if _, ok := supported[aws.ToString(filter.Name)]; !ok {
+			return apiError("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", aws.ToString(filter.Name)))
+		}
+	}
+	return nil
+}
+
+// withTags adds the tag:<key> fields of a resource.
+//
+// fields: The fields of the resource, keyed by filter name.
+// tags: The tags of the resource.
+// map[string][]string: The fields.
+func withTags(fields map[string][]string, tags []types.Tag) map[string][]string {
+	for _, tag := range tags {
+		fields["tag:"+aws.ToString(tag.Key)] = []string{aws.ToString(tag.Value)}
+	}
+	return fields
+}
+
+// matchFilters reports whether a resource satisfies every filter.
+//
+// A filter is satisfied when any of its values matches any of the resource's values for the field.
+//
+// filters: The filters of the call.
+// fields: The fields of the resource, keyed by filter name.
+// bool: Whether the resource satisfies the filters.
+func matchFilters(filters []types.Filter, fields map[string][]string) bool {
+	for _, filter := range filters {
+		matched := false
+		for _, pattern := range filter.Values {
+			for _, value := range fields[aws.ToString(filter.Name)] {
+				if wildcard(pattern).MatchString(value) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcard compiles a filter value, where * matches any sequence of characters and ? any single character.
+//
+// pattern: The filter value.
+// *regexp.Regexp: The matching expression.
+func wildcard(pattern string) *regexp.Regexp {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	return regexp.MustCompile("^" + expression + "$")
+}
+
+// contains reports whether a requested list of IDs or names includes a value, an empty list includes everything.
+//
+// list: The requested IDs or names.
+// value: The value.
+// bool: Whether the list includes the value.
+func contains(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the bounds of the requested page.
+//
+// The NextToken is the offset of the next page. Without maxResults, the pages have the size set with SetPageSize.
+//
+// total: The number of matches.
+// maxResults: The requested page size, nil for a single page.
+// nextToken: The token of the requested page, nil for the first page.
+// int: The start of the page.
+// int: The end of the page.
+// *string: The token of the next page, nil on the last page.
+// error: If the page size or the token is invalid.
+func (f *Fake) paginate(total int, maxResults *int32, nextToken *string) (int, int, *string, error) {
+	start := 0
+	if nextToken != nil {
+		offset, err := strconv.Atoi(*nextToken)
+		if err != nil || offset < 0 || offset > total {
+			return 0, 0, nil, apiError("InvalidNextToken", fmt.Sprintf("The token '%s' is invalid", *nextToken))
+		}
+		start = offset
+	}
+	if maxResults == nil && f.pageSize == 0 {
+		return start, total, nil, nil
+	}
+	if maxResults == nil {
+		maxResults = aws.Int32(int32(f.pageSize))
+	} else if *maxResults < 5 || *maxResults > 1000 {
+		return 0, 0, nil, apiError("InvalidParameterValue", fmt.Sprintf("Value ( %d ) for parameter maxResults is invalid. Parameter must be between 5 and 1000", *maxResults))
+	}
+	end := min(start+int(*maxResults), total)
+	if end < total {
+		return start, end, aws.String(strconv.Itoa(end)), nil
+	}
+	return start, end, nil, nil
+}
+
+// apiError creates an error shaped like an EC2 API error.
+//
+// code: The error code.
+// message: The error message.
+// error: The error.
+func apiError(code string, message string) error {
+	return &smithy.GenericAPIError{Code: code, Message: message, Fault: smithy.FaultClient}
+}
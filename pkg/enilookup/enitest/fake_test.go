@@ -0,0 +1,392 @@
+package enitest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// errorCode returns the API error code of an error, empty when it is not an API error.
+//
+// err: The error.
+// string: The error code.
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// filter returns a filter.
+//
+// name: The filter name.
+// values: The filter values.
+// types.Filter: The filter.
+func filter(name string, values ...string) types.Filter {
+	return types.Filter{Name: aws.String(name), Values: values}
+}
+
+// newFake returns a fake with two groups and five network interfaces:
+//
+//	eni-1 web      in-use     subnet-a
+//	eni-2 web,db   available  subnet-a
+//	eni-3 db       in-use     subnet-b
+//	eni-4 web-old  in-use     subnet-b  tag env=prod
+//	eni-5 (none)   available  subnet-b
+//
+// *enitest.Fake: The fake.
+func newFake() *enitest.Fake {
+	web := types.GroupIdentifier{GroupId: aws.String("sg-0000000000000000a"), GroupName: aws.String("web")}
+	db := types.GroupIdentifier{GroupId: aws.String("sg-0000000000000000b"), GroupName: aws.String("db")}
+	old := types.GroupIdentifier{GroupId: aws.String("sg-0000000000000000c"), GroupName: aws.String("web-old")}
+	networkInterface := func(id string, status types.NetworkInterfaceStatus, subnet string, groups ...types.GroupIdentifier) types.NetworkInterface {
+		return types.NetworkInterface{NetworkInterfaceId: aws.String(id), Status: status, SubnetId: aws.String(subnet), Groups: groups}
+	}
+
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		types.SecurityGroup{GroupId: web.GroupId, GroupName: web.GroupName, VpcId: aws.String("vpc-1")},
+		types.SecurityGroup{GroupId: db.GroupId, GroupName: db.GroupName, VpcId: aws.String("vpc-1")},
+		types.SecurityGroup{GroupId: old.GroupId, GroupName: old.GroupName, VpcId: aws.String("vpc-2"), Tags: []types.Tag{{Key: aws.String("Name"), Value: aws.String("legacy")}}},
+	)
+	tagged := networkInterface("eni-4", types.NetworkInterfaceStatusInUse, "subnet-b", old)
+	tagged.TagSet = []types.Tag{{Key: aws.String("env"), Value: aws.String("prod")}}
+	fake.AddNetworkInterfaces(
+		networkInterface("eni-1", types.NetworkInterfaceStatusInUse, "subnet-a", web),
+		networkInterface("eni-2", types.NetworkInterfaceStatusAvailable, "subnet-a", web, db),
+		networkInterface("eni-3", types.NetworkInterfaceStatusInUse, "subnet-b", db),
+		tagged,
+		networkInterface("eni-5", types.NetworkInterfaceStatusAvailable, "subnet-b"),
+	)
+	return fake
+}
+
+// networkInterfaceIds returns the IDs of the interfaces.
+//
+// output: The output of a call.
+// string: The IDs, comma separated.
+func networkInterfaceIds(output *ec2.DescribeNetworkInterfacesOutput) string {
+	ids := []string{}
+	for _, networkInterface := range output.NetworkInterfaces {
+		ids = append(ids, aws.ToString(networkInterface.NetworkInterfaceId))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestDescribeNetworkInterfacesFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []types.Filter
+		want    string
+	}{
+		{"no filter", nil, "eni-1,eni-2,eni-3,eni-4,eni-5"},
+		{"group-name", []types.Filter{filter("group-name", "web")}, "eni-1,eni-2"},
+		{"group-name values are ORed", []types.Filter{filter("group-name", "web", "db")}, "eni-1,eni-2,eni-3"},
+		{"group-name wildcard", []types.Filter{filter("group-name", "web*")}, "eni-1,eni-2,eni-4"},
+		{"group-name single character wildcard", []types.Filter{filter("group-name", "d?")}, "eni-2,eni-3"},
+		{"group-name is exact", []types.Filter{filter("group-name", "we")}, ""},
+		{"group-id", []types.Filter{filter("group-id", "sg-0000000000000000b")}, "eni-2,eni-3"},
+		{"status", []types.Filter{filter("status", "available")}, "eni-2,eni-5"},
+		{"subnet-id", []types.Filter{filter("subnet-id", "subnet-b")}, "eni-3,eni-4,eni-5"},
+		{"filters are ANDed", []types.Filter{filter("group-name", "web"), filter("status", "in-use")}, "eni-1"},
+		{"group-name and group-id are ANDed", []types.Filter{filter("group-name", "web"), filter("group-id", "sg-0000000000000000b")}, "eni-2"},
+		{"status and subnet", []types.Filter{filter("status", "in-use"), filter("subnet-id", "subnet-b")}, "eni-3,eni-4"},
+		{"tag", []types.Filter{filter("tag:env", "prod")}, "eni-4"},
+		{"missing tag", []types.Filter{filter("tag:owner", "*")}, ""},
+		{"filter without values", []types.Filter{filter("status")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := newFake().DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{Filters: tt.filters})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := networkInterfaceIds(output); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeNetworkInterfacesInvalidInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input *ec2.DescribeNetworkInterfacesInput
+		code  string
+	}{
+		{"unsupported filter", &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{filter("owner-id", "123")}}, "InvalidParameterValue"},
+		{"MaxResults too small", &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(4)}, "InvalidParameterValue"},
+		{"MaxResults too large", &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(1001)}, "InvalidParameterValue"},
+		{"unknown NextToken", &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(5), NextToken: aws.String("token")}, "InvalidNextToken"},
+		{"NextToken past the end", &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(5), NextToken: aws.String("6")}, "InvalidNextToken"},
+		{"IDs with MaxResults", &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni-1"}, MaxResults: aws.Int32(5)}, "InvalidParameterCombination"},
+		{"missing ID", &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni-1", "eni-9"}}, "InvalidNetworkInterfaceID.NotFound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newFake().DescribeNetworkInterfaces(context.Background(), tt.input)
+			if code := errorCode(err); code != tt.code {
+				t.Errorf("err = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestDescribeNetworkInterfacesIds(t *testing.T) {
+	output, err := newFake().DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []string{"eni-4", "eni-2", "eni-3"},
+		Filters:             []types.Filter{filter("status", "in-use")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := networkInterfaceIds(output); got != "eni-3,eni-4" {
+		t.Errorf("got %s, want the in-use interfaces of the IDs in fixture order", got)
+	}
+}
+
+func TestDescribeNetworkInterfacesPagination(t *testing.T) {
+	fake := enitest.New()
+	for i := 0; i < 12; i++ {
+		fake.AddNetworkInterfaces(types.NetworkInterface{NetworkInterfaceId: aws.String(fmt.Sprintf("eni-%02d", i)), Status: types.NetworkInterfaceStatusInUse})
+	}
+
+	// The tokens of a page are accepted by the next call
+	pages := []string{}
+	input := &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(5)}
+	for {
+		output, err := fake.DescribeNetworkInterfaces(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, networkInterfaceIds(output))
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	want := []string{"eni-00,eni-01,eni-02,eni-03,eni-04", "eni-05,eni-06,eni-07,eni-08,eni-09", "eni-10,eni-11"}
+	if strings.Join(pages, " | ") != strings.Join(want, " | ") {
+		t.Errorf("pages = %q, want %q", pages, want)
+	}
+
+	// The SDK paginator follows the tokens, and every page is a call
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(fake, &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(5)})
+	seen := 0
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen += len(output.NetworkInterfaces)
+	}
+	if seen != 12 || fake.Calls("DescribeNetworkInterfaces") != 6 {
+		t.Errorf("paginator saw %d interfaces in %d calls", seen, fake.Calls("DescribeNetworkInterfaces")-3)
+	}
+
+	// A page size splits the calls without MaxResults, MaxResults still wins
+	fake.SetPageSize(7)
+	output, err := fake.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{})
+	if err != nil || len(output.NetworkInterfaces) != 7 || aws.ToString(output.NextToken) != "7" {
+		t.Errorf("page of %d interfaces, token %v, err %v; want 7, \"7\"", len(output.NetworkInterfaces), output.NextToken, err)
+	}
+	output, err = fake.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(10)})
+	if err != nil || len(output.NetworkInterfaces) != 10 {
+		t.Errorf("page of %d interfaces, err %v; want 10", len(output.NetworkInterfaces), err)
+	}
+	// An exact last page has no token
+	output, err = fake.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(6), NextToken: aws.String("6")})
+	if err != nil || len(output.NetworkInterfaces) != 6 || output.NextToken != nil {
+		t.Errorf("last page of %d interfaces, token %v, err %v", len(output.NetworkInterfaces), output.NextToken, err)
+	}
+}
+
+func TestDescribeSecurityGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		input *ec2.DescribeSecurityGroupsInput
+		want  string
+		code  string
+	}{
+		{name: "all", input: &ec2.DescribeSecurityGroupsInput{}, want: "web,db,web-old"},
+		{name: "group-name", input: &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter("group-name", "web*")}}, want: "web,web-old"},
+		{name: "group-id", input: &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter("group-id", "sg-0000000000000000b")}}, want: "db"},
+		{name: "unknown group-id filter value", input: &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter("group-id", "sg-0000000000000000f")}}, want: ""},
+		{name: "vpc and tag", input: &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter("vpc-id", "vpc-2"), filter("tag:Name", "legacy")}}, want: "web-old"},
+		{name: "GroupIds", input: &ec2.DescribeSecurityGroupsInput{GroupIds: []string{"sg-0000000000000000c", "sg-0000000000000000a"}}, want: "web,web-old"},
+		{name: "GroupNames", input: &ec2.DescribeSecurityGroupsInput{GroupNames: []string{"db"}}, want: "db"},
+		{name: "missing GroupIds", input: &ec2.DescribeSecurityGroupsInput{GroupIds: []string{"sg-0000000000000000f"}}, code: "InvalidGroup.NotFound"},
+		{name: "missing GroupNames", input: &ec2.DescribeSecurityGroupsInput{GroupNames: []string{"cache"}}, code: "InvalidGroup.NotFound"},
+		{name: "unsupported filter", input: &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter("ip-permission.cidr", "0.0.0.0/0")}}, code: "InvalidParameterValue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := newFake().DescribeSecurityGroups(context.Background(), tt.input)
+			if tt.code != "" {
+				if code := errorCode(err); code != tt.code {
+					t.Errorf("err = %v, want %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, securityGroup := range output.SecurityGroups {
+				names = append(names, aws.ToString(securityGroup.GroupName))
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeInstances(t *testing.T) {
+	fake := enitest.New()
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		fake.AddInstances(types.Instance{InstanceId: aws.String(id), InstanceType: types.InstanceTypeT3Micro})
+	}
+
+	output, err := fake.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{InstanceIds: []string{"i-3", "i-1"}})
+	if err != nil || len(output.Reservations) != 2 || aws.ToString(output.Reservations[0].Instances[0].InstanceId) != "i-1" {
+		t.Errorf("output = %+v, err = %v", output, err)
+	}
+
+	_, err = fake.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{InstanceIds: []string{"i-1", "i-8", "i-9"}})
+	if errorCode(err) != "InvalidInstanceID.NotFound" || !strings.Contains(err.Error(), "i-8, i-9") {
+		t.Errorf("err = %v, want InvalidInstanceID.NotFound listing i-8 and i-9", err)
+	}
+
+	// The instance-id filter skips the missing instances
+	output, err = fake.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{Filters: []types.Filter{filter("instance-id", "i-2", "i-9")}})
+	if err != nil || len(output.Reservations) != 1 {
+		t.Errorf("output = %+v, err = %v", output, err)
+	}
+}
+
+func TestDescribeRegions(t *testing.T) {
+	fake := enitest.New()
+	fake.AddRegions(
+		types.Region{RegionName: aws.String("eu-west-1")},
+		types.Region{RegionName: aws.String("ap-east-1"), OptInStatus: aws.String("not-opted-in")},
+	)
+	for _, tt := range []struct {
+		allRegions bool
+		want       int
+	}{{false, 1}, {true, 2}} {
+		output, err := fake.DescribeRegions(context.Background(), &ec2.DescribeRegionsInput{AllRegions: aws.Bool(tt.allRegions)})
+		if err != nil || len(output.Regions) != tt.want {
+			t.Errorf("AllRegions=%v: %d regions, err %v; want %d", tt.allRegions, len(output.Regions), err, tt.want)
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	ctx := context.Background()
+	fake := enitest.New()
+	fake.AddAddresses(
+		types.Address{AllocationId: aws.String("eipalloc-1"), AssociationId: aws.String("eipassoc-1"), NetworkInterfaceId: aws.String("eni-1"), PublicIp: aws.String("203.0.113.1")},
+		types.Address{AllocationId: aws.String("eipalloc-2"), PublicIp: aws.String("203.0.113.2")},
+	)
+
+	output, err := fake.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{Filters: []types.Filter{filter("network-interface-id", "eni-1")}})
+	if err != nil || len(output.Addresses) != 1 || aws.ToString(output.Addresses[0].AllocationId) != "eipalloc-1" {
+		t.Errorf("output = %+v, err = %v", output, err)
+	}
+	if _, err := fake.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: []string{"eipalloc-9"}}); errorCode(err) != "InvalidAllocationID.NotFound" {
+		t.Errorf("unknown allocation: err = %v", err)
+	}
+
+	// An associated address is released once disassociated
+	if _, err := fake.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String("eipalloc-1")}); errorCode(err) != "InvalidIPAddress.InUse" {
+		t.Errorf("release while associated: err = %v", err)
+	}
+	if _, err := fake.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-1")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-1")}); errorCode(err) != "InvalidAssociationID.NotFound" {
+		t.Errorf("second disassociation: err = %v", err)
+	}
+	if _, err := fake.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String("eipalloc-1")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String("eipalloc-1")}); errorCode(err) != "InvalidAllocationID.NotFound" {
+		t.Errorf("second release: err = %v", err)
+	}
+	if addresses := fake.Addresses(); len(addresses) != 1 || aws.ToString(addresses[0].AllocationId) != "eipalloc-2" {
+		t.Errorf("addresses = %+v, want eipalloc-2 only", addresses)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	fake := newFake()
+	denied := &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "denied"}
+
+	fake.FailFilter("DescribeNetworkInterfaces", "group-name", denied)
+	if _, err := fake.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{filter("group-name", "web")}}); !errors.Is(err, denied) {
+		t.Errorf("group-name filter err = %v, want the injected error", err)
+	}
+	if _, err := fake.DescribeNetworkInterfaces(context.Background(), &ec2.DescribeNetworkInterfacesInput{Filters: []types.Filter{filter("group-id", "sg-0000000000000000a")}}); err != nil {
+		t.Errorf("group-id filter err = %v, want nil", err)
+	}
+	fake.FailFilter("DescribeNetworkInterfaces", "group-name", nil)
+
+	fake.FailWith("DescribeSecurityGroups", denied)
+	if _, err := fake.DescribeSecurityGroups(context.Background(), nil); !errors.Is(err, denied) {
+		t.Errorf("err = %v, want the injected error", err)
+	}
+	fake.FailWith("DescribeSecurityGroups", nil)
+	if _, err := fake.DescribeSecurityGroups(context.Background(), nil); err != nil {
+		t.Errorf("err = %v after clearing the injected error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fake.DescribeNetworkInterfaces(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if calls := fake.Calls("DescribeNetworkInterfaces"); calls != 3 {
+		t.Errorf("calls = %d, want 3, failed calls included", calls)
+	}
+}
+
+func TestOnCall(t *testing.T) {
+	fake := newFake()
+	fake.SetPageSize(2)
+	calls := []int{}
+	fake.OnCall("DescribeNetworkInterfaces", func(call int) {
+		calls = append(calls, call)
+		if call == 2 {
+			fake.RemoveNetworkInterfaces("eni-1")
+		}
+	})
+
+	pages := []string{}
+	input := &ec2.DescribeNetworkInterfacesInput{}
+	for {
+		output, err := fake.DescribeNetworkInterfaces(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, networkInterfaceIds(output))
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	// Removing eni-1 before the second page shifts eni-3 onto the first page, so it is missed
+	if strings.Join(pages, " | ") != "eni-1,eni-2 | eni-4,eni-5" || fmt.Sprint(calls) != "[1 2]" {
+		t.Errorf("pages = %q, calls = %v", pages, calls)
+	}
+}
@@ -0,0 +1,174 @@
+package enilookup
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+// Sentinel errors matching the failure classes of the package, for use with errors.Is.
+var (
+	ErrGroupNotFound = errors.New("security group not found")
+	ErrThrottled     = errors.New("request throttled")
+	ErrAccessDenied  = errors.New("access denied")
+	ErrRegionInvalid = errors.New("invalid region")
+)
+
+// accessDeniedCodes are the API error codes returned when IAM denies a call.
+var accessDeniedCodes = map[string]bool{
+	"UnauthorizedOperation": true,
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedAccess":    true,
+}
+
+// groupNotFoundCodes are the API error codes returned for security groups that do not exist.
+var groupNotFoundCodes = map[string]bool{
+	"InvalidGroup.NotFound":    true,
+	"InvalidGroupId.NotFound":  true,
+	"InvalidGroupId.Malformed": true,
+}
+
+// GroupNotFoundError is returned when a requested security group does not exist. It matches ErrGroupNotFound.
+type GroupNotFoundError struct {
+	// Group is the security group name or ID.
+	Group string
+	// Err is the underlying API error, if any.
+	Err error
+}
+
+// Error returns the group that was not found.
+func (e *GroupNotFoundError) Error() string {
+	return fmt.Sprintf("security group %s not found", e.Group)
+}
+
+// Is matches ErrGroupNotFound.
+func (e *GroupNotFoundError) Is(target error) bool { return target == ErrGroupNotFound }
+
+// Unwrap returns the underlying API error.
+func (e *GroupNotFoundError) Unwrap() error { return e.Err }
+
+// AccessDeniedError is returned when IAM denies a call. It matches ErrAccessDenied.
+type AccessDeniedError struct {
+	// Action is the denied IAM action, such as ec2:DescribeNetworkInterfaces.
+	Action string
+	// Err is the underlying API error.
+	Err error
+}
+
+// Error returns the denied action and the API error.
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("access denied to %s: %v", e.Action, e.Err)
+}
+
+// Is matches ErrAccessDenied.
+func (e *AccessDeniedError) Is(target error) bool { return target == ErrAccessDenied }
+
+// Unwrap returns the underlying API error.
+func (e *AccessDeniedError) Unwrap() error { return e.Err }
+
+// ThrottledError is returned when a call is still throttled after the SDK retries. It matches ErrThrottled.
+type ThrottledError struct {
+	// Action is the throttled IAM action.
+	Action string
+	// Err is the underlying API error.
+	Err error
+}
+
+// Error returns the throttled action and the API error.
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s throttled: %v", e.Action, e.Err)
+}
+
+// Is matches ErrThrottled.
+func (e *ThrottledError) Is(target error) bool { return target == ErrThrottled }
+
+// Unwrap returns the underlying API error.
+func (e *ThrottledError) Unwrap() error { return e.Err }
+
+// RegionInvalidError is returned when the region of the client does not exist. It matches ErrRegionInvalid.
+type RegionInvalidError struct {
+	// Region is the invalid region, when it can be determined.
+	Region string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the invalid region and the underlying error.
+func (e *RegionInvalidError) Error() string {
+	if e.Region == "" {
+		return fmt.Sprintf("invalid region: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid region %s: %v", e.Region, e.Err)
+}
+
+// Is matches ErrRegionInvalid.
+func (e *RegionInvalidError) Is(target error) bool { return target == ErrRegionInvalid }
+
+// Unwrap returns the underlying error.
+func (e *RegionInvalidError) Unwrap() error { return e.Err }
+
+// classify wraps an error returned by the SDK in the typed error of its failure class.
+//
+// Errors that are already classified, or that match no class, are returned unchanged.
+//
+// err: The error to classify.
+// group: The security group the call was made for, used for ErrGroupNotFound.
+// error: The classified error.
+func classify(err error, group string) error {
+	if err == nil {
+		return nil
+	}
+	for _, sentinel := range []error{ErrGroupNotFound, ErrThrottled, ErrAccessDenied, ErrRegionInvalid} {
+		if errors.Is(err, sentinel) {
+			return err
+		}
+	}
+
+	action := "ec2"
+	var operationErr *smithy.OperationError
+	if errors.As(err, &operationErr) {
+		action = strings.ToLower(operationErr.Service()) + ":" + operationErr.Operation()
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case accessDeniedCodes[apiErr.ErrorCode()]:
+			return &AccessDeniedError{Action: action, Err: err}
+		case groupNotFoundCodes[apiErr.ErrorCode()]:
+			return &GroupNotFoundError{Group: group, Err: err}
+		}
+	}
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return &ThrottledError{Action: action, Err: err}
+	}
+
+	// An unknown region has no endpoint, which surfaces as a failed DNS lookup of the endpoint host
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		region := ""
+		if parts := strings.Split(dnsErr.Name, "."); len(parts) >= 3 {
+			region = parts[1]
+		}
+		return &RegionInvalidError{Region: region, Err: err}
+	}
+
+	return err
+}
+
+// ClassifyError wraps an error returned by an AWS SDK call in the typed error of its failure class.
+//
+// The functions of the package already return classified errors; ClassifyError is meant for callers making
+// their own SDK calls alongside them.
+//
+// err: The error to classify.
+// error: The classified error, or err when it matches no class.
+func ClassifyError(err error) error {
+	return classify(err, "")
+}
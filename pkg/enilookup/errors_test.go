@@ -0,0 +1,113 @@
+package enilookup_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// operationError returns an API error as the SDK returns it, wrapped in the error of the operation.
+//
+// operation: The operation name.
+// code: The API error code.
+// error: The error.
+func operationError(operation string, code string) error {
+	return &smithy.OperationError{
+		ServiceID:     "EC2",
+		OperationName: operation,
+		Err:           &smithy.GenericAPIError{Code: code, Message: "message of " + code, Fault: smithy.FaultClient},
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	dnsErr := &smithy.OperationError{ServiceID: "EC2", OperationName: "DescribeNetworkInterfaces", Err: &net.DNSError{
+		Err: "no such host", Name: "ec2.xx-nowhere-1.amazonaws.com", IsNotFound: true,
+	}}
+
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		check    func(error) bool
+	}{
+		{name: "unauthorized", err: operationError("DescribeNetworkInterfaces", "UnauthorizedOperation"), sentinel: enilookup.ErrAccessDenied, check: func(err error) bool {
+			var denied *enilookup.AccessDeniedError
+			return errors.As(err, &denied) && denied.Action == "ec2:DescribeNetworkInterfaces"
+		}},
+		{name: "access denied", err: operationError("DescribeSecurityGroups", "AccessDenied"), sentinel: enilookup.ErrAccessDenied},
+		{name: "group not found", err: operationError("DescribeSecurityGroups", "InvalidGroup.NotFound"), sentinel: enilookup.ErrGroupNotFound},
+		{name: "malformed group ID", err: operationError("DescribeSecurityGroups", "InvalidGroupId.Malformed"), sentinel: enilookup.ErrGroupNotFound},
+		{name: "request limit", err: operationError("DescribeNetworkInterfaces", "RequestLimitExceeded"), sentinel: enilookup.ErrThrottled, check: func(err error) bool {
+			var throttled *enilookup.ThrottledError
+			return errors.As(err, &throttled) && throttled.Action == "ec2:DescribeNetworkInterfaces"
+		}},
+		{name: "throttling", err: operationError("DescribeNetworkInterfaces", "Throttling"), sentinel: enilookup.ErrThrottled},
+		{name: "unknown region", err: dnsErr, sentinel: enilookup.ErrRegionInvalid, check: func(err error) bool {
+			var invalid *enilookup.RegionInvalidError
+			return errors.As(err, &invalid) && invalid.Region == "xx-nowhere-1"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := enilookup.ClassifyError(tt.err)
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("%v does not match %v", err, tt.sentinel)
+			}
+			// The classes survive wrapping, and the API error stays reachable
+			wrapped := fmt.Errorf("region eu-west-1: %w", err)
+			if !errors.Is(wrapped, tt.sentinel) {
+				t.Errorf("wrapped %v does not match %v", wrapped, tt.sentinel)
+			}
+			var operationErr *smithy.OperationError
+			if !errors.As(wrapped, &operationErr) {
+				t.Errorf("the operation error of %v is lost", wrapped)
+			}
+			if tt.check != nil && !tt.check(wrapped) {
+				t.Errorf("unexpected typed error %#v", err)
+			}
+			if again := enilookup.ClassifyError(wrapped); again != wrapped {
+				t.Errorf("classifying a classified error changed it to %v", again)
+			}
+		})
+	}
+}
+
+func TestClassifyErrorUnchanged(t *testing.T) {
+	for _, err := range []error{
+		nil,
+		errors.New("boom"),
+		operationError("DescribeNetworkInterfaces", "InvalidParameterValue"),
+	} {
+		if got := enilookup.ClassifyError(err); got != err {
+			t.Errorf("ClassifyError(%v) = %v, want it unchanged", err, got)
+		}
+		for _, sentinel := range []error{enilookup.ErrGroupNotFound, enilookup.ErrThrottled, enilookup.ErrAccessDenied, enilookup.ErrRegionInvalid} {
+			if errors.Is(err, sentinel) {
+				t.Errorf("%v matches %v", err, sentinel)
+			}
+		}
+	}
+}
+
+func TestLookupReturnsTypedErrors(t *testing.T) {
+	fake := enitest.New()
+	fake.FailWith("DescribeNetworkInterfaces", operationError("DescribeNetworkInterfaces", "RequestLimitExceeded"))
+	_, err := enilookup.Lookup(context.Background(), fake, enilookup.Selector{Input: "web", GroupName: "web"})
+	if !errors.Is(err, enilookup.ErrThrottled) {
+		t.Errorf("Lookup err = %v, want ErrThrottled", err)
+	}
+
+	// A group ID that does not exist fails the lookup of security groups
+	_, err = enilookup.Resolve(context.Background(), enitest.New(), []string{"sg-0a1b2c3d"}, nil)
+	var notFound *enilookup.GroupNotFoundError
+	if !errors.As(err, &notFound) || notFound.Group != "sg-0a1b2c3d" {
+		t.Errorf("Resolve err = %v, want a *GroupNotFoundError for sg-0a1b2c3d", err)
+	}
+}
@@ -0,0 +1,103 @@
+package enilookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// notifyKey is the context key holding the notifier set by WithNotify.
+type notifyKey struct{}
+
+// WithNotify returns a context whose lookups report human readable notices, such as a fallback being used, to fn.
+//
+// ctx: The parent context.
+// fn: Called with every notice.
+// context.Context: The context carrying fn.
+func WithNotify(ctx context.Context, fn func(string)) context.Context {
+	return context.WithValue(ctx, notifyKey{}, fn)
+}
+
+// notify reports a notice to the notifier of the context, if any.
+//
+// ctx: The context carrying the notifier.
+// format: The notice format.
+// args: The notice arguments.
+func notify(ctx context.Context, format string, args ...any) {
+	if fn, ok := ctx.Value(notifyKey{}).(func(string)); ok && fn != nil {
+		fn(fmt.Sprintf(format, args...))
+	}
+}
+
+// FallbackError is returned when the group-name filter is denied and the group-id fallback fails as well.
+//
+// Both errors are kept so the user can see exactly which action or condition to request.
+type FallbackError struct {
+	// GroupName is the name of the security group.
+	GroupName string
+	// NameFilterErr is the error of DescribeNetworkInterfaces with the group-name filter.
+	NameFilterErr error
+	// FallbackErr is the error of the fallback, from DescribeSecurityGroups or from DescribeNetworkInterfaces with the group-id filter.
+	FallbackErr error
+}
+
+// Error returns both errors.
+func (e *FallbackError) Error() string {
+	return fmt.Sprintf("lookup of %s failed with the group-name filter: %v; the group-id fallback failed as well: %v", e.GroupName, e.NameFilterErr, e.FallbackErr)
+}
+
+// Unwrap returns both errors.
+func (e *FallbackError) Unwrap() []error {
+	return []error{e.NameFilterErr, e.FallbackErr}
+}
+
+// isUnauthorized reports whether the error is an EC2 UnauthorizedOperation error.
+//
+// err: The error to check.
+// bool: Whether the call was denied.
+func isUnauthorized(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "UnauthorizedOperation"
+}
+
+// streamByGroupId retries a denied group-name lookup by resolving the name to group IDs and filtering on them.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// groupName: The name of the security group.
+// nameFilterErr: The error of the group-name lookup.
+// fn: Called once per network interface.
+// error: A *FallbackError when the fallback fails, a *GroupNotFoundError when no group has the name,
+// or the error of fn wrapped in a *callbackError.
+func streamByGroupId(ctx context.Context, api API, groupName string, nameFilterErr error, fn func(types.NetworkInterface) error) error {
+	securityGroups, err := describeSecurityGroups(ctx, api, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{{Name: aws.String("group-name"), Values: []string{groupName}}},
+	})
+	if err != nil {
+		return &FallbackError{GroupName: groupName, NameFilterErr: classify(nameFilterErr, groupName), FallbackErr: classify(err, groupName)}
+	}
+
+	groupIds := []string{}
+	for _, securityGroup := range securityGroups {
+		groupIds = append(groupIds, aws.ToString(securityGroup.GroupId))
+	}
+	if len(groupIds) == 0 {
+		return &GroupNotFoundError{Group: groupName}
+	}
+	notify(ctx, "the group-name filter was denied for %s, looked up by group ID %v instead", groupName, groupIds)
+
+	fallbackErr := streamFilter(ctx, api, types.Filter{Name: aws.String("group-id"), Values: groupIds}, fn)
+	var cbErr *callbackError
+	if errors.As(fallbackErr, &cbErr) {
+		return cbErr
+	}
+	if fallbackErr != nil {
+		return &FallbackError{GroupName: groupName, NameFilterErr: classify(nameFilterErr, groupName), FallbackErr: classify(fallbackErr, groupName)}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package enilookup
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// filtersKey is the context key holding the filters set by WithFilters.
+type filtersKey struct{}
+
+// WithFilters returns a context whose lookups send the filters with the security group filter of every
+// DescribeNetworkInterfaces call, such as status or subnet-id filters. The API ANDs them.
+//
+// ctx: The parent context.
+// filters: The additional DescribeNetworkInterfaces filters.
+// context.Context: The context carrying the filters.
+func WithFilters(ctx context.Context, filters []types.Filter) context.Context {
+	return context.WithValue(ctx, filtersKey{}, filters)
+}
+
+// filtersOf returns the additional filters of the context, or nil.
+//
+// ctx: The context carrying the filters.
+// []types.Filter: The filters.
+func filtersOf(ctx context.Context) []types.Filter {
+	filters, _ := ctx.Value(filtersKey{}).([]types.Filter)
+	return filters
+}
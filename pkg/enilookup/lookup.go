@@ -0,0 +1,125 @@
+package enilookup
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Stream calls fn for every network interface attached to the selected security group, page by page.
+//
+// When the group-name filter is denied with UnauthorizedOperation, which some permission boundaries do while allowing
+// the group-id filter, the name is resolved to group IDs and the lookup is retried with those. The fallback is
+// reported to the notifier set with WithNotify.
+//
+// Returning an error from fn stops the lookup and returns that error.
+//
+// Under heavy churn, an interface reattached during the pagination can be returned on several pages, so fn may
+// see the same NetworkInterfaceId more than once. Lookup collapses those duplicates.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// selector: The security group, selected by name or by ID.
+// fn: Called once per network interface.
+// error: If fn fails, its error. If an API call fails, a typed error matching ErrAccessDenied, ErrThrottled,
+// ErrGroupNotFound or ErrRegionInvalid when the failure is of one of those classes.
+func Stream(ctx context.Context, api API, selector Selector, fn func(types.NetworkInterface) error) error {
+	emitted := 0
+	err := streamFilter(ctx, api, selector.Filter(), func(networkInterface types.NetworkInterface) error {
+		emitted++
+		return fn(networkInterface)
+	})
+	var cbErr *callbackError
+	if errors.As(err, &cbErr) {
+		return cbErr.err
+	}
+	if err != nil && selector.GroupName != "" && emitted == 0 && isUnauthorized(err) {
+		err = streamByGroupId(ctx, api, selector.GroupName, err, fn)
+		if errors.As(err, &cbErr) {
+			return cbErr.err
+		}
+	}
+	return classify(err, selector.Input)
+}
+
+// callbackError wraps an error returned by the callback of a stream, so it is not mistaken for an API error.
+type callbackError struct {
+	err error
+}
+
+// Error returns the error of the callback.
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
+
+// streamFilter calls fn for every network interface matching the filter, page by page.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// filter: The DescribeNetworkInterfaces filter, sent with the filters set with WithFilters.
+// fn: Called once per network interface.
+// error: The error of a failed API call, or the error of fn wrapped in a *callbackError.
+func streamFilter(ctx context.Context, api API, filter types.Filter, fn func(types.NetworkInterface) error) error {
+	// Describe the network interfaces
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(api, &ec2.DescribeNetworkInterfacesInput{
+		Filters: append([]types.Filter{filter}, filtersOf(ctx)...),
+	})
+
+	for paginator.HasMorePages() {
+		describeNetworkInterfacesOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if stats := statsOf(ctx); stats != nil {
+			stats.Pages.Add(1)
+		}
+		for _, networkInterface := range describeNetworkInterfacesOutput.NetworkInterfaces {
+			if err := fn(networkInterface); err != nil {
+				return &callbackError{err: err}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Lookup retrieves every network interface attached to the selected security group.
+//
+// An interface returned on several pages is reported once, at the position it was first returned, with the
+// version of the object returned last. The collapsed duplicates are counted in the Stats set with WithStats.
+// Interfaces returned without an ID cannot be told apart and are all reported.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// selector: The security group, selected by name or by ID.
+// []types.NetworkInterface: The network interfaces.
+// error: If an API call fails, see Stream.
+func Lookup(ctx context.Context, api API, selector Selector) ([]types.NetworkInterface, error) {
+	networkInterfaces := []types.NetworkInterface{}
+	positions := map[string]int{}
+	err := Stream(ctx, api, selector, func(networkInterface types.NetworkInterface) error {
+		if networkInterface.NetworkInterfaceId == nil {
+			networkInterfaces = append(networkInterfaces, networkInterface)
+			return nil
+		}
+		networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+		if i, ok := positions[networkInterfaceId]; ok {
+			// Keep the last seen version of a duplicate
+			networkInterfaces[i] = networkInterface
+			if stats := statsOf(ctx); stats != nil {
+				stats.Duplicates.Add(1)
+			}
+			return nil
+		}
+		positions[networkInterfaceId] = len(networkInterfaces)
+		networkInterfaces = append(networkInterfaces, networkInterface)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return networkInterfaces, nil
+}
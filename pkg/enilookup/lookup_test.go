@@ -0,0 +1,302 @@
+package enilookup_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// ids returns the IDs of the network interfaces.
+//
+// networkInterfaces: The interfaces.
+// string: The IDs, comma separated.
+func ids(networkInterfaces []types.NetworkInterface) string {
+	list := []string{}
+	for _, networkInterface := range networkInterfaces {
+		list = append(list, aws.ToString(networkInterface.NetworkInterfaceId))
+	}
+	return strings.Join(list, ",")
+}
+
+// lookupFake returns a fake with the web group and its interfaces eni-0 to eni-<n-1>, in use but for every third.
+//
+// n: The number of interfaces.
+// *enitest.Fake: The fake.
+func lookupFake(n int) *enitest.Fake {
+	web := group("sg-0a1b2c3d4e5f60718", "web", "")
+	fake := enitest.New()
+	fake.AddSecurityGroups(web, group("sg-0e1b2c3d4e5f60718", "db", ""))
+	networkInterfaces := attached(web, n)
+	for i := range networkInterfaces {
+		if i%3 == 2 {
+			networkInterfaces[i].Status = types.NetworkInterfaceStatusAvailable
+		}
+	}
+	fake.AddNetworkInterfaces(networkInterfaces...)
+	return fake
+}
+
+func TestLookup(t *testing.T) {
+	fake := lookupFake(6)
+	fake.SetPageSize(4)
+	stats := &enilookup.Stats{}
+	ctx := enilookup.WithStats(context.Background(), stats)
+
+	byName, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: "web", GroupName: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byId, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: "sg-0a1b2c3d4e5f60718", GroupId: "sg-0a1b2c3d4e5f60718"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "eni-0,eni-1,eni-2,eni-3,eni-4,eni-5"; ids(byName) != want || ids(byId) != want {
+		t.Errorf("by name %s, by ID %s, want %s", ids(byName), ids(byId), want)
+	}
+	if stats.Pages.Load() != 4 || stats.Duplicates.Load() != 0 {
+		t.Errorf("pages = %d, duplicates = %d, want 4 and 0", stats.Pages.Load(), stats.Duplicates.Load())
+	}
+
+	empty, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: "db", GroupName: "db"})
+	if err != nil || len(empty) != 0 {
+		t.Errorf("db: %v, %v; want no interface", ids(empty), err)
+	}
+}
+
+func TestLookupWithFilters(t *testing.T) {
+	fake := lookupFake(6)
+	ctx := enilookup.WithFilters(context.Background(), []types.Filter{{Name: aws.String("status"), Values: []string{"available"}}})
+	networkInterfaces, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: "web", GroupName: "web"})
+	if err != nil || ids(networkInterfaces) != "eni-2,eni-5" {
+		t.Errorf("got %s, %v; want the available interfaces", ids(networkInterfaces), err)
+	}
+}
+
+func TestLookupCollapsesDuplicates(t *testing.T) {
+	fake := lookupFake(6)
+	fake.SetPageSize(3)
+	fake.OnCall("DescribeNetworkInterfaces", func(call int) {
+		if call != 2 {
+			return
+		}
+		// eni-0 is reattached during the pagination and moves to the end of the listing
+		reattached := attached(group("sg-0a1b2c3d4e5f60718", "web", ""), 1)[0]
+		reattached.Description = aws.String("reattached")
+		fake.RemoveNetworkInterfaces("eni-0")
+		fake.AddNetworkInterfaces(reattached)
+	})
+	stats := &enilookup.Stats{}
+
+	networkInterfaces, err := enilookup.Lookup(enilookup.WithStats(context.Background(), stats), fake, enilookup.Selector{Input: "web", GroupName: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// eni-3 shifted onto the first page is missed, eni-0 keeps its position with its last version
+	if ids(networkInterfaces) != "eni-0,eni-1,eni-2,eni-4,eni-5" || aws.ToString(networkInterfaces[0].Description) != "reattached" {
+		t.Errorf("got %s, first %v", ids(networkInterfaces), aws.ToString(networkInterfaces[0].Description))
+	}
+	if stats.Duplicates.Load() != 1 {
+		t.Errorf("duplicates = %d, want 1", stats.Duplicates.Load())
+	}
+}
+
+// TestLookupInterfacesWithoutId checks that interfaces returned without an ID are not collapsed into one.
+func TestLookupInterfacesWithoutId(t *testing.T) {
+	web := group("sg-0a1b2c3d4e5f60718", "web", "")
+	networkInterfaces := attached(web, 3)
+	networkInterfaces[0].NetworkInterfaceId = nil
+	networkInterfaces[2].NetworkInterfaceId = nil
+	fake := enitest.New()
+	fake.AddSecurityGroups(web)
+	fake.AddNetworkInterfaces(networkInterfaces...)
+	stats := &enilookup.Stats{}
+
+	found, err := enilookup.Lookup(enilookup.WithStats(context.Background(), stats), fake, enilookup.Selector{Input: "web", GroupName: "web"})
+	if err != nil || ids(found) != ",eni-1," {
+		t.Errorf("got %q, %v; want the 3 interfaces", ids(found), err)
+	}
+	if stats.Duplicates.Load() != 0 {
+		t.Errorf("duplicates = %d, want 0", stats.Duplicates.Load())
+	}
+
+	counts := []int{}
+	err = enilookup.WaitUntil(context.Background(), enilookup.CountAtMost(3), enilookup.WaitOptions{
+		API:       fake,
+		Selectors: []enilookup.Selector{{Input: "web", GroupName: "web"}},
+		OnPoll:    func(poll int, count int) { counts = append(counts, count) },
+	})
+	if err != nil || fmt.Sprint(counts) != "[3]" {
+		t.Errorf("counts = %v, err = %v; want [3]", counts, err)
+	}
+}
+
+func TestStreamCallbackError(t *testing.T) {
+	fake := lookupFake(6)
+	fake.SetPageSize(2)
+	stop := errors.New("stop")
+	seen := 0
+	err := enilookup.Stream(context.Background(), fake, enilookup.Selector{Input: "web", GroupName: "web"}, func(types.NetworkInterface) error {
+		seen++
+		if seen == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || seen != 3 || fake.Calls("DescribeNetworkInterfaces") != 2 {
+		t.Errorf("err = %v after %d interfaces and %d calls, want the callback error after 3 and 2", err, seen, fake.Calls("DescribeNetworkInterfaces"))
+	}
+}
+
+func TestStreamFallback(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "denied by a permission boundary"}
+
+	t.Run("group-id fallback", func(t *testing.T) {
+		fake := lookupFake(3)
+		fake.FailFilter("DescribeNetworkInterfaces", "group-name", denied)
+		notices := []string{}
+		ctx := enilookup.WithNotify(context.Background(), func(notice string) { notices = append(notices, notice) })
+
+		networkInterfaces, err := enilookup.Lookup(ctx, fake, enilookup.Selector{Input: "web", GroupName: "web"})
+		if err != nil || ids(networkInterfaces) != "eni-0,eni-1,eni-2" {
+			t.Fatalf("got %s, %v", ids(networkInterfaces), err)
+		}
+		if len(notices) != 1 || !strings.Contains(notices[0], "sg-0a1b2c3d4e5f60718") {
+			t.Errorf("notices = %q", notices)
+		}
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		fake := lookupFake(3)
+		fake.FailFilter("DescribeNetworkInterfaces", "group-name", denied)
+		_, err := enilookup.Lookup(context.Background(), fake, enilookup.Selector{Input: "cache", GroupName: "cache"})
+		if !errors.Is(err, enilookup.ErrGroupNotFound) {
+			t.Errorf("err = %v, want ErrGroupNotFound", err)
+		}
+	})
+
+	t.Run("denied fallback", func(t *testing.T) {
+		fake := lookupFake(3)
+		fake.FailFilter("DescribeNetworkInterfaces", "group-name", denied)
+		fake.FailWith("DescribeSecurityGroups", &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "no DescribeSecurityGroups"})
+		_, err := enilookup.Lookup(context.Background(), fake, enilookup.Selector{Input: "web", GroupName: "web"})
+		var fallbackErr *enilookup.FallbackError
+		if !errors.As(err, &fallbackErr) || fallbackErr.GroupName != "web" {
+			t.Fatalf("err = %v, want a *FallbackError", err)
+		}
+		// Both errors are kept and classified
+		if !errors.Is(fallbackErr.NameFilterErr, enilookup.ErrAccessDenied) || !strings.Contains(fallbackErr.FallbackErr.Error(), "no DescribeSecurityGroups") {
+			t.Errorf("errors = %v; %v", fallbackErr.NameFilterErr, fallbackErr.FallbackErr)
+		}
+	})
+
+	t.Run("no fallback by ID", func(t *testing.T) {
+		fake := lookupFake(3)
+		fake.FailFilter("DescribeNetworkInterfaces", "group-id", denied)
+		_, err := enilookup.Lookup(context.Background(), fake, enilookup.Selector{Input: "sg-0a1b2c3d4e5f60718", GroupId: "sg-0a1b2c3d4e5f60718"})
+		if !errors.Is(err, enilookup.ErrAccessDenied) || fake.Calls("DescribeSecurityGroups") != 0 {
+			t.Errorf("err = %v, %d DescribeSecurityGroups calls; want ErrAccessDenied without fallback", err, fake.Calls("DescribeSecurityGroups"))
+		}
+	})
+}
+
+func TestCountPerGroup(t *testing.T) {
+	fake := enitest.New()
+	selectors := []enilookup.Selector{}
+	// More groups than the values of a filter, across both filters
+	for i := 0; i < 250; i++ {
+		securityGroup := group(fmt.Sprintf("sg-%017x", i), fmt.Sprintf("group-%d", i), "")
+		fake.AddSecurityGroups(securityGroup)
+		for j, networkInterface := range attached(securityGroup, i%3) {
+			networkInterface.NetworkInterfaceId = aws.String(fmt.Sprintf("eni-%d-%d", i, j))
+			fake.AddNetworkInterfaces(networkInterface)
+		}
+		if i%2 == 0 {
+			selectors = append(selectors, enilookup.Selector{Input: aws.ToString(securityGroup.GroupId), GroupId: aws.ToString(securityGroup.GroupId)})
+		} else {
+			selectors = append(selectors, enilookup.Selector{Input: aws.ToString(securityGroup.GroupName), GroupName: aws.ToString(securityGroup.GroupName)})
+		}
+	}
+
+	counts, err := enilookup.CountPerGroup(context.Background(), fake, selectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 250 {
+		t.Fatalf("got %d counts, want 250", len(counts))
+	}
+	for i, selector := range selectors {
+		if counts[selector.Key()] != i%3 {
+			t.Errorf("%s: %d interfaces, want %d", selector.Key(), counts[selector.Key()], i%3)
+		}
+	}
+	// 125 IDs in a chunk and 125 names in another
+	if calls := fake.Calls("DescribeNetworkInterfaces"); calls != 2 {
+		t.Errorf("DescribeNetworkInterfaces calls = %d, want 2", calls)
+	}
+}
+
+// pagedAPI answers DescribeNetworkInterfaces with canned pages, the NextToken being the index of the next page.
+type pagedAPI struct {
+	enilookup.API
+	pages [][]types.NetworkInterface
+}
+
+// DescribeNetworkInterfaces returns the page of the token.
+func (p *pagedAPI) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	page := 0
+	if params.NextToken != nil {
+		page, _ = strconv.Atoi(*params.NextToken)
+	}
+	output := &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: p.pages[page]}
+	if page+1 < len(p.pages) {
+		output.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
+
+func TestLookupOverlappingPages(t *testing.T) {
+	networkInterface := func(id string, status types.NetworkInterfaceStatus) types.NetworkInterface {
+		return types.NetworkInterface{NetworkInterfaceId: aws.String(id), Status: status}
+	}
+	// eni-1 is returned on the first page, then again on the third after its reattachment
+	api := &pagedAPI{pages: [][]types.NetworkInterface{
+		{networkInterface("eni-1", types.NetworkInterfaceStatusAvailable), networkInterface("eni-2", types.NetworkInterfaceStatusInUse)},
+		{networkInterface("eni-3", types.NetworkInterfaceStatusInUse)},
+		{networkInterface("eni-4", types.NetworkInterfaceStatusInUse), networkInterface("eni-1", types.NetworkInterfaceStatusInUse)},
+	}}
+	stats := &enilookup.Stats{}
+
+	networkInterfaces, err := enilookup.Lookup(enilookup.WithStats(context.Background(), stats), api, enilookup.Selector{Input: "web", GroupName: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(networkInterfaces) != "eni-1,eni-2,eni-3,eni-4" {
+		t.Errorf("got %s, want every interface exactly once", ids(networkInterfaces))
+	}
+	if networkInterfaces[0].Status != types.NetworkInterfaceStatusInUse {
+		t.Errorf("eni-1 is %s, want the last seen version", networkInterfaces[0].Status)
+	}
+	if stats.Pages.Load() != 3 || stats.Duplicates.Load() != 1 {
+		t.Errorf("pages = %d, duplicates = %d, want 3 and 1", stats.Pages.Load(), stats.Duplicates.Load())
+	}
+
+	// Stream itself reports every occurrence
+	seen := 0
+	if err := enilookup.Stream(context.Background(), api, enilookup.Selector{Input: "web", GroupName: "web"}, func(types.NetworkInterface) error {
+		seen++
+		return nil
+	}); err != nil || seen != 5 {
+		t.Errorf("Stream saw %d interfaces, %v; want 5", seen, err)
+	}
+}
@@ -0,0 +1,289 @@
+package enilookup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+var (
+	// groupIdPattern matches security group IDs.
+	groupIdPattern = regexp.MustCompile(`^sg-[0-9a-f]+$`)
+
+	// truncatedGroupIdPattern matches group IDs that may have been truncated to 8 hex characters.
+	truncatedGroupIdPattern = regexp.MustCompile(`^sg-[0-9a-f]{8}$`)
+)
+
+// Selector identifies a requested security group, either by name or by ID.
+type Selector struct {
+	// Input is the value as given by the user.
+	Input string
+	// GroupId is set when the group is selected by ID.
+	GroupId string
+	// GroupName is set when the group is selected by name.
+	GroupName string
+	// MatchedOn is the attribute the input name matched when it was resolved to a group ID with MatchNameTag or
+	// MatchBoth: MatchedOnGroupName or MatchedOnNameTag.
+	MatchedOn string
+	// Ordinal is the position of the selector in the order returned by ResolveWith, which outputs sort sections by.
+	Ordinal int
+}
+
+// Key returns a string identifying the selector among the selectors returned by a single Resolve call.
+//
+// It is the input, followed by the group ID when the input name was resolved to several groups.
+func (s Selector) Key() string {
+	if s.MatchedOn != "" {
+		return s.Input + " (" + s.GroupId + ")"
+	}
+	return s.Input
+}
+
+// MatchOn controls which attribute of the security groups the input names are matched against.
+type MatchOn string
+
+// The attributes input names can be matched against.
+const (
+	// MatchGroupName matches the GroupName, with the group-name filter of DescribeNetworkInterfaces.
+	MatchGroupName MatchOn = "groupname"
+	// MatchNameTag matches the Name tag, resolving every input name to the IDs of the groups carrying it.
+	MatchNameTag MatchOn = "nametag"
+	// MatchBoth matches either, resolving every input name to the IDs of the groups with that GroupName or Name tag.
+	MatchBoth MatchOn = "both"
+)
+
+// The values of Selector.MatchedOn.
+const (
+	MatchedOnGroupName = "GroupName"
+	MatchedOnNameTag   = "Name tag"
+)
+
+// ParseMatchOn parses the value of a -match-on flag.
+//
+// value: groupname, nametag or both.
+// MatchOn: The parsed value.
+// error: If the value is not supported.
+func ParseMatchOn(value string) (MatchOn, error) {
+	switch MatchOn(value) {
+	case MatchGroupName, MatchNameTag, MatchBoth:
+		return MatchOn(value), nil
+	}
+	return "", fmt.Errorf("supported values are: groupname, nametag, both")
+}
+
+// ResolveOptions controls how Resolve turns inputs into selectors.
+type ResolveOptions struct {
+	// MatchOn is the attribute the input names are matched against, MatchGroupName when empty.
+	MatchOn MatchOn
+	// Notify is called with a human readable notice when an input was resolved by prefix. May be nil.
+	Notify func(string)
+}
+
+// Filter returns the DescribeNetworkInterfaces filter matching the interfaces of the selected group.
+func (s Selector) Filter() types.Filter {
+	if s.GroupId != "" {
+		return types.Filter{Name: aws.String("group-id"), Values: []string{s.GroupId}}
+	}
+	return types.Filter{Name: aws.String("group-name"), Values: []string{s.GroupName}}
+}
+
+// AmbiguousGroupIdError is returned when a truncated group ID matches several security groups.
+type AmbiguousGroupIdError struct {
+	Prefix  string
+	Matches []types.SecurityGroup
+}
+
+// Error returns the prefix and every matching group.
+func (e *AmbiguousGroupIdError) Error() string {
+	matches := []string{}
+	for _, group := range e.Matches {
+		matches = append(matches, fmt.Sprintf("%s (%s)", aws.ToString(group.GroupId), aws.ToString(group.GroupName)))
+	}
+	return fmt.Sprintf("group ID prefix %s is ambiguous, it matches: %s", e.Prefix, strings.Join(matches, ", "))
+}
+
+// Resolve turns the inputs into selectors.
+//
+// Inputs that look like security group IDs select by ID, everything else selects by name.
+// An 8-hex-digit ID that does not exist is resolved as a prefix of the full ID: the security groups are enumerated
+// and matched on ID prefix. A single match is used instead of the input and reported through notify, several
+// matches return an *AmbiguousGroupIdError.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// inputs: The security group names and IDs.
+// notify: Called with a human readable notice when an input was resolved by prefix. May be nil.
+// []Selector: The selectors, in the order of the inputs.
+// error: A typed error if an API call fails, see Stream, a *GroupNotFoundError if a truncated ID matches no group,
+// or an *AmbiguousGroupIdError if it matches several.
+func Resolve(ctx context.Context, api API, inputs []string, notify func(string)) ([]Selector, error) {
+	return ResolveWith(ctx, api, inputs, ResolveOptions{Notify: notify})
+}
+
+// ResolveWith turns the inputs into selectors, like Resolve, with the given options.
+//
+// With MatchNameTag or MatchBoth, every input name is resolved with DescribeSecurityGroups to the IDs of the matching
+// groups, de-duplicated by group ID, and yields one selector per group with MatchedOn set. A name matching no group
+// returns a *GroupNotFoundError.
+//
+// The selectors are in a deterministic order: that of the inputs, with the groups an input name expands to sorted
+// by group ID. Every selector is numbered with its position in that order in Ordinal.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// inputs: The security group names and IDs.
+// opts: The options.
+// []Selector: The selectors, in the order of the inputs.
+// error: See Resolve.
+func ResolveWith(ctx context.Context, api API, inputs []string, opts ResolveOptions) ([]Selector, error) {
+	notify := opts.Notify
+	selectors := []Selector{}
+	var allGroups []types.SecurityGroup
+
+	for _, input := range inputs {
+		if !groupIdPattern.MatchString(input) {
+			if opts.MatchOn == "" || opts.MatchOn == MatchGroupName {
+				selectors = append(selectors, Selector{Input: input, GroupName: input})
+				continue
+			}
+			matched, err := resolveName(ctx, api, input, opts.MatchOn)
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, matched...)
+			continue
+		}
+		if !truncatedGroupIdPattern.MatchString(input) {
+			selectors = append(selectors, Selector{Input: input, GroupId: input})
+			continue
+		}
+
+		// Check whether the short ID exists as is
+		exact, err := describeSecurityGroups(ctx, api, &ec2.DescribeSecurityGroupsInput{
+			Filters: []types.Filter{{Name: aws.String("group-id"), Values: []string{input}}},
+		})
+		if err != nil {
+			return nil, classify(err, input)
+		}
+		if len(exact) > 0 {
+			selectors = append(selectors, Selector{Input: input, GroupId: input})
+			continue
+		}
+
+		// Fall back to matching on the ID prefix
+		if allGroups == nil {
+			allGroups, err = describeSecurityGroups(ctx, api, &ec2.DescribeSecurityGroupsInput{})
+			if err != nil {
+				return nil, classify(err, input)
+			}
+		}
+		matches := matchGroupIdPrefix(allGroups, input)
+		switch len(matches) {
+		case 0:
+			return nil, &GroupNotFoundError{Group: input}
+		case 1:
+			groupId := aws.ToString(matches[0].GroupId)
+			if notify != nil {
+				notify(fmt.Sprintf("%s matched %s (%s) by ID prefix", input, groupId, aws.ToString(matches[0].GroupName)))
+			}
+			selectors = append(selectors, Selector{Input: input, GroupId: groupId})
+		default:
+			return nil, &AmbiguousGroupIdError{Prefix: input, Matches: matches}
+		}
+	}
+
+	for i := range selectors {
+		selectors[i].Ordinal = i
+	}
+	return selectors, nil
+}
+
+// resolveName resolves an input name to the selectors of the groups whose Name tag, or also GroupName, matches it.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// input: The security group name.
+// matchOn: MatchNameTag or MatchBoth.
+// []Selector: A selector per matching group, sorted by group ID.
+// error: A typed error if an API call fails, or a *GroupNotFoundError if no group matches.
+func resolveName(ctx context.Context, api API, input string, matchOn MatchOn) ([]Selector, error) {
+	filters := []types.Filter{{Name: aws.String("tag:Name"), Values: []string{input}}}
+	if matchOn == MatchBoth {
+		filters = append([]types.Filter{{Name: aws.String("group-name"), Values: []string{input}}}, filters...)
+	}
+
+	matchedOn := map[string]string{}
+	for _, filter := range filters {
+		// Filters are ANDed, so every attribute is queried on its own
+		groups, err := describeSecurityGroups(ctx, api, &ec2.DescribeSecurityGroupsInput{Filters: []types.Filter{filter}})
+		if err != nil {
+			return nil, classify(err, input)
+		}
+		for _, group := range groups {
+			groupId := aws.ToString(group.GroupId)
+			if _, ok := matchedOn[groupId]; ok {
+				continue
+			}
+			matchedOn[groupId] = MatchedOnNameTag
+			if aws.ToString(filter.Name) == "group-name" {
+				matchedOn[groupId] = MatchedOnGroupName
+			}
+		}
+	}
+	if len(matchedOn) == 0 {
+		return nil, &GroupNotFoundError{Group: input}
+	}
+
+	groupIds := []string{}
+	for groupId := range matchedOn {
+		groupIds = append(groupIds, groupId)
+	}
+	sort.Strings(groupIds)
+	selectors := []Selector{}
+	for _, groupId := range groupIds {
+		selectors = append(selectors, Selector{Input: input, GroupId: groupId, MatchedOn: matchedOn[groupId]})
+	}
+	return selectors, nil
+}
+
+// matchGroupIdPrefix returns the groups whose ID starts with the given prefix.
+//
+// groups: The groups to search.
+// prefix: The group ID prefix.
+// []types.SecurityGroup: The matching groups.
+func matchGroupIdPrefix(groups []types.SecurityGroup, prefix string) []types.SecurityGroup {
+	matches := []types.SecurityGroup{}
+	for _, group := range groups {
+		if strings.HasPrefix(aws.ToString(group.GroupId), prefix) {
+			matches = append(matches, group)
+		}
+	}
+	return matches
+}
+
+// describeSecurityGroups retrieves every security group matching the input, following the pages of the response.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// input: The DescribeSecurityGroups input.
+// []types.SecurityGroup: The security groups.
+// error: If an API call fails.
+func describeSecurityGroups(ctx context.Context, api API, input *ec2.DescribeSecurityGroupsInput) ([]types.SecurityGroup, error) {
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(api, input)
+
+	securityGroups := []types.SecurityGroup{}
+	for paginator.HasMorePages() {
+		describeSecurityGroupsOutput, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		securityGroups = append(securityGroups, describeSecurityGroupsOutput.SecurityGroups...)
+	}
+	return securityGroups, nil
+}
@@ -0,0 +1,145 @@
+package enilookup_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// group returns a security group fixture.
+//
+// groupId: The group ID.
+// groupName: The group name.
+// nameTag: The Name tag, empty for none.
+// types.SecurityGroup: The security group.
+func group(groupId string, groupName string, nameTag string) types.SecurityGroup {
+	securityGroup := types.SecurityGroup{GroupId: aws.String(groupId), GroupName: aws.String(groupName), VpcId: aws.String("vpc-1")}
+	if nameTag != "" {
+		securityGroup.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(nameTag)}}
+	}
+	return securityGroup
+}
+
+func TestResolvePrefix(t *testing.T) {
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		group("sg-0a1b2c3d4e5f60718", "web", ""),
+		group("sg-0b1b2c3d00000000a", "db", ""),
+		group("sg-0b1b2c3d11111111b", "cache", ""),
+		group("sg-0c1b2c3d", "short", ""),
+	)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		notice  bool
+		wantErr func(error) bool
+	}{
+		{name: "exact short ID", input: "sg-0c1b2c3d", want: "sg-0c1b2c3d"},
+		{name: "one match", input: "sg-0a1b2c3d", want: "sg-0a1b2c3d4e5f60718", notice: true},
+		{name: "no match", input: "sg-0d1b2c3d", wantErr: func(err error) bool {
+			var notFound *enilookup.GroupNotFoundError
+			return errors.As(err, &notFound) && notFound.Group == "sg-0d1b2c3d"
+		}},
+		{name: "many matches", input: "sg-0b1b2c3d", wantErr: func(err error) bool {
+			var ambiguous *enilookup.AmbiguousGroupIdError
+			return errors.As(err, &ambiguous) && len(ambiguous.Matches) == 2 &&
+				strings.Contains(err.Error(), "sg-0b1b2c3d00000000a (db)") && strings.Contains(err.Error(), "sg-0b1b2c3d11111111b (cache)")
+		}},
+		{name: "full ID is not resolved", input: "sg-0ffffffffffffffff", want: "sg-0ffffffffffffffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notices := []string{}
+			selectors, err := enilookup.Resolve(context.Background(), fake, []string{tt.input}, func(notice string) { notices = append(notices, notice) })
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(selectors) != 1 || selectors[0].GroupId != tt.want || selectors[0].Input != tt.input {
+				t.Fatalf("selectors = %+v, want group %s", selectors, tt.want)
+			}
+			if tt.notice != (len(notices) == 1) {
+				t.Errorf("notices = %q", notices)
+			}
+		})
+	}
+}
+
+func TestResolvePrefixEnumeratesOnce(t *testing.T) {
+	fake := enitest.New()
+	fake.AddSecurityGroups(group("sg-0a1b2c3d4e5f60718", "web", ""), group("sg-0e1b2c3d4e5f60718", "db", ""))
+
+	selectors, err := enilookup.Resolve(context.Background(), fake, []string{"sg-0a1b2c3d", "web", "sg-0e1b2c3d"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, selector := range selectors {
+		got = append(got, selector.GroupId+selector.GroupName)
+	}
+	if strings.Join(got, ",") != "sg-0a1b2c3d4e5f60718,web,sg-0e1b2c3d4e5f60718" {
+		t.Errorf("selectors = %v", got)
+	}
+	// Two exact lookups and a single enumeration
+	if calls := fake.Calls("DescribeSecurityGroups"); calls != 3 {
+		t.Errorf("DescribeSecurityGroups calls = %d, want 3", calls)
+	}
+}
+
+func TestResolveWithMatchOn(t *testing.T) {
+	fake := enitest.New()
+	fake.AddSecurityGroups(
+		group("sg-0000000000000000b", "web", ""),
+		group("sg-0000000000000000a", "launch-wizard-1", "web"),
+		group("sg-0000000000000000c", "db", "database"),
+	)
+
+	tests := []struct {
+		matchOn enilookup.MatchOn
+		input   string
+		want    []string
+	}{
+		{enilookup.MatchGroupName, "web", []string{""}},
+		{enilookup.MatchNameTag, "web", []string{"sg-0000000000000000a Name tag"}},
+		{enilookup.MatchBoth, "web", []string{"sg-0000000000000000a Name tag", "sg-0000000000000000b GroupName"}},
+		{enilookup.MatchBoth, "database", []string{"sg-0000000000000000c Name tag"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.matchOn)+"/"+tt.input, func(t *testing.T) {
+			selectors, err := enilookup.ResolveWith(context.Background(), fake, []string{tt.input}, enilookup.ResolveOptions{MatchOn: tt.matchOn})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for i, selector := range selectors {
+				if selector.Ordinal != i {
+					t.Errorf("selector %d has ordinal %d", i, selector.Ordinal)
+				}
+				got = append(got, strings.TrimSpace(selector.GroupId+" "+selector.MatchedOn))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("selectors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := enilookup.ResolveWith(context.Background(), fake, []string{"missing"}, enilookup.ResolveOptions{MatchOn: enilookup.MatchBoth})
+	var notFound *enilookup.GroupNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("err = %v, want a *GroupNotFoundError", err)
+	}
+}
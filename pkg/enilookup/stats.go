@@ -0,0 +1,35 @@
+package enilookup
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// statsKey is the context key holding the counters set by WithStats.
+type statsKey struct{}
+
+// Stats counts what the lookups of a context did. It is safe for concurrent use.
+type Stats struct {
+	// Pages is the number of DescribeNetworkInterfaces pages fetched.
+	Pages atomic.Int64
+	// Duplicates is the number of network interfaces returned on several pages and collapsed by Lookup.
+	Duplicates atomic.Int64
+}
+
+// WithStats returns a context whose lookups add their counts to stats.
+//
+// ctx: The parent context.
+// stats: The counters to add to.
+// context.Context: The context carrying stats.
+func WithStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// statsOf returns the counters of the context, or nil.
+//
+// ctx: The context carrying the counters.
+// *Stats: The counters.
+func statsOf(ctx context.Context) *Stats {
+	stats, _ := ctx.Value(statsKey{}).(*Stats)
+	return stats
+}
@@ -0,0 +1,136 @@
+package enilookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// ErrWaitTimeout is returned by WaitUntil when the condition did not hold before the timeout expired.
+var ErrWaitTimeout = errors.New("timed out waiting for condition")
+
+// Condition reports whether the number of attached network interfaces satisfies a wait.
+type Condition struct {
+	// Description is the human readable form of the condition, as accepted by ParseCondition.
+	Description string
+	// Holds is called with the current number of network interfaces.
+	Holds func(count int) bool
+}
+
+// Empty returns the condition that holds when no network interface is attached.
+func Empty() Condition {
+	return Condition{Description: "empty", Holds: func(count int) bool { return count == 0 }}
+}
+
+// CountAtMost returns the condition that holds when at most n network interfaces are attached.
+func CountAtMost(n int) Condition {
+	return Condition{Description: fmt.Sprintf("count<=%d", n), Holds: func(count int) bool { return count <= n }}
+}
+
+// ParseCondition parses a condition of the form "empty" or "count<=N".
+//
+// value: The condition to parse.
+// Condition: The parsed condition.
+// error: If the value is not a supported condition.
+func ParseCondition(value string) (Condition, error) {
+	if value == "empty" {
+		return Empty(), nil
+	}
+	if limit, ok := strings.CutPrefix(value, "count<="); ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return Condition{}, fmt.Errorf("invalid count in condition %q", value)
+		}
+		return CountAtMost(n), nil
+	}
+	return Condition{}, fmt.Errorf("invalid condition %q: supported conditions are empty and count<=N", value)
+}
+
+// WaitOptions configures WaitUntil.
+type WaitOptions struct {
+	// API is the EC2 API used for the lookups.
+	API API
+	// Selectors are the security groups whose interfaces are counted. An interface attached to several of them counts once.
+	Selectors []Selector
+	// Timeout is the maximum duration of the wait. Zero waits until the context is done.
+	Timeout time.Duration
+	// Interval is the duration between two polls.
+	Interval time.Duration
+	// ExcludeShared leaves shared service ENIs, see IsShared, out of the count.
+	ExcludeShared bool
+	// OnPoll is called after every poll with its number, starting at 1, and the count. May be nil.
+	OnPoll func(poll int, count int)
+}
+
+// WaitUntil repeatedly counts the network interfaces attached to the selected groups until the condition holds.
+//
+// ctx: The context used for the API calls.
+// cond: The condition to wait for.
+// opts: The wait options.
+// error: ErrWaitTimeout when the timeout expired, or the error of a failed lookup.
+func WaitUntil(ctx context.Context, cond Condition, opts WaitOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	for poll := 1; ; poll++ {
+		count, err := countNetworkInterfaces(ctx, opts.API, opts.Selectors, opts.ExcludeShared)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrWaitTimeout
+			}
+			return err
+		}
+		if opts.OnPoll != nil {
+			opts.OnPoll(poll, count)
+		}
+		if cond.Holds(count) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrWaitTimeout
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// countNetworkInterfaces counts the unique network interfaces attached to the selected groups.
+//
+// Interfaces returned without an ID cannot be told apart and are each counted.
+//
+// ctx: The context used for the API calls.
+// api: The EC2 API.
+// selectors: The security groups.
+// excludeShared: Whether shared service ENIs are left out of the count.
+// int: The number of unique network interfaces.
+// error: If a lookup fails.
+func countNetworkInterfaces(ctx context.Context, api API, selectors []Selector, excludeShared bool) (int, error) {
+	seen := map[string]bool{}
+	unidentified := 0
+	for _, selector := range selectors {
+		err := Stream(ctx, api, selector, func(networkInterface types.NetworkInterface) error {
+			switch {
+			case excludeShared && IsShared(networkInterface):
+			case networkInterface.NetworkInterfaceId == nil:
+				unidentified++
+			default:
+				seen[aws.ToString(networkInterface.NetworkInterfaceId)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(seen) + unidentified, nil
+}
@@ -0,0 +1,144 @@
+package enilookup_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+// attached returns network interface fixtures carrying a security group.
+//
+// securityGroup: The group.
+// n: The number of interfaces.
+// []types.NetworkInterface: The interfaces, eni-0 to eni-<n-1>.
+func attached(securityGroup types.SecurityGroup, n int) []types.NetworkInterface {
+	networkInterfaces := []types.NetworkInterface{}
+	for i := 0; i < n; i++ {
+		networkInterfaces = append(networkInterfaces, types.NetworkInterface{
+			NetworkInterfaceId: aws.String(fmt.Sprintf("eni-%d", i)),
+			Status:             types.NetworkInterfaceStatusInUse,
+			Groups:             []types.GroupIdentifier{{GroupId: securityGroup.GroupId, GroupName: securityGroup.GroupName}},
+		})
+	}
+	return networkInterfaces
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "empty", want: "empty"},
+		{value: "count<=3", want: "count<=3"},
+		{value: "count<=0", want: "count<=0"},
+		{value: "count<=-1", wantErr: true},
+		{value: "count<=x", wantErr: true},
+		{value: "count<3", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		cond, err := enilookup.ParseCondition(tt.value)
+		if (err != nil) != tt.wantErr || cond.Description != tt.want {
+			t.Errorf("ParseCondition(%q) = %q, %v", tt.value, cond.Description, err)
+		}
+	}
+}
+
+func TestWaitUntilShrinking(t *testing.T) {
+	web := group("sg-0a1b2c3d4e5f60718", "web", "")
+	tests := []struct {
+		cond  enilookup.Condition
+		polls []int
+	}{
+		{enilookup.Empty(), []int{3, 2, 1, 0}},
+		{enilookup.CountAtMost(1), []int{3, 2, 1}},
+		{enilookup.CountAtMost(3), []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond.Description, func(t *testing.T) {
+			fake := enitest.New()
+			fake.AddSecurityGroups(web)
+			fake.AddNetworkInterfaces(attached(web, 3)...)
+			polls := []int{}
+			err := enilookup.WaitUntil(context.Background(), tt.cond, enilookup.WaitOptions{
+				API:       fake,
+				Selectors: []enilookup.Selector{{Input: "web", GroupName: "web"}},
+				Interval:  time.Millisecond,
+				OnPoll: func(poll int, count int) {
+					if poll != len(polls)+1 {
+						t.Errorf("poll %d after %d polls", poll, len(polls))
+					}
+					polls = append(polls, count)
+					// An interface is detached between two polls
+					fake.RemoveNetworkInterfaces(fmt.Sprintf("eni-%d", count-1))
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(polls) != fmt.Sprint(tt.polls) {
+				t.Errorf("polls = %v, want %v", polls, tt.polls)
+			}
+		})
+	}
+}
+
+func TestWaitUntilTimeout(t *testing.T) {
+	web := group("sg-0a1b2c3d4e5f60718", "web", "")
+	fake := enitest.New()
+	fake.AddSecurityGroups(web)
+	fake.AddNetworkInterfaces(attached(web, 2)...)
+
+	err := enilookup.WaitUntil(context.Background(), enilookup.Empty(), enilookup.WaitOptions{
+		API:       fake,
+		Selectors: []enilookup.Selector{{Input: "web", GroupName: "web"}},
+		Timeout:   20 * time.Millisecond,
+		Interval:  5 * time.Millisecond,
+	})
+	if !errors.Is(err, enilookup.ErrWaitTimeout) {
+		t.Errorf("err = %v, want ErrWaitTimeout", err)
+	}
+}
+
+func TestWaitUntilCountsSharedInterfacesOnce(t *testing.T) {
+	web := group("sg-0a1b2c3d4e5f60718", "web", "")
+	db := group("sg-0e1b2c3d4e5f60718", "db", "")
+	fake := enitest.New()
+	fake.AddSecurityGroups(web, db)
+	networkInterfaces := attached(web, 2)
+	networkInterfaces[1].Groups = append(networkInterfaces[1].Groups, types.GroupIdentifier{GroupId: db.GroupId, GroupName: db.GroupName})
+	fake.AddNetworkInterfaces(networkInterfaces...)
+
+	counts := []int{}
+	err := enilookup.WaitUntil(context.Background(), enilookup.CountAtMost(2), enilookup.WaitOptions{
+		API:       fake,
+		Selectors: []enilookup.Selector{{Input: "web", GroupName: "web"}, {Input: "db", GroupName: "db"}},
+		OnPoll:    func(poll int, count int) { counts = append(counts, count) },
+	})
+	if err != nil || fmt.Sprint(counts) != "[2]" {
+		t.Errorf("counts = %v, err = %v; want [2]", counts, err)
+	}
+}
+
+func TestWaitUntilLookupError(t *testing.T) {
+	fake := enitest.New()
+	denied := errors.New("boom")
+	fake.FailWith("DescribeNetworkInterfaces", denied)
+
+	err := enilookup.WaitUntil(context.Background(), enilookup.Empty(), enilookup.WaitOptions{
+		API:       fake,
+		Selectors: []enilookup.Selector{{Input: "web", GroupName: "web"}},
+	})
+	if err == nil || errors.Is(err, enilookup.ErrWaitTimeout) {
+		t.Errorf("err = %v, want the lookup error", err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Progress event names. They are part of the -progress-format json schema and must not change.
+const (
+	progressGroupStarted   = "group_started"
+	progressGroupCompleted = "group_completed"
+	progressPageFetched    = "page_fetched"
+	progressThrottled      = "throttled"
+	progressRunCompleted   = "run_completed"
+)
+
+// progressEvent is a single line of the -progress-format json stream.
+type progressEvent struct {
+	Seq           int64  `json:"seq"`
+	Event         string `json:"event"`
+	Time          string `json:"time"`
+	CorrelationId string `json:"correlation_id"`
+	Region        string `json:"region,omitempty"`
+	Group         string `json:"group,omitempty"`
+	Operation     string `json:"operation,omitempty"`
+	Interfaces    *int   `json:"interfaces,omitempty"`
+	Groups        *int   `json:"groups,omitempty"`
+}
+
+// progressGroupKey is the context key holding the security group a call is made for.
+type progressGroupKey struct{}
+
+// progressReporter reports the progress of a run on stderr, either as a TTY spinner or as JSON events.
+type progressReporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+	seq    int64
+	// correlationId is stamped on every event.
+	correlationId string
+	// animate is set when the tty format runs on a terminal, the spinner is started by the first group of a run.
+	animate bool
+	spinner *spinner
+}
+
+// newProgressReporter creates a progress reporter for the given format.
+//
+// The tty format only shows a spinner when stderr is a terminal, the json format always emits events
+// and the none format reports nothing.
+//
+// format: The progress format: tty, json or none.
+// correlationId: The correlation ID of the run, stamped on every event.
+// *progressReporter: The progress reporter.
+// error: If the format is not supported.
+func newProgressReporter(format string, correlationId string) (*progressReporter, error) {
+	reporter := &progressReporter{w: os.Stderr, format: format, correlationId: correlationId}
+	switch format {
+	case "tty":
+		reporter.animate = isTerminal(os.Stderr)
+	case "json", "none":
+	default:
+		return nil, fmt.Errorf("supported values are: tty, json, none")
+	}
+	return reporter, nil
+}
+
+// groupStarted reports that the lookup of a security group started.
+//
+// ctx: The context of the lookup.
+// region: The region of the security group.
+// group: The security group, as given by the user.
+// context.Context: The context to use for the calls made for the security group.
+func (p *progressReporter) groupStarted(ctx context.Context, region string, group string) context.Context {
+	p.emit(progressEvent{Event: progressGroupStarted, Region: region, Group: group})
+	if p.animate && p.spinner == nil {
+		p.spinner = newSpinner(os.Stderr)
+	}
+	if p.spinner != nil {
+		p.spinner.setLabel(fmt.Sprintf("Looking up %s (%s)", group, region))
+	}
+	return context.WithValue(ctx, progressGroupKey{}, group)
+}
+
+// groupCompleted reports that the lookup of a security group completed.
+//
+// region: The region of the security group.
+// group: The security group, as given by the user.
+// interfaces: The number of network interfaces found.
+func (p *progressReporter) groupCompleted(region string, group string, interfaces int) {
+	p.emit(progressEvent{Event: progressGroupCompleted, Region: region, Group: group, Interfaces: &interfaces})
+}
+
+// runCompleted reports that the run completed and stops the spinner.
+//
+// groups: The number of security groups looked up.
+// interfaces: The total number of network interfaces found.
+func (p *progressReporter) runCompleted(groups int, interfaces int) {
+	if p.spinner != nil {
+		p.spinner.stop()
+		p.spinner = nil
+	}
+	p.emit(progressEvent{Event: progressRunCompleted, Groups: &groups, Interfaces: &interfaces})
+}
+
+// emit writes the event when the json format is active, assigning it the next sequence number.
+//
+// event: The event to write.
+func (p *progressReporter) emit(event progressEvent) {
+	if p.format != "json" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	event.Seq = p.seq
+	event.CorrelationId = p.correlationId
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(p.w, "%s\n", data)
+}
+
+// apiOptions returns the SDK middleware reporting fetched pages and throttled attempts.
+//
+// []func(*middleware.Stack) error: The API options to add to the AWS configuration.
+func (p *progressReporter) apiOptions() []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ProgressPageFetched", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if err == nil {
+					group, _ := ctx.Value(progressGroupKey{}).(string)
+					p.emit(progressEvent{Event: progressPageFetched, Region: awsmiddleware.GetRegion(ctx), Group: group, Operation: awsmiddleware.GetOperationName(ctx)})
+				}
+				return out, metadata, err
+			}), middleware.After)
+		},
+		func(stack *middleware.Stack) error {
+			// Inserted after the retry middleware so that every attempt is seen
+			return stack.Finalize.Insert(middleware.FinalizeMiddlewareFunc("ProgressThrottled", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleFinalize(ctx, in)
+				if err != nil && retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+					group, _ := ctx.Value(progressGroupKey{}).(string)
+					p.emit(progressEvent{Event: progressThrottled, Region: awsmiddleware.GetRegion(ctx), Group: group, Operation: awsmiddleware.GetOperationName(ctx)})
+				}
+				return out, metadata, err
+			}), "Retry", middleware.After)
+		},
+	}
+}
+
+// isTerminal reports whether the file is a terminal.
+//
+// f: The file to check.
+// bool: Whether the file is a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// spinner animates a single status line on a terminal.
+type spinner struct {
+	mu    sync.Mutex
+	w     io.Writer
+	label string
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newSpinner starts a spinner writing to w.
+//
+// w: The terminal to write to.
+// *spinner: The running spinner.
+func newSpinner(w io.Writer) *spinner {
+	s := &spinner{w: w, done: make(chan struct{})}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// setLabel changes the text shown next to the spinner.
+//
+// label: The new text.
+func (s *spinner) setLabel(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.label = label
+}
+
+// run redraws the spinner until it is stopped.
+func (s *spinner) run() {
+	defer s.wg.Done()
+	frames := []string{"|", "/", "-", "\\"}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		select {
+		case <-s.done:
+			fmt.Fprint(s.w, "\r\033[K")
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			fmt.Fprintf(s.w, "\r\033[K%s %s", frames[frame%len(frames)], s.label)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// stop stops the spinner and clears its line.
+func (s *spinner) stop() {
+	close(s.done)
+	s.wg.Wait()
+}
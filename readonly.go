@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// mutatingFlags are the flags that modify AWS resources. Every such flag must be listed here, so -read-only and
+// the readonly build reject it.
+var mutatingFlags = []string{"release-eips"}
+
+// readOnlyOperationPrefixes are the prefixes of the API operations allowed in read-only mode.
+var readOnlyOperationPrefixes = []string{"Describe", "Get", "List", "DecodeAuthorizationMessage"}
+
+// readOnlyError is returned for an API call blocked by the read-only mode.
+type readOnlyError struct {
+	// Service is the ID of the AWS service.
+	Service string
+	// Operation is the name of the blocked operation.
+	Operation string
+}
+
+// Error describes the blocked call.
+func (e *readOnlyError) Error() string {
+	// The SDK wraps the error with the service and operation already
+	return fmt.Sprintf("blocked in read-only mode, only %s operations are allowed", strings.Join(readOnlyOperationPrefixes, ", "))
+}
+
+// checkReadOnly rejects the mutating flags set on the command line in read-only mode.
+//
+// fs: The parsed flag set.
+// readOnly: Whether the read-only mode is on.
+// error: Naming the first mutating flag set, nil when there is none or the mode is off.
+func checkReadOnly(fs *flag.FlagSet, readOnly bool) error {
+	if !readOnly {
+		return nil
+	}
+	if set := setMutatingFlags(fs); len(set) > 0 {
+		return fmt.Errorf("-%s modifies resources and is rejected in read-only mode", set[0])
+	}
+	return nil
+}
+
+// setMutatingFlags returns the mutating flags set on the command line.
+//
+// fs: The parsed flag set.
+// []string: The names of the flags, in lexical order.
+func setMutatingFlags(fs *flag.FlagSet) []string {
+	set := []string{}
+	fs.Visit(func(f *flag.Flag) {
+		if slices.Contains(mutatingFlags, f.Name) {
+			set = append(set, f.Name)
+		}
+	})
+	return set
+}
+
+// readOnlyAPIOptions returns the SDK middleware failing every API call that is not a read, a second line of
+// defense behind checkReadOnly.
+//
+// []func(*middleware.Stack) error: The API options to add to the AWS configuration.
+func readOnlyAPIOptions() []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// Inserted right after the operation name is known, before the input is validated or anything is sent
+			return stack.Initialize.Insert(middleware.InitializeMiddlewareFunc("ReadOnlyGuard", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				operation := awsmiddleware.GetOperationName(ctx)
+				for _, prefix := range readOnlyOperationPrefixes {
+					if strings.HasPrefix(operation, prefix) {
+						return next.HandleInitialize(ctx, in)
+					}
+				}
+				return middleware.InitializeOutput{}, middleware.Metadata{}, &readOnlyError{Service: awsmiddleware.GetServiceID(ctx), Operation: operation}
+			}), "RegisterServiceMetadata", middleware.After)
+		},
+	}
+}
@@ -0,0 +1,7 @@
+//go:build readonly
+
+package main
+
+// readOnlyBuild is set by the readonly build tag: the read-only mode cannot be turned off and the code paths
+// guarded by it are compiled out.
+const readOnlyBuild = true
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// readOnlyTestClient returns an EC2 client with the read-only middleware, sending its calls to a test server
+// answering every call with an empty DescribeRegions response.
+//
+// t: The test.
+// *ec2.Client: The client.
+// *atomic.Int32: The number of requests the server received.
+func readOnlyTestClient(t *testing.T) (*ec2.Client, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, `<DescribeRegionsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><regionInfo/></DescribeRegionsResponse>`)
+	}))
+	t.Cleanup(server.Close)
+	client := ec2.New(ec2.Options{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+		APIOptions:       readOnlyAPIOptions(),
+	})
+	return client, requests
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	client, requests := readOnlyTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{}); err != nil {
+		t.Fatalf("DescribeRegions: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("DescribeRegions sent %d requests, want 1", requests.Load())
+	}
+
+	for name, call := range map[string]func() error{
+		"ReleaseAddress": func() error {
+			_, err := client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String("eipalloc-1")})
+			return err
+		},
+		"DisassociateAddress": func() error {
+			_, err := client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-1")})
+			return err
+		},
+		"DeleteNetworkInterface": func() error {
+			_, err := client.DeleteNetworkInterface(ctx, &ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String("eni-1")})
+			return err
+		},
+	} {
+		var readOnlyErr *readOnlyError
+		if err := call(); !errors.As(err, &readOnlyErr) {
+			t.Errorf("%s: error %v, want a readOnlyError", name, err)
+		} else if readOnlyErr.Operation != name || readOnlyErr.Service != "EC2" {
+			t.Errorf("%s: blocked %s %s", name, readOnlyErr.Service, readOnlyErr.Operation)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("the blocked calls sent %d requests", requests.Load()-1)
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	for _, name := range mutatingFlags {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Bool(name, false, "")
+		fs.Bool("dry-run", false, "")
+		if err := fs.Parse([]string{"-dry-run", "-" + name}); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkReadOnly(fs, false); err != nil {
+			t.Errorf("-%s outside read-only mode: %v", name, err)
+		}
+		want := "-" + name + " modifies resources and is rejected in read-only mode"
+		if err := checkReadOnly(fs, true); err == nil || err.Error() != want {
+			t.Errorf("-%s in read-only mode: error %v, want %q", name, err, want)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("release-eips", false, "")
+	if err := checkReadOnly(fs, true); err != nil {
+		t.Errorf("no mutating flag set: %v", err)
+	}
+}
+
+// buildTool builds the tool into a temporary directory.
+//
+// t: The test.
+// tags: The build tags, empty for none.
+// string: The path of the binary.
+// string: Its symbols, as printed by go tool nm.
+func buildTool(t *testing.T, tags string) (string, string) {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "interfaces")
+	if out, err := exec.Command("go", "build", "-tags", tags, "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build -tags %q: %v\n%s", tags, err, out)
+	}
+	out, err := exec.Command("go", "tool", "nm", binary).Output()
+	if err != nil {
+		t.Fatalf("go tool nm: %v", err)
+	}
+	return binary, string(out)
+}
+
+func TestReadOnlyBuildOmitsRelease(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the tool twice")
+	}
+	releasePaths := []string{"main.releaseIdleEips", "main.releaseIdleEip"}
+	_, symbols := buildTool(t, "")
+	for _, symbol := range releasePaths {
+		if !strings.Contains(symbols, " "+symbol+"\n") {
+			t.Fatalf("the default build has no %s, the check below would prove nothing", symbol)
+		}
+	}
+	binary, symbols := buildTool(t, "readonly")
+	for _, symbol := range releasePaths {
+		if strings.Contains(symbols, " "+symbol+"\n") {
+			t.Errorf("the readonly build contains %s", symbol)
+		}
+	}
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-release-eips", "-yes", "web"}, "-release-eips modifies resources and is rejected in read-only mode"},
+		{[]string{"-read-only=false", "web"}, "-read-only cannot be turned off in a readonly build"},
+	} {
+		out, err := exec.Command(binary, test.args...).CombinedOutput()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitUsage || !strings.Contains(string(out), test.want) {
+			t.Errorf("readonly build %v: %v, want exit %d and %q\n%s", test.args, err, exitUsage, test.want, out)
+		}
+	}
+}
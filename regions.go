@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// regionProbe holds the outcome of probing a single region.
+type regionProbe struct {
+	Region  string `json:"region"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+	// Transient is set when the probe timed out or failed for another reason than the access of the credentials,
+	// so its outcome says nothing about the next run.
+	Transient bool      `json:"transient,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// definite reports whether the outcome of a probe holds until the access of the credentials changes, so it can be
+// cached: the region was allowed, denied the call or is not enabled for the account.
+//
+// bool: Whether the probe can be cached.
+func (p regionProbe) definite() bool {
+	return !p.Transient && (p.Allowed || p.Reason == "UnauthorizedOperation" || p.Reason == "OptInRequired")
+}
+
+// regionFailure records a region whose scan failed part way through.
+type regionFailure struct {
+	Region string `json:"region"`
+	Error  string `json:"error"`
+}
+
+// regionsAPI is the part of the EC2 client listing the regions.
+type regionsAPI interface {
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+}
+
+// getRegions retrieves the regions available to the account.
+//
+// ctx: The context used for the API call.
+// ec2Client: The EC2 client.
+// includeOptIn: Whether to include opt-in regions that are not enabled for the account.
+// []types.Region: The regions.
+// error: If the regions cannot be described.
+func getRegions(ctx context.Context, ec2Client regionsAPI, includeOptIn bool) ([]types.Region, error) {
+	describeRegionsOutput, err := ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{
+		AllRegions: aws.Bool(includeOptIn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return describeRegionsOutput.Regions, nil
+}
+
+// selectRegions returns the names of the regions to scan.
+//
+// regions: The regions returned by DescribeRegions.
+// exclude: The names of the regions to leave out.
+// []string: The sorted region names.
+func selectRegions(regions []types.Region, exclude []string) []string {
+	selected := []string{}
+	for _, region := range regions {
+		name := aws.ToString(region.RegionName)
+		if name == "" || slices.Contains(exclude, name) {
+			continue
+		}
+		selected = append(selected, name)
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+// parseRegionList splits a comma separated list of region names.
+//
+// value: The comma separated list.
+// []string: The region names, without blanks.
+func parseRegionList(value string) []string {
+	regions := []string{}
+	for _, region := range strings.Split(value, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// getPermittedRegions drops the regions the credentials cannot query.
+//
+// Each region is probed concurrently with a cheap DescribeSecurityGroups call. Regions that deny the call
+// or do not answer within the timeout are skipped, and the reason is printed to stderr.
+// The definite probe results are stored in the on-disk cache, keyed by account, for the duration of the cache TTL.
+// Timeouts and other transient failures are never cached, the region is probed again on the next run.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// regions: The regions to probe.
+// timeout: The maximum duration of a single probe.
+// cacheTTL: The maximum age of cached probe results. Zero disables the cache.
+// []string: The permitted region names.
+func getPermittedRegions(ctx context.Context, cfg aws.Config, regions []string, timeout time.Duration, cacheTTL time.Duration) []string {
+	cacheName := ""
+	if accountId, err := getAccountId(ctx, cfg); err == nil {
+		cacheName = fmt.Sprintf("region-probe-%s.json", accountId)
+	}
+
+	// Reuse the fresh cached probes and probe the other regions
+	cachedProbes := map[string]regionProbe{}
+	if cacheName != "" {
+		readCache(cacheName, cacheTTL, &cachedProbes)
+	}
+	probes := map[string]regionProbe{}
+	unprobed := []string{}
+	for _, region := range regions {
+		if probe, ok := cachedProbes[region]; ok && probe.definite() && time.Since(probe.CheckedAt) <= cacheTTL {
+			probes[region] = probe
+		} else {
+			unprobed = append(unprobed, region)
+		}
+	}
+	fresh := map[string]regionProbe{}
+	for _, probe := range probeRegions(ctx, cfg, unprobed, timeout) {
+		fresh[probe.Region] = probe
+		if probe.definite() {
+			cachedProbes[probe.Region] = probe
+		} else {
+			delete(cachedProbes, probe.Region)
+		}
+	}
+	if cacheName != "" && cacheTTL > 0 && len(unprobed) > 0 {
+		if err := writeCache(cacheName, cachedProbes); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not cache region probe results: %v\n", err)
+		}
+	}
+
+	permitted := []string{}
+	for _, region := range regions {
+		probe, cached := probes[region]
+		if !cached {
+			probe = fresh[region]
+		}
+		if probe.Allowed {
+			permitted = append(permitted, region)
+			continue
+		}
+		if cached {
+			fmt.Fprintf(os.Stderr, "Skipping region %s: %s (cached)\n", probe.Region, probe.Reason)
+		} else {
+			fmt.Fprintf(os.Stderr, "Skipping region %s: %s\n", probe.Region, probe.Reason)
+		}
+	}
+
+	return permitted
+}
+
+// probeRegions probes the given regions concurrently.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// regions: The regions to probe.
+// timeout: The maximum duration of a single probe.
+// []regionProbe: The probe results, in the order of the regions.
+func probeRegions(ctx context.Context, cfg aws.Config, regions []string, timeout time.Duration) []regionProbe {
+	probes := make([]regionProbe, len(regions))
+
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			probes[i] = probeRegion(ctx, cfg, region, timeout)
+		}(i, region)
+	}
+	wg.Wait()
+
+	return probes
+}
+
+// probeRegion checks whether the credentials can describe security groups in the given region.
+//
+// ctx: The context used for the API call.
+// cfg: The AWS configuration.
+// region: The region to probe.
+// timeout: The maximum duration of the probe.
+// regionProbe: The probe result.
+func probeRegion(ctx context.Context, cfg aws.Config, region string, timeout time.Duration) regionProbe {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checkedAt := time.Now().UTC()
+	_, err := newEC2Client(cfg, region).DescribeSecurityGroups(probeCtx, &ec2.DescribeSecurityGroupsInput{
+		MaxResults: aws.Int32(5),
+	})
+	if err == nil {
+		return regionProbe{Region: region, Allowed: true, CheckedAt: checkedAt}
+	}
+
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr) && (apiErr.ErrorCode() == "UnauthorizedOperation" || apiErr.ErrorCode() == "OptInRequired"):
+		return regionProbe{Region: region, Reason: apiErr.ErrorCode(), CheckedAt: checkedAt}
+	case errors.Is(err, context.DeadlineExceeded) || probeCtx.Err() != nil:
+		return regionProbe{Region: region, Reason: fmt.Sprintf("timed out after %s", timeout), Transient: true, CheckedAt: checkedAt}
+	}
+
+	// Other errors are left for the full scan to report
+	return regionProbe{Region: region, Allowed: true, Transient: true, CheckedAt: checkedAt}
+}
+
+// isAuthFailure reports whether the error is an EC2 AuthFailure error, as returned by a region
+// whose access was revoked during the scan.
+//
+// err: The error to check.
+// bool: Whether the region refused the credentials.
+func isAuthFailure(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AuthFailure"
+}
+
+// printRegionFailures prints the regions whose scan failed.
+//
+// w: The writer to print to.
+// failures: The failed regions.
+func printRegionFailures(w io.Writer, failures []regionFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Failed regions:")
+	for _, failure := range failures {
+		fmt.Fprintf(w, "  %s: %s\n", failure.Region, failure.Error)
+	}
+}
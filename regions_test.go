@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+
+	"interfaces/m/v2/pkg/enilookup/enitest"
+)
+
+func TestRegionSelection(t *testing.T) {
+	fake := enitest.New()
+	fake.AddRegions(
+		types.Region{RegionName: aws.String("us-east-1"), OptInStatus: aws.String("opt-in-not-required")},
+		types.Region{RegionName: aws.String("eu-west-1"), OptInStatus: aws.String("opt-in-not-required")},
+		types.Region{RegionName: aws.String("af-south-1"), OptInStatus: aws.String("opted-in")},
+		types.Region{RegionName: aws.String("ap-east-1"), OptInStatus: aws.String("not-opted-in")},
+		types.Region{RegionName: aws.String("me-south-1"), OptInStatus: aws.String("not-opted-in")},
+	)
+
+	tests := []struct {
+		name         string
+		includeOptIn bool
+		exclude      string
+		want         string
+	}{
+		{name: "enabled regions", want: "af-south-1,eu-west-1,us-east-1"},
+		{name: "with opt-in regions", includeOptIn: true, want: "af-south-1,ap-east-1,eu-west-1,me-south-1,us-east-1"},
+		{name: "excluded regions", exclude: " us-east-1,,af-south-1 ", want: "eu-west-1"},
+		{name: "excluded opt-in region", includeOptIn: true, exclude: "me-south-1", want: "af-south-1,ap-east-1,eu-west-1,us-east-1"},
+		{name: "unknown excluded region", exclude: "xx-nowhere-1", want: "af-south-1,eu-west-1,us-east-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regions, err := getRegions(context.Background(), fake, tt.includeOptIn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(selectRegions(regions, parseRegionList(tt.exclude)), ","); got != tt.want {
+				t.Errorf("regions = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRegionsError(t *testing.T) {
+	fake := enitest.New()
+	fake.FailWith("DescribeRegions", &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "denied"})
+	if _, err := getRegions(context.Background(), fake, false); err == nil {
+		t.Error("a denied DescribeRegions returned no error")
+	}
+}
+
+func TestParseRegionList(t *testing.T) {
+	tests := map[string][]string{
+		"":                        {},
+		"us-east-1":               {"us-east-1"},
+		" us-east-1 , eu-west-1,": {"us-east-1", "eu-west-1"},
+		",,":                      {},
+	}
+	for value, want := range tests {
+		if got := parseRegionList(value); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("parseRegionList(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	if !isAuthFailure(fmt.Errorf("scan: %w", &smithy.GenericAPIError{Code: "AuthFailure"})) {
+		t.Error("a wrapped AuthFailure was not recognized")
+	}
+	if isAuthFailure(&smithy.GenericAPIError{Code: "UnauthorizedOperation"}) {
+		t.Error("UnauthorizedOperation was taken for an AuthFailure")
+	}
+}
+
+// TestPermittedRegionsCache probes a region of every outcome twice and checks that only the definite outcomes are
+// served from the cache, the timed out and failing regions being probed again.
+func TestPermittedRegionsCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var mu sync.Mutex
+	probes := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		region := strings.Trim(r.URL.Path, "/")
+		if region == "" {
+			io.WriteString(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`)
+			return
+		}
+		mu.Lock()
+		probes[region]++
+		mu.Unlock()
+		switch region {
+		case "eu-west-1":
+			io.WriteString(w, `<DescribeSecurityGroupsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><securityGroupInfo/></DescribeSecurityGroupsResponse>`)
+		case "us-east-1", "af-south-1":
+			code := map[string]string{"us-east-1": "UnauthorizedOperation", "af-south-1": "OptInRequired"}[region]
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, `<Response><Errors><Error><Code>%s</Code><Message>denied</Message></Error></Errors><RequestID>test</RequestID></Response>`, code)
+		case "ap-east-1":
+			time.Sleep(300 * time.Millisecond)
+		case "sa-east-1":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `<Response><Errors><Error><Code>InvalidParameterValue</Code><Message>failure</Message></Error></Errors><RequestID>test</RequestID></Response>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	cfg := aws.Config{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...any) (aws.Endpoint, error) {
+			if service == ec2.ServiceID {
+				return aws.Endpoint{URL: server.URL + "/" + region}, nil
+			}
+			return aws.Endpoint{URL: server.URL}, nil
+		}),
+	}
+
+	regions := []string{"af-south-1", "ap-east-1", "eu-west-1", "sa-east-1", "us-east-1"}
+	for run := 1; run <= 2; run++ {
+		permitted := getPermittedRegions(context.Background(), cfg, regions, 100*time.Millisecond, time.Hour)
+		if got := strings.Join(permitted, ","); got != "eu-west-1,sa-east-1" {
+			t.Errorf("run %d: permitted %s, want eu-west-1,sa-east-1", run, got)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"af-south-1": 1, "ap-east-1": 2, "eu-west-1": 1, "sa-east-1": 2, "us-east-1": 1}
+	if fmt.Sprint(probes) != fmt.Sprint(want) {
+		t.Errorf("probes per region %v, want %v", probes, want)
+	}
+}
+
+func TestRegionProbeDefinite(t *testing.T) {
+	for _, tt := range []struct {
+		probe regionProbe
+		want  bool
+	}{
+		{regionProbe{Allowed: true}, true},
+		{regionProbe{Reason: "UnauthorizedOperation"}, true},
+		{regionProbe{Reason: "OptInRequired"}, true},
+		{regionProbe{Reason: "timed out after 5s", Transient: true}, false},
+		{regionProbe{Allowed: true, Transient: true}, false},
+		// Written before transient probes were marked
+		{regionProbe{Reason: "timed out after 5s"}, false},
+	} {
+		if got := tt.probe.definite(); got != tt.want {
+			t.Errorf("%+v: definite %v, want %v", tt.probe, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// renderOptions controls how the results of a run are rendered.
+type renderOptions struct {
+	Output          string
+	GroupBy         string
+	AppTagKey       string
+	AzBalance       bool
+	AzSkewThreshold float64
+	ExcludeShared   bool
+	PublicOnly      bool
+	ShowRegion      bool
+	// AccountId is the account of the security groups, used by the graph outputs.
+	AccountId string
+	// Board keeps the rows of the previous iteration of the board outputs.
+	Board *boardState
+	// Runbook adds the actions freeing the security groups from their interfaces.
+	Runbook bool
+	// RollupTags replaces the interfaces by their counts per value of these tags, set with -rollup-tag.
+	RollupTags []string
+	// Template is the template of -output template, executed per interface or once per run as TemplateScope sets.
+	Template      *template.Template
+	TemplateScope string
+	// Endpoints is the endpoint mode of the run: standard, fips, dualstack or fips+dualstack.
+	Endpoints string
+	// CorrelationId is the correlation ID of the run, empty when -no-timestamps leaves a generated ID out.
+	CorrelationId string
+	// Convention replaces the report by the violations of this convention, set with -convention.
+	Convention *convention
+	// ExplainEni replaces the report by the narratives of this network interface, set with -explain-eni.
+	ExplainEni string
+	// Narrative adds the narrative of every interface to the text report, set with -verbose-narrative.
+	Narrative bool
+}
+
+// renderer renders the results of a run in an output format. Every format registers one with registerRenderer,
+// from the init function of the file implementing it, and is selected by its name with -output.
+type renderer interface {
+	// Name is the value of -output selecting the renderer.
+	Name() string
+	// Description is the one-line description listed by -h.
+	Description() string
+	// ContentType is the media type of the output, e.g. application/json.
+	ContentType() string
+	// Extension is the file extension of the output, used by -split-by.
+	Extension() string
+	// Render writes what a run found.
+	Render(w io.Writer, run scanResult, opts renderOptions) error
+}
+
+// formatRenderer is a renderer built from its attributes and a render function.
+type formatRenderer struct {
+	name        string
+	description string
+	contentType string
+	extension   string
+	render      func(w io.Writer, run scanResult, opts renderOptions) error
+}
+
+// Name returns the value of -output selecting the renderer.
+func (r formatRenderer) Name() string { return r.name }
+
+// Description returns the one-line description listed by -h.
+func (r formatRenderer) Description() string { return r.description }
+
+// ContentType returns the media type of the output.
+func (r formatRenderer) ContentType() string { return r.contentType }
+
+// Extension returns the file extension of the output.
+func (r formatRenderer) Extension() string { return r.extension }
+
+// Render writes what a run found.
+func (r formatRenderer) Render(w io.Writer, run scanResult, opts renderOptions) error {
+	return r.render(w, run, opts)
+}
+
+// renderers holds the registered renderers keyed by name.
+var renderers = map[string]renderer{}
+
+// registerRenderer makes an output format selectable with -output.
+//
+// r: The renderer. Registering two renderers with the same name panics.
+func registerRenderer(r renderer) {
+	if _, ok := renderers[r.Name()]; ok {
+		panic(fmt.Sprintf("renderer %s registered twice", r.Name()))
+	}
+	renderers[r.Name()] = r
+}
+
+// rendererNames returns the names of the registered renderers.
+//
+// []string: The names, sorted.
+func rendererNames() []string {
+	names := []string{}
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outputUsage returns the usage of -output, listing every registered format with its description.
+//
+// string: The usage.
+func outputUsage() string {
+	lines := []string{"The output `format`, help to list them:"}
+	for _, name := range rendererNames() {
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, renderers[name].Description()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// printOutputFormats prints every registered format with its description, for -output help.
+//
+// w: The writer to print to.
+// error: If the list cannot be written.
+func printOutputFormats(w io.Writer) error {
+	for _, name := range rendererNames() {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", name, renderers[name].Description()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render writes the results of a run in the selected output format.
+//
+// w: The writer to print to.
+// run: What the run found.
+// opts: How to render it.
+// error: If the format is not registered or the renderer fails.
+func render(w io.Writer, run scanResult, opts renderOptions) error {
+	r, ok := renderers[opts.Output]
+	if !ok {
+		return fmt.Errorf("unknown output format %s", opts.Output)
+	}
+	return r.Render(w, run, opts)
+}
+
+// errWriter keeps the first error of the writes to a writer, for the renderers printing with fmt.Fprintf. The
+// writes after the error are dropped.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write writes to the writer unless an earlier write failed.
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// renderPartial prints the groups a failed run looked up before the failure, so a failure late in a run does not
+// lose everything fetched so far. The output is marked incomplete by the Incomplete note of the run.
+//
+// run: What the run found before the failure. Nothing is printed when Incomplete is not set, as when the run
+// failed before any lookup.
+// streamer: The streamer that printed the groups with -stream-groups. May be nil.
+// opts: How to render it.
+// noTimestamps: Whether to leave every timestamp out, as -no-timestamps does.
+func renderPartial(run scanResult, streamer *groupStreamer, opts renderOptions, noTimestamps bool) {
+	if run.Incomplete == "" {
+		return
+	}
+	if noTimestamps {
+		stripTimestamps(&run)
+	}
+	if streamer != nil {
+		if err := streamer.finish(run); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		return
+	}
+	if err := render(os.Stdout, run, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+}
+
+// printRunNotes prints what a run found besides the network interfaces: the verification, the IP capacity,
+// the idle Elastic IPs and the runbook, and why it stopped early.
+//
+// w: The writer to print to.
+// run: What the run found.
+// opts: How to render it.
+func printRunNotes(w io.Writer, run scanResult, opts renderOptions) {
+	printIpCapacity(w, run.IpCapacity)
+	printIdleEips(w, run.IdleEips)
+	if opts.Runbook {
+		printRunbook(w, getRunbook(run.Results))
+	}
+	printVerification(w, run.Verification)
+	if run.Incomplete != "" {
+		fmt.Fprintf(w, "Incomplete: %s\n", run.Incomplete)
+	}
+}
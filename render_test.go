@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestRendererRegistry(t *testing.T) {
+	usage := outputUsage()
+	for _, name := range rendererNames() {
+		r := renderers[name]
+		if r.Description() == "" || r.ContentType() == "" || !strings.HasPrefix(r.Extension(), ".") {
+			t.Errorf("%s: description %q, content type %q, extension %q", name, r.Description(), r.ContentType(), r.Extension())
+		}
+		if !strings.Contains(usage, "  "+name+": "+r.Description()) {
+			t.Errorf("-output usage does not list %s:\n%s", name, usage)
+		}
+	}
+
+	if err := render(io.Discard, scanResult{}, renderOptions{Output: "yaml"}); err == nil || !strings.Contains(err.Error(), "unknown output format yaml") {
+		t.Errorf("unknown format: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a renderer twice did not panic")
+		}
+	}()
+	registerRenderer(formatRenderer{name: "text"})
+}
+
+// TestRenderersEmptyRun renders a run that found nothing, and a group without any interface, with every renderer.
+func TestRenderersEmptyRun(t *testing.T) {
+	empty := scanResult{Results: []groupResult{}, Instances: map[string]types.Instance{}, FailedRegions: []regionFailure{}, Warnings: []runWarning{}}
+	noInterfaces := testRun(false)
+	noInterfaces.Results = []groupResult{testResult("eu-west-1", "web")}
+	for _, name := range rendererNames() {
+		t.Run(name, func(t *testing.T) {
+			for fixture, run := range map[string]scanResult{"empty": empty, "no interfaces": noInterfaces} {
+				output := renderTestRun(t, name, run)
+				switch renderers[name].ContentType() {
+				case "application/json":
+					if !json.Valid(output) {
+						t.Errorf("%s: invalid JSON:\n%s", fixture, output)
+					}
+				case "application/x-ndjson":
+					for _, line := range bytes.Split(bytes.TrimSpace(output), []byte("\n")) {
+						if len(line) > 0 && !json.Valid(line) {
+							t.Errorf("%s: invalid JSON line %s", fixture, line)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// errDiskFull is the error of failingWriter.
+var errDiskFull = errors.New("no space left on device")
+
+// failingWriter fails every write, as a full disk or a closed pipe does.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errDiskFull }
+
+// TestRenderersWriteError checks that the renderers writing their output with fmt, encoding/json and encoding/csv
+// return a write error instead of panicking or dropping it.
+func TestRenderersWriteError(t *testing.T) {
+	run := testRun(false)
+	for _, name := range []string{"text", "json", "csv", "matrix-csv", "matrix-json", "config-items", "graph-json", "board-json"} {
+		opts := renderOptions{Output: name, Board: newBoardState(false)}
+		if name == "csv" {
+			opts.RollupTags = []string{"env"}
+		}
+		if err := renderers[name].Render(failingWriter{}, run, opts); !errors.Is(err, errDiskFull) {
+			t.Errorf("%s: %v, want the write error", name, err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "text", description: "the network interfaces of every security group, for humans", contentType: "text/plain", extension: ".txt", render: renderText})
+	registerRenderer(formatRenderer{name: "json", description: "the text report as a JSON document", contentType: "application/json", extension: ".json", render: renderJSON})
+}
+
+// renderText prints the run for humans: the report, or the rollup, application, instance, zone, convention or
+// explanation view when selected.
+//
+// out: The writer to print to.
+// run: What the run found.
+// opts: How to render it.
+// error: If the output cannot be written.
+func renderText(out io.Writer, run scanResult, opts renderOptions) error {
+	w := &errWriter{w: out}
+	switch {
+	case len(opts.RollupTags) > 0:
+		printRollup(w, opts.RollupTags, getRollup(run.Results, opts.RollupTags, run.Instances))
+		printRegionFailures(w, run.FailedRegions)
+	case opts.GroupBy == "app":
+		printAppReport(w, groupByApp(run.Results, opts.AppTagKey, run.Instances))
+		printRegionFailures(w, run.FailedRegions)
+	case opts.GroupBy == "instance":
+		printInstanceReport(w, groupByInstance(run.Results), run.Instances, run.EffectiveRules)
+		printRegionFailures(w, run.FailedRegions)
+	case opts.AzBalance:
+		printZoneBalances(w, getZoneBalances(run.Results, opts.AzSkewThreshold, opts.ExcludeShared), opts.ShowRegion)
+		printRegionFailures(w, run.FailedRegions)
+	case opts.Convention != nil:
+		printViolations(w, getViolations(run.Results, opts.Convention), opts.ShowRegion)
+		printRegionFailures(w, run.FailedRegions)
+	case opts.ExplainEni != "":
+		printExplanation(w, opts.ExplainEni, getExplanation(run.Results, run.Instances, opts.ExplainEni), opts.ShowRegion)
+		printRegionFailures(w, run.FailedRegions)
+	default:
+		printReport(w, run.Results, run.Instances, run.Sightings, opts.ShowRegion, opts.ExcludeShared, opts.PublicOnly, opts.Narrative)
+		printRegionFailures(w, run.FailedRegions)
+		printRunNotes(w, run, opts)
+	}
+	return w.err
+}
+
+// renderJSON writes the run as a JSON document: the report, or the rollup, application, instance, zone, convention
+// or explanation view when selected.
+//
+// w: The writer to print to.
+// run: What the run found.
+// opts: How to render it.
+// error: If the document cannot be encoded or written.
+func renderJSON(w io.Writer, run scanResult, opts renderOptions) error {
+	switch {
+	case len(opts.RollupTags) > 0:
+		return writeJSON(w, rollupReport{RollupTags: opts.RollupTags, Rows: getRollup(run.Results, opts.RollupTags, run.Instances), FailedRegions: run.FailedRegions})
+	case opts.GroupBy == "app":
+		return writeJSON(w, newAppReport(groupByApp(run.Results, opts.AppTagKey, run.Instances), run.Instances, run.Sightings, run.FailedRegions))
+	case opts.GroupBy == "instance":
+		return writeJSON(w, newInstanceReport(groupByInstance(run.Results), run.Instances, run.Sightings, run.EffectiveRules, run.FailedRegions))
+	case opts.AzBalance:
+		return writeJSON(w, zoneBalanceReport{ZoneBalances: getZoneBalances(run.Results, opts.AzSkewThreshold, opts.ExcludeShared), FailedRegions: run.FailedRegions})
+	case opts.Convention != nil:
+		r := getViolations(run.Results, opts.Convention)
+		r.FailedRegions = run.FailedRegions
+		return writeJSON(w, r)
+	case opts.ExplainEni != "":
+		return writeJSON(w, explainReport{NetworkInterfaceId: opts.ExplainEni, Narratives: getExplanation(run.Results, run.Instances, opts.ExplainEni), FailedRegions: run.FailedRegions})
+	default:
+		r := newReport(run.Results, run.Instances, run.Sightings, opts.ExcludeShared, run.FailedRegions)
+		r.Metadata = reportMetadata{Version: programVersion(), Endpoints: opts.Endpoints, CorrelationId: opts.CorrelationId, GeneratedAt: run.ScannedAt}
+		r.Complete = run.Incomplete == ""
+		r.Incomplete = run.Incomplete
+		r.Verification = run.Verification
+		r.IpCapacity = run.IpCapacity
+		r.IdleEips = run.IdleEips
+		r.Warnings = run.Warnings
+		if opts.Runbook {
+			r.Actions = getRunbook(run.Results)
+		}
+		return writeJSON(w, r)
+	}
+}
+
+// report is the JSON document describing the network interfaces found per security group.
+type report struct {
+	Metadata      reportMetadata     `json:"metadata"`
+	Groups        []groupReport      `json:"groups"`
+	FailedRegions []regionFailure    `json:"failed_regions"`
+	Complete      bool               `json:"complete"`
+	Incomplete    string             `json:"incomplete,omitempty"`
+	Verification  *verification      `json:"verification,omitempty"`
+	IpCapacity    []instanceCapacity `json:"ip_capacity,omitempty"`
+	IdleEips      []idleEip          `json:"idle_eips,omitempty"`
+	Actions       []runbookAction    `json:"actions,omitempty"`
+	Warnings      []runWarning       `json:"warnings"`
+}
+
+// reportMetadata is the envelope of the JSON document, the only place holding when the run happened.
+type reportMetadata struct {
+	Version       string     `json:"version"`
+	Endpoints     string     `json:"endpoints"`
+	CorrelationId string     `json:"correlation_id,omitempty"`
+	GeneratedAt   *time.Time `json:"generated_at,omitempty"`
+}
+
+// groupReport is the JSON form of a groupResult.
+type groupReport struct {
+	Region            string                   `json:"region"`
+	SecurityGroupName string                   `json:"security_group_name,omitempty"`
+	SecurityGroupId   string                   `json:"security_group_id,omitempty"`
+	MatchedOn         string                   `json:"matched_on,omitempty"`
+	Status            string                   `json:"status"`
+	Denial            *groupDenial             `json:"denial,omitempty"`
+	Error             string                   `json:"error,omitempty"`
+	NetworkInterfaces []networkInterfaceReport `json:"network_interfaces"`
+	TotalInterfaces   int                      `json:"total_interfaces"`
+	FoundInterfaces   int                      `json:"found_interfaces"`
+	Truncated         bool                     `json:"truncated"`
+	SharedInterfaces  int                      `json:"shared_interfaces"`
+	SharedExcluded    bool                     `json:"shared_excluded"`
+	Exposures         []exposure               `json:"exposures,omitempty"`
+	Churn             *groupChurn              `json:"churn,omitempty"`
+	AgeHistogram      map[string]int           `json:"age_histogram,omitempty"`
+	Incremental       *groupRefresh            `json:"incremental,omitempty"`
+}
+
+// networkInterfaceReport is the JSON form of a network interface. The fields the SDK may leave nil are null.
+type networkInterfaceReport struct {
+	NetworkInterfaceId *string                `json:"network_interface_id"`
+	InterfaceType      string                 `json:"interface_type"`
+	Status             string                 `json:"status"`
+	AvailabilityZone   *string                `json:"availability_zone"`
+	SubnetId           *string                `json:"subnet_id"`
+	VpcId              *string                `json:"vpc_id"`
+	PrivateIpAddress   *string                `json:"private_ip_address"`
+	PublicIp           string                 `json:"public_ip,omitempty"`
+	Description        *string                `json:"description"`
+	ManagedBy          string                 `json:"managed_by"`
+	Shared             bool                   `json:"shared"`
+	InstanceId         string                 `json:"instance_id,omitempty"`
+	InstanceName       string                 `json:"instance_name,omitempty"`
+	Permissions        *[]interfacePermission `json:"permissions,omitempty"`
+	FirstSeen          *time.Time             `json:"first_seen,omitempty"`
+	LastSeen           *time.Time             `json:"last_seen,omitempty"`
+}
+
+// newReport builds the JSON document for the results.
+//
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// excludeShared: Whether shared service ENIs are left out of the totals.
+// failedRegions: The regions whose scan failed.
+// report: The JSON document.
+func newReport(results []groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting, excludeShared bool, failedRegions []regionFailure) report {
+	groups := []groupReport{}
+	for _, result := range results {
+		total, shared := countInterfaces(result.NetworkInterfaces, excludeShared)
+		groups = append(groups, groupReport{
+			Region:            result.Region,
+			SecurityGroupName: result.Selector.GroupName,
+			SecurityGroupId:   result.Selector.GroupId,
+			MatchedOn:         result.Selector.MatchedOn,
+			Status:            groupStatus(result),
+			Denial:            result.Denial,
+			Error:             result.LookupError,
+			NetworkInterfaces: newNetworkInterfaceReports(result.NetworkInterfaces, instances, result.Permissions, sightings),
+			TotalInterfaces:   total,
+			FoundInterfaces:   max(result.Found, len(result.NetworkInterfaces)),
+			Truncated:         result.Truncated,
+			SharedInterfaces:  shared,
+			SharedExcluded:    excludeShared,
+			Exposures:         result.Exposures,
+			Churn:             result.Churn,
+			AgeHistogram:      ageHistogramMap(result.AgeHistogram),
+			Incremental:       result.Incremental,
+		})
+	}
+	return report{Groups: groups, FailedRegions: failedRegions}
+}
+
+// countInterfaces counts the network interfaces, telling Hyperplane ENIs shared by many workloads apart.
+//
+// networkInterfaces: The network interfaces to count.
+// excludeShared: Whether shared interfaces are left out of the total.
+// int: The total used for thresholds and verdicts.
+// int: The number of shared interfaces.
+func countInterfaces(networkInterfaces []types.NetworkInterface, excludeShared bool) (int, int) {
+	total, shared := 0, 0
+	for _, networkInterface := range networkInterfaces {
+		if enilookup.IsShared(networkInterface) {
+			shared++
+			if excludeShared {
+				continue
+			}
+		}
+		total++
+	}
+	return total, shared
+}
+
+// newNetworkInterfaceReports converts the network interfaces to their JSON form.
+//
+// networkInterfaces: The network interfaces.
+// instances: The resolved instances keyed by instance ID.
+// permissions: The permissions granted on the interfaces keyed by interface ID. May be nil.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// []networkInterfaceReport: The JSON form of the network interfaces.
+func newNetworkInterfaceReports(networkInterfaces []types.NetworkInterface, instances map[string]types.Instance, permissions map[string][]interfacePermission, sightings map[string]interfaceSighting) []networkInterfaceReport {
+	reports := []networkInterfaceReport{}
+	for _, networkInterface := range networkInterfaces {
+		networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+		networkInterfaceReport := networkInterfaceReport{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			InterfaceType:      string(networkInterface.InterfaceType),
+			Status:             string(networkInterface.Status),
+			AvailabilityZone:   networkInterface.AvailabilityZone,
+			SubnetId:           networkInterface.SubnetId,
+			VpcId:              networkInterface.VpcId,
+			PrivateIpAddress:   networkInterface.PrivateIpAddress,
+			Description:        networkInterface.Description,
+			ManagedBy:          string(enilookup.Classify(networkInterface).ManagedBy),
+			Shared:             enilookup.IsShared(networkInterface),
+		}
+		if permissions != nil {
+			granted := append([]interfacePermission{}, permissions[networkInterfaceId]...)
+			networkInterfaceReport.Permissions = &granted
+		}
+		if sighting, ok := sightings[networkInterfaceId]; ok {
+			// Structured output is in UTC whatever the zone of the sighting
+			firstSeen, lastSeen := sighting.FirstSeen.UTC(), sighting.LastSeen.UTC()
+			networkInterfaceReport.FirstSeen = &firstSeen
+			networkInterfaceReport.LastSeen = &lastSeen
+		}
+		if isPublic(networkInterface) {
+			networkInterfaceReport.PublicIp = aws.ToString(networkInterface.Association.PublicIp)
+		}
+		if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+			networkInterfaceReport.InstanceId = aws.ToString(networkInterface.Attachment.InstanceId)
+			if instance, ok := instances[networkInterfaceReport.InstanceId]; ok {
+				networkInterfaceReport.InstanceName = tagValue(instance.Tags, "Name")
+			}
+		}
+		reports = append(reports, networkInterfaceReport)
+	}
+	return reports
+}
+
+// printReport prints the security groups and the network interfaces that are attached to them.
+//
+// w: The writer to print to.
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+// showRegion: Whether to print the region of every security group.
+// excludeShared: Whether shared service ENIs are left out of the totals.
+// publicOnly: Whether the exposures of the security groups are printed.
+// narrative: Whether to print the plain-English sentence describing every interface.
+func printReport(w io.Writer, results []groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting, showRegion bool, excludeShared bool, publicOnly bool, narrative bool) {
+	var index *groupIndex
+	if narrative {
+		index = newGroupIndex(results)
+	}
+	for _, result := range results {
+		// Print the security group and the network interfaces that are attached to it
+		printGroupHeader(w, result.Selector, result.Region, showRegion)
+		if result.Denial != nil {
+			printDenial(w, result.Denial)
+			continue
+		}
+		if result.LookupError != "" {
+			printLookupError(w, result.LookupError)
+			continue
+		}
+		for _, networkInterface := range result.NetworkInterfaces {
+			fmt.Fprintf(w, "Network interfaces:\n")
+			fmt.Fprintf(w, "  NetworkInterface ID: %s\n", derefOr(networkInterface.NetworkInterfaceId, missingValue))
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil {
+				fmt.Fprintf(w, "  InstanceId: %s\n", derefOr(networkInterface.Attachment.InstanceId, missingValue))
+				if instance, ok := instances[aws.ToString(networkInterface.Attachment.InstanceId)]; ok {
+					fmt.Fprintf(w, "  Instance Name: %s\n", displayText(tagValue(instance.Tags, "Name")))
+				}
+			}
+			fmt.Fprintf(w, "  Status: %s\n", networkInterface.Status)
+			if isPublic(networkInterface) {
+				fmt.Fprintf(w, "  Public IP: %s\n", derefOr(networkInterface.Association.PublicIp, missingValue))
+			}
+			if enilookup.IsShared(networkInterface) {
+				fmt.Fprintf(w, "  Shared: yes\n")
+			}
+			printPermissions(w, result.Permissions[aws.ToString(networkInterface.NetworkInterfaceId)])
+			sighting, ok := sightings[aws.ToString(networkInterface.NetworkInterfaceId)]
+			printSighting(w, sighting, ok)
+			if narrative {
+				fmt.Fprintf(w, "  Narrative: %s\n", getNarrative(getInterfaceFacts(result, networkInterface, instances, index)))
+			}
+			fmt.Fprintln(w)
+		}
+		printRefresh(w, result.Incremental)
+		printChurn(w, result.Churn)
+		printTruncation(w, result)
+		printAgeHistogram(w, result.AgeHistogram)
+		printTotal(w, result.NetworkInterfaces, excludeShared)
+		if publicOnly && len(result.NetworkInterfaces) > 0 {
+			printExposures(w, result.Exposures)
+		}
+	}
+}
+
+// printTotal prints the number of network interfaces of a security group, calling out shared service ENIs.
+//
+// w: The writer to print to.
+// networkInterfaces: The network interfaces of the security group.
+// excludeShared: Whether shared service ENIs are left out of the total.
+func printTotal(w io.Writer, networkInterfaces []types.NetworkInterface, excludeShared bool) {
+	total, shared := countInterfaces(networkInterfaces, excludeShared)
+	switch {
+	case shared == 0:
+		fmt.Fprintf(w, "Total: %d network interfaces\n", total)
+	case excludeShared:
+		fmt.Fprintf(w, "Total: %d network interfaces (%d shared service ENIs excluded, potentially many workloads)\n", total, shared)
+	default:
+		fmt.Fprintf(w, "Total: %d network interfaces (%d shared service ENIs, potentially many workloads)\n", total, shared)
+	}
+	fmt.Fprintln(w)
+}
+
+// printGroupHeader prints the line identifying a security group.
+//
+// w: The writer to print to.
+// selector: The security group, selected by name or by ID.
+// region: The region of the security group.
+// showRegion: Whether to also print the region.
+func printGroupHeader(w io.Writer, selector enilookup.Selector, region string, showRegion bool) {
+	if selector.GroupId != "" {
+		fmt.Fprintf(w, "Security group ID: %s\n", selector.GroupId)
+	} else {
+		fmt.Fprintf(w, "Security group name: %s\n", displayText(selector.GroupName))
+	}
+	if selector.MatchedOn != "" {
+		fmt.Fprintf(w, "Matched on: %s %s\n", selector.MatchedOn, displayText(selector.Input))
+	}
+	if showRegion {
+		fmt.Fprintf(w, "Region: %s\n", region)
+	}
+}
+
+// writeJSON writes the value to the writer as indented JSON.
+//
+// w: The writer to print to.
+// v: The value to write.
+// error: If the value cannot be encoded or written.
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
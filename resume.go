@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// resumeKey identifies a unit of work of a sweep.
+type resumeKey struct {
+	Account string
+	Region  string
+	Group   string
+}
+
+// resumeRecord is a line of the resume file, written when a unit of work completes.
+//
+// The result of the unit is stored with the record so an interrupted sweep can be merged into the final report.
+type resumeRecord struct {
+	Account     string      `json:"account"`
+	Region      string      `json:"region"`
+	Group       string      `json:"group"`
+	CompletedAt time.Time   `json:"completed_at"`
+	Result      groupResult `json:"result"`
+}
+
+// resumeState tracks the completed units of a sweep in an append-only NDJSON file.
+//
+// A nil *resumeState is valid and records nothing.
+type resumeState struct {
+	mu        sync.Mutex
+	file      *os.File
+	account   string
+	completed map[resumeKey]groupResult
+}
+
+// openResumeState opens the resume file, loading the units it records.
+//
+// Lines that cannot be decoded are skipped with a warning. A last line cut short by a crash, without its newline,
+// is cut from the file so the next record starts on a line of its own.
+//
+// path: The path of the resume file.
+// account: The account the sweep runs in.
+// noResume: Whether to ignore and overwrite any existing state.
+// *resumeState: The resume state.
+// error: If the file cannot be read or opened for appending.
+func openResumeState(path string, account string, noResume bool) (*resumeState, error) {
+	state := &resumeState{account: account, completed: map[resumeKey]groupResult{}}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if noResume {
+		flags |= os.O_TRUNC
+	} else {
+		end, partial, err := state.load(path)
+		if err != nil {
+			return nil, err
+		}
+		if partial {
+			if err := os.Truncate(path, end); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	state.file = file
+	return state, nil
+}
+
+// load reads the completed units recorded in the resume file.
+//
+// path: The path of the resume file.
+// int64: The length of the complete lines of the file.
+// bool: Whether the file ends with a partial line, after the complete lines.
+// error: If the file exists but cannot be read.
+func (r *resumeState) load(path string) (int64, bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	end := int64(0)
+	partial := false
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				fmt.Fprintf(os.Stderr, "warning: dropping the incomplete last line %d of the resume file\n", line)
+				partial = true
+			}
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: stopped reading the resume file: %v\n", err)
+			break
+		}
+		end += int64(len(data))
+		var record resumeRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping unreadable line %d of the resume file: %v\n", line, err)
+			continue
+		}
+		r.completed[resumeKey{Account: record.Account, Region: record.Region, Group: record.Group}] = record.Result
+	}
+	if len(r.completed) > 0 {
+		fmt.Fprintf(os.Stderr, "Resuming: %d units already completed\n", len(r.completed))
+	}
+	return end, partial, nil
+}
+
+// lookup returns the stored result of a completed unit.
+//
+// region: The region of the unit.
+// group: The security group of the unit, as given by the user.
+// groupResult: The stored result.
+// bool: Whether the unit was completed by a previous run.
+func (r *resumeState) lookup(region string, group string) (groupResult, bool) {
+	if r == nil {
+		return groupResult{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.completed[resumeKey{Account: r.account, Region: region, Group: group}]
+	return result, ok
+}
+
+// record appends the completion record of a unit.
+//
+// result: The result of the unit.
+// error: If the record cannot be written.
+func (r *resumeState) record(result groupResult) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.Marshal(resumeRecord{
+		Account:     r.account,
+		Region:      result.Region,
+		Group:       result.Selector.Key(),
+		CompletedAt: time.Now().UTC(),
+		Result:      result,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	r.completed[resumeKey{Account: r.account, Region: result.Region, Group: result.Selector.Key()}] = result
+	return nil
+}
+
+// clear empties the resume file once the sweep has completed every unit, so the next sweep starts over.
+//
+// error: If the file cannot be truncated.
+func (r *resumeState) clear() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.file.Truncate(0); err != nil {
+		return err
+	}
+	r.completed = map[resumeKey]groupResult{}
+	return nil
+}
+
+// close closes the resume file.
+func (r *resumeState) close() {
+	if r != nil {
+		r.file.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestResumeTruncatedLine resumes from a file whose last record was cut short by a crash, and checks that the
+// records written afterwards survive the next resume.
+func TestResumeTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.ndjson")
+	state, err := openResumeState(path, "123456789012", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range []string{"web", "db"} {
+		if err := state.record(testResult("eu-west-1", group)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state.close()
+
+	// The crash cut the record of db in the middle
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.IndexByte(data, '\n') + 1
+	if err := os.WriteFile(path, data[:first+20], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = openResumeState(path, "123456789012", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.lookup("eu-west-1", "web"); !ok {
+		t.Error("web, completed before the crash, is not resumed")
+	}
+	if _, ok := state.lookup("eu-west-1", "db"); ok {
+		t.Error("db, cut short by the crash, is resumed")
+	}
+	for _, group := range []string{"db", "cache"} {
+		if err := state.record(testResult("eu-west-1", group)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state.close()
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("%d lines, want the 3 records:\n%s", len(lines), data)
+	}
+	for i, line := range lines {
+		var record resumeRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Errorf("line %d: %v\n%s", i+1, err, line)
+		}
+	}
+
+	state, err = openResumeState(path, "123456789012", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.close()
+	for _, group := range []string{"web", "db", "cache"} {
+		if _, ok := state.lookup("eu-west-1", group); !ok {
+			t.Errorf("%s is not resumed", group)
+		}
+	}
+}
+
+func TestResumeNoResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.ndjson")
+	if err := os.WriteFile(path, []byte(`{"account":"123456789012","region":"eu-west-1","group":"web"}`+"\n{\"acc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := openResumeState(path, "123456789012", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.close()
+	if _, ok := state.lookup("eu-west-1", "web"); ok {
+		t.Error("-no-resume resumed a unit")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("-no-resume left the file: %v, %v", info, err)
+	}
+}
+
+// TestResumeClearedAfterSweep interrupts a sweep, completes it from the resume file, and checks that the file is
+// emptied once every unit completed, so the next sweep with the same file queries the API again.
+func TestResumeClearedAfterSweep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.ndjson")
+	progress, _ := newProgressReporter("none", "")
+	sweep := func(cfg aws.Config) error {
+		t.Helper()
+		state, err := openResumeState(path, "123456789012", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer state.close()
+		_, err = scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(3), progress, scanOptions{Resume: state})
+		return err
+	}
+
+	// The third lookup fails, the two completed units are kept
+	failing, _ := lookupEC2Config(t, 0, 3, nil)
+	if err := sweep(failing); err == nil {
+		t.Fatal("the interrupted sweep succeeded")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		t.Fatalf("the interrupted sweep left no state: %v, %v", info, err)
+	}
+
+	cfg, calls := lookupEC2Config(t, 0, 0, nil)
+	if err := sweep(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls resuming, want only the unit left", calls.Load())
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("the completed sweep left its state: %v, %v", info, err)
+	}
+
+	if err := sweep(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("%d calls, want the 3 units of the next sweep queried again", calls.Load()-1)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "csv", description: "the -rollup-tag pivot as CSV", contentType: "text/csv", extension: ".csv", render: func(w io.Writer, run scanResult, opts renderOptions) error {
+		if len(opts.RollupTags) == 0 {
+			return fmt.Errorf("-output csv requires -rollup-tag")
+		}
+		if err := writeRollupCSV(w, opts.RollupTags, getRollup(run.Results, opts.RollupTags, run.Instances)); err != nil {
+			return err
+		}
+		printRegionFailures(os.Stderr, run.FailedRegions)
+		return nil
+	}})
+}
+
+// rollupKeySeparator joins the tag values of a multi-key rollup row into its composite key.
+const rollupKeySeparator = " / "
+
+// rollupTags is the repeatable -rollup-tag flag.
+type rollupTags struct {
+	Keys []string
+}
+
+// Set appends a tag key.
+//
+// value: The tag key.
+// error: If the key is empty.
+func (r *rollupTags) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("the tag key cannot be empty")
+	}
+	r.Keys = append(r.Keys, value)
+	return nil
+}
+
+// String returns the tag keys, comma separated.
+func (r *rollupTags) String() string {
+	return strings.Join(r.Keys, ",")
+}
+
+// rollupRow counts the network interfaces carrying a combination of tag values.
+type rollupRow struct {
+	// Key is the composite key of the row, the tag values joined by rollupKeySeparator
+	Key string `json:"key"`
+	// Tags holds the value of every rollup tag, untaggedApp when the interface and its instance lack it
+	Tags              map[string]string `json:"tags"`
+	NetworkInterfaces int               `json:"network_interfaces"`
+	Instances         int               `json:"instances"`
+	PublicIps         int               `json:"public_ips"`
+}
+
+// rollupReport is the JSON document of a -rollup-tag run.
+type rollupReport struct {
+	RollupTags    []string        `json:"rollup_tags"`
+	Rows          []rollupRow     `json:"rows"`
+	FailedRegions []regionFailure `json:"failed_regions"`
+}
+
+// getRollup counts the network interfaces of the results, their instances and public IPs per tag values.
+//
+// Every tag is looked up on the network interface and then on the attached instance, when it was resolved.
+// Interfaces matched by several security groups are only counted once.
+//
+// results: The network interfaces found per security group.
+// keys: The tag keys to pivot on.
+// instances: The resolved instances keyed by instance ID.
+// []rollupRow: The rows sorted by key, with the rows holding untagged values last.
+func getRollup(results []groupResult, keys []string, instances map[string]types.Instance) []rollupRow {
+	rows := map[string]*rollupRow{}
+	seenInterfaces := map[string]bool{}
+	seenInstances := map[string]map[string]bool{}
+
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+			if seenInterfaces[networkInterfaceId] {
+				continue
+			}
+			seenInterfaces[networkInterfaceId] = true
+
+			values := []string{}
+			tags := map[string]string{}
+			for _, key := range keys {
+				value := appForNetworkInterface(networkInterface, key, instances)
+				values = append(values, value)
+				tags[key] = value
+			}
+			rowKey := strings.Join(values, rollupKeySeparator)
+			row, ok := rows[rowKey]
+			if !ok {
+				row = &rollupRow{Key: rowKey, Tags: tags}
+				rows[rowKey] = row
+				seenInstances[rowKey] = map[string]bool{}
+			}
+			row.NetworkInterfaces++
+			if networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil && !seenInstances[rowKey][aws.ToString(networkInterface.Attachment.InstanceId)] {
+				seenInstances[rowKey][aws.ToString(networkInterface.Attachment.InstanceId)] = true
+				row.Instances++
+			}
+			if isPublic(networkInterface) {
+				row.PublicIps++
+			}
+		}
+	}
+
+	// Sort the rows by key, keeping the rows with untagged values last
+	sorted := []rollupRow{}
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	untagged := func(row rollupRow) bool {
+		for _, value := range row.Tags {
+			if value == untaggedApp {
+				return true
+			}
+		}
+		return false
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if untagged(sorted[i]) != untagged(sorted[j]) {
+			return untagged(sorted[j])
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// rollupRecords returns the header and the rows of the rollup as table cells.
+//
+// With a single tag, its values are the first column. With several, a composite key column comes first, followed
+// by a column per tag.
+//
+// keys: The tag keys.
+// rows: The rollup rows.
+// [][]string: The header followed by a record per row.
+func rollupRecords(keys []string, rows []rollupRow) [][]string {
+	header := append([]string{}, keys...)
+	if len(keys) > 1 {
+		header = append([]string{"key"}, keys...)
+	}
+	records := [][]string{append(header, "network_interfaces", "instances", "public_ips")}
+	for _, row := range rows {
+		record := []string{}
+		if len(keys) > 1 {
+			record = append(record, row.Key)
+		}
+		for _, key := range keys {
+			record = append(record, row.Tags[key])
+		}
+		records = append(records, append(record, strconv.Itoa(row.NetworkInterfaces), strconv.Itoa(row.Instances), strconv.Itoa(row.PublicIps)))
+	}
+	return records
+}
+
+// printRollup prints the rollup as an aligned table.
+//
+// w: The writer to print to.
+// keys: The tag keys.
+// rows: The rollup rows.
+func printRollup(w io.Writer, keys []string, rows []rollupRow) {
+	records := rollupRecords(keys, rows)
+	widths := make([]int, len(records[0]))
+	for _, record := range records {
+		for i, cell := range record {
+			record[i] = displayText(cell)
+			widths[i] = max(widths[i], len(record[i]))
+		}
+	}
+	for _, record := range records {
+		cells := []string{}
+		for i, cell := range record {
+			cells = append(cells, fmt.Sprintf("%-*s", widths[i], cell))
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+}
+
+// writeRollupCSV writes the rollup as CSV.
+//
+// w: The writer to print to.
+// keys: The tag keys.
+// rows: The rollup rows.
+// error: If the CSV cannot be written.
+func writeRollupCSV(w io.Writer, keys []string, rows []rollupRow) error {
+	return csv.NewWriter(w).WriteAll(rollupRecords(keys, rows))
+}
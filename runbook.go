@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// Kinds of runbook actions, in the order they are printed.
+const (
+	runbookDeleteInterface = "delete-network-interface"
+	runbookChangeGroups    = "change-interface-groups"
+	runbookDetachInterface = "detach-network-interface"
+	runbookTerminate       = "terminate-instance"
+	runbookLambda          = "update-lambda-vpc-config"
+	runbookEndpoint        = "delete-vpc-endpoint"
+	runbookLoadBalancer    = "delete-load-balancer"
+	runbookNatGateway      = "delete-nat-gateway"
+	runbookManual          = "manual"
+)
+
+// runbookKinds is the order in which the kinds of actions are printed.
+var runbookKinds = []string{
+	runbookDeleteInterface,
+	runbookChangeGroups,
+	runbookDetachInterface,
+	runbookTerminate,
+	runbookLambda,
+	runbookEndpoint,
+	runbookLoadBalancer,
+	runbookNatGateway,
+	runbookManual,
+}
+
+// runbookAction is the next step to free a security group from a network interface.
+type runbookAction struct {
+	Kind               string `json:"kind"`
+	Region             string `json:"region"`
+	SecurityGroup      string `json:"security_group"`
+	NetworkInterfaceId string `json:"network_interface_id"`
+	ManagedBy          string `json:"managed_by"`
+	ResourceId         string `json:"resource_id,omitempty"`
+	Action             string `json:"action"`
+	// Command is the AWS CLI command performing the action, when the resource can be identified.
+	Command      string `json:"command,omitempty"`
+	Irreversible bool   `json:"irreversible"`
+}
+
+// getRunbook prescribes, per network interface, the action that frees the security group from it.
+//
+// results: The network interfaces found per security group.
+// []runbookAction: The actions, by kind in runbookKinds order, then by group and interface ID.
+func getRunbook(results []groupResult) []runbookAction {
+	actions := []runbookAction{}
+	index := newGroupIndex(results)
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			actions = append(actions, newRunbookAction(result, networkInterface, index))
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool {
+		a, b := runbookRank(actions[i].Kind), runbookRank(actions[j].Kind)
+		if a != b {
+			return a < b
+		}
+		if actions[i].SecurityGroup != actions[j].SecurityGroup {
+			return actions[i].SecurityGroup < actions[j].SecurityGroup
+		}
+		return actions[i].NetworkInterfaceId < actions[j].NetworkInterfaceId
+	})
+	return actions
+}
+
+// newRunbookAction prescribes the action freeing the security group of the result from the network interface.
+//
+// result: The security group.
+// networkInterface: The network interface using it.
+// index: The security groups of the interfaces of the run.
+// runbookAction: The action.
+func newRunbookAction(result groupResult, networkInterface types.NetworkInterface, index *groupIndex) runbookAction {
+	classification := enilookup.Classify(networkInterface)
+	networkInterfaceId := aws.ToString(networkInterface.NetworkInterfaceId)
+	action := runbookAction{
+		Region:             result.Region,
+		SecurityGroup:      result.Selector.Key(),
+		NetworkInterfaceId: networkInterfaceId,
+		ManagedBy:          string(classification.ManagedBy),
+		ResourceId:         classification.ResourceId,
+	}
+	region := " --region " + result.Region
+	remaining := remainingGroupIds(result.Selector, networkInterfaceId, index)
+
+	switch {
+	case networkInterface.Status == types.NetworkInterfaceStatusAvailable && !aws.ToBool(networkInterface.RequesterManaged):
+		action.Kind = runbookDeleteInterface
+		action.Action = "Delete the unattached interface"
+		action.Command = "aws ec2 delete-network-interface --network-interface-id " + networkInterfaceId + region
+		action.Irreversible = true
+	case classification.ManagedBy == enilookup.ManagedByEC2 && len(remaining) > 0:
+		action.Kind = runbookChangeGroups
+		action.Action = "Remove the security group from the instance interface, keeping its other groups"
+		action.Command = "aws ec2 modify-network-interface-attribute --network-interface-id " + networkInterfaceId + " --groups " + strings.Join(remaining, " ") + region
+	case classification.ManagedBy == enilookup.ManagedByEC2 && aws.ToInt32(networkInterface.Attachment.DeviceIndex) > 0:
+		action.Kind = runbookDetachInterface
+		action.Action = "Detach the secondary interface, its only group is this one"
+		action.Command = "aws ec2 detach-network-interface --attachment-id " + aws.ToString(networkInterface.Attachment.AttachmentId) + region
+	case classification.ManagedBy == enilookup.ManagedByEC2:
+		action.Kind = runbookTerminate
+		action.Action = "Terminate the instance, or attach another security group to its primary interface"
+		action.Command = "aws ec2 terminate-instances --instance-ids " + classification.ResourceId + region
+		action.Irreversible = true
+	case classification.ManagedBy == enilookup.ManagedByLambda:
+		action.Kind = runbookLambda
+		action.Action = "Remove the security group from the function's VPC configuration; list all its subnets, AWS releases the interface afterwards"
+		groups := strings.Join(remaining, ",")
+		if groups == "" {
+			action.Action = "Detach the function from the VPC, this is its only security group; AWS releases the interface afterwards"
+		}
+		action.Command = "aws lambda update-function-configuration --function-name " + classification.ResourceId + " --vpc-config SubnetIds=" + aws.ToString(networkInterface.SubnetId) + ",SecurityGroupIds=" + groups + region
+	case classification.ManagedBy == enilookup.ManagedByEndpoint && classification.ResourceId != "":
+		action.Kind = runbookEndpoint
+		action.Action = "Delete the VPC endpoint"
+		action.Command = "aws ec2 delete-vpc-endpoints --vpc-endpoint-ids " + classification.ResourceId + region
+		action.Irreversible = true
+	case classification.ManagedBy == enilookup.ManagedByELB && classification.ResourceId != "":
+		action.Kind = runbookLoadBalancer
+		action.Action = "Deregister the targets and delete the load balancer"
+		if strings.HasPrefix(aws.ToString(networkInterface.Description), "ELB app/") || strings.HasPrefix(aws.ToString(networkInterface.Description), "ELB net/") {
+			action.Command = "aws elbv2 delete-load-balancer --load-balancer-arn $(aws elbv2 describe-load-balancers --names " + classification.ResourceId + " --query 'LoadBalancers[0].LoadBalancerArn' --output text" + region + ")" + region
+		} else {
+			action.Command = "aws elb delete-load-balancer --load-balancer-name " + classification.ResourceId + region
+		}
+		action.Irreversible = true
+	case classification.ManagedBy == enilookup.ManagedByNAT && classification.ResourceId != "":
+		action.Kind = runbookNatGateway
+		action.Action = "Delete the NAT gateway"
+		action.Command = "aws ec2 delete-nat-gateway --nat-gateway-id " + classification.ResourceId + region
+		action.Irreversible = true
+	default:
+		action.Kind = runbookManual
+		action.Action = fmt.Sprintf("Change the security groups of the owning %s resource, the interface is managed by it", classification.ManagedBy)
+	}
+	return action
+}
+
+// remainingGroupIds returns the IDs of the other security groups of the network interface.
+//
+// selector: The security group to free.
+// networkInterfaceId: The ID of the network interface.
+// index: The security groups of the interfaces of the run.
+// []string: The IDs of the groups of the interface other than the selected one.
+func remainingGroupIds(selector enilookup.Selector, networkInterfaceId string, index *groupIndex) []string {
+	remaining := []string{}
+	_, selected := index.selected(selector)
+	for _, groupId := range index.groupsOf(networkInterfaceId) {
+		if selected[groupId] {
+			continue
+		}
+		remaining = append(remaining, groupId)
+	}
+	return remaining
+}
+
+// runbookRank returns the position of the kind in runbookKinds.
+//
+// kind: The kind of action.
+// int: The position.
+func runbookRank(kind string) int {
+	for i, k := range runbookKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(runbookKinds)
+}
+
+// printRunbook prints the actions grouped by kind, marking the irreversible ones.
+//
+// w: The writer to print to.
+// actions: The actions, nil when -runbook is not set.
+func printRunbook(w io.Writer, actions []runbookAction) {
+	if actions == nil {
+		return
+	}
+	fmt.Fprintf(w, "Runbook: %d actions\n", len(actions))
+	kind := ""
+	for _, action := range actions {
+		if action.Kind != kind {
+			kind = action.Kind
+			fmt.Fprintf(w, "\n%s:\n", kind)
+		}
+		marker := ""
+		if action.Irreversible {
+			marker = " [IRREVERSIBLE]"
+		}
+		resource := ""
+		if action.ResourceId != "" {
+			resource = " " + displayText(action.ResourceId)
+		}
+		fmt.Fprintf(w, "  %s (%s%s) in %s (%s)%s: %s\n", action.NetworkInterfaceId, action.ManagedBy, resource, displayText(action.SecurityGroup), action.Region, marker, action.Action)
+		if action.Command != "" {
+			fmt.Fprintf(w, "    %s\n", displayText(action.Command))
+		}
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,19 @@
+package main
+
+// missingValue is printed in the text outputs in place of a value the SDK left nil.
+const missingValue = "-"
+
+// derefOr returns the string a pointer from the SDK points to, or a default when it is nil.
+//
+// The SDK leaves fields nil when the API omits them, which happens transiently, e.g. a GroupName while the group
+// is being deleted, so its pointers are never dereferenced directly.
+//
+// s: The pointer.
+// def: The value returned when s is nil.
+// string: The string s points to, or def.
+func derefOr(s *string, def string) string {
+	if s == nil {
+		return def
+	}
+	return *s
+}
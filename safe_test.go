@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// nilPointers sets pointer fields of a value to nil, each with probability p, and walks into the structs, slices,
+// maps and pointers left.
+//
+// v: The value, addressable.
+// rng: The source of randomness.
+// p: The probability of setting a pointer to nil, 1 for every pointer.
+func nilPointers(v reflect.Value, rng *rand.Rand, p float64) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if v.CanSet() && rng.Float64() < p {
+			v.SetZero()
+			return
+		}
+		nilPointers(v.Elem(), rng, p)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				nilPointers(v.Field(i), rng, p)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			nilPointers(v.Index(i), rng, p)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values are not addressable, so change a copy
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			nilPointers(value, rng, p)
+			v.SetMapIndex(key, value)
+		}
+	}
+}
+
+func TestDerefOr(t *testing.T) {
+	value := "sg-1"
+	if got := derefOr(&value, missingValue); got != "sg-1" {
+		t.Errorf("derefOr of a set pointer: %q", got)
+	}
+	if got := derefOr(nil, missingValue); got != missingValue {
+		t.Errorf("derefOr of nil: %q", got)
+	}
+}
+
+// TestRenderersNilPointers feeds runs whose SDK pointers are randomly nil through every renderer, as the API
+// omitting fields leaves them.
+func TestRenderersNilPointers(t *testing.T) {
+	for _, name := range rendererNames() {
+		t.Run(name, func(t *testing.T) {
+			for seed := int64(0); seed < 100; seed++ {
+				p := 0.3
+				if seed == 0 {
+					p = 1
+				}
+				run := testRun(false)
+				nilPointers(reflect.ValueOf(&run).Elem(), rand.New(rand.NewSource(seed)), p)
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("seed %d: panic: %v", seed, r)
+						}
+					}()
+					if len(renderTestRun(t, name, run)) == 0 {
+						t.Errorf("seed %d: no output", seed)
+					}
+				}()
+			}
+		})
+	}
+}
+
+func TestRenderNilOptionalFields(t *testing.T) {
+	run := testRun(false)
+	run.Results = run.Results[:1]
+	run.Results[0].NetworkInterfaces = []types.NetworkInterface{{Groups: []types.GroupIdentifier{{}}, TagSet: []types.Tag{{}}}}
+	run.Results[0].Found, run.Results[0].Permissions = 1, nil
+
+	// Missing values are rendered as - in text
+	text := string(renderTestRun(t, "text", run))
+	if !strings.Contains(text, "NetworkInterface ID: "+missingValue) {
+		t.Errorf("text output without the missing interface ID shown as %s:\n%s", missingValue, text)
+	}
+
+	// And as null in JSON
+	var report struct {
+		Groups []struct {
+			NetworkInterfaces []map[string]any `json:"network_interfaces"`
+		} `json:"groups"`
+	}
+	if err := json.Unmarshal(renderTestRun(t, "json", run), &report); err != nil {
+		t.Fatal(err)
+	}
+	networkInterface := report.Groups[0].NetworkInterfaces[0]
+	for _, field := range []string{"network_interface_id", "availability_zone", "subnet_id", "vpc_id", "private_ip_address", "description"} {
+		if value, ok := networkInterface[field]; !ok || value != nil {
+			t.Errorf("%s is %v, want null", field, value)
+		}
+	}
+
+	// Nothing dereferenced prints as a nil pointer would
+	for _, name := range rendererNames() {
+		if output := renderTestRun(t, name, run); bytes.Contains(output, []byte("<nil>")) {
+			t.Errorf("%s prints a nil pointer:\n%s", name, output)
+		}
+	}
+}
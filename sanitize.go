@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// sanitizeDisplay escapes the control characters of the free-text fields of the human readable outputs. It is set
+// when stdout is a terminal, unless -no-sanitize is given.
+//
+// Structured output (JSON, CSV, graph-json and config items) is written byte for byte regardless.
+var sanitizeDisplay = false
+
+// sanitizeStderr escapes the control characters of the warnings and notices printed on stderr. It is set when
+// stderr is a terminal, unless -no-sanitize is given, whatever stdout is redirected to.
+var sanitizeStderr = false
+
+// displayText renders a free-text field coming from AWS, such as a description, a tag value or a group name, for
+// humans.
+//
+// With sanitizeDisplay, the C0 and C1 control characters, DEL, the bidirectional formatting characters and the
+// bytes of invalid UTF-8 are escaped as \xNN or \uNNNN, so a field cannot move the cursor, clear the screen or
+// reorder the text around it. Backslashes are kept as is.
+//
+// s: The field.
+// string: The field as printed.
+func displayText(s string) string {
+	return escapeText(s, sanitizeDisplay)
+}
+
+// stderrText renders a line printed on stderr, escaped as displayText escapes a field when sanitizeStderr is set.
+//
+// s: The line.
+// string: The line as printed.
+func stderrText(s string) string {
+	return escapeText(s, sanitizeStderr)
+}
+
+// escapeText escapes the characters unsafeRune reports.
+//
+// s: The text.
+// sanitize: Whether to escape, s is returned as is otherwise.
+// string: The text as printed.
+func escapeText(s string, sanitize bool) string {
+	if !sanitize || !strings.ContainsFunc(s, unsafeRune) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1, r < 0x80 && unsafeRune(r):
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		case unsafeRune(r):
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// unsafeRune reports whether a character can alter the terminal or the display of the text around it.
+//
+// r: The character. utf8.RuneError stands for a byte of invalid UTF-8.
+// bool: Whether displayText escapes it.
+func unsafeRune(r rune) bool {
+	switch {
+	case r < 0x20, r == 0x7f, r >= 0x80 && r <= 0x9f:
+		// C0 controls, tab and newline included as the fields are printed on a line, DEL and C1 controls
+		return true
+	case r == 0x061c, r == 0x200e, r == 0x200f, r >= 0x202a && r <= 0x202e, r >= 0x2066 && r <= 0x2069:
+		// Bidirectional marks, embeddings, overrides and isolates
+		return true
+	}
+	return r == utf8.RuneError
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// hostileText clears the screen, returns to the start of the line and reverses the rest of it.
+const hostileText = "evil\x1b[2J\rok\u202egnp.exe"
+
+// escapedHostileText is hostileText as printed on a terminal.
+const escapedHostileText = `evil\x1b[2J\x0dok\u202egnp.exe`
+
+// setSanitizeDisplay sets sanitizeDisplay for the test, restoring it at its end.
+//
+// t: The test.
+// sanitize: The value.
+func setSanitizeDisplay(t *testing.T, sanitize bool) {
+	t.Helper()
+	saved := sanitizeDisplay
+	sanitizeDisplay = sanitize
+	t.Cleanup(func() { sanitizeDisplay = saved })
+}
+
+// setSanitizeStderr sets sanitizeStderr for the test, restoring it at its end.
+//
+// t: The test.
+// sanitize: The value.
+func setSanitizeStderr(t *testing.T, sanitize bool) {
+	t.Helper()
+	saved := sanitizeStderr
+	sanitizeStderr = sanitize
+	t.Cleanup(func() { sanitizeStderr = saved })
+}
+
+// captureStderr returns what a function prints to stderr.
+//
+// t: The test.
+// f: The function.
+// string: The output.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stderr
+	os.Stderr = w
+	f()
+	os.Stderr = saved
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// hostileRun returns the test run with hostileText in the group name, the instance Name tag, a description and
+// the env tag values.
+//
+// scanResult: The run.
+func hostileRun() scanResult {
+	run := testRun(false)
+	for i := range run.Results {
+		run.Results[i].Selector.GroupName = hostileText
+		run.Results[i].Selector.Input = hostileText
+		for j := range run.Results[i].NetworkInterfaces {
+			networkInterface := &run.Results[i].NetworkInterfaces[j]
+			networkInterface.Description = aws.String(hostileText)
+			tags := []types.Tag{}
+			for _, tag := range networkInterface.TagSet {
+				if aws.ToString(tag.Key) == "env" {
+					tag.Value = aws.String(hostileText)
+				}
+				tags = append(tags, tag)
+			}
+			networkInterface.TagSet = tags
+		}
+	}
+	run.Instances["i-0aaa"] = testInstance("i-0aaa", hostileText)
+	return run
+}
+
+// renderHostileRun renders hostileRun with a renderer.
+//
+// t: The test.
+// name: The renderer.
+// opts: The options, the output set from name.
+// string: The output.
+func renderHostileRun(t *testing.T, name string, opts renderOptions) string {
+	t.Helper()
+	opts.Output = name
+	var b bytes.Buffer
+	if err := renderers[name].Render(&b, hostileRun(), opts); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return b.String()
+}
+
+func TestDisplayText(t *testing.T) {
+	setSanitizeDisplay(t, true)
+	for value, want := range map[string]string{
+		"web":                    "web",
+		`C:\path\to`:             `C:\path\to`,
+		"café 日本語 😀":             "café 日本語 😀",
+		hostileText:              escapedHostileText,
+		"line\nbreak\ttab":       `line\x0abreak\x09tab`,
+		"del\x7f":                `del\x7f`,
+		"c1\u009bcsi":            `c1\u009bcsi`,
+		"invalid\xff\xfeutf8":    `invalid\xff\xfeutf8`,
+		"\u2066isolate\u2069":    `\u2066isolate\u2069`,
+		"\u200fmark\u061c":       `\u200fmark\u061c`,
+		"\u202aembed\u202c ok":   `\u202aembed\u202c ok`,
+		"zero\u200bwidth joiner": "zero\u200bwidth joiner",
+	} {
+		if got := displayText(value); got != want {
+			t.Errorf("displayText(%q) = %q, want %q", value, got, want)
+		}
+	}
+
+	setSanitizeDisplay(t, false)
+	if got := displayText(hostileText); got != hostileText {
+		t.Errorf("with -no-sanitize, displayText(%q) = %q", hostileText, got)
+	}
+}
+
+// TestRenderersSanitize feeds control characters through the text report, its narrative, the markdown table and the
+// -rollup-tag table and the board, which must escape them on a terminal and print them as is with -no-sanitize.
+func TestRenderersSanitize(t *testing.T) {
+	outputs := []struct {
+		name string
+		opts renderOptions
+	}{
+		{"text", renderOptions{Narrative: true}},
+		{"markdown", renderOptions{}},
+		{"text", renderOptions{RollupTags: []string{"env"}}},
+		{"board", renderOptions{Board: newBoardState(false)}},
+	}
+	for _, output := range outputs {
+		setSanitizeDisplay(t, true)
+		out := renderHostileRun(t, output.name, output.opts)
+		if strings.ContainsAny(out, "\x1b\r\u202e") {
+			t.Errorf("%s %+v prints raw control characters:\n%q", output.name, output.opts, out)
+		}
+		if !strings.Contains(out, escapedHostileText) {
+			t.Errorf("%s %+v lacks the escaped text %s:\n%s", output.name, output.opts, escapedHostileText, out)
+		}
+
+		setSanitizeDisplay(t, false)
+		if out := renderHostileRun(t, output.name, output.opts); !strings.Contains(out, hostileText) {
+			t.Errorf("%s %+v with -no-sanitize lacks the raw text:\n%q", output.name, output.opts, out)
+		}
+	}
+}
+
+// TestStructuredOutputsNotSanitized checks that JSON and CSV keep the fields byte for byte on a terminal.
+func TestStructuredOutputsNotSanitized(t *testing.T) {
+	setSanitizeDisplay(t, true)
+
+	var decoded report
+	if err := json.Unmarshal([]byte(renderHostileRun(t, "json", renderOptions{})), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Groups) == 0 || len(decoded.Groups[0].NetworkInterfaces) == 0 {
+		t.Fatalf("JSON groups %+v", decoded.Groups)
+	}
+	if group := decoded.Groups[0]; group.SecurityGroupName != hostileText || aws.ToString(group.NetworkInterfaces[0].Description) != hostileText {
+		t.Errorf("JSON group name %q, description %q, want %q", group.SecurityGroupName, aws.ToString(group.NetworkInterfaces[0].Description), hostileText)
+	}
+	if out := renderHostileRun(t, "csv", renderOptions{RollupTags: []string{"env"}}); !strings.Contains(out, hostileText) {
+		t.Errorf("CSV lacks the raw tag value:\n%q", out)
+	}
+}
+
+// TestWarningsSanitize checks that the warnings on stderr are escaped from the terminal check of stderr, not of
+// stdout, while the warnings of the JSON output keep the raw text.
+func TestWarningsSanitize(t *testing.T) {
+	flushWarnings(io.Discard)
+	duplicate := runWarning{Code: warningDuplicateSection, Subject: "section left out", Region: "eu-west-1", Group: hostileText, Message: hostileText + " (eu-west-1) selects security groups already reported under web"}
+
+	// stdout redirected to a file, stderr on a terminal
+	setSanitizeDisplay(t, false)
+	setSanitizeStderr(t, true)
+	out := captureStderr(t, func() {
+		warn(duplicate)
+		warnOnce("sanitize-test", "cannot list the availability zones of %s", hostileText)
+	})
+	if strings.ContainsAny(out, "\x1b\r\u202e") || strings.Count(out, escapedHostileText) != 2 {
+		t.Errorf("stderr on a terminal:\n%q", out)
+	}
+	if warnings := flushWarnings(io.Discard); len(warnings) != 1 || warnings[0].Message != duplicate.Message {
+		t.Errorf("recorded warnings %+v, want the raw message", warnings)
+	}
+
+	// stdout on a terminal, stderr redirected to a file
+	setSanitizeDisplay(t, true)
+	setSanitizeStderr(t, false)
+	if out := captureStderr(t, func() { warn(duplicate) }); !strings.Contains(out, hostileText) {
+		t.Errorf("stderr redirected lacks the raw text:\n%q", out)
+	}
+	flushWarnings(io.Discard)
+}
+
+// TestStderrLinesSanitize sends the hostile text through the -expect-env warning and the notices of the group
+// resolution, which print AWS tag values and group names on stderr.
+func TestStderrLinesSanitize(t *testing.T) {
+	setSanitizeDisplay(t, false)
+	mismatches := []envMismatch{{Region: "eu-west-1", GroupId: "sg-1", Value: hostileText}}
+	notice := hostileText + " matches 2 security groups"
+	for _, sanitize := range []bool{true, false} {
+		setSanitizeStderr(t, sanitize)
+		var b bytes.Buffer
+		printEnvMismatches(&b, "env=prod", mismatches, "env")
+		printNotice(&b, notice)
+		want := "warning: -expect-env env=prod: sg-1 (eu-west-1) is tagged env=" + hostileText + "\nnotice: " + notice + "\n"
+		if sanitize {
+			want = strings.ReplaceAll(want, hostileText, escapedHostileText)
+		}
+		if b.String() != want {
+			t.Errorf("sanitize %v: stderr\n%q\nwant\n%q", sanitize, b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// scanOptions controls what scanRegion looks up besides the network interfaces.
+type scanOptions struct {
+	// MatchOn is the attribute the input names are matched against.
+	MatchOn enilookup.MatchOn
+	// ResolveInstances looks up the attached instances.
+	ResolveInstances bool
+	// Status keeps the interfaces with this status, empty keeps them all.
+	Status string
+	// InstanceId keeps the interfaces attached to this instance, empty keeps them all.
+	InstanceId string
+	// PublicOnly keeps the interfaces with a public IP and looks up the ingress rules exposing them.
+	PublicOnly bool
+	// ShowPermissions looks up the permissions granted on the interfaces.
+	ShowPermissions bool
+	// HasPermissionsOnly keeps the interfaces that carry permissions.
+	HasPermissionsOnly bool
+	// Where keeps the interfaces satisfying a -where expression, nil keeps them all.
+	Where *whereFilter
+	// History records the interfaces seen across runs, nil records nothing.
+	History *interfaceHistory
+	// NewSince keeps the interfaces first seen within this duration, 0 keeps them all.
+	NewSince time.Duration
+	// Churn compares the interfaces with the previous snapshot of the same query, stored for ChurnAccount.
+	Churn        bool
+	ChurnAccount string
+	// Stats prints the statistics of the run to stderr.
+	Stats bool
+	// Usage samples the resource usage of the run, with Stats. May be nil.
+	Usage *usageStats
+	// IpCapacity compares the IP usage of the resolved instances with the InstanceTypeLimits,
+	// flagging those at or above WarnAt percent.
+	IpCapacity         bool
+	InstanceTypeLimits map[string]instanceTypeLimits
+	WarnAt             float64
+	// AgeBuckets are the upper bounds of the age buckets counted per group, nil counts none.
+	AgeBuckets []time.Duration
+	// MaxPerGroup and MaxResults cap the interfaces listed per group and in total, 0 for no cap.
+	MaxPerGroup int
+	MaxResults  int
+	// EffectiveRules consolidates the ingress rules of every group on every interface of the resolved instances.
+	EffectiveRules bool
+	// EipAudit looks up the Elastic IPs associated with the interfaces that serve no traffic.
+	EipAudit bool
+	// OnGroup, when set, is called with every security group as soon as its lookup completes, after the other
+	// lookups, the history and the client-side filters were applied to it alone.
+	OnGroup func(result groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting)
+	// Budget stops the run before the next group once -budget-duration or -budget-api-calls is used up. May be nil.
+	Budget *runBudget
+	// Sts decodes the authorization messages of the groups whose lookup was denied. May be nil.
+	Sts *sts.Client
+	// GroupGate asks for confirmation before looking up more groups than -max-groups. May be nil.
+	GroupGate *groupGate
+	// EndpointMode is the endpoint variant the clients call, the regions lacking it are reported as failed when
+	// several regions are scanned.
+	EndpointMode endpointMode
+	// Explain prints the resolved order of the selectors of every region to stderr.
+	Explain bool
+	// Resume skips the security groups completed by a previous run and records the completed ones. May be nil.
+	Resume *resumeState
+	// Incremental serves the details of the security groups whose interface count is unchanged from the store.
+	// May be nil.
+	Incremental *incrementalStore
+}
+
+// scanResult holds everything a run found across regions.
+type scanResult struct {
+	Results       []groupResult
+	Instances     map[string]types.Instance
+	FailedRegions []regionFailure
+	// Sightings are when the interfaces were first and last seen keyed by interface ID, set with -history-file.
+	Sightings map[string]interfaceSighting
+	// Verification compares the run with a previous pass, set with -verify.
+	Verification *verification
+	// IpCapacity is the IP usage of the resolved instances, set with -ip-capacity.
+	IpCapacity []instanceCapacity
+	// EffectiveRules are the consolidated allow-lists of the resolved instances keyed by instance ID, set with
+	// -effective-rules.
+	EffectiveRules map[string]instanceRules
+	// IdleEips are the Elastic IPs of the interfaces serving no traffic, set with -eip-audit.
+	IdleEips []idleEip
+	// Incomplete is why the run stopped before looking up every security group, empty when it completed.
+	Incomplete string
+	// ScannedAt is when the run started, nil with -no-timestamps.
+	ScannedAt *time.Time
+	// Warnings are the warnings raised by the run, every occurrence included.
+	Warnings []runWarning
+}
+
+// scan looks up the network interfaces of the security groups in every region.
+//
+// A region that starts returning AuthFailure, or that has no endpoint of opts.EndpointMode when several regions
+// are scanned, is recorded as failed and the other regions are still scanned.
+// The security groups are resolved in every region before any network interface is looked up, so the run can be
+// stopped by opts.GroupGate when it selects too many.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// regions: The regions to scan.
+// securityGroupNames: The security group names and IDs.
+// progress: The progress reporter.
+// opts: What to look up besides the network interfaces.
+// scanResult: What the run found. When the run fails after the lookups started, the groups looked up before the
+// failure, with Incomplete set.
+// error: If an API call fails for another reason.
+func scan(ctx context.Context, cfg aws.Config, regions []string, securityGroupNames []string, progress *progressReporter, opts scanOptions) (scanResult, error) {
+	started := time.Now()
+	stopSampling := func() {}
+	if opts.Stats && opts.Usage != nil {
+		stopSampling = opts.Usage.sample(ctx, usageSampleInterval)
+		defer stopSampling()
+	}
+	stats := &enilookup.Stats{}
+	ctx = enilookup.WithStats(ctx, stats)
+
+	run := scanResult{
+		Results:       []groupResult{},
+		Instances:     map[string]types.Instance{},
+		FailedRegions: []regionFailure{},
+		Warnings:      []runWarning{},
+	}
+	if opts.IpCapacity {
+		run.IpCapacity = []instanceCapacity{}
+	}
+	if opts.EffectiveRules {
+		run.EffectiveRules = map[string]instanceRules{}
+	}
+	if opts.EipAudit {
+		run.IdleEips = []idleEip{}
+	}
+	if opts.History != nil {
+		run.Sightings = map[string]interfaceSighting{}
+	}
+	now := time.Now().UTC()
+	pipeline := newFilterPipeline(opts, now)
+	limiter := newResultLimiter(opts.MaxPerGroup, opts.MaxResults)
+	if onGroup := opts.OnGroup; onGroup != nil {
+		// The streamed groups are counted and capped as they complete
+		opts.OnGroup = func(result groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting) {
+			if opts.AgeBuckets != nil {
+				result.AgeHistogram = getAgeHistogram(result, opts.AgeBuckets, now)
+			}
+			limiter.limit(&result)
+			onGroup(result, instances, sightings)
+		}
+	}
+
+	// Resolve the security group names and IDs in every region
+	scanned := []string{}
+	selectors := map[string][]enilookup.Selector{}
+	groups := 0
+	for _, region := range regions {
+		regionSelectors, err := enilookup.ResolveWith(ctx, newEC2Client(cfg, region), securityGroupNames, enilookup.ResolveOptions{
+			MatchOn: opts.MatchOn,
+			Notify: func(notice string) {
+				printNotice(os.Stderr, notice)
+			},
+		})
+		if isAuthFailure(err) {
+			run.FailedRegions = append(run.FailedRegions, regionFailure{Region: region, Error: err.Error()})
+			continue
+		}
+		if len(regions) > 1 && isEndpointFailure(err, opts.EndpointMode) {
+			// The region has no FIPS or dual-stack endpoint, report it without aborting the others
+			run.FailedRegions = append(run.FailedRegions, endpointFailure(region, err, opts.EndpointMode))
+			continue
+		}
+		if err != nil {
+			return scanResult{}, err
+		}
+		scanned = append(scanned, region)
+		selectors[region] = regionSelectors
+		if opts.Explain {
+			printResolvedOrder(os.Stderr, region, regionSelectors)
+		}
+		groups += len(regionSelectors)
+	}
+	calls := estimateAPICalls(groups, len(scanned), opts)
+	if err := opts.GroupGate.check(groups, calls); err != nil {
+		return scanResult{}, err
+	}
+	opts.Budget.printEstimate(calls)
+
+	// A failure stops the run, the groups looked up so far are still returned
+	var fatalErr error
+	for _, region := range scanned {
+		if opts.Budget.check() != "" {
+			break
+		}
+		ec2Client := newEC2Client(cfg, region)
+		regionRun, err := scanRegion(ctx, ec2Client, region, selectors[region], progress, pipeline, now, opts)
+		if isAuthFailure(err) {
+			// Access to the region was revoked during the scan, report it without aborting the others
+			run.FailedRegions = append(run.FailedRegions, regionFailure{Region: region, Error: err.Error()})
+			continue
+		}
+		run.Results = append(run.Results, regionRun.Results...)
+		maps.Copy(run.Instances, regionRun.Instances)
+		if run.Sightings != nil {
+			maps.Copy(run.Sightings, regionRun.Sightings)
+		}
+		if err != nil {
+			fatalErr = err
+			break
+		}
+
+		// Compare the IP usage of the instances with the limits of their types
+		if opts.IpCapacity {
+			capacities, err := getIpCapacity(ctx, ec2Client, region, regionRun.Instances, opts.InstanceTypeLimits, opts.WarnAt)
+			if err != nil {
+				fatalErr = err
+				break
+			}
+			run.IpCapacity = append(run.IpCapacity, capacities...)
+		}
+
+		// Consolidate the ingress rules of the instances
+		if opts.EffectiveRules {
+			effectiveRules, err := getEffectiveRules(ctx, ec2Client, regionRun.Instances)
+			if err != nil {
+				fatalErr = err
+				break
+			}
+			maps.Copy(run.EffectiveRules, effectiveRules)
+		}
+
+		// Find the Elastic IPs of the interfaces serving no traffic
+		if opts.EipAudit {
+			eips, err := getIdleEips(ctx, ec2Client, region, regionRun.Results, regionRun.Instances)
+			if err != nil {
+				fatalErr = err
+				break
+			}
+			run.IdleEips = append(run.IdleEips, eips...)
+		}
+	}
+
+	// Save the history once, with the interfaces of every group looked up
+	if err := opts.History.save(); err != nil && fatalErr == nil {
+		fatalErr = err
+	}
+
+	if fatalErr != nil {
+		run.Incomplete = fmt.Sprintf("stopped by an error, %d of %d security groups looked up", len(run.Results)-countFailed(run.Results), groups)
+	} else if reason := opts.Budget.check(); reason != "" && len(run.Results) < groups {
+		run.Incomplete = fmt.Sprintf("%s, %d of %d security groups looked up", reason, len(run.Results), groups)
+	}
+	if fatalErr == nil && run.Incomplete == "" && len(run.FailedRegions) == 0 && countDenied(run.Results) == 0 {
+		// Every unit completed, a later sweep with the same resume file queries them again
+		if err := opts.Resume.clear(); err != nil {
+			fatalErr = err
+		}
+	}
+	sortSections(run.Results, scanned)
+	sortResults(run.Results)
+	run.ScannedAt = &now
+	if opts.OnGroup == nil {
+		run.Results = collapseDuplicateSections(run.Results)
+		if opts.Churn {
+			getChurn(run.Results, opts.ChurnAccount)
+		}
+		if opts.AgeBuckets != nil {
+			for i := range run.Results {
+				run.Results[i].AgeHistogram = getAgeHistogram(run.Results[i], opts.AgeBuckets, now)
+			}
+		}
+		limiter.apply(run.Results)
+	}
+
+	totalInterfaces := 0
+	for _, result := range run.Results {
+		totalInterfaces += len(result.NetworkInterfaces)
+	}
+	progress.runCompleted(len(run.Results), totalInterfaces)
+	run.Warnings = flushWarnings(os.Stderr)
+	if opts.Stats {
+		fmt.Fprintf(os.Stderr, "Stats: %d groups, %d network interfaces, %d pages, %d duplicates collapsed, %s\n",
+			len(run.Results), totalInterfaces, stats.Pages.Load(), stats.Duplicates.Load(), time.Since(started).Round(time.Millisecond))
+		if summary := pipeline.summary(); summary != "" {
+			fmt.Fprintf(os.Stderr, "Filters: %s\n", summary)
+		}
+		if opts.Incremental != nil {
+			printRefreshStats(os.Stderr, run.Results)
+		}
+		if opts.Usage != nil {
+			stopSampling()
+			opts.Usage.printUsage(os.Stderr)
+		}
+	}
+
+	return run, fatalErr
+}
+
+// scanRegion looks up the network interfaces of the security groups in a single region.
+//
+// The interfaces are recorded in the history as returned by the API, then the client-side filters are applied
+// once every lookup they depend on is done. With opts.OnGroup, that happens group by group, as each completes.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region.
+// region: The region.
+// selectors: The security groups resolved in the region.
+// progress: The progress reporter.
+// pipeline: The client-side filters.
+// now: The time of the run.
+// opts: What to look up besides the network interfaces.
+// scanResult: The network interfaces found per security group, the resolved instances and the sightings. When a
+// lookup fails, those of the groups completed before the failure.
+// error: If an API call fails.
+func scanRegion(ctx context.Context, ec2Client *ec2.Client, region string, selectors []enilookup.Selector, progress *progressReporter, pipeline *filterPipeline, now time.Time, opts scanOptions) (scanResult, error) {
+	// For each security group, get the network interfaces that are attached to it
+	results := []groupResult{}
+	streamed := scanResult{Instances: map[string]types.Instance{}}
+	if opts.History != nil {
+		streamed.Sightings = map[string]interfaceSighting{}
+	}
+	emit := func(result groupResult) error {
+		results = append(results, result)
+		if opts.OnGroup == nil {
+			return nil
+		}
+		// Finish the group on its own and hand it over as soon as it completes
+		group, err := enrichResults(ctx, ec2Client, results[len(results)-1:], pipeline, now, opts)
+		if err != nil {
+			return err
+		}
+		sortResults(group.Results)
+		if opts.Churn {
+			getChurn(group.Results, opts.ChurnAccount)
+		}
+		opts.OnGroup(group.Results[0], group.Instances, group.Sightings)
+		streamed.Results = append(streamed.Results, group.Results[0])
+		maps.Copy(streamed.Instances, group.Instances)
+		if streamed.Sightings != nil {
+			maps.Copy(streamed.Sightings, group.Sightings)
+		}
+		return nil
+	}
+	partial := func(err error) (scanResult, error) {
+		if opts.OnGroup != nil {
+			return streamed, err
+		}
+		// Keep the groups completed before the failure, unless they cannot be enriched either
+		completed, enrichErr := enrichResults(ctx, ec2Client, results, pipeline, now, opts)
+		if enrichErr != nil {
+			return scanResult{}, err
+		}
+		return completed, err
+	}
+
+	// Count the interfaces of every group in a single pass, to serve the unchanged groups from the store
+	counts, err := opts.Incremental.count(ctx, ec2Client, selectors)
+	if err != nil {
+		return partial(err)
+	}
+	for _, selector := range selectors {
+		if result, ok := opts.Resume.lookup(region, selector.Key()); ok {
+			// Ordered by the selector of this run, the inputs may have changed since the stored one
+			result.Selector = selector
+			if err := emit(result); err != nil {
+				return partial(err)
+			}
+			continue
+		}
+		stored, refresh, ok := opts.Incremental.lookup(region, selector, counts)
+		if ok {
+			progress.groupCompleted(region, selector.Key(), len(stored.NetworkInterfaces))
+			if err := emit(stored); err != nil {
+				return partial(err)
+			}
+			continue
+		}
+
+		if opts.Budget.check() != "" {
+			// Out of budget, report the groups completed so far
+			break
+		}
+		groupCtx := progress.groupStarted(ctx, region, selector.Key())
+		networkInterfaces, err := getNetworkInterfacesForSecurityGroup(groupCtx, ec2Client, selector)
+		if errors.Is(err, enilookup.ErrAccessDenied) {
+			// Denied for this group only, e.g. by a condition on its VPC; not recorded, so a resumed run retries it
+			progress.groupCompleted(region, selector.Key(), 0)
+			denied := groupResult{Region: region, Selector: selector, NetworkInterfaces: []types.NetworkInterface{}, Denial: newGroupDenial(ctx, opts.Sts, err), Incremental: refresh}
+			if err := emit(denied); err != nil {
+				return partial(err)
+			}
+			continue
+		}
+		if err != nil {
+			// The failed group is reported with its error, after the groups completed before it
+			progress.groupCompleted(region, selector.Key(), 0)
+			failed := groupResult{Region: region, Selector: selector, NetworkInterfaces: []types.NetworkInterface{}, LookupError: err.Error(), Incremental: refresh}
+			if emitErr := emit(failed); emitErr != nil {
+				return partial(emitErr)
+			}
+			return partial(err)
+		}
+		progress.groupCompleted(region, selector.Key(), len(networkInterfaces))
+
+		result := groupResult{
+			Region:            region,
+			Selector:          selector,
+			NetworkInterfaces: networkInterfaces,
+			Incremental:       refresh,
+		}
+		if err := opts.Resume.record(result); err != nil {
+			return partial(err)
+		}
+		if err := emit(result); err != nil {
+			return partial(err)
+		}
+	}
+
+	if opts.OnGroup != nil {
+		return streamed, nil
+	}
+	return enrichResults(ctx, ec2Client, results, pipeline, now, opts)
+}
+
+// enrichResults looks up what the options select about the network interfaces of a region, records them in the
+// history and applies the client-side filters.
+//
+// The groups served from the incremental store keep their stored details, the details of the others are stored
+// before the filters are applied.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region.
+// results: The network interfaces found per security group, filtered and enriched in place.
+// pipeline: The client-side filters.
+// now: The time of the run.
+// opts: What to look up besides the network interfaces.
+// scanResult: The results, the resolved instances and the sightings.
+// error: If an API call fails.
+func enrichResults(ctx context.Context, ec2Client *ec2.Client, results []groupResult, pipeline *filterPipeline, now time.Time, opts scanOptions) (scanResult, error) {
+	// Only the groups not served from the store are looked up
+	refreshed := []groupResult{}
+	positions := []int{}
+	storedInstances := map[string]types.Instance{}
+	for i, result := range results {
+		if result.Incremental != nil && result.Incremental.Source == refreshSourceStore {
+			maps.Copy(storedInstances, result.Incremental.instances)
+			continue
+		}
+		refreshed = append(refreshed, result)
+		positions = append(positions, i)
+	}
+
+	// Look up the permissions granted on the interfaces
+	if opts.ShowPermissions {
+		if err := getPermissions(ctx, ec2Client, refreshed); err != nil {
+			return scanResult{}, err
+		}
+	}
+
+	// Look up the attached instances when requested
+	instances := map[string]types.Instance{}
+	denied := false
+	if opts.ResolveInstances {
+		var err error
+		instances, err = getInstancesForNetworkInterfaces(ctx, ec2Client, refreshed)
+		if errors.Is(enilookup.ClassifyError(err), enilookup.ErrAccessDenied) {
+			// The interfaces are still reported, without their instances
+			warnDenied("ec2:DescribeInstances", refreshed, "its instances are left unresolved", err)
+			instances, denied = map[string]types.Instance{}, true
+		} else if err != nil {
+			return scanResult{}, err
+		}
+	}
+	for j, i := range positions {
+		results[i] = refreshed[j]
+	}
+	if !denied {
+		// The details without their instances are not stored, the next run looks them up again
+		opts.Incremental.store(refreshed, instances)
+	}
+	maps.Copy(instances, storedInstances)
+
+	// Record the interfaces in the history
+	sightings := opts.History.observe(results, now)
+
+	// Apply the client-side filters to the enriched interfaces
+	eliminations, err := pipeline.apply(results, sightings)
+	if err != nil {
+		return scanResult{}, err
+	}
+	for _, elimination := range eliminations {
+		warn(runWarning{
+			Code:    warningAllFiltered,
+			Subject: elimination.Filter,
+			Region:  elimination.Region,
+			Group:   elimination.Group,
+			Message: fmt.Sprintf("%s (%s): the %d network interfaces found were all removed by client-side filters, %s removed the last %d",
+				elimination.Group, elimination.Region, elimination.Found, elimination.Filter, elimination.Removed),
+		})
+	}
+
+	// Look up the ingress rules exposing the public interfaces
+	if opts.PublicOnly {
+		if err := getExposures(ctx, ec2Client, results); err != nil {
+			return scanResult{}, err
+		}
+	}
+
+	return scanResult{Results: results, Instances: instances, Sightings: sightings}, nil
+}
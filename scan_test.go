@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestScanPartialOnFailure fails the lookup of the third of four groups and checks that the two groups completed
+// before the failure are still reported, marked incomplete, followed by the failed group with its error.
+func TestScanPartialOnFailure(t *testing.T) {
+	cfg, calls := lookupEC2Config(t, 0, 3, nil)
+	progress, _ := newProgressReporter("none", "")
+
+	run, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(4), progress, scanOptions{})
+	if err == nil || !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("scan error %v, want the injected failure", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls, want the run stopped at the failing one", calls.Load())
+	}
+	statuses := []string{}
+	for _, result := range run.Results {
+		statuses = append(statuses, result.Selector.GroupName+" "+groupStatus(result))
+	}
+	if got := strings.Join(statuses, ", "); got != "group-0 ok, group-1 ok, group-2 error" {
+		t.Fatalf("partial results %s, want group-0 and group-1 then the failed group-2", got)
+	}
+	if want := "stopped by an error, 2 of 4 security groups looked up"; run.Incomplete != want {
+		t.Errorf("incomplete %q, want %q", run.Incomplete, want)
+	}
+
+	text := string(renderTestRun(t, "text", run))
+	for _, line := range []string{"Security group name: group-0", "NetworkInterface ID: eni-group-0", "Security group name: group-1", "Lookup failed, the network interfaces could not be listed: " + run.Results[2].LookupError, "Incomplete: " + run.Incomplete} {
+		if !strings.Contains(text, line) {
+			t.Errorf("text output lacks %q:\n%s", line, text)
+		}
+	}
+	var decoded report
+	if err := json.Unmarshal(renderTestRun(t, "json", run), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Complete || decoded.Incomplete != run.Incomplete || len(decoded.Groups) != 3 {
+		t.Fatalf("JSON report complete %v, incomplete %q, %d groups", decoded.Complete, decoded.Incomplete, len(decoded.Groups))
+	}
+	if failed := decoded.Groups[2]; failed.Status != groupStatusError || !strings.Contains(failed.Error, "injected failure") {
+		t.Errorf("JSON failed group status %q, error %q", failed.Status, failed.Error)
+	}
+}
+
+func TestScanComplete(t *testing.T) {
+	cfg, _ := lookupEC2Config(t, 0, 0, nil)
+	progress, _ := newProgressReporter("none", "")
+
+	run, err := scan(context.Background(), cfg, []string{"eu-west-1"}, testGroupNames(3), progress, scanOptions{})
+	if err != nil || len(run.Results) != 3 || run.Incomplete != "" {
+		t.Errorf("%d groups, incomplete %q, error %v, want the 3 groups of a complete run", len(run.Results), run.Incomplete, err)
+	}
+}
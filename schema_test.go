@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// jsonSchema is the subset of JSON Schema the documents in schemas/ use.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Const                any                    `json:"const"`
+	Pattern              string                 `json:"pattern"`
+	Format               string                 `json:"format"`
+}
+
+// loadSchema reads a checked-in JSON Schema.
+//
+// file: The schema file.
+// *jsonSchema: The schema.
+// error: If the file cannot be read or parsed.
+func loadSchema(file string) (*jsonSchema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	schema := &jsonSchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return schema, nil
+}
+
+// validate checks a decoded JSON document against the schema.
+//
+// value: The document, as decoded into an any.
+// path: The path of the value in the document, for the problems.
+// []string: The problems, empty when the document is valid.
+func (s *jsonSchema) validate(value any, path string) []string {
+	problems := []string{}
+	fail := func(format string, args ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if s.Const != nil && fmt.Sprint(value) != fmt.Sprint(s.Const) {
+		fail("%v is not %v", value, s.Const)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			found = found || fmt.Sprint(allowed) == fmt.Sprint(value)
+		}
+		if !found {
+			fail("%v is not one of %v", value, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			fail("%T is not an object", value)
+			return problems
+		}
+		for _, key := range s.Required {
+			if _, ok := object[key]; !ok {
+				fail("missing required property %s", key)
+			}
+		}
+		keys := []string{}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := s.Properties[key]; ok {
+				problems = append(problems, property.validate(object[key], path+"."+key)...)
+				continue
+			}
+			switch string(s.AdditionalProperties) {
+			case "", "true":
+			case "false":
+				fail("unexpected property %s", key)
+			default:
+				additional := &jsonSchema{}
+				if err := json.Unmarshal(s.AdditionalProperties, additional); err != nil {
+					fail("invalid additionalProperties: %v", err)
+					continue
+				}
+				problems = append(problems, additional.validate(object[key], path+"."+key)...)
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			fail("%T is not an array", value)
+			return problems
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				problems = append(problems, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			fail("%T is not a string", value)
+			return problems
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(text) {
+			fail("%q does not match %s", text, s.Pattern)
+		}
+		if _, err := time.Parse(time.RFC3339, text); s.Format == "date-time" && err != nil {
+			fail("%q is not a date-time", text)
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			fail("%v is not an integer", value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("%v is not a number", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("%v is not a boolean", value)
+		}
+	}
+	return problems
+}
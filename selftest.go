@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// selftestStep is a harmless call made by the selftest mode.
+type selftestStep struct {
+	Name string
+	// Run makes the call and returns a short description of its result.
+	Run func(ctx context.Context) (string, error)
+}
+
+// runSelftest checks that the credentials, region and permissions are set up, without any security group names.
+//
+// Every step prints a PASS or FAIL line with its latency, and the error on failure. The later steps still run when
+// one fails so every missing permission is reported at once.
+//
+// ctx: The context used for the API calls.
+// cfg: The AWS configuration.
+// int: The exit code, exitError when any step fails.
+func runSelftest(ctx context.Context, cfg aws.Config) int {
+	ec2Client := newEC2Client(cfg, "")
+	steps := []selftestStep{
+		{Name: "sts:GetCallerIdentity", Run: func(ctx context.Context) (string, error) {
+			accountId, err := getAccountId(ctx, cfg)
+			return "account " + accountId, err
+		}},
+		{Name: "ec2:DescribeRegions", Run: func(ctx context.Context) (string, error) {
+			output, err := ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d regions enabled, using %s", len(output.Regions), cfg.Region), nil
+		}},
+		{Name: "ec2:DescribeSecurityGroups", Run: func(ctx context.Context) (string, error) {
+			output, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{MaxResults: aws.Int32(5)})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d security groups on the first page", len(output.SecurityGroups)), nil
+		}},
+		{Name: "ec2:DescribeNetworkInterfaces", Run: func(ctx context.Context) (string, error) {
+			output, err := ec2Client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{MaxResults: aws.Int32(5)})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d network interfaces on the first page", len(output.NetworkInterfaces)), nil
+		}},
+	}
+
+	code := 0
+	for _, step := range steps {
+		started := time.Now()
+		detail, err := step.Run(ctx)
+		latency := time.Since(started).Round(time.Millisecond)
+		if err != nil {
+			fmt.Printf("FAIL  %-30s %8s  %v\n", step.Name, latency, enilookup.ClassifyError(err))
+			code = exitError
+			continue
+		}
+		fmt.Printf("PASS  %-30s %8s  %s\n", step.Name, latency, detail)
+	}
+	return code
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// splitManifestName is the name of the manifest written next to the partition files.
+const splitManifestName = "manifest.json"
+
+// unsafeFileNameChars matches the characters replaced when a partition key is turned into a file name.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// splitManifest is the JSON document listing the partition files.
+type splitManifest struct {
+	SplitBy    string              `json:"split_by"`
+	Output     string              `json:"output"`
+	Partitions []splitPartitionRef `json:"partitions"`
+}
+
+// splitPartitionRef describes a partition file in the manifest.
+type splitPartitionRef struct {
+	Key               string `json:"key"`
+	File              string `json:"file"`
+	Groups            int    `json:"groups"`
+	NetworkInterfaces int    `json:"network_interfaces"`
+	Sha256            string `json:"sha256"`
+}
+
+// splitter writes the results of a run as one file per partition.
+type splitter struct {
+	dir         string
+	splitBy     string
+	ownerTagKey string
+	showEmpty   bool
+	// boards keeps the board state of every partition across -watch iterations.
+	boards map[string]*boardState
+}
+
+// newSplitter creates a splitter.
+//
+// dir: The directory the partition files are written to.
+// splitBy: The partition key: group, vpc or owner.
+// ownerTagKey: The tag key holding the owner, used to split by owner.
+// showEmpty: Whether partitions without network interfaces are written.
+// *splitter: The splitter.
+// error: If the partition key is not supported.
+func newSplitter(dir string, splitBy string, ownerTagKey string, showEmpty bool) (*splitter, error) {
+	switch splitBy {
+	case "group", "vpc", "owner":
+	default:
+		return nil, fmt.Errorf("supported values are: group, vpc, owner")
+	}
+	return &splitter{dir: dir, splitBy: splitBy, ownerTagKey: ownerTagKey, showEmpty: showEmpty, boards: map[string]*boardState{}}, nil
+}
+
+// write renders every partition of the run to its own file and writes the manifest.
+//
+// The partitions are sorted by key so the manifest can be diffed between runs.
+//
+// run: What the run found.
+// opts: How to render every partition.
+// error: If a file cannot be written.
+func (s *splitter) write(run scanResult, opts renderOptions) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	partitions := s.partition(run.Results, run.Instances)
+	keys := []string{}
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	manifest := splitManifest{SplitBy: s.splitBy, Output: opts.Output, Partitions: []splitPartitionRef{}}
+	usedNames := map[string]bool{}
+	for _, key := range keys {
+		results := partitions[key]
+		interfaces := 0
+		for _, result := range results {
+			interfaces += len(result.NetworkInterfaces)
+		}
+		if interfaces == 0 && !s.showEmpty {
+			continue
+		}
+
+		// Render the partition
+		if s.boards[key] == nil {
+			s.boards[key] = newBoardState(false)
+		}
+		partitionOpts := opts
+		partitionOpts.Board = s.boards[key]
+		var buffer bytes.Buffer
+		if err := render(&buffer, scanResult{Results: results, Instances: run.Instances, FailedRegions: run.FailedRegions, Sightings: run.Sightings, Warnings: run.Warnings}, partitionOpts); err != nil {
+			return err
+		}
+
+		name := partitionFileName(key, opts.Output, usedNames)
+		if err := os.WriteFile(filepath.Join(s.dir, name), buffer.Bytes(), 0o644); err != nil {
+			return err
+		}
+		checksum := sha256.Sum256(buffer.Bytes())
+		manifest.Partitions = append(manifest.Partitions, splitPartitionRef{
+			Key:               key,
+			File:              name,
+			Groups:            len(results),
+			NetworkInterfaces: interfaces,
+			Sha256:            hex.EncodeToString(checksum[:]),
+		})
+	}
+
+	var buffer bytes.Buffer
+	if err := writeJSON(&buffer, manifest); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, splitManifestName), buffer.Bytes(), 0o644)
+}
+
+// partition splits the results by the partition key.
+//
+// When splitting by vpc or owner, a security group whose interfaces have several keys appears
+// in every matching partition with the matching interfaces only.
+//
+// results: The network interfaces found per security group.
+// instances: The resolved instances keyed by instance ID.
+// map[string][]groupResult: The results of every partition keyed by partition key.
+func (s *splitter) partition(results []groupResult, instances map[string]types.Instance) map[string][]groupResult {
+	partitions := map[string][]groupResult{}
+	for _, result := range results {
+		if s.splitBy == "group" {
+			partitions[result.Selector.Key()] = append(partitions[result.Selector.Key()], result)
+			continue
+		}
+
+		byKey := map[string]groupResult{}
+		order := []string{}
+		for _, networkInterface := range result.NetworkInterfaces {
+			key := aws.ToString(networkInterface.VpcId)
+			if s.splitBy == "owner" {
+				key = appForNetworkInterface(networkInterface, s.ownerTagKey, instances)
+			}
+			partial, ok := byKey[key]
+			if !ok {
+				partial = result
+				partial.NetworkInterfaces = nil
+				order = append(order, key)
+			}
+			partial.NetworkInterfaces = append(partial.NetworkInterfaces, networkInterface)
+			byKey[key] = partial
+		}
+		for _, key := range order {
+			partitions[key] = append(partitions[key], byKey[key])
+		}
+	}
+	return partitions
+}
+
+// partitionFileName turns a partition key into a unique file name.
+//
+// key: The partition key.
+// output: The output format, selecting the extension.
+// usedNames: The file names already taken, updated with the returned name.
+// string: The file name.
+func partitionFileName(key string, output string, usedNames map[string]bool) string {
+	base := unsafeFileNameChars.ReplaceAllString(key, "_")
+	if base == "" || base == "." || base == ".." {
+		base = "_"
+	}
+	name := base + renderers[output].Extension()
+	for i := 2; usedNames[name] || name == splitManifestName; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, renderers[output].Extension())
+	}
+	usedNames[name] = true
+	return name
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// streamedGroup is an NDJSON line of -stream-groups: a security group, numbered by order of completion.
+type streamedGroup struct {
+	Type string `json:"type"`
+	// Completed is the position of the group in the order of completion, starting at 1.
+	Completed int `json:"completed"`
+	groupReport
+}
+
+// streamedSummary is the last NDJSON line of a -stream-groups run.
+type streamedSummary struct {
+	Type          string             `json:"type"`
+	CorrelationId string             `json:"correlation_id,omitempty"`
+	Groups        int                `json:"groups"`
+	FailedRegions []regionFailure    `json:"failed_regions"`
+	Complete      bool               `json:"complete"`
+	Incomplete    string             `json:"incomplete,omitempty"`
+	IpCapacity    []instanceCapacity `json:"ip_capacity,omitempty"`
+	IdleEips      []idleEip          `json:"idle_eips,omitempty"`
+	Actions       []runbookAction    `json:"actions,omitempty"`
+	Warnings      []runWarning       `json:"warnings"`
+}
+
+// groupStreamer prints every security group as soon as its lookup completes, for -stream-groups.
+type groupStreamer struct {
+	w            io.Writer
+	opts         renderOptions
+	noTimestamps bool
+	// onViolation is called instead of printing a group violating an invariant, nil when the checks are off.
+	onViolation func(err error)
+	// completed is the number of groups printed by the current run.
+	completed int
+	// err is the first error writing the output, which stops the printing and is returned by finish.
+	err error
+}
+
+// newGroupStreamer creates a streamer printing in the output format of the options.
+//
+// w: The writer to print to.
+// opts: How to render the groups, with the text, json or markdown output.
+// noTimestamps: Whether to leave every timestamp out, as -no-timestamps does.
+// onViolation: Called with the *invariantError of a group violating an invariant, which is then not printed. nil
+// prints every group unchecked, as -no-invariant-checks does.
+// *groupStreamer: The streamer.
+func newGroupStreamer(w io.Writer, opts renderOptions, noTimestamps bool, onViolation func(err error)) *groupStreamer {
+	return &groupStreamer{w: w, opts: opts, noTimestamps: noTimestamps, onViolation: onViolation}
+}
+
+// group prints a completed security group, labeled with its position in the order of completion.
+//
+// The group is checked on its own for the invariants of a section: the checks across sections need every group,
+// and the sections of a group passed both by name and by ID are not collapsed while streaming.
+//
+// result: The network interfaces found for the security group.
+// instances: The resolved instances keyed by instance ID.
+// sightings: When the interfaces were first and last seen keyed by interface ID. May be nil.
+func (s *groupStreamer) group(result groupResult, instances map[string]types.Instance, sightings map[string]interfaceSighting) {
+	if s.err != nil {
+		return
+	}
+	results := []groupResult{result}
+	if s.onViolation != nil {
+		if err := checkInvariants(scanResult{Results: results, Instances: instances, Sightings: sightings}, s.opts.ExcludeShared); err != nil {
+			s.onViolation(err)
+			return
+		}
+	}
+	s.completed++
+	if s.noTimestamps {
+		group := scanResult{Results: results, Sightings: sightings}
+		stripTimestamps(&group)
+		sightings = group.Sightings
+	}
+	switch s.opts.Output {
+	case "json":
+		group := newReport(results, instances, sightings, s.opts.ExcludeShared, nil).Groups[0]
+		s.writeLine(streamedGroup{Type: "group", Completed: s.completed, groupReport: group})
+	case "markdown":
+		w := &errWriter{w: s.w}
+		fmt.Fprintf(w, "<!-- completed %d, in order of completion -->\n", s.completed)
+		printMarkdownReport(w, results, instances, sightings, s.opts.ShowRegion, s.opts.PublicOnly)
+		s.err = w.err
+	default:
+		w := &errWriter{w: s.w}
+		fmt.Fprintf(w, "=== Completed %d (%s, in order of completion) ===\n", s.completed, result.Region)
+		printReport(w, results, instances, sightings, s.opts.ShowRegion, s.opts.ExcludeShared, s.opts.PublicOnly, s.opts.Narrative)
+		s.err = w.err
+	}
+}
+
+// finish prints what the run found besides the security groups and resets the numbering for the next run.
+//
+// run: What the run found.
+// error: The first error writing the output of the run, the groups included.
+func (s *groupStreamer) finish(run scanResult) error {
+	defer func() {
+		s.completed = 0
+		s.err = nil
+	}()
+	if s.err != nil {
+		return s.err
+	}
+	if s.opts.Output == "json" {
+		s.writeLine(streamedSummary{Type: "summary", CorrelationId: s.opts.CorrelationId, Groups: s.completed, FailedRegions: run.FailedRegions, Complete: run.Incomplete == "", Incomplete: run.Incomplete, IpCapacity: run.IpCapacity, IdleEips: run.IdleEips, Actions: s.runbook(run), Warnings: run.Warnings})
+	} else {
+		w := &errWriter{w: s.w}
+		printRegionFailures(w, run.FailedRegions)
+		printRunNotes(w, run, s.opts)
+		s.err = w.err
+	}
+	return s.err
+}
+
+// runbook returns the actions of the run, nil without -runbook.
+//
+// run: What the run found.
+// []runbookAction: The actions.
+func (s *groupStreamer) runbook(run scanResult) []runbookAction {
+	if !s.opts.Runbook {
+		return nil
+	}
+	return getRunbook(run.Results)
+}
+
+// writeLine writes a value as a single JSON line, keeping the error in s.err.
+//
+// v: The value.
+func (s *groupStreamer) writeLine(v any) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		_, err = fmt.Fprintf(s.w, "%s\n", data)
+	}
+	s.err = err
+}
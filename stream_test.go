@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGroupStreamer(t *testing.T) {
+	run := testRun(false)
+	var violations []error
+	onViolation := func(err error) { violations = append(violations, err) }
+
+	var out strings.Builder
+	s := newGroupStreamer(&out, renderOptions{Output: "text"}, true, onViolation)
+	for _, result := range run.Results {
+		s.group(result, run.Instances, nil)
+	}
+	if len(violations) != 0 {
+		t.Fatalf("violations: %v", violations)
+	}
+	for i, label := range []string{"=== Completed 1 (eu-west-1,", "=== Completed 2 (eu-west-1,", "=== Completed 3 (us-east-1,"} {
+		if strings.Count(out.String(), label) != 1 {
+			t.Errorf("section %d: no %q in\n%s", i+1, label, out.String())
+		}
+	}
+
+	// A group listing an interface twice is not printed
+	s.finish(run)
+	out.Reset()
+	broken := run.Results[0]
+	broken.NetworkInterfaces = append(broken.NetworkInterfaces, broken.NetworkInterfaces[0])
+	s.group(broken, run.Instances, nil)
+	var invariantErr *invariantError
+	if len(violations) != 1 || !errors.As(violations[0], &invariantErr) {
+		t.Fatalf("violations = %v, want an invariantError", violations)
+	}
+	if want := "is listed twice under web"; !strings.Contains(violations[0].Error(), want) {
+		t.Errorf("violation %q, want %q", violations[0], want)
+	}
+	if out.Len() != 0 {
+		t.Errorf("the group was printed:\n%s", out.String())
+	}
+
+	// Unchecked, as with -no-invariant-checks
+	s = newGroupStreamer(&out, renderOptions{Output: "text"}, true, nil)
+	s.group(broken, run.Instances, nil)
+	if !strings.Contains(out.String(), "=== Completed 1 (eu-west-1,") {
+		t.Errorf("unchecked group not printed:\n%s", out.String())
+	}
+}
+
+func TestGroupStreamerNDJSON(t *testing.T) {
+	run := testRun(false)
+	var out strings.Builder
+	s := newGroupStreamer(&out, renderOptions{Output: "json", CorrelationId: "job-1"}, true, func(err error) { t.Error(err) })
+	for _, result := range run.Results {
+		s.group(result, run.Instances, nil)
+	}
+	if err := s.finish(run); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(run.Results)+1 {
+		t.Fatalf("%d lines, want %d:\n%s", len(lines), len(run.Results)+1, out.String())
+	}
+	for i, line := range lines {
+		var document struct {
+			Type          string `json:"type"`
+			Completed     int    `json:"completed"`
+			Groups        int    `json:"groups"`
+			CorrelationId string `json:"correlation_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &document); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		switch {
+		case i < len(run.Results) && (document.Type != "group" || document.Completed != i+1):
+			t.Errorf("line %d: %s", i+1, line)
+		case i == len(run.Results) && (document.Type != "summary" || document.Groups != len(run.Results) || document.CorrelationId != "job-1"):
+			t.Errorf("summary: %s", line)
+		}
+	}
+}
+
+// TestGroupStreamerWriteError checks that a write error stops the streamed output and is returned by finish.
+func TestGroupStreamerWriteError(t *testing.T) {
+	run := testRun(false)
+	for _, output := range []string{"json", "markdown", "text"} {
+		s := newGroupStreamer(failingWriter{}, renderOptions{Output: output}, true, nil)
+		for _, result := range run.Results {
+			s.group(result, run.Instances, nil)
+		}
+		if s.completed != 1 {
+			t.Errorf("%s: %d groups printed after the write error, want 1", output, s.completed)
+		}
+		if err := s.finish(run); !errors.Is(err, errDiskFull) {
+			t.Errorf("%s: finish returned %v, want the write error", output, err)
+		}
+
+		// The next -watch iteration starts over
+		var out strings.Builder
+		s.w = &out
+		s.group(run.Results[0], run.Instances, nil)
+		if err := s.finish(run); err != nil || s.completed != 0 || out.Len() == 0 {
+			t.Errorf("%s: after the error, finish returned %v, printed %q", output, err, out.String())
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// noSecurityGroup is the group reported for the interfaces without security group, such as NAT gateway interfaces.
+const noSecurityGroup = "(no security group)"
+
+// teardownOrder is the order in which removing the owning resources typically unblocks a VPC deletion: endpoints
+// and NAT gateways first, then load balancers, instances and Lambda functions, whose interfaces AWS releases last.
+var teardownOrder = []enilookup.ManagedBy{
+	enilookup.ManagedByEndpoint,
+	enilookup.ManagedByNAT,
+	enilookup.ManagedByELB,
+	enilookup.ManagedByEC2,
+	enilookup.ManagedByLambda,
+	enilookup.ManagedByRDS,
+	enilookup.ManagedByEFS,
+	enilookup.ManagedByOther,
+}
+
+// teardownReport lists the network interfaces blocking the deletion of a VPC.
+type teardownReport struct {
+	VpcId               string            `json:"vpc_id"`
+	RemainingInterfaces int               `json:"remaining_interfaces"`
+	Groups              []teardownGroup   `json:"groups"`
+	Blockers            []teardownBlocker `json:"blockers"`
+}
+
+// teardownGroup is a security group of the VPC and the number of interfaces still using it.
+type teardownGroup struct {
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Remaining int    `json:"remaining"`
+}
+
+// teardownBlocker is a network interface left in the VPC.
+type teardownBlocker struct {
+	NetworkInterfaceId string   `json:"network_interface_id"`
+	Status             string   `json:"status"`
+	ManagedBy          string   `json:"managed_by"`
+	ResourceId         string   `json:"resource_id,omitempty"`
+	SubnetId           string   `json:"subnet_id"`
+	Description        string   `json:"description"`
+	Groups             []string `json:"groups"`
+}
+
+// getTeardownBlockers lists the security groups of the VPC and every network interface left in it.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region of the VPC.
+// vpcId: The VPC being torn down.
+// teardownReport: The groups sorted by ID, and the interfaces in teardownOrder, then by interface ID.
+// error: If an API call fails.
+func getTeardownBlockers(ctx context.Context, ec2Client *ec2.Client, vpcId string) (teardownReport, error) {
+	vpcFilter := []types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcId}}}
+	report := teardownReport{VpcId: vpcId, Groups: []teardownGroup{}, Blockers: []teardownBlocker{}}
+
+	// Enumerate the security groups of the VPC
+	groups := map[string]*teardownGroup{}
+	groupPaginator := ec2.NewDescribeSecurityGroupsPaginator(ec2Client, &ec2.DescribeSecurityGroupsInput{Filters: vpcFilter})
+	for groupPaginator.HasMorePages() {
+		describeSecurityGroupsOutput, err := groupPaginator.NextPage(ctx)
+		if err != nil {
+			return teardownReport{}, err
+		}
+		for _, securityGroup := range describeSecurityGroupsOutput.SecurityGroups {
+			groupId := aws.ToString(securityGroup.GroupId)
+			groups[groupId] = &teardownGroup{GroupId: groupId, GroupName: aws.ToString(securityGroup.GroupName)}
+		}
+	}
+
+	// Every interface left in the VPC blocks its deletion, with or without a security group
+	interfacePaginator := ec2.NewDescribeNetworkInterfacesPaginator(ec2Client, &ec2.DescribeNetworkInterfacesInput{Filters: vpcFilter})
+	for interfacePaginator.HasMorePages() {
+		describeNetworkInterfacesOutput, err := interfacePaginator.NextPage(ctx)
+		if err != nil {
+			return teardownReport{}, err
+		}
+		for _, networkInterface := range describeNetworkInterfacesOutput.NetworkInterfaces {
+			classification := enilookup.Classify(networkInterface)
+			blocker := teardownBlocker{
+				NetworkInterfaceId: aws.ToString(networkInterface.NetworkInterfaceId),
+				Status:             string(networkInterface.Status),
+				ManagedBy:          string(classification.ManagedBy),
+				ResourceId:         classification.ResourceId,
+				SubnetId:           aws.ToString(networkInterface.SubnetId),
+				Description:        aws.ToString(networkInterface.Description),
+				Groups:             []string{},
+			}
+			for _, group := range networkInterface.Groups {
+				groupId := aws.ToString(group.GroupId)
+				if groups[groupId] == nil {
+					// A group listed after the groups were enumerated
+					groups[groupId] = &teardownGroup{GroupId: groupId, GroupName: aws.ToString(group.GroupName)}
+				}
+				groups[groupId].Remaining++
+				blocker.Groups = append(blocker.Groups, groupId)
+			}
+			if len(blocker.Groups) == 0 {
+				blocker.Groups = append(blocker.Groups, noSecurityGroup)
+			}
+			sort.Strings(blocker.Groups)
+			report.Blockers = append(report.Blockers, blocker)
+		}
+	}
+	report.RemainingInterfaces = len(report.Blockers)
+
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].GroupId < report.Groups[j].GroupId })
+	sort.Slice(report.Blockers, func(i, j int) bool {
+		a, b := teardownRank(report.Blockers[i].ManagedBy), teardownRank(report.Blockers[j].ManagedBy)
+		if a != b {
+			return a < b
+		}
+		return report.Blockers[i].NetworkInterfaceId < report.Blockers[j].NetworkInterfaceId
+	})
+	return report, nil
+}
+
+// teardownRank returns the position of the resource kind in teardownOrder.
+//
+// managedBy: The kind of resource owning an interface.
+// int: The position, the kinds not in teardownOrder last.
+func teardownRank(managedBy string) int {
+	for i, kind := range teardownOrder {
+		if string(kind) == managedBy {
+			return i
+		}
+	}
+	return len(teardownOrder)
+}
+
+// printTeardownReport prints the interfaces blocking the deletion of the VPC, by resource kind in teardownOrder.
+//
+// w: The writer to print to.
+// report: The blockers.
+func printTeardownReport(w io.Writer, report teardownReport) {
+	fmt.Fprintf(w, "VPC %s: %d network interfaces remaining\n\n", report.VpcId, report.RemainingInterfaces)
+	managedBy := ""
+	for _, blocker := range report.Blockers {
+		if blocker.ManagedBy != managedBy {
+			managedBy = blocker.ManagedBy
+			fmt.Fprintf(w, "%s:\n", managedBy)
+		}
+		resource := ""
+		if blocker.ResourceId != "" {
+			resource = " " + displayText(blocker.ResourceId)
+		}
+		fmt.Fprintf(w, "  %s (%s, %s)%s: %v\n", blocker.NetworkInterfaceId, blocker.Status, blocker.SubnetId, resource, blocker.Groups)
+	}
+	if len(report.Blockers) > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Security groups:\n")
+	for _, group := range report.Groups {
+		fmt.Fprintf(w, "  %s (%s): %d remaining\n", group.GroupId, displayText(group.GroupName), group.Remaining)
+	}
+}
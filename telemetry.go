@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// telemetryTimeout bounds the telemetry request so it never delays the run noticeably.
+const telemetryTimeout = 2 * time.Second
+
+// exitClasses names the exit codes in the telemetry payload.
+var exitClasses = map[int]string{
+	0:                 "success",
+	exitError:         "error",
+	exitUsage:         "usage",
+	exitGroupNotFound: "group_not_found",
+	exitAccessDenied:  "access_denied",
+	exitThrottled:     "throttled",
+	exitRegionInvalid: "region_invalid",
+	exitUnstable:      "unstable",
+	exitEnvMismatch:   "env_mismatch",
+	exitInternal:      "internal",
+	exitViolation:     "violation",
+}
+
+// activeTelemetry is the telemetry of the run, nil unless -telemetry-endpoint or -telemetry-dry-run is set.
+var activeTelemetry *telemetry
+
+// telemetryPayload is the anonymous usage report sent at the end of a run.
+//
+// It only holds flag names, bucketed counts, the duration, the version and the exit class: never flag values,
+// ARNs, names, IPs or account IDs.
+type telemetryPayload struct {
+	Version           string   `json:"version"`
+	Flags             []string `json:"flags"`
+	Regions           string   `json:"regions"`
+	Groups            string   `json:"groups"`
+	NetworkInterfaces string   `json:"network_interfaces"`
+	DurationSeconds   int      `json:"duration_seconds"`
+	ExitClass         string   `json:"exit_class"`
+}
+
+// telemetry collects the usage of a run.
+type telemetry struct {
+	endpoint   string
+	dryRun     bool
+	started    time.Time
+	flags      []string
+	regions    int
+	groups     int
+	interfaces int
+}
+
+// newTelemetry creates the telemetry of the run.
+//
+// endpoint: The URL the payload is POSTed to, empty to never send it.
+// dryRun: Whether to print the payload to stderr instead of sending it.
+// fs: The parsed flags, whose names are reported.
+// *telemetry: The telemetry, nil when it is disabled.
+func newTelemetry(endpoint string, dryRun bool, fs *flag.FlagSet) *telemetry {
+	if endpoint == "" && !dryRun {
+		return nil
+	}
+	t := &telemetry{endpoint: endpoint, dryRun: dryRun, started: time.Now(), flags: []string{}}
+	fs.Visit(func(f *flag.Flag) {
+		t.flags = append(t.flags, f.Name)
+	})
+	sort.Strings(t.flags)
+	return t
+}
+
+// observe records the counts of a run.
+//
+// regions: The number of regions scanned.
+// run: What the run found.
+func (t *telemetry) observe(regions int, run scanResult) {
+	if t == nil {
+		return
+	}
+	t.regions = regions
+	t.groups = len(run.Results)
+	t.interfaces = 0
+	for _, result := range run.Results {
+		t.interfaces += len(result.NetworkInterfaces)
+	}
+}
+
+// payload builds the anonymous usage report.
+//
+// code: The exit code of the run.
+// telemetryPayload: The report.
+func (t *telemetry) payload(code int) telemetryPayload {
+	exitClass, ok := exitClasses[code]
+	if !ok {
+		exitClass = "other"
+	}
+	return telemetryPayload{
+		Version:           programVersion(),
+		Flags:             t.flags,
+		Regions:           countBucket(t.regions),
+		Groups:            countBucket(t.groups),
+		NetworkInterfaces: countBucket(t.interfaces),
+		DurationSeconds:   int(time.Since(t.started).Seconds()),
+		ExitClass:         exitClass,
+	}
+}
+
+// send reports the usage of the run, printing the payload with -telemetry-dry-run.
+//
+// Failing to send the payload is silently ignored.
+//
+// code: The exit code of the run.
+func (t *telemetry) send(code int) {
+	if t == nil {
+		return
+	}
+	data, err := json.Marshal(t.payload(code))
+	if err != nil {
+		return
+	}
+	if t.dryRun {
+		fmt.Fprintf(os.Stderr, "telemetry payload: %s\n", data)
+		return
+	}
+
+	client := &http.Client{Timeout: telemetryTimeout}
+	response, err := client.Post(t.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	response.Body.Close()
+}
+
+// countBucket returns the bucket of a count, so exact counts are not reported.
+//
+// n: The count.
+// string: The bucket.
+func countBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n == 1:
+		return "1"
+	case n <= 10:
+		return "2-10"
+	case n <= 100:
+		return "11-100"
+	case n <= 1000:
+		return "101-1000"
+	default:
+		return ">1000"
+	}
+}
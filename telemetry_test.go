@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestTelemetryPayloadIsAnonymous(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.String("security-group-names", "", "")
+	fs.String("role-arn", "", "")
+	fs.String("profile", "", "")
+	fs.Bool("all-regions", false, "")
+	fs.String("unused", "", "")
+	secrets := []string{"sg-0a1b2c3d4e5f60718", "prod-web", "arn:aws:iam::123456789012:role/audit", "123456789012", "10.0.0.1", "203.0.113.10", "eni-1", "i-1", "eu-west-1", "team-a"}
+	if err := fs.Parse([]string{"-security-group-names", "sg-0a1b2c3d4e5f60718,prod-web", "-role-arn", secrets[2], "-profile", "team-a", "-all-regions"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := newTelemetry("", true, fs)
+	web := testGroup("sg-0a1b2c3d4e5f60718", "prod-web")
+	networkInterface := testInterface("eni-1", "i-1", web)
+	networkInterface.OwnerId = aws.String("123456789012")
+	tm.observe(3, scanResult{Results: []groupResult{testResult("eu-west-1", "prod-web", networkInterface)}})
+
+	data, err := json.Marshal(tm.payload(exitAccessDenied))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("the payload holds %q: %s", secret, data)
+		}
+	}
+
+	payload := tm.payload(exitAccessDenied)
+	if strings.Join(payload.Flags, ",") != "all-regions,profile,role-arn,security-group-names" {
+		t.Errorf("flags = %v, want the names of the flags set, sorted", payload.Flags)
+	}
+	if payload.Regions != "2-10" || payload.Groups != "1" || payload.NetworkInterfaces != "1" || payload.ExitClass != "access_denied" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if tm.payload(42).ExitClass != "other" {
+		t.Error("an unknown exit code has a class")
+	}
+}
+
+func TestTelemetryDisabled(t *testing.T) {
+	if newTelemetry("", false, flag.NewFlagSet("test", flag.ContinueOnError)) != nil {
+		t.Error("telemetry is enabled without an endpoint or -telemetry-dry-run")
+	}
+	// A disabled telemetry does nothing
+	var tm *telemetry
+	tm.observe(1, scanResult{})
+	tm.send(0)
+}
+
+func TestTelemetryExitClasses(t *testing.T) {
+	for _, code := range []int{exitError, exitUsage, exitGroupNotFound, exitAccessDenied, exitThrottled, exitRegionInvalid, exitUnstable, exitEnvMismatch, exitInternal, exitViolation} {
+		if _, ok := exitClasses[code]; !ok {
+			t.Errorf("exit code %d has no telemetry class", code)
+		}
+	}
+}
+
+func TestCountBucket(t *testing.T) {
+	tests := map[int]string{0: "0", 1: "1", 2: "2-10", 10: "2-10", 11: "11-100", 100: "11-100", 101: "101-1000", 1000: "101-1000", 1001: ">1000"}
+	for n, want := range tests {
+		if got := countBucket(n); got != want {
+			t.Errorf("countBucket(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestTelemetrySend(t *testing.T) {
+	received := make(chan telemetryPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload telemetryPayload
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	newTelemetry(server.URL, false, fs).send(0)
+	select {
+	case payload := <-received:
+		if payload.ExitClass != "success" || payload.Version == "" {
+			t.Errorf("payload = %+v", payload)
+		}
+	default:
+		t.Error("nothing was sent")
+	}
+
+	// An unreachable endpoint is ignored
+	server.Close()
+	newTelemetry(server.URL, false, fs).send(0)
+}
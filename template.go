@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func init() {
+	registerRenderer(formatRenderer{name: "template", description: "the -template or -template-file, executed per interface or once per run as set by -template-scope", contentType: "text/plain", extension: ".txt", render: renderTemplate})
+}
+
+// Template scopes, set with -template-scope.
+const (
+	templateScopeInterface = "interface"
+	templateScopeRun       = "run"
+)
+
+// templateRun is what a template of the run scope is executed against once.
+type templateRun struct {
+	Metadata reportMetadata
+	Groups   []groupReport
+	// Interfaces holds the interfaces of every group, an interface found for several groups once per group.
+	Interfaces    []templateInterface
+	Summary       templateSummary
+	FailedRegions []regionFailure
+}
+
+// templateSummary holds the counts of a run, for templates of the run scope.
+type templateSummary struct {
+	Groups            int
+	NetworkInterfaces int
+	// UniqueNetworkInterfaces counts an interface found for several groups once.
+	UniqueNetworkInterfaces int
+	Denied                  int
+	Complete                bool
+	Incomplete              string
+}
+
+// templateInterface is a network interface with the security group it was found for, what a template of the
+// interface scope is executed against.
+type templateInterface struct {
+	Region        string
+	SecurityGroup string
+	networkInterfaceReport
+}
+
+// parseTemplate parses the template of -template or -template-file with the template functions.
+//
+// text: The template given with -template, empty when it is read from the file.
+// path: The file given with -template-file, empty when the template is given with -template.
+// *template.Template: The parsed template.
+// error: If the file cannot be read or the template does not parse.
+func parseTemplate(text string, path string) (*template.Template, error) {
+	name := "template"
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text, name = string(data), filepath.Base(path)
+	}
+	return template.New(name).Funcs(templateFuncs()).Parse(text)
+}
+
+// renderTemplate executes the template of the options, once per network interface or once against the whole run.
+//
+// w: The writer to print to.
+// run: What the run found.
+// opts: How to render it, with the template and its scope.
+// error: If the template fails.
+func renderTemplate(w io.Writer, run scanResult, opts renderOptions) error {
+	if opts.Template == nil {
+		return fmt.Errorf("-output template requires -template or -template-file")
+	}
+	r := newReport(run.Results, run.Instances, run.Sightings, opts.ExcludeShared, run.FailedRegions)
+	r.Metadata = reportMetadata{Version: programVersion(), Endpoints: opts.Endpoints, CorrelationId: opts.CorrelationId, GeneratedAt: run.ScannedAt}
+	data := templateRun{
+		Metadata:      r.Metadata,
+		Groups:        r.Groups,
+		Interfaces:    []templateInterface{},
+		FailedRegions: r.FailedRegions,
+		Summary: templateSummary{
+			Groups:     len(r.Groups),
+			Denied:     countDenied(run.Results),
+			Complete:   run.Incomplete == "",
+			Incomplete: run.Incomplete,
+		},
+	}
+	seen := map[string]bool{}
+	for i, group := range r.Groups {
+		for _, networkInterface := range group.NetworkInterfaces {
+			data.Interfaces = append(data.Interfaces, templateInterface{Region: group.Region, SecurityGroup: run.Results[i].Selector.Key(), networkInterfaceReport: networkInterface})
+			id := derefOr(networkInterface.NetworkInterfaceId, missingValue)
+			if !seen[id] {
+				seen[id] = true
+				data.Summary.UniqueNetworkInterfaces++
+			}
+		}
+	}
+	data.Summary.NetworkInterfaces = len(data.Interfaces)
+
+	if opts.TemplateScope == templateScopeRun {
+		if err := opts.Template.Execute(w, data); err != nil {
+			return err
+		}
+	} else {
+		for _, networkInterface := range data.Interfaces {
+			if err := opts.Template.Execute(w, networkInterface); err != nil {
+				return err
+			}
+		}
+	}
+	printRegionFailures(os.Stderr, run.FailedRegions)
+	return nil
+}
+
+// templateFuncs returns the functions available to the templates.
+//
+// The functions taking a list take it last, so it can be piped: {{ .Interfaces | groupBy "ManagedBy" }}.
+//
+// template.FuncMap: The functions.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"groupBy":      templateGroupBy,
+		"sortBy":       templateSortBy,
+		"count":        templateCount,
+		"unique":       templateUnique,
+		"join":         templateJoin,
+		"humanizeAge":  humanizeAge,
+		"cidrContains": cidrContains,
+		"formatTable":  formatTable,
+	}
+}
+
+// templateItems returns the elements of a list.
+//
+// items: A slice or an array.
+// []reflect.Value: The elements.
+// error: If items is not a list.
+func templateItems(items any) ([]reflect.Value, error) {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", items)
+	}
+	values := make([]reflect.Value, v.Len())
+	for i := range values {
+		values[i] = v.Index(i)
+	}
+	return values, nil
+}
+
+// templateField returns a field of an item, following pointers.
+//
+// item: The item.
+// path: The field name, or dotted names for nested fields. Map items are indexed by the names.
+// reflect.Value: The field, invalid when the item has no such field or a pointer on the way is nil.
+func templateField(item reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		for item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+			if item.IsNil() {
+				return reflect.Value{}
+			}
+			item = item.Elem()
+		}
+		switch item.Kind() {
+		case reflect.Struct:
+			item = item.FieldByName(name)
+		case reflect.Map:
+			item = item.MapIndex(reflect.ValueOf(name))
+		default:
+			return reflect.Value{}
+		}
+		if !item.IsValid() {
+			return item
+		}
+	}
+	return item
+}
+
+// templateString returns a value as the templates print it, nil pointers and missing fields as an empty string.
+//
+// v: The value.
+// string: The value as a string.
+func templateString(v reflect.Value) string {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return formatTimestamp(t)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// templateGroupBy groups the items of a list by the value of a field.
+//
+// field: The field to group on.
+// items: The list.
+// map[string][]any: The items keyed by field value, which templates range over in key order.
+// error: If items is not a list.
+func templateGroupBy(field string, items any) (map[string][]any, error) {
+	values, err := templateItems(items)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string][]any{}
+	for _, v := range values {
+		key := templateString(templateField(v, field))
+		groups[key] = append(groups[key], v.Interface())
+	}
+	return groups, nil
+}
+
+// templateSortBy sorts the items of a list by the value of a field, numbers numerically and everything else as
+// strings. Items with equal values keep their order.
+//
+// field: The field to sort on.
+// items: The list.
+// []any: The sorted items.
+// error: If items is not a list.
+func templateSortBy(field string, items any) ([]any, error) {
+	values, err := templateItems(items)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := templateField(values[i], field), templateField(values[j], field)
+		if a.IsValid() && b.IsValid() && a.CanInt() && b.CanInt() {
+			return a.Int() < b.Int()
+		}
+		if a.IsValid() && b.IsValid() && a.CanFloat() && b.CanFloat() {
+			return a.Float() < b.Float()
+		}
+		return templateString(a) < templateString(b)
+	})
+	sorted := []any{}
+	for _, v := range values {
+		sorted = append(sorted, v.Interface())
+	}
+	return sorted, nil
+}
+
+// templateCount returns the length of a list, a map or a string.
+//
+// items: The list, map or string.
+// int: The length.
+// error: If items has no length.
+func templateCount(items any) (int, error) {
+	v := reflect.ValueOf(items)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		return v.Len(), nil
+	}
+	return 0, fmt.Errorf("expected a list, got %T", items)
+}
+
+// templateUnique removes the items of a list printing like an earlier one.
+//
+// items: The list.
+// []any: The items, in order, without duplicates.
+// error: If items is not a list.
+func templateUnique(items any) ([]any, error) {
+	values, err := templateItems(items)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	unique := []any{}
+	for _, v := range values {
+		key := templateString(v)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, v.Interface())
+		}
+	}
+	return unique, nil
+}
+
+// templateJoin joins the items of a list as the templates print them.
+//
+// sep: The separator.
+// items: The list.
+// string: The joined items.
+// error: If items is not a list.
+func templateJoin(sep string, items any) (string, error) {
+	values, err := templateItems(items)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{}
+	for _, v := range values {
+		parts = append(parts, templateString(v))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// humanizeAge returns how long ago a time was, in its largest unit: 45s, 12m, 5h or 3d.
+//
+// t: A time.Time or a *time.Time.
+// string: The age, missingValue for a nil time.
+// error: If t is not a time.
+func humanizeAge(t any) (string, error) {
+	var at time.Time
+	switch v := t.(type) {
+	case time.Time:
+		at = v
+	case *time.Time:
+		if v == nil {
+			return missingValue, nil
+		}
+		at = *v
+	default:
+		return "", fmt.Errorf("expected a time, got %T", t)
+	}
+	age := time.Since(at)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds())), nil
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes())), nil
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours())), nil
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24)), nil
+	}
+}
+
+// cidrContains reports whether a CIDR contains an IP address.
+//
+// cidr: The CIDR, IPv4 or IPv6.
+// ip: The address, a string or a *string. A nil or empty address is not contained.
+// bool: Whether the CIDR contains the address.
+// error: If the CIDR or the address cannot be parsed.
+func cidrContains(cidr string, ip any) (bool, error) {
+	address := ""
+	switch v := ip.(type) {
+	case string:
+		address = v
+	case *string:
+		address = derefOr(v, "")
+	default:
+		return false, fmt.Errorf("expected an IP address, got %T", ip)
+	}
+	if address == "" {
+		return false, nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return false, err
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false, err
+	}
+	return prefix.Contains(addr), nil
+}
+
+// formatTable formats the items of a list as an aligned table, with a header and a column per field.
+//
+// fields: The fields of the columns, comma separated.
+// items: The list.
+// string: The table, a line per row.
+// error: If items is not a list.
+func formatTable(fields string, items any) (string, error) {
+	values, err := templateItems(items)
+	if err != nil {
+		return "", err
+	}
+	columns := strings.Split(fields, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	records := [][]string{columns}
+	for _, v := range values {
+		record := []string{}
+		for _, column := range columns {
+			record = append(record, templateString(templateField(v, column)))
+		}
+		records = append(records, record)
+	}
+	widths := make([]int, len(columns))
+	for _, record := range records {
+		for i, cell := range record {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	var table strings.Builder
+	for _, record := range records {
+		cells := []string{}
+		for i, cell := range record {
+			cells = append(cells, fmt.Sprintf("%-*s", widths[i], cell))
+		}
+		table.WriteString(strings.TrimRight(strings.Join(cells, "  "), " ") + "\n")
+	}
+	return table.String(), nil
+}
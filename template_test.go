@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// update rewrites the golden files of the example templates instead of comparing with them.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// executeTemplate executes a template text against data with the template functions.
+//
+// t: The test.
+// text: The template.
+// data: What it is executed against.
+// string: The output.
+// error: If the template does not parse or fails.
+func executeTemplate(t *testing.T, text string, data any) (string, error) {
+	t.Helper()
+	tmpl, err := parseTemplate(text, "")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+	return b.String(), err
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	type item struct {
+		Name  string
+		Kind  string
+		Size  int
+		Score float64
+		Ip    *string
+		Inner *struct{ Value string }
+	}
+	items := []item{
+		{Name: "c", Kind: "lambda", Size: 10, Score: 0.5, Ip: aws.String("10.0.0.3")},
+		{Name: "a", Kind: "instance", Size: 9, Score: 2, Inner: &struct{ Value string }{"x"}},
+		{Name: "b", Kind: "lambda", Size: 100, Score: 1.5, Ip: aws.String("192.168.0.1")},
+	}
+	data := struct {
+		Items   []item
+		Letters []string
+		Empty   []string
+	}{Items: items, Letters: []string{"b", "a", "b"}, Empty: []string{}}
+	for _, tt := range []struct {
+		text string
+		want string
+	}{
+		{`{{ range $kind, $items := groupBy "Kind" .Items }}{{ $kind }}={{ count $items }} {{ end }}`, "instance=1 lambda=2 "},
+		{`{{ range sortBy "Name" .Items }}{{ .Name }}{{ end }}`, "abc"},
+		{`{{ range sortBy "Size" .Items }}{{ .Size }} {{ end }}`, "9 10 100 "},
+		{`{{ range sortBy "Score" .Items }}{{ .Name }}{{ end }}`, "cba"},
+		{`{{ range sortBy "Inner.Value" .Items }}{{ .Name }}{{ end }}`, "cba"},
+		{`{{ range sortBy "Missing" .Items }}{{ .Name }}{{ end }}`, "cab"},
+		{`{{ count .Items }} {{ count "four" }} {{ groupBy "Kind" .Items | count }}`, "3 4 2"},
+		{`{{ range unique .Letters }}{{ . }}{{ end }}`, "ba"},
+		{`{{ join ", " .Letters }}|{{ join ", " .Empty }}`, "b, a, b|"},
+		{`{{ range .Items }}{{ cidrContains "10.0.0.0/8" .Ip }} {{ end }}`, "true false false "},
+		{`{{ cidrContains "2001:db8::/32" "2001:db8::1" }} {{ cidrContains "10.0.0.0/8" "" }}`, "true false"},
+		{`{{ .Items | sortBy "Name" | formatTable "Name, Kind,Ip" }}`, "Name  Kind      Ip\na     instance\nb     lambda    192.168.0.1\nc     lambda    10.0.0.3\n"},
+	} {
+		got, err := executeTemplate(t, tt.text, data)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	for text, want := range map[string]string{
+		`{{ count 1 }}`:                            "expected a list, got int",
+		`{{ groupBy "Kind" "text" }}`:              "expected a list, got string",
+		`{{ cidrContains "10.0.0.0" "10.0.0.1" }}`: "no '/'",
+		`{{ cidrContains "10.0.0.0/8" "host" }}`:   "unable to parse IP",
+		`{{ cidrContains "10.0.0.0/8" 1 }}`:        "expected an IP address, got int",
+		`{{ humanizeAge "yesterday" }}`:            "expected a time, got string",
+	} {
+		if _, err := executeTemplate(t, text, nil); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestHumanizeAge(t *testing.T) {
+	now := time.Now()
+	for age, want := range map[time.Duration]string{
+		10 * time.Second: "10s",
+		90 * time.Second: "1m",
+		5 * time.Hour:    "5h",
+		47 * time.Hour:   "47h",
+		72 * time.Hour:   "3d",
+	} {
+		at := now.Add(-age)
+		if got, err := humanizeAge(at); err != nil || got != want {
+			t.Errorf("humanizeAge(-%v) = %q, %v, want %q", age, got, err, want)
+		}
+		if got, err := humanizeAge(&at); err != nil || got != want {
+			t.Errorf("humanizeAge(&-%v) = %q, %v, want %q", age, got, err, want)
+		}
+	}
+	if got, err := humanizeAge((*time.Time)(nil)); err != nil || got != missingValue {
+		t.Errorf("humanizeAge(nil) = %q, %v", got, err)
+	}
+}
+
+// TestTemplateGolden renders the test run with the example templates and compares the outputs with the golden
+// files in testdata/templates. Run with -update to rewrite them.
+func TestTemplateGolden(t *testing.T) {
+	for name, scope := range map[string]string{"managed-by": templateScopeRun, "private-ranges": templateScopeInterface} {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := parseTemplate("", filepath.Join("templates", name+".tmpl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			run := testRun(false)
+			sortSections(run.Results, []string{"eu-west-1", "us-east-1"})
+			sortResults(run.Results)
+			var b bytes.Buffer
+			if err := renderTemplate(&b, run, renderOptions{Template: tmpl, TemplateScope: scope}); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "templates", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, b.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b.Bytes(), want) {
+				t.Errorf("output:\n%s\nwant:\n%s", b.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateRequiresTemplate(t *testing.T) {
+	if err := renderTemplate(&bytes.Buffer{}, testRun(false), renderOptions{}); err == nil || !strings.Contains(err.Error(), "requires -template or -template-file") {
+		t.Errorf("error %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+)
+
+// displayLocation is the time zone human readable timestamps are rendered in, set with -timezone.
+//
+// Structured output (JSON, graph-json, history and attestations) keeps RFC 3339 UTC timestamps regardless.
+var displayLocation = time.UTC
+
+// formatTimestamp renders a timestamp for humans, in RFC 3339 in the display time zone.
+//
+// t: The timestamp.
+// string: The timestamp with the offset of the display time zone, e.g. 2024-03-10T01:59:00-05:00.
+func formatTimestamp(t time.Time) string {
+	return t.In(displayLocation).Format(time.RFC3339)
+}
+
+// setDisplayLocation sets the time zone human readable timestamps are rendered in.
+//
+// name: The IANA time zone name given with -timezone, e.g. America/New_York.
+// error: If the time zone is unknown.
+func setDisplayLocation(name string) error {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	displayLocation = location
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withDisplayLocation sets the display time zone for a test.
+//
+// t: The test.
+// name: The IANA time zone name.
+func withDisplayLocation(t *testing.T, name string) {
+	t.Helper()
+	previous := displayLocation
+	t.Cleanup(func() { displayLocation = previous })
+	if err := setDisplayLocation(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetDisplayLocation(t *testing.T) {
+	previous := displayLocation
+	t.Cleanup(func() { displayLocation = previous })
+	for _, name := range []string{"Mars/Olympus_Mons", "utc+2", "America/New York"} {
+		if err := setDisplayLocation(name); err == nil {
+			t.Errorf("%q: no error", name)
+		}
+	}
+	if displayLocation != previous {
+		t.Error("an invalid zone changed the display time zone")
+	}
+}
+
+func TestFormatTimestampDST(t *testing.T) {
+	withDisplayLocation(t, "America/New_York")
+	// New York springs forward at 2024-03-10 07:00 UTC, from 01:59:59 EST to 03:00:00 EDT
+	for utc, want := range map[string]string{
+		"2024-03-10T06:59:00Z": "2024-03-10T01:59:00-05:00",
+		"2024-03-10T07:00:00Z": "2024-03-10T03:00:00-04:00",
+		"2024-11-03T05:30:00Z": "2024-11-03T01:30:00-04:00",
+		"2024-11-03T06:30:00Z": "2024-11-03T01:30:00-05:00",
+	} {
+		at, err := time.Parse(time.RFC3339, utc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatTimestamp(at); got != want {
+			t.Errorf("%s: %s, want %s", utc, got, want)
+		}
+	}
+}
+
+func TestTimezoneLeavesJSONInUTC(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := testRun(false)
+	run.Sightings = map[string]interfaceSighting{"eni-0aaa": {
+		FirstSeen: time.Date(2024, 3, 10, 1, 59, 0, 0, newYork),
+		LastSeen:  time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+	}}
+	render := func(output string) string {
+		var b bytes.Buffer
+		if err := renderers[output].Render(&b, run, renderOptions{Output: output}); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	inUTC := render("json")
+	withDisplayLocation(t, "Asia/Kolkata")
+	if got := render("json"); got != inUTC {
+		t.Errorf("-timezone changed the JSON output:\n%s\nin UTC:\n%s", got, inUTC)
+	}
+	for _, want := range []string{`"first_seen": "2024-03-10T06:59:00Z"`, `"last_seen": "2024-03-10T07:00:00Z"`} {
+		if !strings.Contains(inUTC, want) {
+			t.Errorf("JSON has no %s:\n%s", want, inUTC)
+		}
+	}
+	if text := render("text"); !strings.Contains(text, "First seen: 2024-03-10T12:29:00+05:30") {
+		t.Errorf("text not in the display time zone:\n%s", text)
+	}
+}
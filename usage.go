@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// usageSampleInterval is how often the heap and the goroutines are sampled with -stats.
+const usageSampleInterval = 100 * time.Millisecond
+
+// usageStats is the resource usage of the program, reported with -stats.
+type usageStats struct {
+	// heapPeak is the highest heap allocation sampled, in bytes
+	heapPeak atomic.Uint64
+	// goroutinePeak is the highest number of goroutines sampled
+	goroutinePeak atomic.Int64
+	// responseBytes is the number of bytes of API response bodies read, counted by the middleware
+	responseBytes atomic.Int64
+}
+
+// countingBody counts the bytes read from an API response body.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+// Read reads from the body and counts the bytes read.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// apiOptions returns the SDK middleware counting the bytes of the API responses.
+//
+// []func(*middleware.Stack) error: The API options to add to the AWS configuration.
+func (u *usageStats) apiOptions() []func(*middleware.Stack) error {
+	return []func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// Added last, closest to the transport, so the body is wrapped before it is deserialized
+			return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("UsageResponseBytes", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleDeserialize(ctx, in)
+				if response, ok := out.RawResponse.(*smithyhttp.Response); ok && response.Body != nil {
+					response.Body = &countingBody{ReadCloser: response.Body, n: &u.responseBytes}
+				}
+				return out, metadata, err
+			}), middleware.After)
+		},
+	}
+}
+
+// observe samples the heap and the goroutines, keeping the peaks.
+func (u *usageStats) observe() {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	for peak := u.heapPeak.Load(); memStats.HeapAlloc > peak && !u.heapPeak.CompareAndSwap(peak, memStats.HeapAlloc); peak = u.heapPeak.Load() {
+	}
+	goroutines := int64(runtime.NumGoroutine())
+	for peak := u.goroutinePeak.Load(); goroutines > peak && !u.goroutinePeak.CompareAndSwap(peak, goroutines); peak = u.goroutinePeak.Load() {
+	}
+}
+
+// sample samples the usage in the background until the context is canceled or the returned function is called.
+//
+// ctx: Stops the sampling when canceled.
+// interval: The duration between two samples.
+// func(): Stops the sampling, taking a last sample, and waits for the sampler to exit.
+func (u *usageStats) sample(ctx context.Context, interval time.Duration) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		u.observe()
+		for {
+			select {
+			case <-ctx.Done():
+				u.observe()
+				return
+			case <-ticker.C:
+				u.observe()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// printUsage prints the resource usage.
+//
+// w: The writer to print to.
+func (u *usageStats) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s heap peak, %d goroutines peak, %s of API responses\n",
+		formatBytes(int64(u.heapPeak.Load())), u.goroutinePeak.Load(), formatBytes(u.responseBytes.Load()))
+}
+
+// formatBytes formats a number of bytes with a binary unit, e.g. 12.3 MiB.
+//
+// n: The number of bytes.
+// string: The formatted number.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+func TestUsageSamplerStopsOnCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	usage := &usageStats{}
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := usage.sample(ctx, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	// The sampler exits on its own once the context is canceled
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines after the cancellation, %d before the sampler started", n, before)
+	}
+
+	// And stopping it afterwards does not block
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stopping a canceled sampler blocks")
+	}
+	if usage.heapPeak.Load() == 0 || usage.goroutinePeak.Load() < 2 {
+		t.Errorf("heap peak %d, goroutine peak %d, want the samples taken", usage.heapPeak.Load(), usage.goroutinePeak.Load())
+	}
+}
+
+func TestUsageResponseBytes(t *testing.T) {
+	const body = `<DescribeRegionsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><regionInfo/></DescribeRegionsResponse>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+	usage := &usageStats{}
+	client := ec2.New(ec2.Options{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+		APIOptions:       usage.apiOptions(),
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := client.DescribeRegions(context.Background(), &ec2.DescribeRegionsInput{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := usage.responseBytes.Load(); got != 2*int64(len(body)) {
+		t.Errorf("counted %d bytes of responses, want %d", got, 2*len(body))
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KiB", 12897485: "12.3 MiB", 1 << 40: "1.0 TiB", 1 << 50: "1024.0 TiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
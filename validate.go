@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// identifierClass is a kind of AWS identifier the inputs are checked against.
+type identifierClass struct {
+	// Name is the kind of identifier, as written in the errors.
+	Name string
+	// Prefix is the prefix of the resource IDs of the class, empty for the other identifiers.
+	Prefix  string
+	Pattern *regexp.Regexp
+	Example string
+}
+
+// The identifier classes.
+var (
+	regionClass           = identifierClass{Name: "region", Pattern: regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+$`), Example: "eu-west-1"}
+	availabilityZoneClass = identifierClass{Name: "availability zone", Pattern: regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+([a-z]|-[a-z0-9-]+-[0-9]+[a-z]?)$`), Example: "eu-west-1a"}
+	accountClass          = identifierClass{Name: "account ID", Pattern: regexp.MustCompile(`^[0-9]{12}$`), Example: "123456789012"}
+	subnetClass           = resourceIdClass("subnet ID", "subnet")
+	vpcClass              = resourceIdClass("VPC ID", "vpc")
+	instanceClass         = resourceIdClass("instance ID", "i")
+	networkInterfaceClass = resourceIdClass("network interface ID", "eni")
+	securityGroupClass    = resourceIdClass("security group ID", "sg")
+)
+
+// resourceIdClasses are the classes of the resource IDs, used to tell which ID a misplaced value is.
+var resourceIdClasses = []identifierClass{subnetClass, vpcClass, instanceClass, networkInterfaceClass, securityGroupClass}
+
+// filterIdentifierClasses are the classes of the values of the --filters holding identifiers, by filter name.
+var filterIdentifierClasses = map[string]identifierClass{
+	"availability-zone":      availabilityZoneClass,
+	"subnet-id":              subnetClass,
+	"vpc-id":                 vpcClass,
+	"attachment.instance-id": instanceClass,
+	"network-interface-id":   networkInterfaceClass,
+	"group-id":               securityGroupClass,
+	"owner-id":               accountClass,
+}
+
+// zoneRegion matches the region at the start of an availability zone name, Local and Wavelength Zones included.
+var zoneRegion = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-[a-z]+-[0-9]+`)
+
+// regionWithoutDash matches a region missing the dash before its number, such as eu-west1.
+var regionWithoutDash = regexp.MustCompile(`^([a-z]{2}(?:-gov|-iso[a-z]?)?-[a-z]+)([0-9]+)$`)
+
+// resourceIdClass returns the class of the resource IDs with the given prefix, followed by 8 or 17 hex digits.
+//
+// name: The kind of identifier.
+// prefix: The prefix of the IDs, without the dash.
+// identifierClass: The class.
+func resourceIdClass(name string, prefix string) identifierClass {
+	return identifierClass{
+		Name:    name,
+		Prefix:  prefix,
+		Pattern: regexp.MustCompile(`^` + prefix + `-([0-9a-f]{8}|[0-9a-f]{17})$`),
+		Example: prefix + "-0123456789abcdef0",
+	}
+}
+
+// inputProblem is an input of the run that is not a valid identifier.
+type inputProblem struct {
+	// Input names where the value was given, a flag or the source of the region.
+	Input   string
+	Value   string
+	Problem string
+}
+
+// String returns the input, the value and the problem.
+func (p inputProblem) String() string {
+	return fmt.Sprintf("%s %q: %s", p.Input, p.Value, p.Problem)
+}
+
+// validationInputs are the identifiers given to the run.
+type validationInputs struct {
+	// Region is the region of the configuration, the active region.
+	Region         string
+	AllRegions     bool
+	ExcludeRegions []string
+	VpcId          string
+	InstanceId     string
+	ExplainEni     string
+	// Filters are the --filters, every one of them.
+	Filters []types.Filter
+}
+
+// normalizeFilterValues trims the whitespace around the values of the filters, in place.
+//
+// filters: The filters.
+func normalizeFilterValues(filters []types.Filter) {
+	for _, filter := range filters {
+		for i, value := range filter.Values {
+			filter.Values[i] = strings.TrimSpace(value)
+		}
+	}
+}
+
+// checkIdentifier checks the syntax of an identifier.
+//
+// class: The class the identifier must belong to.
+// value: The identifier.
+// string: What is wrong with it, empty when it is valid.
+func checkIdentifier(class identifierClass, value string) string {
+	switch {
+	case class.Pattern.MatchString(value):
+		return ""
+	case value == "":
+		return fmt.Sprintf("empty %s", class.Name)
+	case class.Pattern.MatchString(strings.ToLower(value)):
+		return fmt.Sprintf("%ss are lower case, use %s", class.Name, strings.ToLower(value))
+	}
+	if class == regionClass {
+		if match := regionWithoutDash.FindStringSubmatch(value); match != nil {
+			return fmt.Sprintf("not a region, did you mean %s-%s?", match[1], match[2])
+		}
+	}
+	for _, other := range resourceIdClasses {
+		if other.Prefix != class.Prefix && other.Pattern.MatchString(value) {
+			return fmt.Sprintf("this is %s, not %s", withArticle(other.Name), withArticle(class.Name))
+		}
+	}
+	return fmt.Sprintf("not a valid %s, such as %s", class.Name, class.Example)
+}
+
+// withArticle returns a noun preceded by its indefinite article.
+//
+// noun: The noun.
+// string: The noun with a or an.
+func withArticle(noun string) string {
+	if strings.ContainsAny(noun[:1], "aeiou") {
+		return "an " + noun
+	}
+	return "a " + noun
+}
+
+// validateInputs checks the identifiers given to the run before any lookup starts.
+//
+// The syntax of every identifier is checked, and the availability zones of the --filters must belong to the
+// active region. Without -all-regions, the zones are also checked against those of the region, listed with a
+// single DescribeAvailabilityZones call stored in the on-disk cache.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the active region.
+// inputs: The identifiers.
+// cacheTTL: The maximum age of the cached availability zones.
+// []inputProblem: Every problem found, in input order.
+func validateInputs(ctx context.Context, ec2Client *ec2.Client, inputs validationInputs, cacheTTL time.Duration) []inputProblem {
+	problems := []inputProblem{}
+	check := func(input string, class identifierClass, value string) bool {
+		if problem := checkIdentifier(class, value); problem != "" {
+			problems = append(problems, inputProblem{Input: input, Value: value, Problem: problem})
+			return false
+		}
+		return true
+	}
+
+	// The region is named after where it is set, the flags having none
+	regionValid := inputs.AllRegions || check("region (AWS_REGION or the profile)", regionClass, inputs.Region)
+	for _, region := range inputs.ExcludeRegions {
+		check("-exclude-regions", regionClass, region)
+	}
+	if inputs.VpcId != "" {
+		check("-vpc-id", vpcClass, inputs.VpcId)
+	}
+	if inputs.InstanceId != "" {
+		check("-instance-id", instanceClass, inputs.InstanceId)
+	}
+	if inputs.ExplainEni != "" {
+		check("-explain-eni", networkInterfaceClass, inputs.ExplainEni)
+	}
+
+	zones := []string{}
+	for _, filter := range inputs.Filters {
+		name := aws.ToString(filter.Name)
+		class, ok := filterIdentifierClasses[name]
+		if !ok {
+			continue
+		}
+		input := "--filters Name=" + name
+		for _, value := range filter.Values {
+			if strings.ContainsAny(value, "*?") {
+				// A wildcard value matches identifiers rather than being one
+				continue
+			}
+			if !check(input, class, value) || class != availabilityZoneClass || inputs.AllRegions || !regionValid {
+				continue
+			}
+			if region := zoneRegion.FindString(value); region != inputs.Region {
+				problems = append(problems, inputProblem{Input: input, Value: value, Problem: fmt.Sprintf("this zone is in %s, not in the active region %s", region, inputs.Region)})
+				continue
+			}
+			zones = append(zones, value)
+		}
+	}
+
+	// Check the zones exist in the active region
+	if len(zones) > 0 {
+		regionZones, err := getAvailabilityZones(ctx, ec2Client, inputs.Region, cacheTTL)
+		if err != nil {
+			warnOnce("availability-zones", "cannot list the availability zones of %s, the zones of --filters are not checked: %v", inputs.Region, err)
+			return problems
+		}
+		for _, zone := range zones {
+			if !slices.Contains(regionZones, zone) {
+				problems = append(problems, inputProblem{Input: "--filters Name=availability-zone", Value: zone, Problem: fmt.Sprintf("no such zone in %s, its zones are: %s", inputs.Region, strings.Join(regionZones, ", "))})
+			}
+		}
+	}
+	return problems
+}
+
+// getAvailabilityZones returns the names of the availability zones of a region, from the on-disk cache when fresh.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client of the region.
+// region: The region.
+// cacheTTL: The maximum age of the cached zones.
+// []string: The zone names, sorted, the zones the account has not opted in to included.
+// error: If the zones cannot be described.
+func getAvailabilityZones(ctx context.Context, ec2Client *ec2.Client, region string, cacheTTL time.Duration) ([]string, error) {
+	cacheName := fmt.Sprintf("availability-zones-%s.json", region)
+	zones := []string{}
+	if _, ok := readCache(cacheName, cacheTTL, &zones); ok {
+		return zones, nil
+	}
+
+	describeAvailabilityZonesOutput, err := ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
+		AllAvailabilityZones: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, enilookup.ClassifyError(err)
+	}
+	for _, zone := range describeAvailabilityZonesOutput.AvailabilityZones {
+		zones = append(zones, aws.ToString(zone.ZoneName))
+	}
+	slices.Sort(zones)
+	if err := writeCache(cacheName, zones); err != nil {
+		warnOnce("availability-zones-cache", "could not store the availability zones: %v", err)
+	}
+	return zones, nil
+}
+
+// formatInputProblems returns every input problem, a line each, for the usage error.
+//
+// problems: The problems.
+// string: The message.
+func formatInputProblems(problems []inputProblem) string {
+	lines := []string{fmt.Sprintf("%d invalid inputs, nothing was looked up (-no-validate skips these checks):", len(problems))}
+	for _, problem := range problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestCheckIdentifier(t *testing.T) {
+	for _, tt := range []struct {
+		class identifierClass
+		value string
+		want  string
+	}{
+		{regionClass, "eu-west-1", ""},
+		{regionClass, "us-gov-west-1", ""},
+		{regionClass, "us-isob-east-1", ""},
+		{regionClass, "eu-west1", "not a region, did you mean eu-west-1?"},
+		{regionClass, "EU-WEST-1", "regions are lower case, use eu-west-1"},
+		{regionClass, "", "empty region"},
+		{regionClass, "europe", "not a valid region, such as eu-west-1"},
+
+		{availabilityZoneClass, "eu-west-1a", ""},
+		{availabilityZoneClass, "us-west-2-lax-1a", ""},
+		{availabilityZoneClass, "us-east-1-wl1-bos-wlz-1", ""},
+		{availabilityZoneClass, "eu-west-1", "not a valid availability zone, such as eu-west-1a"},
+		{availabilityZoneClass, "euw1-az1", "not a valid availability zone, such as eu-west-1a"},
+
+		{accountClass, "123456789012", ""},
+		{accountClass, "12345678901", "not a valid account ID, such as 123456789012"},
+		{accountClass, "1234-5678-9012", "not a valid account ID, such as 123456789012"},
+
+		{subnetClass, "subnet-0123abcd", ""},
+		{subnetClass, "subnet-0123456789abcdef0", ""},
+		{subnetClass, "subnet-0abc", "not a valid subnet ID, such as subnet-0123456789abcdef0"},
+		{subnetClass, "subnet-0123ABCD", "subnet IDs are lower case, use subnet-0123abcd"},
+		{subnetClass, "vpc-0123abcd", "this is a VPC ID, not a subnet ID"},
+
+		{vpcClass, "vpc-0123456789abcdef0", ""},
+		{vpcClass, "subnet-0123abcd", "this is a subnet ID, not a VPC ID"},
+		{vpcClass, "vpc-123", "not a valid VPC ID, such as vpc-0123456789abcdef0"},
+
+		{instanceClass, "i-0123456789abcdef0", ""},
+		{instanceClass, "eni-0123abcd", "this is a network interface ID, not an instance ID"},
+		{instanceClass, "i-0123456789abcdefg", "not a valid instance ID, such as i-0123456789abcdef0"},
+
+		{networkInterfaceClass, "eni-0123abcd", ""},
+		{networkInterfaceClass, "i-0123abcd", "this is an instance ID, not a network interface ID"},
+		{networkInterfaceClass, "", "empty network interface ID"},
+
+		{securityGroupClass, "sg-0123456789abcdef0", ""},
+		{securityGroupClass, "sg-web", "not a valid security group ID, such as sg-0123456789abcdef0"},
+		{securityGroupClass, "subnet-0123456789abcdef0", "this is a subnet ID, not a security group ID"},
+	} {
+		if got := checkIdentifier(tt.class, tt.value); got != tt.want {
+			t.Errorf("%s %q: %q, want %q", tt.class.Name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeFilterValues(t *testing.T) {
+	filters := []types.Filter{{Name: aws.String("subnet-id"), Values: []string{"subnet-0123abcd ", "\tsubnet-4567abcd"}}}
+	normalizeFilterValues(filters)
+	if got := strings.Join(filters[0].Values, ","); got != "subnet-0123abcd,subnet-4567abcd" {
+		t.Errorf("values %q", got)
+	}
+}
+
+// zonesEC2Client returns an EC2 client whose DescribeAvailabilityZones calls are answered by a test server with
+// the given zones, caching them in a temporary directory.
+//
+// t: The test.
+// zones: The zone names.
+// *ec2.Client: The client.
+// *atomic.Int32: The number of calls the server answered.
+func zonesEC2Client(t *testing.T, zones ...string) (*ec2.Client, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		items := ""
+		for _, zone := range zones {
+			items += fmt.Sprintf("<item><zoneName>%s</zoneName></item>", zone)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<DescribeAvailabilityZonesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><availabilityZoneInfo>%s</availabilityZoneInfo></DescribeAvailabilityZonesResponse>`, items)
+	}))
+	t.Cleanup(server.Close)
+	return ec2.New(ec2.Options{
+		Region:           "eu-west-1",
+		Credentials:      aws.AnonymousCredentials{},
+		BaseEndpoint:     aws.String(server.URL),
+		RetryMaxAttempts: 1,
+	}), calls
+}
+
+func TestValidateInputs(t *testing.T) {
+	ec2Client, calls := zonesEC2Client(t, "eu-west-1a", "eu-west-1b", "eu-west-1c")
+	inputs := validationInputs{
+		Region:         "eu-west-1",
+		ExcludeRegions: []string{"us-east-1", "us-east1"},
+		VpcId:          "subnet-0123abcd",
+		InstanceId:     "i-0123456789abcdef0",
+		ExplainEni:     "eni-xyz",
+		Filters: []types.Filter{
+			{Name: aws.String("availability-zone"), Values: []string{"eu-west-1a", "eu-west-1d", "us-east-1a", "eu-west-1*"}},
+			{Name: aws.String("subnet-id"), Values: []string{"subnet-0abc"}},
+			{Name: aws.String("status"), Values: []string{"not-an-identifier"}},
+		},
+	}
+	want := []string{
+		`-exclude-regions "us-east1": not a region, did you mean us-east-1?`,
+		`-vpc-id "subnet-0123abcd": this is a subnet ID, not a VPC ID`,
+		`-explain-eni "eni-xyz": not a valid network interface ID, such as eni-0123456789abcdef0`,
+		`--filters Name=availability-zone "us-east-1a": this zone is in us-east-1, not in the active region eu-west-1`,
+		`--filters Name=subnet-id "subnet-0abc": not a valid subnet ID, such as subnet-0123456789abcdef0`,
+		`--filters Name=availability-zone "eu-west-1d": no such zone in eu-west-1, its zones are: eu-west-1a, eu-west-1b, eu-west-1c`,
+	}
+	got := []string{}
+	for _, problem := range validateInputs(context.Background(), ec2Client, inputs, time.Hour) {
+		got = append(got, problem.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The zones are listed once, then served from the cache
+	validateInputs(context.Background(), ec2Client, inputs, time.Hour)
+	if calls.Load() != 1 {
+		t.Errorf("%d DescribeAvailabilityZones calls, want 1", calls.Load())
+	}
+
+	// With -all-regions, neither the region nor the zones are checked against it
+	inputs = validationInputs{Region: "eu-west1", AllRegions: true, Filters: []types.Filter{{Name: aws.String("availability-zone"), Values: []string{"us-east-1a"}}}}
+	if problems := validateInputs(context.Background(), ec2Client, inputs, time.Hour); len(problems) != 0 {
+		t.Errorf("-all-regions: %v", problems)
+	}
+
+	// An invalid active region is reported once, without checking the zones against it
+	inputs = validationInputs{Region: "eu-west1", Filters: inputs.Filters}
+	problems := validateInputs(context.Background(), ec2Client, inputs, time.Hour)
+	if len(problems) != 1 || problems[0].String() != `region (AWS_REGION or the profile) "eu-west1": not a region, did you mean eu-west-1?` {
+		t.Errorf("invalid region: %v", problems)
+	}
+	if message := formatInputProblems(problems); !strings.HasPrefix(message, "1 invalid inputs, nothing was looked up (-no-validate skips these checks):\n  region") {
+		t.Errorf("message:\n%s", message)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// verification is the comparison of two passes of the lookup made with -verify.
+type verification struct {
+	FirstPassAt  *time.Time          `json:"first_pass_at,omitempty"`
+	SecondPassAt *time.Time          `json:"second_pass_at,omitempty"`
+	Stable       bool                `json:"stable"`
+	Added        []verificationDelta `json:"added"`
+	Removed      []verificationDelta `json:"removed"`
+}
+
+// verificationDelta is a network interface found by only one of the passes.
+type verificationDelta struct {
+	Region             string `json:"region"`
+	Group              string `json:"group"`
+	NetworkInterfaceId string `json:"network_interface_id"`
+}
+
+// verifyRuns compares the network interfaces found by two passes of the lookup.
+//
+// first: The results of the first pass.
+// firstAt: When the first pass started.
+// second: The results of the second pass.
+// secondAt: When the second pass started.
+// *verification: The comparison, with the deltas sorted by region, group and interface ID.
+func verifyRuns(first []groupResult, firstAt time.Time, second []groupResult, secondAt time.Time) *verification {
+	firstSet, secondSet := verificationSet(first), verificationSet(second)
+	v := &verification{FirstPassAt: &firstAt, SecondPassAt: &secondAt, Added: []verificationDelta{}, Removed: []verificationDelta{}}
+	for delta := range secondSet {
+		if !firstSet[delta] {
+			v.Added = append(v.Added, delta)
+		}
+	}
+	for delta := range firstSet {
+		if !secondSet[delta] {
+			v.Removed = append(v.Removed, delta)
+		}
+	}
+	sortDeltas(v.Added)
+	sortDeltas(v.Removed)
+	v.Stable = len(v.Added) == 0 && len(v.Removed) == 0
+	return v
+}
+
+// verificationSet returns the network interfaces of the results, per region and group.
+//
+// results: The network interfaces found per security group.
+// map[verificationDelta]bool: The set of interfaces.
+func verificationSet(results []groupResult) map[verificationDelta]bool {
+	set := map[verificationDelta]bool{}
+	for _, result := range results {
+		for _, networkInterface := range result.NetworkInterfaces {
+			set[verificationDelta{Region: result.Region, Group: result.Selector.Key(), NetworkInterfaceId: aws.ToString(networkInterface.NetworkInterfaceId)}] = true
+		}
+	}
+	return set
+}
+
+// sortDeltas sorts the deltas by region, group and interface ID.
+//
+// deltas: The deltas, sorted in place.
+func sortDeltas(deltas []verificationDelta) {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Region != deltas[j].Region {
+			return deltas[i].Region < deltas[j].Region
+		}
+		if deltas[i].Group != deltas[j].Group {
+			return deltas[i].Group < deltas[j].Group
+		}
+		return deltas[i].NetworkInterfaceId < deltas[j].NetworkInterfaceId
+	})
+}
+
+// printVerification prints the comparison of the two passes.
+//
+// w: The writer to print to.
+// v: The comparison, nil when -verify is not set.
+func printVerification(w io.Writer, v *verification) {
+	if v == nil {
+		return
+	}
+	passes := "passes"
+	if v.FirstPassAt != nil && v.SecondPassAt != nil {
+		passes = fmt.Sprintf("passes at %s and %s", formatTimestamp(*v.FirstPassAt), formatTimestamp(*v.SecondPassAt))
+	}
+	if v.Stable {
+		fmt.Fprintf(w, "Verification: stable (%s)\n", passes)
+		return
+	}
+	fmt.Fprintf(w, "Verification: unstable, %d added and %d removed between the %s\n", len(v.Added), len(v.Removed), passes)
+	for _, delta := range v.Added {
+		fmt.Fprintf(w, "  + %s %s (%s)\n", delta.NetworkInterfaceId, delta.Group, delta.Region)
+	}
+	for _, delta := range v.Removed {
+		fmt.Fprintf(w, "  - %s %s (%s)\n", delta.NetworkInterfaceId, delta.Group, delta.Region)
+	}
+}
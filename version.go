@@ -0,0 +1,19 @@
+package main
+
+import "runtime/debug"
+
+// version is the version of the program, set at build time with -ldflags "-X main.version=...".
+var version = ""
+
+// programVersion returns the version of the program, falling back on the module version of the build.
+//
+// string: The version.
+func programVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+
+	"interfaces/m/v2/pkg/enilookup"
+)
+
+// runWait blocks until the network interfaces attached to the security groups satisfy the condition.
+//
+// Every poll prints the current count. The run succeeds when the condition holds and fails when the timeout expires.
+//
+// ctx: The context used for the API calls.
+// ec2Client: The EC2 client.
+// securityGroupNames: The security group names and IDs.
+// until: The condition, as accepted by enilookup.ParseCondition.
+// timeout: The maximum duration of the wait.
+// interval: The duration between two polls.
+// excludeShared: Whether shared service ENIs are left out of the count.
+// int: The exit code.
+func runWait(ctx context.Context, ec2Client *ec2.Client, securityGroupNames []string, until string, timeout time.Duration, interval time.Duration, excludeShared bool) int {
+	cond, err := enilookup.ParseCondition(until)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid value %q for -wait-until: %v\n", until, err)
+		return exitUsage
+	}
+
+	// Resolve the security group names and IDs
+	selectors, err := enilookup.Resolve(ctx, ec2Client, securityGroupNames, func(notice string) {
+		fmt.Fprintf(os.Stderr, "notice: %s\n", notice)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(err)
+	}
+
+	err = enilookup.WaitUntil(ctx, cond, enilookup.WaitOptions{
+		API:           ec2Client,
+		Selectors:     selectors,
+		Timeout:       timeout,
+		Interval:      interval,
+		ExcludeShared: excludeShared,
+		OnPoll: func(poll int, count int) {
+			fmt.Printf("Poll %d: %d network interfaces\n", poll, count)
+		},
+	})
+	switch {
+	case err == nil:
+		fmt.Printf("Condition %s holds\n", cond.Description)
+		return 0
+	case errors.Is(err, enilookup.ErrWaitTimeout):
+		fmt.Printf("Condition %s did not hold within %s\n", cond.Description, timeout)
+		return exitError
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitCode(err)
+	}
+}
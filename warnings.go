@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// warnedMu guards warned.
+	warnedMu sync.Mutex
+	// warned holds the keys of the warnings already printed by warnOnce.
+	warned = map[string]bool{}
+)
+
+// printNotice prints a notice of the security group resolution, such as a name resolved to several groups.
+//
+// w: The writer to print to, stderr.
+// notice: The notice.
+func printNotice(w io.Writer, notice string) {
+	fmt.Fprintln(w, stderrText("notice: "+notice))
+}
+
+// warnOnce prints a warning to stderr the first time it is called with the given key.
+//
+// key: Identifies the warning.
+// format: The warning format.
+// args: The warning arguments.
+func warnOnce(key string, format string, args ...any) {
+	warnedMu.Lock()
+	defer warnedMu.Unlock()
+	if warned[key] {
+		return
+	}
+	warned[key] = true
+	fmt.Fprintln(os.Stderr, stderrText(fmt.Sprintf("warning: "+format, args...)))
+}
+
+// The codes of the run warnings, W- for a degradation of the output and N- for a notice.
+const (
+	warningAccessDenied     = "W-ACCESS-DENIED"
+	warningAllFiltered      = "N-ALL-FILTERED"
+	warningDuplicateSection = "N-DUPLICATE-SECTION"
+)
+
+// maxWarningKeys is the number of distinct warnings de-duplicated in a run, the ones beyond it are printed every
+// time so the table stays bounded.
+const maxWarningKeys = 1000
+
+// dedupeWarnings prints only the first occurrence of each run warning on stderr, with the number of repeats at the
+// end of the run. It is set unless -no-dedupe-warnings is given.
+var dedupeWarnings = true
+
+// runWarning is a warning raised while looking up the security groups, reported in the JSON output.
+type runWarning struct {
+	Code string `json:"code"`
+	// Subject is what the warning is about, such as the denied API action, identical warnings sharing the code and
+	// the subject.
+	Subject string `json:"subject"`
+	Region  string `json:"region,omitempty"`
+	// Group is the security group the warning was raised for, as given by the user.
+	Group   string `json:"group,omitempty"`
+	Message string `json:"message"`
+}
+
+// key returns what identical warnings share.
+func (w runWarning) key() string {
+	return w.Code + " (" + w.Subject + ")"
+}
+
+// String returns the warning as printed on stderr.
+func (w runWarning) String() string {
+	level := "warning"
+	if strings.HasPrefix(w.Code, "N-") {
+		level = "notice"
+	}
+	return fmt.Sprintf("%s: %s [%s]", level, w.Message, w.Code)
+}
+
+var (
+	// runWarningsMu guards the run warnings.
+	runWarningsMu sync.Mutex
+	// runWarnings are the warnings of the current run, every occurrence included.
+	runWarnings = []runWarning{}
+	// warningCounts are the occurrences of the run warnings keyed by runWarning.key, in first seen order in
+	// warningKeys.
+	warningCounts = map[string]int{}
+	warningKeys   = []string{}
+)
+
+// warn records a run warning and prints it to stderr, unless an identical one was already printed in the run.
+//
+// w: The warning.
+func warn(w runWarning) {
+	runWarningsMu.Lock()
+	defer runWarningsMu.Unlock()
+	runWarnings = append(runWarnings, w)
+	key := w.key()
+	if !dedupeWarnings {
+		fmt.Fprintln(os.Stderr, stderrText(w.String()))
+		return
+	}
+	if _, ok := warningCounts[key]; !ok {
+		fmt.Fprintln(os.Stderr, stderrText(w.String()))
+		if len(warningKeys) >= maxWarningKeys {
+			return
+		}
+		warningKeys = append(warningKeys, key)
+	}
+	warningCounts[key]++
+}
+
+// flushWarnings prints how many occurrences of every run warning were left out, then starts a new run.
+//
+// w: The writer to print to.
+// []runWarning: The warnings of the run, every occurrence included.
+func flushWarnings(w io.Writer) []runWarning {
+	runWarningsMu.Lock()
+	defer runWarningsMu.Unlock()
+	for _, key := range warningKeys {
+		switch repeats := warningCounts[key] - 1; {
+		case repeats == 1:
+			fmt.Fprintf(w, "…and 1 more occurrence of %s\n", key)
+		case repeats > 1:
+			fmt.Fprintf(w, "…and %d more occurrences of %s\n", repeats, key)
+		}
+	}
+	warnings := runWarnings
+	runWarnings = []runWarning{}
+	warningCounts = map[string]int{}
+	warningKeys = []string{}
+	return warnings
+}
+
+// warnDenied records a W-ACCESS-DENIED warning for every security group with network interfaces, when the
+// credentials may not call an API action enriching them.
+//
+// action: The denied API action, such as ec2:DescribeInstances.
+// results: The network interfaces found per security group.
+// consequence: What is left out of the output, e.g. "the instances are left unresolved".
+// err: The denial.
+func warnDenied(action string, results []groupResult, consequence string, err error) {
+	for _, result := range results {
+		if len(result.NetworkInterfaces) == 0 {
+			continue
+		}
+		warn(runWarning{
+			Code:    warningAccessDenied,
+			Subject: action,
+			Region:  result.Region,
+			Group:   result.Selector.Key(),
+			Message: fmt.Sprintf("%s (%s): cannot call %s, %s: %v", result.Selector.Key(), result.Region, action, consequence, err),
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/vm"
+)
+
+// whereEnv is the variable set a -where expression is evaluated against, one network interface at a time.
+type whereEnv struct {
+	Status        string            `expr:"status"`
+	InterfaceType string            `expr:"interface_type"`
+	PrivateIp     string            `expr:"private_ip"`
+	SubnetId      string            `expr:"subnet_id"`
+	Az            string            `expr:"az"`
+	Description   string            `expr:"description"`
+	Tags          map[string]string `expr:"tags"`
+	Groups        []string          `expr:"groups"`
+	IsPublic      bool              `expr:"is_public"`
+	AgeDays       int               `expr:"age_days"`
+}
+
+// whereFilter is a compiled -where expression.
+type whereFilter struct {
+	program *vm.Program
+	now     time.Time
+}
+
+// compileWhere parses and type checks a -where expression.
+//
+// source: The expression, which must evaluate to a boolean.
+// *whereFilter: The compiled filter.
+// error: If the expression does not parse or type check, giving the position of the error.
+func compileWhere(source string) (*whereFilter, error) {
+	program, err := expr.Compile(source, expr.Env(whereEnv{}), expr.AsBool())
+	var positioned *file.Error
+	if err != nil && !errors.As(err, &positioned) {
+		// The result type is checked on the whole expression, so the error points at its start
+		positioned = &file.Error{Message: err.Error()}
+		err = positioned.Bind(file.NewSource(source))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &whereFilter{program: program, now: time.Now()}, nil
+}
+
+// newWhereEnv builds the variables of a network interface.
+//
+// age_days counts the days since the interface was attached, it is 0 for a detached interface
+// since the API does not report when an interface was created.
+//
+// networkInterface: The network interface.
+// now: The time the age is measured at.
+// whereEnv: The variables.
+func newWhereEnv(networkInterface types.NetworkInterface, now time.Time) whereEnv {
+	env := whereEnv{
+		Status:        string(networkInterface.Status),
+		InterfaceType: string(networkInterface.InterfaceType),
+		PrivateIp:     aws.ToString(networkInterface.PrivateIpAddress),
+		SubnetId:      aws.ToString(networkInterface.SubnetId),
+		Az:            aws.ToString(networkInterface.AvailabilityZone),
+		Description:   aws.ToString(networkInterface.Description),
+		Tags:          map[string]string{},
+		Groups:        []string{},
+		IsPublic:      isPublic(networkInterface),
+	}
+	for _, tag := range networkInterface.TagSet {
+		env.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for _, group := range networkInterface.Groups {
+		env.Groups = append(env.Groups, aws.ToString(group.GroupName))
+	}
+	if networkInterface.Attachment != nil && networkInterface.Attachment.AttachTime != nil {
+		env.AgeDays = int(now.Sub(*networkInterface.Attachment.AttachTime).Hours() / 24)
+	}
+	return env
+}
+
+// match reports whether a network interface satisfies the expression.
+//
+// networkInterface: The network interface.
+// bool: Whether the expression holds.
+// error: If the evaluation fails.
+func (w *whereFilter) match(networkInterface types.NetworkInterface) (bool, error) {
+	result, err := expr.Run(w.program, newWhereEnv(networkInterface, w.now))
+	if err != nil {
+		return false, fmt.Errorf("evaluating -where for %s: %w", aws.ToString(networkInterface.NetworkInterfaceId), err)
+	}
+	return result.(bool), nil
+}
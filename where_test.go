@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestWhere(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	web := testGroup("sg-0a1b2c3d4e5f60718", "web")
+	db := testGroup("sg-0e1b2c3d4e5f60718", "db")
+
+	attached := testInterface("eni-1", "i-1", web, db)
+	attached.Attachment.AttachTime = aws.Time(now.Add(-45 * 24 * time.Hour))
+	attached.TagSet = []types.Tag{{Key: aws.String("owner"), Value: aws.String("team-a")}, {Key: aws.String("env"), Value: aws.String("prod")}}
+	attached.Association = &types.NetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.10")}
+	attached.Description = aws.String("Primary network interface")
+
+	available := testInterface("eni-2", "", web)
+	available.PrivateIpAddress = aws.String("10.0.1.7")
+	available.SubnetId = aws.String("subnet-2")
+	available.AvailabilityZone = aws.String("eu-west-1b")
+	available.InterfaceType = types.NetworkInterfaceTypeLambda
+
+	tests := []struct {
+		expression string
+		want       []bool
+	}{
+		{`status == "available"`, []bool{false, true}},
+		{`status == "available" && age_days > 30 && !("owner" in tags)`, []bool{false, false}},
+		{`age_days > 30`, []bool{true, false}},
+		{`age_days == 0`, []bool{false, true}},
+		{`!("owner" in tags)`, []bool{false, true}},
+		{`tags.env == "prod"`, []bool{true, false}},
+		{`tags["owner"] startsWith "team-"`, []bool{true, false}},
+		{`"db" in groups`, []bool{true, false}},
+		{`len(groups) > 1`, []bool{true, false}},
+		{`is_public`, []bool{true, false}},
+		{`interface_type == "lambda"`, []bool{false, true}},
+		{`private_ip startsWith "10.0.1."`, []bool{false, true}},
+		{`subnet_id == "subnet-2" || az endsWith "a"`, []bool{true, true}},
+		{`description contains "Primary"`, []bool{true, false}},
+		{`description matches "^$"`, []bool{false, true}},
+		{`any(groups, # == "web")`, []bool{true, true}},
+		{`true`, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			filter, err := compileWhere(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter.now = now
+			for i, networkInterface := range []types.NetworkInterface{attached, available} {
+				got, err := filter.match(networkInterface)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("%s: got %v, want %v", aws.ToString(networkInterface.NetworkInterfaceId), got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWhereCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		position   string
+	}{
+		{`status ==`, "(1:9)"},
+		{`status == "available" &&`, "(1:24)"},
+		{`age_days`, "(1:1)"},
+		{`owner == "a"`, "(1:1)"},
+		{`age_days > "30"`, "(1:10)"},
+		{`status + 1`, "(1:8)"},
+	}
+	for _, tt := range tests {
+		_, err := compileWhere(tt.expression)
+		if err == nil {
+			t.Errorf("%s: compiled", tt.expression)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.position) {
+			t.Errorf("%s: error %q does not give the position %s", tt.expression, err, tt.position)
+		}
+	}
+}